@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"open-todo-go/internal/mailer"
+	"open-todo-go/internal/store"
+	"time"
+)
+
+const (
+	// verifyEmailTTL is how long an email verification link works for.
+	verifyEmailTTL = 48 * time.Hour
+	// resetPasswordTTL is how long a password reset link works for.
+	resetPasswordTTL = time.Hour
+	// mailTimeout bounds how long sending one email may take.
+	mailTimeout = time.Minute
+)
+
+var errInvalidUserToken = errors.New("invalid or expired token")
+
+type VerifyEmailPayload struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type EmailPayload struct {
+	Email string `json:"email" validate:"required,email,max=200"`
+}
+
+type ResetPasswordPayload struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// sendMail sends msg in the background, so a slow mail server does not hold
+// up the request. Failures are only logged.
+func (app *application) sendMail(msg mailer.Message) {
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Errorw("mailer panicked", "to", msg.To, "error", err)
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+
+		if err := app.mailer.Send(ctx, msg); err != nil {
+			app.logger.Errorw("failed to send email", "to", msg.To, "subject", msg.Subject, "error", err)
+		}
+	}()
+}
+
+// appLink returns the link to path in the web app with token in its query.
+func (app *application) appLink(path, token string) string {
+	return app.config.appURL + path + "?" + url.Values{"token": {token}}.Encode()
+}
+
+// sendVerificationEmail mails the user a new link to verify their email
+// address with, which replaces any earlier one.
+func (app *application) sendVerificationEmail(ctx context.Context, user *store.User) error {
+	token := &store.UserToken{
+		UserID:    user.ID,
+		Purpose:   store.PurposeVerifyEmail,
+		ExpiresAt: time.Now().Add(verifyEmailTTL),
+	}
+	if err := app.store.UserTokens.Create(ctx, token); err != nil {
+		return err
+	}
+
+	app.sendMail(mailer.VerifyEmail(user.Email, user.Username, app.appLink("/verify-email", token.Token)))
+	return nil
+}
+
+// VerifyEmailHandler marks the email address the token was mailed to as
+// verified.
+func (app *application) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var payload VerifyEmailPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	userID, err := app.store.UserTokens.Consume(ctx, store.PurposeVerifyEmail, payload.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.badRequestResponse(w, r, errInvalidUserToken)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.store.Users.SetEmailVerified(ctx, userID); err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to verify email: %w", err))
+		return
+	}
+	app.jsonResponse(w, http.StatusOK, nil)
+}
+
+// ResendVerificationHandler mails a new verification link. It answers the
+// same whether or not the address is registered, so it cannot be used to
+// find out who has an account.
+func (app *application) ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	var payload EmailPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user, err := app.store.Users.GetByEmail(ctx, payload.Email)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+	case err != nil:
+		app.internalServerError(w, r, err)
+		return
+	case user.EmailVerifiedAt == nil:
+		if err := app.sendVerificationEmail(ctx, user); err != nil {
+			app.internalServerError(w, r, fmt.Errorf("failed to send verification email: %w", err))
+			return
+		}
+	}
+	app.jsonResponse(w, http.StatusOK, nil)
+}
+
+// ForgotPasswordHandler mails a password reset link. Like
+// ResendVerificationHandler, it answers the same for unknown addresses.
+func (app *application) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload EmailPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user, err := app.store.Users.GetByEmail(ctx, payload.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.jsonResponse(w, http.StatusOK, nil)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	token := &store.UserToken{
+		UserID:    user.ID,
+		Purpose:   store.PurposeResetPassword,
+		ExpiresAt: time.Now().Add(resetPasswordTTL),
+	}
+	if err := app.store.UserTokens.Create(ctx, token); err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to create reset token: %w", err))
+		return
+	}
+
+	link := app.appLink("/reset-password", token.Token)
+	app.sendMail(mailer.ResetPassword(user.Email, user.Username, link, resetPasswordTTL))
+	app.jsonResponse(w, http.StatusOK, nil)
+}
+
+// ResetPasswordHandler sets a new password with a token from
+// ForgotPasswordHandler, signs the user out everywhere, revokes their
+// personal access tokens and unlocks the account. Following the link proves
+// the user owns the address, so it also counts as verifying it.
+func (app *application) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResetPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.passwordPolicy.Check(payload.Password); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	userID, err := app.store.UserTokens.Consume(ctx, store.PurposeResetPassword, payload.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.badRequestResponse(w, r, errInvalidUserToken)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.setPassword(user, payload.Password); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.Users.UpdatePassword(ctx, user); err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to update password: %w", err))
+		return
+	}
+
+	if err := app.store.Sessions.RevokeAll(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to revoke sessions: %w", err))
+		return
+	}
+
+	if err := app.store.AccessTokens.DeleteAll(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to revoke access tokens: %w", err))
+		return
+	}
+
+	if err := app.store.Users.ResetFailedLogins(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if user.EmailVerifiedAt == nil {
+		if err := app.store.Users.SetEmailVerified(ctx, user.ID); err != nil {
+			app.internalServerError(w, r, fmt.Errorf("failed to verify email: %w", err))
+			return
+		}
+	}
+	app.jsonResponse(w, http.StatusOK, nil)
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"open-todo-go/internal/store"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const adminUsage = "usage: api admin grant <email> | revoke <email>"
+
+// The actions recorded in the audit log.
+const (
+	auditUserSuspend   = "user.suspend"
+	auditUserUnsuspend = "user.unsuspend"
+	auditUserRole      = "user.role"
+	auditUserDelete    = "user.delete"
+)
+
+var errAdminSelf = errors.New("admins cannot suspend, demote or delete themselves")
+
+type SetRolePayload struct {
+	Role store.UserRole `json:"role" validate:"required,oneof=user admin"`
+}
+
+type SetSuspendedPayload struct {
+	Suspended *bool `json:"suspended" validate:"required"`
+	// Reason is kept in the audit log.
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+// requireAdminMiddleware refuses users who are not admins. It goes after
+// AuthTokenMiddleware.
+func (app *application) requireAdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if getUserFromContext(r).Role != store.UserRoleAdmin {
+			app.forbiddenResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// audit records an action of the authenticated admin on the target user.
+// Failing to record it is logged rather than failing a change that has
+// already been made.
+func (app *application) audit(r *http.Request, action string, targetUserID int64, details map[string]any) {
+	actorID := getUserIdFromContext(r)
+	entry := &store.AuditEntry{ActorID: &actorID, Action: action, TargetUserID: &targetUserID}
+	if details != nil {
+		data, err := json.Marshal(details)
+		if err != nil {
+			app.logger.Errorw("failed to encode audit details", "action", action, "error", err)
+		}
+		entry.Details = data
+	}
+	if err := app.store.Audit.Create(r.Context(), entry); err != nil {
+		app.logger.Errorw("failed to record audit entry", "action", action, "actorID", actorID,
+			"targetUserID", targetUserID, "error", err)
+	}
+}
+
+// getTargetUser returns the user named in the route, or writes an error.
+// Anonymized users are not found.
+func (app *application) getTargetUser(w http.ResponseWriter, r *http.Request) *store.User {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid user ID: %w", err))
+		return nil
+	}
+
+	user, err := app.store.Users.GetByID(r.Context(), userID)
+	if err == nil && user.DeletedAt != nil {
+		err = store.ErrNotFound
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil
+	}
+	return user
+}
+
+// AdminGetUsers lists users, filtered by the q, role and suspended query
+// parameters and paged with limit and cursor.
+func (app *application) AdminGetUsers(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseUserFilter(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(filter); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	page, err := app.store.Users.List(r.Context(), filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to list users: %w", err))
+		}
+		return
+	}
+	respondJSON(w, page)
+}
+
+// AdminGetUser returns a user and their usage of the service.
+func (app *application) AdminGetUser(w http.ResponseWriter, r *http.Request) {
+	user := app.getTargetUser(w, r)
+	if user == nil {
+		return
+	}
+
+	usage, err := app.store.Users.Usage(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to count usage: %w", err))
+		return
+	}
+
+	respondJSON(w, map[string]any{
+		"user":  user,
+		"usage": usage,
+	})
+}
+
+// AdminSetSuspended suspends a user or lifts their suspension. Suspending
+// signs the user out everywhere; their access tokens stop working until the
+// suspension is lifted.
+func (app *application) AdminSetSuspended(w http.ResponseWriter, r *http.Request) {
+	var payload SetSuspendedPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.getTargetUser(w, r)
+	if user == nil {
+		return
+	}
+	if user.ID == getUserIdFromContext(r) {
+		app.badRequestResponse(w, r, errAdminSelf)
+		return
+	}
+
+	ctx := r.Context()
+	if err := app.store.Users.SetSuspended(ctx, user.ID, *payload.Suspended); err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to suspend user: %w", err))
+		return
+	}
+
+	action := auditUserUnsuspend
+	if *payload.Suspended {
+		action = auditUserSuspend
+	}
+
+	var details map[string]any
+	if payload.Reason != "" {
+		details = map[string]any{"reason": payload.Reason}
+	}
+	app.audit(r, action, user.ID, details)
+
+	user, err := app.store.Users.GetByID(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.jsonResponse(w, http.StatusOK, user)
+}
+
+// AdminSetRole makes a user an admin or takes it away.
+func (app *application) AdminSetRole(w http.ResponseWriter, r *http.Request) {
+	var payload SetRolePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.getTargetUser(w, r)
+	if user == nil {
+		return
+	}
+	if user.ID == getUserIdFromContext(r) && payload.Role != store.UserRoleAdmin {
+		app.badRequestResponse(w, r, errAdminSelf)
+		return
+	}
+
+	if err := app.store.Users.SetRole(r.Context(), user.ID, payload.Role); err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to set role: %w", err))
+		return
+	}
+
+	app.audit(r, auditUserRole, user.ID, map[string]any{"from": user.Role, "to": payload.Role})
+
+	user.Role = payload.Role
+	app.jsonResponse(w, http.StatusOK, user)
+}
+
+// AdminDeleteUser deletes a user's account. With ?todos=anonymize their todos
+// and projects are kept, as when users delete their own account.
+func (app *application) AdminDeleteUser(w http.ResponseWriter, r *http.Request) {
+	todos := r.URL.Query().Get("todos")
+	if err := Validate.Var(todos, "omitempty,oneof=delete anonymize"); err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("todos must be delete or anonymize"))
+		return
+	}
+
+	user := app.getTargetUser(w, r)
+	if user == nil {
+		return
+	}
+	if user.ID == getUserIdFromContext(r) {
+		app.badRequestResponse(w, r, errAdminSelf)
+		return
+	}
+
+	ctx := r.Context()
+	var err error
+	if todos == "anonymize" {
+		err = app.store.Users.Anonymize(ctx, user.ID)
+	} else {
+		err = app.store.Users.Delete(ctx, user.ID)
+	}
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to delete user: %w", err))
+		return
+	}
+
+	app.audit(r, auditUserDelete, user.ID, map[string]any{
+		"username": user.Username,
+		"email":    user.Email,
+		"todos":    todos,
+	})
+	app.jsonResponse(w, http.StatusOK, nil)
+}
+
+// AdminGetAudit lists the audit log, newest first. It is filtered by the
+// actorID and targetUserID query parameters and paged with limit and
+// before, the ID of the last entry of the previous page.
+func (app *application) AdminGetAudit(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	filter := store.AuditFilter{Limit: 50}
+
+	parseID := func(name string) (*int64, error) {
+		v := qs.Get(name)
+		if v == "" {
+			return nil, nil
+		}
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 1 {
+			return nil, fmt.Errorf("invalid %s %q", name, v)
+		}
+		return &id, nil
+	}
+
+	var err error
+	if filter.ActorID, err = parseID("actorID"); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if filter.TargetUserID, err = parseID("targetUserID"); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	before, err := parseID("before")
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if before != nil {
+		filter.Before = *before
+	}
+	if v := qs.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 1 || filter.Limit > 100 {
+			app.badRequestResponse(w, r, fmt.Errorf("limit must be between 1 and 100"))
+			return
+		}
+	}
+
+	entries, err := app.store.Audit.List(r.Context(), filter)
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to list audit log: %w", err))
+		return
+	}
+	respondJSON(w, entries)
+}
+
+// runAdmin implements the `admin` subcommand of the API binary, which makes
+// the first admins. Changes are audited without an actor.
+func runAdmin(ctx context.Context, storage store.Storage, args []string) error {
+	if len(args) != 2 || (args[0] != "grant" && args[0] != "revoke") {
+		return errors.New(adminUsage)
+	}
+
+	user, err := storage.Users.GetByEmail(ctx, args[1])
+	if err == nil && user.DeletedAt != nil {
+		err = store.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[1], err)
+	}
+
+	role := store.UserRoleAdmin
+	if args[0] == "revoke" {
+		role = store.UserRoleUser
+	}
+	if err := storage.Users.SetRole(ctx, user.ID, role); err != nil {
+		return err
+	}
+
+	details, err := json.Marshal(map[string]any{"from": user.Role, "to": role})
+	if err != nil {
+		return err
+	}
+	if err := storage.Audit.Create(ctx, &store.AuditEntry{
+		Action:       auditUserRole,
+		TargetUserID: &user.ID,
+		Details:      details,
+	}); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s is now %s\n", user.Email, role)
+	return nil
+}
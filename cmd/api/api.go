@@ -4,7 +4,13 @@ import (
 	"expvar"
 	"log"
 	"net/http"
+	"net/netip"
 	"open-todo-go/internal/auth"
+	"open-todo-go/internal/mailer"
+	"open-todo-go/internal/oidc"
+	"open-todo-go/internal/password"
+	"open-todo-go/internal/privacy"
+	"open-todo-go/internal/ratelimiter"
 	"open-todo-go/internal/store"
 	"time"
 
@@ -18,31 +24,138 @@ type application struct {
 	store         store.Storage
 	logger        *zap.SugaredLogger
 	authenticator auth.Authenticator
+	mailer        mailer.Mailer
+	rateLimiter   rateLimiters
+	// oidcProviders are the identity providers users can log in with, by
+	// name.
+	oidcProviders map[string]*oidc.Provider
+	// exportLinks signs the download links of data exports.
+	exportLinks *privacy.Links
+	// passwords hashes new passwords and checks existing ones;
+	// passwordPolicy is what new passwords must be like.
+	passwords      *password.Hashing
+	passwordPolicy *password.Policy
+	// trustedProxies are the proxies whose X-Forwarded-For and X-Real-IP
+	// headers say where a request came from.
+	trustedProxies []netip.Prefix
+}
+
+// rateLimiters are the limiters of each route group. A nil limiter lets
+// everything through.
+type rateLimiters struct {
+	// global limits every request per client IP.
+	global ratelimiter.Limiter
+	// auth limits the account endpoints that work without a token, such as
+	// logging in and resetting a password, per client IP.
+	auth ratelimiter.Limiter
+	// api limits authenticated requests per user.
+	api ratelimiter.Limiter
 }
 
 type config struct {
-	addr string
-	db   dbConfig
-	auth authConfig
+	addr          string
+	db            dbConfig
+	auth          authConfig
+	migrationsDir string
+	reminders     remindersConfig
+	mail          mailConfig
+	rateLimiter   rateLimiterConfig
+	oidc          []oidc.Config
+	privacy       privacyConfig
+	trash         trashConfig
+	password      passwordConfig
+	// appURL is where the web app is served; links in emails point there.
+	appURL string
+	// apiURL is where this API is served, for links that point at it
+	// directly, such as export downloads.
+	apiURL string
+}
+
+type trashConfig struct {
+	pollInterval time.Duration
+	// retention is how long deleted todos stay in the trash; 0 keeps them
+	// until they are purged by hand.
+	retention time.Duration
+}
+
+type privacyConfig struct {
+	pollInterval time.Duration
+	// exportTTL is how long an export can be downloaded.
+	exportTTL time.Duration
+	// linkSecret signs export download links. Without it they are signed
+	// with a key derived from the access token secret.
+	linkSecret string
+}
+
+type passwordConfig struct {
+	// hasher is "argon2id" or "bcrypt"; hashes of the other scheme are
+	// still accepted and replaced on the next login.
+	hasher     string
+	bcryptCost int
+	argon2     password.Argon2id
+	minLength  int
+	maxLength  int
+	// breachedList is a file of passwords that may not be used, one per
+	// line, in plain text or as SHA-1 hashes.
+	breachedList string
+}
+
+type mailConfig struct {
+	// driver is "log", "file" or "smtp".
+	driver string
+	from   string
+	dir    string
+	smtp   smtpConfig
+}
+
+type smtpConfig struct {
+	addr     string
+	username string
+	password string
+}
+
+type rateLimiterConfig struct {
+	global ratelimiter.Config
+	auth   ratelimiter.Config
+	api    ratelimiter.Config
+}
+
+type remindersConfig struct {
+	pollInterval time.Duration
 }
 
 type authConfig struct {
-	basic basicConfig
 	token tokenConfig
+	// requireVerifiedEmail refuses logins until the user has verified
+	// their email address.
+	requireVerifiedEmail bool
+	lockout              lockoutConfig
 }
 
-type tokenConfig struct {
-	secret string
-	exp    time.Duration
-	iss    string
+// lockoutConfig is how accounts are locked after failed logins: threshold
+// failures in a row lock the account for duration, and every further
+// failure doubles that, up to maxDuration.
+type lockoutConfig struct {
+	threshold   int
+	duration    time.Duration
+	maxDuration time.Duration
 }
 
-type basicConfig struct {
-	user string
-	pass string
+type tokenConfig struct {
+	secret string
+	// exp is how long access tokens live; refreshExp how long a refresh
+	// token can be exchanged for a new pair.
+	exp        time.Duration
+	refreshExp time.Duration
+	iss        string
+	// signingKey is the PEM file access tokens are signed with; verifyKeys
+	// are files of retired keys whose tokens are still accepted.
+	signingKey string
+	verifyKeys []string
 }
 
 type dbConfig struct {
+	driver       string
 	addr         string
 	maxOpenConns int
 	maxIdleConns int
@@ -53,9 +166,10 @@ func (app *application) mount() http.Handler {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(app.RealIPMiddleware)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
+	r.Use(app.RateLimiterMiddleware)
 	// r.Use(cors.Handler(cors.Options{
 	// 	AllowedOrigins:   []string{env.GetString("CORS_ALLOWED_ORIGIN", "http://localhost:5174")},
 	// 	AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
@@ -70,21 +184,114 @@ func (app *application) mount() http.Handler {
 	// processing should be stopped.
 	r.Use(middleware.Timeout(60 * time.Second))
 
+	r.Get("/.well-known/jwks.json", app.jwksHandler)
+
+	authRateLimit := app.rateLimit(app.rateLimiter.auth, clientIP)
+	apiRateLimit := app.rateLimit(app.rateLimiter.api, userRateLimitKey)
+
 	r.Route("/api/v1", func(r chi.Router) {
 		r.Get("/health", app.healthCheckHandler)
-		r.With(app.BasicAuthMiddleware()).Get("/debug/vars", expvar.Handler().ServeHTTP)
+		r.With(authRateLimit).Get("/exports/{exportID}/download", app.DownloadExport)
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware, app.requireSessionMiddleware, app.requireAdminMiddleware, apiRateLimit)
+			r.Get("/debug/vars", expvar.Handler().ServeHTTP)
+			r.Get("/users", app.AdminGetUsers)
+			r.Get("/users/{userID}", app.AdminGetUser)
+			r.Put("/users/{userID}/suspension", app.AdminSetSuspended)
+			r.Put("/users/{userID}/role", app.AdminSetRole)
+			r.Delete("/users/{userID}", app.AdminDeleteUser)
+			r.Get("/audit", app.AdminGetAudit)
+		})
 		r.Route("/todos", func(r chi.Router) {
-			r.Use(app.AuthTokenMiddleware)
-			r.Get("/", app.GetAllTodos)
-			r.Get("/{todoID}", app.GetTodoById)
+			r.Use(app.AuthTokenMiddleware, apiRateLimit)
+			r.Group(func(r chi.Router) {
+				r.Use(app.accountScopeMiddleware)
+				r.Get("/", app.GetAllTodos)
+				r.Get("/search", app.SearchTodos)
+				r.Get("/overdue", app.GetOverdueTodos)
+				r.Get("/due-today", app.GetTodosDueToday)
+				r.Get("/upcoming", app.GetUpcomingTodos)
+				r.Get("/trash", app.GetTrash)
+				r.Delete("/trash", app.EmptyTrash)
+				r.Post("/trash/{todoID}/restore", app.RestoreTodo)
+				r.Delete("/trash/{todoID}", app.PurgeTodo)
+			})
+			r.With(app.todosContextMiddleware).Get("/{todoID}", app.GetTodoById)
 			// r.Get("/todos/tag/{tag}", todoHandler.GetTodosByTag)
 			r.Post("/create", app.CreateTodo)
-			r.Put("/update/{todoID}", app.UpdateTodo)
-			r.Delete("/delete/{todoID}", app.DeleteTodo)
+			r.With(app.todosContextMiddleware).Put("/update/{todoID}", app.UpdateTodo)
+			r.With(app.todosContextMiddleware).Delete("/delete/{todoID}", app.DeleteTodo)
+			r.With(app.todosContextMiddleware).Get("/{todoID}/occurrences", app.GetOccurrences)
+			r.With(app.todosContextMiddleware).Get("/{todoID}/subtree", app.GetSubtree)
+			r.With(app.todosContextMiddleware).Put("/{todoID}/move", app.MoveTodo)
+			r.With(app.todosContextMiddleware).Get("/{todoID}/history", app.GetTodoHistory)
+			r.With(app.todosContextMiddleware).Post("/{todoID}/history/{version}/revert", app.RevertTodo)
+			r.Route("/{todoID}/reminders", func(r chi.Router) {
+				r.Use(app.todosContextMiddleware)
+				r.Get("/", app.GetReminders)
+				r.Post("/", app.CreateReminder)
+				r.Delete("/{reminderID}", app.DeleteReminder)
+			})
+		})
+		r.Route("/projects", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware, apiRateLimit)
+			r.Group(func(r chi.Router) {
+				r.Use(app.accountScopeMiddleware)
+				r.Get("/", app.GetProjects)
+				r.Post("/", app.CreateProject)
+				r.Post("/invitations/accept", app.AcceptInvitation)
+			})
+			r.Route("/{projectID}", func(r chi.Router) {
+				r.Use(app.projectsContextMiddleware)
+				r.Get("/", app.GetProject)
+				r.Put("/", app.UpdateProject)
+				r.Delete("/", app.DeleteProject)
+				r.Get("/todos", app.GetProjectTodos)
+				r.Get("/members", app.GetMembers)
+				r.Put("/members/{userID}", app.UpdateMember)
+				r.Delete("/members/{userID}", app.RemoveMember)
+				r.Get("/invitations", app.GetInvitations)
+				r.Post("/invitations", app.CreateInvitation)
+				r.Delete("/invitations/{invitationID}", app.DeleteInvitation)
+			})
 		})
 		r.Route("/user", func(r chi.Router) {
-			r.Post("/create", app.RegisterUserHandler)
-			r.Post("/login", app.LoginHandler)
+			r.Group(func(r chi.Router) {
+				r.Use(authRateLimit)
+				r.Post("/create", app.RegisterUserHandler)
+				r.Post("/login", app.LoginHandler)
+				r.Post("/login/mfa", app.LoginMFAHandler)
+				r.Post("/refresh", app.RefreshTokenHandler)
+				r.Post("/verify-email", app.VerifyEmailHandler)
+				r.Post("/verify-email/resend", app.ResendVerificationHandler)
+				r.Post("/password/forgot", app.ForgotPasswordHandler)
+				r.Post("/password/reset", app.ResetPasswordHandler)
+				r.Get("/oidc/providers", app.GetOIDCProviders)
+				r.Post("/oidc/{provider}/start", app.StartOIDCLogin)
+				r.Post("/oidc/{provider}/callback", app.OIDCCallbackHandler)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware, app.requireSessionMiddleware, apiRateLimit)
+				r.Post("/logout", app.LogoutHandler)
+				r.Get("/me", app.GetCurrentUser)
+				r.Patch("/me", app.UpdateCurrentUser)
+				r.Delete("/me", app.DeleteCurrentUser)
+				r.Post("/password", app.ChangePassword)
+				r.Get("/me/data-requests", app.GetDataRequests)
+				r.Post("/me/export", app.RequestExport)
+				r.Post("/me/erasure", app.RequestErasure)
+				r.Get("/tokens", app.GetAccessTokens)
+				r.Post("/tokens", app.CreateAccessToken)
+				r.Delete("/tokens/{tokenID}", app.DeleteAccessToken)
+				r.Get("/mfa", app.GetMFAStatus)
+				r.Post("/mfa/totp", app.EnrollTOTP)
+				r.Post("/mfa/totp/confirm", app.ConfirmTOTP)
+				r.Post("/mfa/totp/disable", app.DisableTOTP)
+				r.Post("/mfa/recovery-codes", app.RegenerateRecoveryCodes)
+				r.Get("/identities", app.GetIdentities)
+				r.Delete("/identities/{identityID}", app.DeleteIdentity)
+				r.Post("/oidc/{provider}/link", app.StartOIDCLink)
+			})
 		})
 	})
 	return r
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"open-todo-go/internal/auth"
+	"open-todo-go/internal/mailer"
+	"open-todo-go/internal/password"
+	"open-todo-go/internal/privacy"
+	"open-todo-go/internal/store"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestApplication returns an application on the memory store, with
+// rate limits off and logins allowed before the email is verified.
+func newTestApplication(t *testing.T) *application {
+	t.Helper()
+
+	key, err := auth.NewHMACKey("test-secret-test-secret-test-secret")
+	if err != nil {
+		t.Fatalf("NewHMACKey: %v", err)
+	}
+	authenticator, err := auth.NewJWTAuthenticator(key, nil, "open-todo-go", "open-todo-go")
+	if err != nil {
+		t.Fatalf("NewJWTAuthenticator: %v", err)
+	}
+
+	logger := zap.NewNop().Sugar()
+	return &application{
+		config: config{
+			auth: authConfig{
+				token: tokenConfig{
+					exp:        15 * time.Minute,
+					refreshExp: time.Hour,
+					iss:        "open-todo-go",
+				},
+				lockout: lockoutConfig{threshold: 5, duration: time.Minute, maxDuration: time.Hour},
+			},
+			appURL: "http://app.test",
+			apiURL: "http://api.test",
+		},
+		store:          store.NewMemoryStorage(),
+		logger:         logger,
+		authenticator:  authenticator,
+		mailer:         &mailer.LogMailer{Logger: logger},
+		exportLinks:    &privacy.Links{BaseURL: "http://api.test", Key: []byte("test-link-key")},
+		passwords:      password.NewHashing(password.Bcrypt{Cost: bcrypt.MinCost}),
+		passwordPolicy: &password.Policy{MinLength: 8, MaxLength: 72},
+	}
+}
+
+// testClient sends requests to a mounted application, authenticated as the
+// user it logged in as, if any.
+type testClient struct {
+	t       *testing.T
+	handler http.Handler
+	token   string
+	userID  int64
+}
+
+func newTestClient(t *testing.T, app *application) *testClient {
+	return &testClient{t: t, handler: app.mount()}
+}
+
+// do sends body, if not nil, as JSON and returns the response.
+func (c *testClient) do(method, path string, body any) *httptest.ResponseRecorder {
+	c.t.Helper()
+
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			c.t.Fatalf("encode %s %s: %v", method, path, err)
+		}
+	}
+	r := httptest.NewRequest(method, "/api/v1"+path, &buf)
+	r.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		r.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	w := httptest.NewRecorder()
+	c.handler.ServeHTTP(w, r)
+	return w
+}
+
+// expect sends a request, fails unless it answers with status, and decodes
+// the response into dst, if not nil.
+func (c *testClient) expect(status int, method, path string, body, dst any) {
+	c.t.Helper()
+
+	w := c.do(method, path, body)
+	if w.Code != status {
+		c.t.Fatalf("%s %s = %d %s, want %d", method, path, w.Code, w.Body, status)
+	}
+	if dst != nil {
+		decodeResponse(c.t, w, dst)
+	}
+}
+
+// decodeResponse decodes the body of w into dst, unwrapping the data
+// envelope of jsonResponse.
+func decodeResponse(t *testing.T, w *httptest.ResponseRecorder, dst any) {
+	t.Helper()
+
+	body := w.Body.Bytes()
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err == nil && len(envelope) == 1 && envelope["data"] != nil {
+		body = envelope["data"]
+	}
+	if err := json.Unmarshal(body, dst); err != nil {
+		t.Fatalf("decode %s: %v", w.Body, err)
+	}
+}
+
+// loginTestUser registers name with a verified email and returns a client
+// logged in as them.
+func loginTestUser(t *testing.T, app *application, name string) *testClient {
+	t.Helper()
+
+	c := newTestClient(t, app)
+	creds := map[string]string{
+		"username": name,
+		"email":    name + "@example.com",
+		"password": "correct horse battery",
+	}
+	c.expect(http.StatusOK, http.MethodPost, "/user/create", creds, nil)
+	user, err := app.store.Users.GetByEmail(context.Background(), creds["email"])
+	if err != nil {
+		t.Fatalf("GetByEmail: %v", err)
+	}
+	if err := app.store.Users.SetEmailVerified(context.Background(), user.ID); err != nil {
+		t.Fatalf("SetEmailVerified: %v", err)
+	}
+
+	var tokens struct {
+		Token string `json:"token"`
+	}
+	delete(creds, "username")
+	c.expect(http.StatusOK, http.MethodPost, "/user/login", creds, &tokens)
+	if tokens.Token == "" {
+		t.Fatal("login returned no token")
+	}
+	c.token = tokens.Token
+	c.userID = user.ID
+	return c
+}
+
+// shareProject creates a project owned by owner and shares it with member.
+func shareProject(t *testing.T, owner, member *testClient, memberEmail string, role store.Role) *store.Project {
+	t.Helper()
+
+	var project store.Project
+	owner.expect(http.StatusCreated, http.MethodPost, "/projects/", map[string]any{"name": "Shared"}, &project)
+
+	var invitation store.Invitation
+	owner.expect(http.StatusCreated, http.MethodPost, fmt.Sprintf("/projects/%d/invitations", project.ID),
+		map[string]any{"email": memberEmail, "role": role}, &invitation)
+	member.expect(http.StatusCreated, http.MethodPost, "/projects/invitations/accept",
+		map[string]any{"token": invitation.Token}, nil)
+	return &project
+}
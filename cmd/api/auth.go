@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"open-todo-go/internal/store"
@@ -12,8 +14,8 @@ import (
 
 type RegisterUserPayload struct {
 	Username string `json:"username" validate:"required,max=20"`
-	Email    string `json:"email" validate:"required,max=200"`
-	Password string `json:"password" validate:"required,min=6,max=30"`
+	Email    string `json:"email" validate:"required,email,max=200"`
+	Password string `json:"password" validate:"required"`
 }
 
 type LoggedInUser struct {
@@ -26,12 +28,14 @@ func (app *application) RegisterUserHandler(w http.ResponseWriter, r *http.Reque
 
 	if err := readJSON(w, r, &payload); err != nil {
 		app.badRequestResponse(w, r, err)
-		fmt.Println("readJSON", err)
 		return
 	}
 	if err := Validate.Struct(payload); err != nil {
 		app.badRequestResponse(w, r, err)
-		fmt.Println("validation", err)
+		return
+	}
+	if err := app.passwordPolicy.Check(payload.Password); err != nil {
+		app.badRequestResponse(w, r, err)
 		return
 	}
 
@@ -39,24 +43,15 @@ func (app *application) RegisterUserHandler(w http.ResponseWriter, r *http.Reque
 		Username: payload.Username,
 		Email:    payload.Email,
 	}
-	fmt.Println("user", user)
 
-	if err := user.Password.SetPassword(payload.Password); err != nil {
+	if err := app.setPassword(user, payload.Password); err != nil {
 		app.internalServerError(w, r, err)
-		fmt.Println("password", err)
 		return
 	}
-	fmt.Println("after_user", user)
 
 	ctx := r.Context()
 
-	// plainToken := uuid.New().String()
-
-	// hash := sha256.Sum256([]byte(plainToken))
-	// hashToken := hex.EncodeToString(hash[:])
-
 	err := app.store.Users.Create(ctx, user)
-	fmt.Println("create()", err)
 	if err != nil {
 		switch err {
 		case store.ErrDuplicateEmail:
@@ -69,12 +64,19 @@ func (app *application) RegisterUserHandler(w http.ResponseWriter, r *http.Reque
 
 		return
 	}
+
+	if err := app.sendVerificationEmail(ctx, user); err != nil {
+		app.logger.Errorw("failed to send verification email", "userID", user.ID, "error", err)
+	}
+
 	app.jsonResponse(w, http.StatusOK, nil)
 }
 
+var errAccountLocked = errors.New("account is locked after too many failed logins")
+
 type CreateUserTokenPayload struct {
 	Email    string `json:"email" validate:"required,email,max=255"`
-	Password string `json:"password" validate:"required,min=3,max=72"`
+	Password string `json:"password" validate:"required"`
 }
 
 func (app *application) LoginHandler(w http.ResponseWriter, r *http.Request) {
@@ -90,45 +92,232 @@ func (app *application) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user by email
+	// Get user by email. Unknown emails, locked accounts and wrong passwords
+	// get the same answer after the same work, so logging in does not tell
+	// which emails are registered.
 	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
 	if err != nil {
 		switch err {
 		case store.ErrNotFound:
+			app.passwords.VerifyDummy(payload.Password)
 			app.unauthorizedErrorResponse(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
 		}
 		return
 	}
-	var userID int64
-	// Verify password using the hash stored in user.Password.hash
-	if !app.authenticator.VerifyPassword(payload.Password, string(user.Password.Hash)) {
+
+	ok, rehash := app.passwords.Verify(payload.Password, string(user.Password.Hash))
+	// The password of a locked account is checked but, right or wrong, not
+	// accepted, so the lock stops guessing without being reported.
+	if user.Locked(time.Now()) {
+		app.unauthorizedErrorResponse(w, r, errAccountLocked)
+		return
+	}
+	if !ok {
+		if err := app.recordFailedLogin(r.Context(), user.ID); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
 		app.unauthorizedErrorResponse(w, r, fmt.Errorf("invalid email or password"))
 		return
+	}
+	if rehash {
+		app.rehashPassword(r.Context(), user, payload.Password)
+	}
+
+	if user.SuspendedAt != nil {
+		app.accountSuspendedResponse(w, r)
+		return
+	}
+
+	if app.config.auth.requireVerifiedEmail && user.EmailVerifiedAt == nil {
+		app.unverifiedEmailResponse(w, r)
+		return
+	}
+
+	mfa, err := app.mfaEnabled(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if mfa {
+		app.mfaChallengeResponse(w, r, user)
+		return
+	}
+
+	app.startSession(w, r, user)
+}
+
+// startSession logs the user in: it clears their failed logins, creates a
+// session and sends its first tokens.
+func (app *application) startSession(w http.ResponseWriter, r *http.Request, user *store.User) {
+	if user.FailedLogins > 0 || user.LockedUntil != nil {
+		if err := app.store.Users.ResetFailedLogins(r.Context(), user.ID); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	session := &store.Session{
+		UserID:           user.ID,
+		RefreshExpiresAt: time.Now().Add(app.config.auth.token.refreshExp),
+	}
+	if err := app.store.Sessions.Create(r.Context(), session); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.tokensResponse(w, r, session)
+}
+
+// recordFailedLogin counts a wrong password or second factor and locks the
+// account once there have been too many in a row.
+func (app *application) recordFailedLogin(ctx context.Context, userID int64) error {
+	failures, err := app.store.Users.RecordFailedLogin(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	lockFor := app.config.auth.lockout.lockDuration(failures)
+	if lockFor == 0 {
+		return nil
+	}
+	app.logger.Warnw("account locked", "userID", userID, "failures", failures, "duration", lockFor)
+	return app.store.Users.LockLogin(ctx, userID, time.Now().Add(lockFor))
+}
+
+// lockDuration is how long to lock an account for after failures failed
+// logins in a row, or 0 if it should not be locked.
+func (c lockoutConfig) lockDuration(failures int) time.Duration {
+	if c.threshold <= 0 || failures < c.threshold {
+		return 0
+	}
+
+	d := c.duration
+	for i := c.threshold; i < failures && d < c.maxDuration; i++ {
+		d *= 2
+	}
+	return min(d, c.maxDuration)
+}
+
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+// RefreshTokenHandler exchanges a refresh token for a new access token and
+// refresh token. Each refresh token works once; presenting one again ends
+// the session it belongs to.
+func (app *application) RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	expiresAt := time.Now().Add(app.config.auth.token.refreshExp)
+	session, err := app.store.Sessions.Refresh(r.Context(), payload.RefreshToken, expiresAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("invalid refresh token"))
+		case errors.Is(err, store.ErrTokenReused):
+			app.logger.Warnw("refresh token reused, session revoked", "error", err)
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// A session does not outlive the account or survive its suspension.
+	if _, err := app.getUser(r.Context(), session.UserID); err != nil {
+		if err := app.store.Sessions.Revoke(r.Context(), session.UserID, session.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+			app.logger.Errorw("failed to revoke session", "sessionID", session.ID, "error", err)
+		}
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("invalid refresh token"))
+		case errors.Is(err, errAccountSuspended):
+			app.accountSuspendedResponse(w, r)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.tokensResponse(w, r, session)
+}
+
+// LogoutHandler ends the session the request was authenticated with, or
+// every session of the user with ?all=true.
+func (app *application) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	all := false
+	if v := r.URL.Query().Get("all"); v != "" {
+		var err error
+		if all, err = strconv.ParseBool(v); err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid all: %w", err))
+			return
+		}
+	}
+
+	ctx := r.Context()
+	userID := getUserIdFromContext(r)
+
+	var err error
+	if all {
+		err = app.store.Sessions.RevokeAll(ctx, userID)
 	} else {
-		userID = user.ID
+		err = app.store.Sessions.Revoke(ctx, userID, getSessionIdFromContext(r))
+	}
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		app.internalServerError(w, r, err)
+		return
 	}
+	app.jsonResponse(w, http.StatusOK, nil)
+}
 
-	// Create JWT claims
+// tokensResponse signs an access token for session and sends it along with
+// the session's new refresh token.
+func (app *application) tokensResponse(w http.ResponseWriter, r *http.Request, session *store.Session) {
+	now := time.Now()
+	expiresAt := now.Add(app.config.auth.token.exp)
 	claims := jwt.MapClaims{
-		"sub": user.ID,
-		"exp": time.Now().Add(app.config.auth.token.exp).Unix(),
-		"iat": time.Now().Unix(),
-		"nbf": time.Now().Unix(),
+		"sub": session.UserID,
+		"sid": session.ID,
+		"exp": expiresAt.Unix(),
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
 		"iss": app.config.auth.token.iss,
 		"aud": app.config.auth.token.iss,
 	}
 
-	// Generate JWT token
 	token, err := app.authenticator.GenerateToken(claims)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	// Send the token in response
-	if err := app.jsonResponse(w, http.StatusOK, map[string]string{"token": token, "userID": strconv.FormatInt(userID, 10)}); err != nil {
+	response := map[string]any{
+		"token":            token,
+		"expiresAt":        expiresAt.UTC(),
+		"refreshToken":     session.RefreshToken,
+		"refreshExpiresAt": session.RefreshExpiresAt,
+		"userID":           strconv.FormatInt(session.UserID, 10),
+	}
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
+
+// jwksHandler publishes the public keys access tokens are signed with so
+// other services can verify them.
+func (app *application) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondJSON(w, app.authenticator.JWKS())
+}
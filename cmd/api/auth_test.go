@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"open-todo-go/internal/password"
+	"open-todo-go/internal/store"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRefreshRefusesSuspendedAndDeletedUsers(t *testing.T) {
+	app := newTestApplication(t)
+	ctx := context.Background()
+	alice := loginTestUser(t, app, "alice")
+	bob := loginTestUser(t, app, "bob")
+
+	// Sessions are created after the suspension and deletion so the
+	// handler, not the store revoking them, is what refuses the refresh.
+	if err := app.store.Users.SetSuspended(ctx, alice.userID, true); err != nil {
+		t.Fatalf("SetSuspended: %v", err)
+	}
+	if err := app.store.Users.Anonymize(ctx, bob.userID); err != nil {
+		t.Fatalf("Anonymize: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		userID int64
+		want   int
+	}{
+		{"suspended", alice.userID, http.StatusForbidden},
+		{"deleted", bob.userID, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &store.Session{UserID: tt.userID, RefreshExpiresAt: time.Now().Add(time.Hour)}
+			if err := app.store.Sessions.Create(ctx, session); err != nil {
+				t.Fatalf("Create session: %v", err)
+			}
+
+			c := newTestClient(t, app)
+			payload := map[string]string{"refreshToken": session.RefreshToken}
+			c.expect(tt.want, http.MethodPost, "/user/refresh", payload, nil)
+
+			// The session was ended rather than left to be retried.
+			if err := app.store.Users.SetSuspended(ctx, alice.userID, false); err != nil {
+				t.Fatalf("SetSuspended: %v", err)
+			}
+			c.expect(http.StatusUnauthorized, http.MethodPost, "/user/refresh", payload, nil)
+			if err := app.store.Users.SetSuspended(ctx, alice.userID, true); err != nil {
+				t.Fatalf("SetSuspended: %v", err)
+			}
+		})
+	}
+}
+
+func TestLockDuration(t *testing.T) {
+	lockout := lockoutConfig{threshold: 3, duration: time.Minute, maxDuration: 10 * time.Minute}
+
+	tests := []struct {
+		name     string
+		cfg      lockoutConfig
+		failures int
+		want     time.Duration
+	}{
+		{"no failures", lockout, 0, 0},
+		{"below threshold", lockout, 2, 0},
+		{"at threshold", lockout, 3, time.Minute},
+		{"doubles", lockout, 4, 2 * time.Minute},
+		{"doubles again", lockout, 6, 8 * time.Minute},
+		{"capped", lockout, 7, 10 * time.Minute},
+		{"many failures", lockout, 1000, 10 * time.Minute},
+		{"duration over cap", lockoutConfig{threshold: 1, duration: time.Hour, maxDuration: time.Minute}, 1, time.Minute},
+		{"disabled", lockoutConfig{duration: time.Minute, maxDuration: time.Hour}, 1000, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.lockDuration(tt.failures); got != tt.want {
+				t.Fatalf("lockDuration(%d) = %v, want %v", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
+
+// countingHasher is bcrypt that counts how many hashes it checks.
+type countingHasher struct {
+	password.Bcrypt
+	verified *atomic.Int64
+}
+
+func (h countingHasher) Verify(password, encoded string) (bool, error) {
+	h.verified.Add(1)
+	return h.Bcrypt.Verify(password, encoded)
+}
+
+func TestLoginDoesNotRevealAccounts(t *testing.T) {
+	app := newTestApplication(t)
+	var verified atomic.Int64
+	app.passwords = password.NewHashing(countingHasher{password.Bcrypt{Cost: bcrypt.MinCost}, &verified})
+	loginTestUser(t, app, "alice")
+
+	c := newTestClient(t, app)
+	login := func(email, password string) (int, string) {
+		t.Helper()
+		verified.Store(0)
+		w := c.do(http.MethodPost, "/user/login", map[string]string{"email": email, "password": password})
+		if verified.Load() != 1 {
+			t.Fatalf("login as %s checked %d password hashes, want 1", email, verified.Load())
+		}
+		if w.Header().Get("Retry-After") != "" {
+			t.Fatalf("login as %s sent Retry-After", email)
+		}
+		return w.Code, w.Body.String()
+	}
+
+	wantCode, wantBody := login("nobody@example.com", "correct horse battery")
+	if wantCode != http.StatusUnauthorized {
+		t.Fatalf("login as an unknown email = %d %s, want 401", wantCode, wantBody)
+	}
+	same := func(name, email, password string) {
+		t.Helper()
+		if code, body := login(email, password); code != wantCode || body != wantBody {
+			t.Fatalf("%s: login = %d %s, want %d %s as for an unknown email", name, code, body, wantCode, wantBody)
+		}
+	}
+
+	same("wrong password", "alice@example.com", "wrong horse battery")
+	for i := 1; i < app.config.auth.lockout.threshold; i++ {
+		same("wrong password", "alice@example.com", "wrong horse battery")
+	}
+	user, err := app.store.Users.GetByEmail(context.Background(), "alice@example.com")
+	if err != nil {
+		t.Fatalf("GetByEmail: %v", err)
+	}
+	if !user.Locked(time.Now()) {
+		t.Fatal("alice is not locked after too many failed logins")
+	}
+
+	same("locked, wrong password", "alice@example.com", "wrong horse battery")
+	same("locked, right password", "alice@example.com", "correct horse battery")
+
+	if err := app.store.Users.ResetFailedLogins(context.Background(), user.ID); err != nil {
+		t.Fatalf("ResetFailedLogins: %v", err)
+	}
+	if code, body := login("alice@example.com", "correct horse battery"); code != http.StatusOK {
+		t.Fatalf("login once unlocked = %d %s, want 200", code, body)
+	}
+}
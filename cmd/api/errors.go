@@ -1,7 +1,12 @@
 package main
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"open-todo-go/internal/policy"
+	"strconv"
+	"time"
 )
 
 func (app *application) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
@@ -16,6 +21,15 @@ func (app *application) forbiddenResponse(w http.ResponseWriter, r *http.Request
 	writeJSONError(w, http.StatusForbidden, "forbidden")
 }
 
+// policyErrorResponse answers a request the policy package denied.
+func (app *application) policyErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, policy.ErrForbidden) {
+		app.forbiddenResponse(w, r)
+		return
+	}
+	app.notFoundResponse(w, r, err)
+}
+
 func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logger.Warnf("bad request", "method", r.Method, "path", r.URL.Path, "error", err.Error())
 
@@ -40,12 +54,25 @@ func (app *application) unauthorizedErrorResponse(w http.ResponseWriter, r *http
 	writeJSONError(w, http.StatusUnauthorized, "unauthorized")
 }
 
-func (app *application) unauthorizedBasicErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Warnf("unauthorized basic error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+func (app *application) unverifiedEmailResponse(w http.ResponseWriter, r *http.Request) {
+	app.logger.Warnw("unverified email", "method", r.Method, "path", r.URL.Path)
+
+	writeJSONError(w, http.StatusForbidden, "email address is not verified")
+}
 
-	w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
+func (app *application) accountLockedResponse(w http.ResponseWriter, r *http.Request, lockedUntil *time.Time) {
+	app.logger.Warnw("login to locked account", "method", r.Method, "path", r.URL.Path)
 
-	writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+	retryAfter := retryAfterSeconds(time.Until(*lockedUntil))
+	w.Header().Set("Retry-After", retryAfter)
+
+	writeJSONError(w, http.StatusTooManyRequests, "too many failed login attempts, retry after: "+retryAfter)
+}
+
+func (app *application) accountSuspendedResponse(w http.ResponseWriter, r *http.Request) {
+	app.logger.Warnw("login to suspended account", "method", r.Method, "path", r.URL.Path)
+
+	writeJSONError(w, http.StatusForbidden, errAccountSuspended.Error())
 }
 
 func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter string) {
@@ -55,3 +82,9 @@ func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http
 
 	writeJSONError(w, http.StatusTooManyRequests, "rate limit exceeded, retry after: "+retryAfter)
 }
+
+// retryAfterSeconds formats d for a Retry-After header, which takes whole
+// seconds.
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(max(int(math.Ceil(d.Seconds())), 1))
+}
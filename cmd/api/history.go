@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"open-todo-go/internal/policy"
+	"open-todo-go/internal/store"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// recordTodoChange adds a version to the history of a todo changed by the
+// request's user. before is nil for a new todo and after nil for a deleted
+// one.
+func (app *application) recordTodoChange(r *http.Request, action store.TodoAction, before, after *store.Todo) {
+	actorID := getUserIdFromContext(r)
+	app.recordTodoVersion(r.Context(), &actorID, action, before, after)
+}
+
+// recordTodoVersion adds a version to the history of a todo, made by
+// actorID or, if nil, by the service itself. Updates that change nothing
+// are skipped. Failing to record it is logged rather than failing a change
+// that has already been made.
+func (app *application) recordTodoVersion(ctx context.Context, actorID *int64, action store.TodoAction, before, after *store.Todo) {
+	changes, err := store.DiffTodos(before, after)
+	if err != nil {
+		app.logger.Errorw("failed to diff todo", "action", action, "error", err)
+		return
+	}
+	if action == store.TodoUpdated && len(changes) == 0 {
+		return
+	}
+
+	v := &store.TodoVersion{ActorID: actorID, Action: action, Changes: changes}
+	if after != nil {
+		v.Todo = *after
+	} else {
+		v.Todo = *before
+	}
+	if err := app.store.TodoHistory.Create(ctx, v); err != nil {
+		app.logger.Errorw("failed to record todo history", "action", action, "todoID", v.Todo.ID, "error", err)
+	}
+}
+
+// GetTodoHistory lists the versions of a todo, newest first, paged with the
+// before and limit query parameters.
+func (app *application) GetTodoHistory(w http.ResponseWriter, r *http.Request) {
+	todo := getTodoFromContext(r)
+	qs := r.URL.Query()
+	filter := store.TodoHistoryFilter{TodoID: &todo.ID, Limit: 50}
+
+	if v := qs.Get("before"); v != "" {
+		before, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || before < 1 {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid before %q", v))
+			return
+		}
+		filter.Before = before
+	}
+	if v := qs.Get("limit"); v != "" {
+		var err error
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 1 || filter.Limit > 100 {
+			app.badRequestResponse(w, r, fmt.Errorf("limit must be between 1 and 100"))
+			return
+		}
+	}
+
+	versions, err := app.store.TodoHistory.List(r.Context(), filter)
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to fetch todo history: %w", err))
+		return
+	}
+	respondJSON(w, versions)
+}
+
+// RevertTodo puts a todo's content back the way it was at a version of its
+// history. Where the todo sits, its project and parent, is left alone; the
+// update and move endpoints change that.
+func (app *application) RevertTodo(w http.ResponseWriter, r *http.Request) {
+	todo := getTodoFromContext(r)
+	if err := app.authorizeTodo(r, policy.Write); err != nil {
+		app.policyErrorResponse(w, r, err)
+		return
+	}
+
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil || version < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid version %q", chi.URLParam(r, "version")))
+		return
+	}
+
+	ctx := r.Context()
+	v, err := app.store.TodoHistory.Get(ctx, todo.ID, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to fetch todo version: %w", err))
+		}
+		return
+	}
+
+	if err := app.store.Todos.UpdateTodo(ctx, todo.UserID, todo.ID, revertUpdates(&v.Todo)); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to revert todo: %w", err))
+		}
+		return
+	}
+
+	reverted, err := app.store.Todos.GetTodoByID(ctx, todo.UserID, todo.ID)
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to fetch reverted todo: %w", err))
+		return
+	}
+	app.recordTodoChange(r, store.TodoReverted, todo, reverted)
+
+	app.jsonResponse(w, http.StatusOK, reverted)
+}
+
+// revertUpdates returns the updates that give a todo the content of old.
+func revertUpdates(old *store.Todo) map[string]interface{} {
+	timeValue := func(t *time.Time) interface{} {
+		if t == nil {
+			return nil
+		}
+		return *t
+	}
+
+	return map[string]interface{}{
+		"title":                  old.Title,
+		"description":            old.Description,
+		"completed":              old.Completed,
+		"priority":               old.Priority,
+		"tags":                   old.Tags,
+		"start_at":               timeValue(old.StartAt),
+		"due_at":                 timeValue(old.DueAt),
+		"timezone":               old.Timezone,
+		"recurrence":             old.Recurrence,
+		"complete_with_children": old.CompleteWithChildren,
+	}
+}
@@ -2,7 +2,13 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"open-todo-go/internal/recurrence"
+	"open-todo-go/internal/store"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -11,6 +17,11 @@ var Validate *validator.Validate
 
 func init() {
 	Validate = validator.New(validator.WithRequiredStructEnabled())
+
+	Validate.RegisterValidation("rrule", func(fl validator.FieldLevel) bool {
+		_, err := recurrence.Parse(fl.Field().String())
+		return err == nil
+	})
 }
 
 func writeJSON(w http.ResponseWriter, status int, data any) error {
@@ -29,6 +40,111 @@ func readJSON(w http.ResponseWriter, r *http.Request, data any) error {
 	return decoder.Decode(data)
 }
 
+// parseTodoFilter returns the first page of the user's todos, newest first,
+// overridden by the query string of r.
+func parseTodoFilter(r *http.Request) (store.TodoFilter, error) {
+	f := store.DefaultTodoFilter()
+	qs := r.URL.Query()
+
+	if v := qs.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return f, fmt.Errorf("invalid limit: %w", err)
+		}
+		f.Limit = limit
+	}
+	if v := qs.Get("cursor"); v != "" {
+		f.Cursor = v
+	}
+	if v := qs.Get("sort"); v != "" {
+		f.Sort = v
+	}
+	if v := qs.Get("order"); v != "" {
+		f.Order = strings.ToLower(v)
+	}
+	if v := qs.Get("completed"); v != "" {
+		completed, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, fmt.Errorf("invalid completed: %w", err)
+		}
+		f.Completed = &completed
+	}
+
+	for name, dst := range map[string]**int16{"minPriority": &f.MinPriority, "maxPriority": &f.MaxPriority} {
+		if v := qs.Get(name); v != "" {
+			p, err := strconv.ParseInt(v, 10, 16)
+			if err != nil {
+				return f, fmt.Errorf("invalid %s: %w", name, err)
+			}
+			priority := int16(p)
+			*dst = &priority
+		}
+	}
+
+	if v := qs.Get("tags"); v != "" {
+		for _, tag := range strings.Split(v, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				f.Tags = append(f.Tags, tag)
+			}
+		}
+	}
+
+	times := map[string]**time.Time{
+		"createdAfter":  &f.CreatedAfter,
+		"createdBefore": &f.CreatedBefore,
+		"updatedAfter":  &f.UpdatedAfter,
+		"updatedBefore": &f.UpdatedBefore,
+	}
+	for name, dst := range times {
+		if v := qs.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return f, fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*dst = &t
+		}
+	}
+
+	if v := qs.Get("q"); v != "" {
+		f.Search = v
+	}
+
+	return f, nil
+}
+
+// parseUserFilter returns the first page of all users, oldest first,
+// overridden by the query string of r.
+func parseUserFilter(r *http.Request) (store.UserFilter, error) {
+	f := store.DefaultUserFilter()
+	qs := r.URL.Query()
+
+	if v := qs.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return f, fmt.Errorf("invalid limit: %w", err)
+		}
+		f.Limit = limit
+	}
+	if v := qs.Get("cursor"); v != "" {
+		f.Cursor = v
+	}
+	if v := qs.Get("q"); v != "" {
+		f.Search = v
+	}
+	if v := qs.Get("role"); v != "" {
+		f.Role = store.UserRole(v)
+	}
+	if v := qs.Get("suspended"); v != "" {
+		suspended, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, fmt.Errorf("invalid suspended: %w", err)
+		}
+		f.Suspended = &suspended
+	}
+
+	return f, nil
+}
+
 func writeJSONError(w http.ResponseWriter, status int, message string) error {
 	type envelope struct {
 		Error string `json:"error"`
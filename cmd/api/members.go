@@ -0,0 +1,243 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"open-todo-go/internal/mailer"
+	"open-todo-go/internal/policy"
+	"open-todo-go/internal/store"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// invitationTTL is how long an invitation can be accepted for.
+const invitationTTL = 7 * 24 * time.Hour
+
+type UpdateMemberPayload struct {
+	Role store.Role `json:"role" validate:"required,oneof=editor viewer"`
+}
+
+type CreateInvitationPayload struct {
+	Email string     `json:"email" validate:"required,email,max=255"`
+	Role  store.Role `json:"role" validate:"required,oneof=editor viewer"`
+}
+
+type AcceptInvitationPayload struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// GetMembers lists everyone the project is shared with, owner first.
+func (app *application) GetMembers(w http.ResponseWriter, r *http.Request) {
+	project := getProjectFromContext(r)
+	ctx := r.Context()
+
+	owner, err := app.store.Users.GetByID(ctx, project.UserID)
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to fetch project owner: %w", err))
+		return
+	}
+
+	members, err := app.store.Members.GetMembers(ctx, project.ID)
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to fetch members: %w", err))
+		return
+	}
+
+	respondJSON(w, append([]store.ProjectMember{{
+		ProjectID: project.ID,
+		UserID:    owner.ID,
+		Username:  owner.Username,
+		Email:     owner.Email,
+		Role:      store.RoleOwner,
+		CreatedAt: project.CreatedAt,
+	}}, members...))
+}
+
+func (app *application) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	project := getProjectFromContext(r)
+	if err := policy.Project(getUserFromContext(r), project, policy.Manage); err != nil {
+		app.policyErrorResponse(w, r, err)
+		return
+	}
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid user ID: %w", err))
+		return
+	}
+
+	var payload UpdateMemberPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Members.UpdateMember(r.Context(), project.ID, userID, payload.Role); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to update member: %w", err))
+		}
+		return
+	}
+	app.jsonResponse(w, http.StatusOK, nil)
+}
+
+// RemoveMember takes a member off the project. The owner can remove anyone;
+// members can only remove themselves, which is how they leave a project.
+func (app *application) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid user ID: %w", err))
+		return
+	}
+
+	project := getProjectFromContext(r)
+	user := getUserFromContext(r)
+	if userID != user.ID {
+		if err := policy.Project(user, project, policy.Manage); err != nil {
+			app.policyErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if err := app.store.Members.RemoveMember(r.Context(), project.ID, userID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to remove member: %w", err))
+		}
+		return
+	}
+	app.jsonResponse(w, http.StatusOK, nil)
+}
+
+// CreateInvitation invites an email address to the project and mails it the
+// link to accept with. The token is also in the response, for sharing the
+// link some other way.
+func (app *application) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	project := getProjectFromContext(r)
+	user := getUserFromContext(r)
+	if err := policy.Project(user, project, policy.Manage); err != nil {
+		app.policyErrorResponse(w, r, err)
+		return
+	}
+
+	var payload CreateInvitationPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	invitation := &store.Invitation{
+		ProjectID: project.ID,
+		Email:     payload.Email,
+		Role:      payload.Role,
+		InvitedBy: user.ID,
+		ExpiresAt: time.Now().Add(invitationTTL),
+	}
+	if err := app.store.Members.CreateInvitation(r.Context(), invitation); err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to create invitation: %w", err))
+		return
+	}
+
+	link := app.appLink("/invitations/accept", invitation.Token)
+	app.sendMail(mailer.Invitation(invitation.Email, user.Username, project.Name, string(invitation.Role), link))
+
+	app.jsonResponse(w, http.StatusCreated, invitation)
+}
+
+// GetInvitations lists the project's invitations that can still be accepted.
+func (app *application) GetInvitations(w http.ResponseWriter, r *http.Request) {
+	project := getProjectFromContext(r)
+	if err := policy.Project(getUserFromContext(r), project, policy.Manage); err != nil {
+		app.policyErrorResponse(w, r, err)
+		return
+	}
+
+	invitations, err := app.store.Members.GetInvitations(r.Context(), project.ID)
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to fetch invitations: %w", err))
+		return
+	}
+	respondJSON(w, invitations)
+}
+
+func (app *application) DeleteInvitation(w http.ResponseWriter, r *http.Request) {
+	project := getProjectFromContext(r)
+	if err := policy.Project(getUserFromContext(r), project, policy.Manage); err != nil {
+		app.policyErrorResponse(w, r, err)
+		return
+	}
+
+	invitationID, err := strconv.ParseInt(chi.URLParam(r, "invitationID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid invitation ID: %w", err))
+		return
+	}
+
+	if err := app.store.Members.DeleteInvitation(r.Context(), project.ID, invitationID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to delete invitation: %w", err))
+		}
+		return
+	}
+	app.jsonResponse(w, http.StatusOK, nil)
+}
+
+// AcceptInvitation makes the authenticated user a member of the project the
+// token was issued for. The invitation must have been sent to their email,
+// and they must have verified it.
+func (app *application) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	if user.EmailVerifiedAt == nil {
+		app.unverifiedEmailResponse(w, r)
+		return
+	}
+
+	var payload AcceptInvitationPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	member, err := app.store.Members.AcceptInvitation(r.Context(), payload.Token, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, errors.New("invitation not found or expired"))
+		case errors.Is(err, store.ErrInvitationEmail):
+			app.forbiddenResponse(w, r)
+		case errors.Is(err, store.ErrConflict):
+			app.conflictResponse(w, r, errors.New("already a member of this project"))
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to accept invitation: %w", err))
+		}
+		return
+	}
+
+	app.jsonResponse(w, http.StatusCreated, member)
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"open-todo-go/internal/auth"
+	"open-todo-go/internal/store"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mfaTokenTTL is how long a user has to enter their second factor after
+// their password was accepted.
+const mfaTokenTTL = 5 * time.Minute
+
+var (
+	errInvalidMFACode  = errors.New("invalid two-factor code")
+	errInvalidMFAToken = errors.New("invalid or expired MFA token")
+)
+
+// SecondFactorPayload is a code from the user's authenticator app or one of
+// their recovery codes.
+type SecondFactorPayload struct {
+	Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recoveryCode" validate:"required_without=Code,omitempty,max=20"`
+}
+
+type LoginMFAPayload struct {
+	MFAToken string `json:"mfaToken" validate:"required"`
+	SecondFactorPayload
+}
+
+type ConfirmTOTPPayload struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// mfaEnabled reports whether the user has confirmed a TOTP enrollment.
+func (app *application) mfaEnabled(ctx context.Context, userID int64) (bool, error) {
+	totp, err := app.store.TOTP.Get(ctx, userID)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return totp.ConfirmedAt != nil, nil
+}
+
+// mfaChallengeResponse answers a login with a correct password for a user
+// with two-factor login turned on. The MFA token only works with
+// LoginMFAHandler, not as an access token.
+func (app *application) mfaChallengeResponse(w http.ResponseWriter, r *http.Request, user *store.User) {
+	now := time.Now()
+	expiresAt := now.Add(mfaTokenTTL)
+	claims := jwt.MapClaims{
+		"sub": user.ID,
+		"mfa": true,
+		"exp": expiresAt.Unix(),
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"iss": app.config.auth.token.iss,
+		"aud": app.config.auth.token.iss,
+	}
+
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := map[string]any{
+		"mfaRequired":  true,
+		"mfaToken":     token,
+		"mfaExpiresAt": expiresAt.UTC(),
+	}
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// checkSecondFactor accepts a TOTP code, which cannot be used twice, or uses
+// up a recovery code. Wrong codes are errInvalidMFACode.
+func (app *application) checkSecondFactor(ctx context.Context, userID int64, payload SecondFactorPayload) error {
+	if payload.RecoveryCode != "" {
+		err := app.store.TOTP.UseRecoveryCode(ctx, userID, payload.RecoveryCode)
+		if errors.Is(err, store.ErrNotFound) {
+			return errInvalidMFACode
+		}
+		return err
+	}
+
+	totp, err := app.store.TOTP.Get(ctx, userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return errInvalidMFACode
+		}
+		return err
+	}
+
+	step, ok := auth.ValidateTOTP(totp.Secret, payload.Code, time.Now())
+	if !ok || totp.ConfirmedAt == nil {
+		return errInvalidMFACode
+	}
+
+	err = app.store.TOTP.UseStep(ctx, userID, step)
+	if errors.Is(err, store.ErrNotFound) {
+		return errInvalidMFACode
+	}
+	return err
+}
+
+// LoginMFAHandler finishes a login that LoginHandler answered with an MFA
+// token.
+func (app *application) LoginMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var payload LoginMFAPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	token, err := app.authenticator.ValidateToken(payload.MFAToken)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, errInvalidMFAToken)
+		return
+	}
+
+	claims, _ := token.Claims.(jwt.MapClaims)
+	if mfa, _ := claims["mfa"].(bool); !mfa {
+		app.unauthorizedErrorResponse(w, r, errInvalidMFAToken)
+		return
+	}
+
+	userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, errInvalidMFAToken)
+		return
+	}
+
+	ctx := r.Context()
+	user, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.unauthorizedErrorResponse(w, r, errInvalidMFAToken)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if user.Locked(time.Now()) {
+		app.accountLockedResponse(w, r, user.LockedUntil)
+		return
+	}
+	if user.SuspendedAt != nil {
+		app.accountSuspendedResponse(w, r)
+		return
+	}
+
+	if err := app.checkSecondFactor(ctx, user.ID, payload.SecondFactorPayload); err != nil {
+		if !errors.Is(err, errInvalidMFACode) {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if err := app.recordFailedLogin(ctx, user.ID); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		app.unauthorizedErrorResponse(w, r, errInvalidMFACode)
+		return
+	}
+
+	app.startSession(w, r, user)
+}
+
+// GetMFAStatus reports whether two-factor login is on and how many recovery
+// codes are left.
+func (app *application) GetMFAStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := getUserIdFromContext(r)
+
+	enabled, err := app.mfaEnabled(ctx, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	left := 0
+	if enabled {
+		if left, err = app.store.TOTP.CountRecoveryCodes(ctx, userID); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	respondJSON(w, map[string]any{
+		"totpEnabled":       enabled,
+		"recoveryCodesLeft": left,
+	})
+}
+
+// EnrollTOTP starts setting up an authenticator app. Two-factor login stays
+// off until the enrollment is confirmed with ConfirmTOTP.
+func (app *application) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.TOTP.Enroll(r.Context(), user.ID, secret); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictResponse(w, r, errors.New("two-factor login is already enabled"))
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to enroll totp: %w", err))
+		}
+		return
+	}
+
+	app.jsonResponse(w, http.StatusCreated, map[string]any{
+		"secret": auth.EncodeTOTPSecret(secret),
+		"uri":    auth.TOTPURI(secret, app.config.auth.token.iss, user.Email),
+	})
+}
+
+// ConfirmTOTP turns on two-factor login with a code from the app being
+// enrolled. The response is the only place the recovery codes are shown.
+func (app *application) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	var payload ConfirmTOTPPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	userID := getUserIdFromContext(r)
+
+	totp, err := app.store.TOTP.Get(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, errors.New("no pending totp enrollment"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	step, ok := auth.ValidateTOTP(totp.Secret, payload.Code, time.Now())
+	if !ok {
+		app.badRequestResponse(w, r, errInvalidMFACode)
+		return
+	}
+
+	codes, err := app.store.TOTP.Confirm(ctx, userID, step)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, errors.New("no pending totp enrollment"))
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to confirm totp: %w", err))
+		}
+		return
+	}
+
+	app.jsonResponse(w, http.StatusOK, map[string]any{"recoveryCodes": codes})
+}
+
+// DisableTOTP turns off two-factor login. It takes a second factor, so a
+// stolen access token alone cannot weaken the account.
+func (app *application) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := getUserIdFromContext(r)
+	if !app.readSecondFactor(w, r, userID) {
+		return
+	}
+
+	if err := app.store.TOTP.Delete(ctx, userID); err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to disable totp: %w", err))
+		return
+	}
+	app.jsonResponse(w, http.StatusOK, nil)
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes with new ones,
+// shown only in the response.
+func (app *application) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := getUserIdFromContext(r)
+	if !app.readSecondFactor(w, r, userID) {
+		return
+	}
+
+	codes, err := app.store.TOTP.RegenerateRecoveryCodes(ctx, userID)
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to regenerate recovery codes: %w", err))
+		return
+	}
+	app.jsonResponse(w, http.StatusOK, map[string]any{"recoveryCodes": codes})
+}
+
+// readSecondFactor reads a SecondFactorPayload and checks it for a user who
+// has two-factor login on. It writes the error response and returns false if
+// the request cannot go ahead.
+func (app *application) readSecondFactor(w http.ResponseWriter, r *http.Request, userID int64) bool {
+	ctx := r.Context()
+
+	enabled, err := app.mfaEnabled(ctx, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return false
+	}
+	if !enabled {
+		app.notFoundResponse(w, r, errors.New("two-factor login is not enabled"))
+		return false
+	}
+
+	var payload SecondFactorPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return false
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return false
+	}
+
+	if err := app.checkSecondFactor(ctx, userID, payload); err != nil {
+		switch {
+		case errors.Is(err, errInvalidMFACode):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"open-todo-go/internal/auth"
+)
+
+// totpCodeAt computes the code an authenticator app shows for the base32
+// secret, step steps away from now.
+func totpCodeAt(t *testing.T, secret string, step int64) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatalf("decode secret: %v", err)
+	}
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(time.Now().Unix()/int64(auth.TOTPPeriod.Seconds())+step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:])&0x7fffffff)%1000000)
+}
+
+func TestMFALogin(t *testing.T) {
+	app := newTestApplication(t)
+	alice := loginTestUser(t, app, "alice")
+
+	var enrollment struct {
+		Secret string `json:"secret"`
+	}
+	alice.expect(http.StatusCreated, http.MethodPost, "/user/mfa/totp", nil, &enrollment)
+	// Confirming with the previous step's code leaves the current one
+	// unused for logging in.
+	var confirmed struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}
+	previous := totpCodeAt(t, enrollment.Secret, -1)
+	alice.expect(http.StatusOK, http.MethodPost, "/user/mfa/totp/confirm", map[string]string{"code": previous}, &confirmed)
+	if len(confirmed.RecoveryCodes) == 0 {
+		t.Fatal("confirming returned no recovery codes")
+	}
+
+	c := newTestClient(t, app)
+	challenge := func() string {
+		t.Helper()
+		var response struct {
+			MFARequired bool   `json:"mfaRequired"`
+			MFAToken    string `json:"mfaToken"`
+		}
+		c.expect(http.StatusOK, http.MethodPost, "/user/login",
+			map[string]string{"email": "alice@example.com", "password": "correct horse battery"}, &response)
+		if !response.MFARequired || response.MFAToken == "" {
+			t.Fatalf("login = %+v, want an MFA challenge", response)
+		}
+		return response.MFAToken
+	}
+
+	// The challenge token only works for the second step of the login.
+	mfaToken := challenge()
+	withChallenge := &testClient{t: t, handler: c.handler, token: mfaToken}
+	withChallenge.expect(http.StatusUnauthorized, http.MethodGet, "/user/me", nil, nil)
+	withChallenge.expect(http.StatusUnauthorized, http.MethodGet, "/todos/", nil, nil)
+
+	// A code's step can only be used once, the one used to confirm
+	// included.
+	current := totpCodeAt(t, enrollment.Secret, 0)
+	c.expect(http.StatusUnauthorized, http.MethodPost, "/user/login/mfa",
+		map[string]string{"mfaToken": mfaToken, "code": previous}, nil)
+	var tokens struct {
+		Token string `json:"token"`
+	}
+	c.expect(http.StatusOK, http.MethodPost, "/user/login/mfa",
+		map[string]string{"mfaToken": mfaToken, "code": current}, &tokens)
+	if tokens.Token == "" {
+		t.Fatal("MFA login returned no token")
+	}
+	c.expect(http.StatusUnauthorized, http.MethodPost, "/user/login/mfa",
+		map[string]string{"mfaToken": challenge(), "code": current}, nil)
+
+	// Recovery codes work once each.
+	recovery := confirmed.RecoveryCodes[0]
+	c.expect(http.StatusOK, http.MethodPost, "/user/login/mfa",
+		map[string]string{"mfaToken": challenge(), "recoveryCode": recovery}, nil)
+	c.expect(http.StatusUnauthorized, http.MethodPost, "/user/login/mfa",
+		map[string]string{"mfaToken": challenge(), "recoveryCode": recovery}, nil)
+
+	var status struct {
+		RecoveryCodesLeft int `json:"recoveryCodesLeft"`
+	}
+	alice.expect(http.StatusOK, http.MethodGet, "/user/mfa", nil, &status)
+	if status.RecoveryCodesLeft != len(confirmed.RecoveryCodes)-1 {
+		t.Fatalf("recovery codes left = %d, want %d", status.RecoveryCodesLeft, len(confirmed.RecoveryCodes)-1)
+	}
+}
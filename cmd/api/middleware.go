@@ -2,9 +2,13 @@ package main
 
 import (
 	"context"
-	"encoding/base64"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/netip"
+	"open-todo-go/internal/policy"
+	"open-todo-go/internal/ratelimiter"
 	"open-todo-go/internal/store"
 	"strconv"
 	"strings"
@@ -14,7 +18,11 @@ import (
 
 type userKey string
 
-const userCtx userKey = "user"
+const (
+	userCtx        userKey = "user"
+	sessionCtx     userKey = "session"
+	accessTokenCtx userKey = "accessToken"
+)
 
 func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -31,6 +39,11 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 		}
 
 		token := parts[1]
+		if store.IsAccessToken(token) {
+			app.serveWithAccessToken(w, r, next, token)
+			return
+		}
+
 		jwtToken, err := app.authenticator.ValidateToken(token)
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, err)
@@ -38,6 +51,10 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 		}
 
 		claims, _ := jwtToken.Claims.(jwt.MapClaims)
+		if mfa, _ := claims["mfa"].(bool); mfa {
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("two-factor login is not finished"))
+			return
+		}
 
 		userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
 		if err != nil {
@@ -45,8 +62,22 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		sessionID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sid"]), 10, 64)
+		if err != nil {
+			app.unauthorizedErrorResponse(w, r, err)
+			return
+		}
+
 		ctx := r.Context()
 
+		// Access tokens outlive a logout or a reused refresh token, so check
+		// that their session is still live.
+		session, err := app.store.Sessions.Get(ctx, sessionID)
+		if err != nil || session.UserID != userID || session.RevokedAt != nil {
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("session has been revoked"))
+			return
+		}
+
 		user, err := app.getUser(ctx, userID)
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, err)
@@ -54,66 +85,209 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 		}
 
 		ctx = context.WithValue(ctx, userCtx, user)
+		ctx = context.WithValue(ctx, sessionCtx, session.ID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func (app *application) BasicAuthMiddleware() func(http.Handler) http.Handler {
+// serveWithAccessToken authenticates the request with a personal access
+// token. Read-only tokens are held to safe methods here; tokens limited to
+// a project are checked wherever the project is known.
+func (app *application) serveWithAccessToken(w http.ResponseWriter, r *http.Request, next http.Handler, secret string) {
+	ctx := r.Context()
+
+	token, err := app.store.AccessTokens.Authenticate(ctx, secret)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("invalid or expired access token"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if token.Scope != store.ScopeWrite && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	user, err := app.getUser(ctx, token.UserID)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	ctx = context.WithValue(ctx, userCtx, user)
+	ctx = context.WithValue(ctx, accessTokenCtx, token)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// requireSessionMiddleware refuses requests made with a personal access
+// token, so a leaked token cannot be used to manage the account's
+// credentials.
+func (app *application) requireSessionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if getAccessTokenFromContext(r) != nil {
+			app.forbiddenResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// accountScopeMiddleware refuses personal access tokens limited to a project
+// on routes that reach across all of the user's data.
+func (app *application) accountScopeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := getAccessTokenFromContext(r); token != nil && token.ProjectID != nil {
+			app.forbiddenResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// errAccountSuspended is returned for users an admin has suspended.
+var errAccountSuspended = errors.New("account suspended")
+
+// getUser returns the user a request is authenticated as. Deleted users no
+// longer exist as far as authentication is concerned, and suspended users
+// get errAccountSuspended.
+func (app *application) getUser(ctx context.Context, userID int64) (*store.User, error) {
+	user, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt != nil {
+		return nil, store.ErrNotFound
+	}
+	if user.SuspendedAt != nil {
+		return nil, errAccountSuspended
+	}
+	return user, nil
+}
+
+// getAccessTokenFromContext returns the personal access token the request
+// was made with, or nil if it was made with a JWT.
+func getAccessTokenFromContext(r *http.Request) *store.AccessToken {
+	token, _ := r.Context().Value(accessTokenCtx).(*store.AccessToken)
+	return token
+}
+
+// checkTokenProject returns policy.ErrForbidden if the request was made with
+// a personal access token that does not reach projectID.
+func checkTokenProject(r *http.Request, projectID *int64) error {
+	if token := getAccessTokenFromContext(r); token != nil && !token.AllowsProject(projectID) {
+		return policy.ErrForbidden
+	}
+	return nil
+}
+
+// getSessionIdFromContext returns the session the request's access token was
+// issued for.
+func getSessionIdFromContext(r *http.Request) int64 {
+	sessionID, _ := r.Context().Value(sessionCtx).(int64)
+	return sessionID
+}
+
+// RateLimiterMiddleware limits every request per client IP.
+func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
+	return app.rateLimit(app.rateLimiter.global, clientIP)(next)
+}
+
+// rateLimit returns a middleware that limits requests with limiter, telling
+// clients apart by key.
+func (app *application) rateLimit(limiter ratelimiter.Limiter, key func(*http.Request) string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
+		if limiter == nil {
+			return next
+		}
+
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// read the auth header
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				app.unauthorizedBasicErrorResponse(w, r, fmt.Errorf("authorization header is missing"))
+			if allow, retryAfter := limiter.Allow(key(r)); !allow {
+				app.rateLimitExceededResponse(w, r, retryAfterSeconds(retryAfter))
 				return
 			}
 
-			// parse it -> get the base64
-			parts := strings.Split(authHeader, " ")
-			if len(parts) != 2 || parts[0] != "Basic" {
-				app.unauthorizedBasicErrorResponse(w, r, fmt.Errorf("authorization header is malformed"))
-				return
-			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
 
-			// decode it
-			decoded, err := base64.StdEncoding.DecodeString(parts[1])
-			if err != nil {
-				app.unauthorizedBasicErrorResponse(w, r, err)
-				return
+// RealIPMiddleware sets the request's RemoteAddr to the client's address
+// when it came through one of the trusted proxies. X-Forwarded-For is read
+// from the right, skipping trusted proxies, so a client cannot pick its own
+// address by sending the header; X-Real-IP is used without it. Requests
+// from anywhere else keep the address they were made from.
+func (app *application) RealIPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.trustedProxy(clientIP(r)) {
+			if ip := app.forwardedFor(r.Header); ip != "" {
+				r.RemoteAddr = ip
 			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
 
-			// check the credentials
-			username := app.config.auth.basic.user
-			pass := app.config.auth.basic.pass
+// forwardedFor returns the client address the trusted proxies forwarded, or
+// "" if they did not.
+func (app *application) forwardedFor(h http.Header) string {
+	var hops []string
+	for _, v := range h.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(v, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	if len(hops) == 0 {
+		if addr, err := netip.ParseAddr(h.Get("X-Real-IP")); err == nil {
+			return addr.Unmap().String()
+		}
+		return ""
+	}
 
-			creds := strings.SplitN(string(decoded), ":", 2)
-			if len(creds) != 2 || creds[0] != username || creds[1] != pass {
-				app.unauthorizedBasicErrorResponse(w, r, fmt.Errorf("invalid credentials"))
-				return
-			}
+	client := ""
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(hops[i])
+		if err != nil {
+			break
+		}
+		client = addr.Unmap().String()
+		if !app.trustedProxy(client) {
+			break
+		}
+	}
+	return client
+}
 
-			next.ServeHTTP(w, r)
-		})
+// trustedProxy reports whether ip is one of the proxies in TRUSTED_PROXIES.
+func (app *application) trustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range app.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
 	}
+	return false
 }
 
-func (app *application) getUser(ctx context.Context, userID int64) (*store.User, error) {
-	user, err := app.store.Users.GetByID(ctx, userID)
+// clientIP is the address the request came from, as set by
+// RealIPMiddleware when behind a trusted proxy.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
-		return nil, err
+		return r.RemoteAddr
 	}
-	return user, nil
+	return host
 }
 
-// func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
-// 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-// 		if app.config.rateLimiter.Enabled {
-// 			if allow, retryAfter := app.rateLimiter.Allow(r.RemoteAddr); !allow {
-// 				app.rateLimitExceededResponse(w, r, retryAfter.String())
-// 				return
-// 			}
-// 		}
-
-// 		next.ServeHTTP(w, r)
-// 	})
-// }
+// userRateLimitKey keys requests by the authenticated user, so all their
+// sessions and access tokens share one limit.
+func userRateLimitKey(r *http.Request) string {
+	return strconv.FormatInt(getUserIdFromContext(r), 10)
+}
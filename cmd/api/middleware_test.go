@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := parseTrustedProxies([]string{"10.0.0.1", "192.168.1.7/16", "::1", "::ffff:172.16.0.1"})
+	if err != nil {
+		t.Fatalf("parseTrustedProxies: %v", err)
+	}
+	want := []string{"10.0.0.1/32", "192.168.0.0/16", "::1/128", "172.16.0.1/32"}
+	if len(prefixes) != len(want) {
+		t.Fatalf("parseTrustedProxies = %v, want %v", prefixes, want)
+	}
+	for i, prefix := range prefixes {
+		if prefix.String() != want[i] {
+			t.Fatalf("parseTrustedProxies = %v, want %v", prefixes, want)
+		}
+	}
+
+	for _, item := range []string{"proxy.internal", "10.0.0.0/33"} {
+		if _, err := parseTrustedProxies([]string{item}); err == nil {
+			t.Fatalf("parseTrustedProxies(%q) did not fail", item)
+		}
+	}
+}
+
+func TestRealIPMiddleware(t *testing.T) {
+	trusted, err := parseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("parseTrustedProxies: %v", err)
+	}
+	app := &application{trustedProxies: trusted}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		realIP       string
+		wantClientIP string
+	}{
+		{"direct", "203.0.113.7:5000", nil, "", "203.0.113.7"},
+		{"direct with spoofed headers", "203.0.113.7:5000", []string{"198.51.100.1"}, "198.51.100.2", "203.0.113.7"},
+		{"trusted proxy", "10.0.0.2:5000", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		{"client prepends a hop", "10.0.0.2:5000", []string{"192.0.2.9, 198.51.100.1"}, "", "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.2:5000", []string{"198.51.100.1, 10.0.0.3", "10.0.0.4"}, "", "198.51.100.1"},
+		{"only trusted proxies", "10.0.0.2:5000", []string{"10.0.0.3"}, "", "10.0.0.3"},
+		{"bad hop", "10.0.0.2:5000", []string{"198.51.100.1, garbage, 10.0.0.3"}, "", "10.0.0.3"},
+		{"real ip", "10.0.0.2:5000", nil, "198.51.100.2", "198.51.100.2"},
+		{"bad real ip", "10.0.0.2:5000", nil, "garbage", "10.0.0.2"},
+		{"forwarded for wins", "10.0.0.2:5000", []string{"198.51.100.1"}, "198.51.100.2", "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			var got string
+			app.RealIPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = clientIP(r)
+			})).ServeHTTP(httptest.NewRecorder(), r)
+			if got != tt.wantClientIP {
+				t.Fatalf("clientIP = %q, want %q", got, tt.wantClientIP)
+			}
+		})
+	}
+}
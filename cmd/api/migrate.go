@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"open-todo-go/internal/migrate"
+	"strconv"
+)
+
+const migrateUsage = "usage: api migrate up [n] | down [n] | status | create <name>"
+
+// runMigrate implements the `migrate` subcommand of the API binary.
+func runMigrate(ctx context.Context, db *sql.DB, dialect, dir string, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	if args[0] == "create" {
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		files, err := migrate.Create(dir, args[1])
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			fmt.Println("created", f)
+		}
+		return nil
+	}
+
+	m, err := migrate.New(db, dialect)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up", "down":
+		n := 0
+		if args[0] == "down" {
+			n = 1
+		}
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				return fmt.Errorf("invalid migration count %q", args[1])
+			}
+		}
+
+		var done []migrate.Migration
+		if args[0] == "up" {
+			done, err = m.Up(ctx, n)
+		} else {
+			done, err = m.Down(ctx, n)
+		}
+		for _, mig := range done {
+			fmt.Printf("%s %04d_%s\n", args[0], mig.Version, mig.Name)
+		}
+		if errors.Is(err, migrate.ErrNoChange) {
+			fmt.Println(err)
+			return nil
+		}
+		return err
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			state := "pending"
+			if st.Applied {
+				state = "applied " + st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-40s %s\n", st.Version, st.Name, state)
+		}
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"open-todo-go/internal/oidc"
+	"open-todo-go/internal/store"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// oidcFlowTTL is how long a user has to log in at the provider.
+const oidcFlowTTL = 10 * time.Minute
+
+var (
+	errUnknownProvider = errors.New("unknown identity provider")
+	errInvalidState    = errors.New("invalid or expired state")
+	errIdentityLinked  = errors.New("identity is already linked to an account")
+	errMissingEmail    = errors.New("identity provider did not share an email address")
+	// errEmailTaken is returned instead of linking an identity to an
+	// existing account by email, which would hand the account to whoever
+	// controls that address at the provider.
+	errEmailTaken = errors.New("an account with this email already exists; log in and link the identity to it")
+
+	usernameUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+)
+
+type OIDCCallbackPayload struct {
+	State string `json:"state" validate:"required"`
+	Code  string `json:"code" validate:"required"`
+}
+
+// getOIDCProvider returns the provider named in the route, or writes a 404.
+func (app *application) getOIDCProvider(w http.ResponseWriter, r *http.Request) *oidc.Provider {
+	provider, ok := app.oidcProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		app.notFoundResponse(w, r, errUnknownProvider)
+		return nil
+	}
+	return provider
+}
+
+// GetOIDCProviders lists the identity providers users can log in with.
+func (app *application) GetOIDCProviders(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(app.oidcProviders))
+	for name := range app.oidcProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	respondJSON(w, names)
+}
+
+// StartOIDCLogin begins logging in at a provider. The client sends the user
+// to authURL; the provider sends them back to the configured redirect URL,
+// from where the client passes the state and code to OIDCCallbackHandler.
+func (app *application) StartOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	app.startOIDCFlow(w, r, nil)
+}
+
+// StartOIDCLink is StartOIDCLogin for linking an identity to the
+// authenticated user.
+func (app *application) StartOIDCLink(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIdFromContext(r)
+	app.startOIDCFlow(w, r, &userID)
+}
+
+func (app *application) startOIDCFlow(w http.ResponseWriter, r *http.Request, userID *int64) {
+	provider := app.getOIDCProvider(w, r)
+	if provider == nil {
+		return
+	}
+
+	nonce, err := oidc.NewNonce()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	flow := &store.OIDCFlow{
+		Provider:  provider.Name(),
+		Nonce:     nonce,
+		Verifier:  oidc.NewVerifier(),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(oidcFlowTTL),
+	}
+	if err := app.store.Identities.CreateFlow(ctx, flow); err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to start oidc flow: %w", err))
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, flow.State, flow.Nonce, flow.Verifier)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.jsonResponse(w, http.StatusOK, map[string]any{
+		"authURL":   authURL,
+		"expiresAt": flow.ExpiresAt,
+	})
+}
+
+// OIDCCallbackHandler finishes a flow begun by StartOIDCLogin or
+// StartOIDCLink. Logins get the same response as LoginHandler; links get the
+// new identity.
+func (app *application) OIDCCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	provider := app.getOIDCProvider(w, r)
+	if provider == nil {
+		return
+	}
+
+	var payload OIDCCallbackPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	flow, err := app.store.Identities.ConsumeFlow(ctx, provider.Name(), payload.State)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.badRequestResponse(w, r, errInvalidState)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	claims, err := provider.Exchange(ctx, payload.Code, flow.Nonce, flow.Verifier)
+	if err != nil {
+		app.logger.Warnw("oidc exchange failed", "provider", provider.Name(), "error", err)
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("identity provider login failed"))
+		return
+	}
+
+	identity := &store.Identity{
+		Provider: provider.Name(),
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+
+	if flow.UserID != nil {
+		identity.UserID = *flow.UserID
+		if err := app.store.Identities.Create(ctx, identity); err != nil {
+			switch {
+			case errors.Is(err, store.ErrConflict):
+				app.conflictResponse(w, r, errIdentityLinked)
+			default:
+				app.internalServerError(w, r, fmt.Errorf("failed to link identity: %w", err))
+			}
+			return
+		}
+		app.jsonResponse(w, http.StatusCreated, identity)
+		return
+	}
+
+	user, err := app.oidcUser(ctx, identity, claims)
+	if err != nil {
+		switch {
+		case errors.Is(err, errEmailTaken), errors.Is(err, errIdentityLinked):
+			app.conflictResponse(w, r, err)
+		case errors.Is(err, errMissingEmail):
+			app.badRequestResponse(w, r, err)
+		case errors.Is(err, errAccountSuspended):
+			app.accountSuspendedResponse(w, r)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if user.Locked(time.Now()) {
+		app.accountLockedResponse(w, r, user.LockedUntil)
+		return
+	}
+
+	if app.config.auth.requireVerifiedEmail && user.EmailVerifiedAt == nil {
+		app.unverifiedEmailResponse(w, r)
+		return
+	}
+
+	mfa, err := app.mfaEnabled(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if mfa {
+		app.mfaChallengeResponse(w, r, user)
+		return
+	}
+
+	app.startSession(w, r, user)
+}
+
+// oidcUser returns the user identity is linked to, first signing them up if
+// the identity is new.
+func (app *application) oidcUser(ctx context.Context, identity *store.Identity, claims *oidc.Claims) (*store.User, error) {
+	linked, err := app.store.Identities.GetBySubject(ctx, identity.Provider, identity.Subject)
+	if err == nil {
+		return app.getUser(ctx, linked.UserID)
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	if claims.Email == "" {
+		return nil, errMissingEmail
+	}
+	if _, err := app.store.Users.GetByEmail(ctx, claims.Email); err == nil {
+		return nil, errEmailTaken
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	user, err := app.createOIDCUser(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	identity.UserID = user.ID
+	if err := app.store.Identities.Create(ctx, identity); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			return nil, errIdentityLinked
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// createOIDCUser signs up the user a provider vouches for. They get a random
+// password, which they can replace through a password reset, and a username
+// based on the one they have at the provider.
+func (app *application) createOIDCUser(ctx context.Context, claims *oidc.Claims) (*store.User, error) {
+	user := &store.User{Email: claims.Email}
+
+	password, err := oidc.NewNonce()
+	if err != nil {
+		return nil, err
+	}
+	if err := app.setPassword(user, password); err != nil {
+		return nil, err
+	}
+
+	base := oidcUsername(claims)
+	for attempt := 0; ; attempt++ {
+		user.Username = base
+		if attempt > 0 {
+			n, err := rand.Int(rand.Reader, big.NewInt(10000))
+			if err != nil {
+				return nil, err
+			}
+			user.Username = fmt.Sprintf("%s%04d", base, n)
+		}
+
+		err := app.store.Users.Create(ctx, user)
+		if err == nil {
+			break
+		}
+		switch {
+		case errors.Is(err, store.ErrDuplicateUsername) && attempt < 5:
+			continue
+		case errors.Is(err, store.ErrDuplicateEmail):
+			return nil, errEmailTaken
+		default:
+			return nil, err
+		}
+	}
+
+	if claims.EmailVerified {
+		if err := app.store.Users.SetEmailVerified(ctx, user.ID); err != nil {
+			return nil, err
+		}
+		verifiedAt := time.Now()
+		user.EmailVerifiedAt = &verifiedAt
+	} else if err := app.sendVerificationEmail(ctx, user); err != nil {
+		app.logger.Errorw("failed to send verification email", "userID", user.ID, "error", err)
+	}
+	return user, nil
+}
+
+// oidcUsername is the start of a new user's username: their username at the
+// provider or else the local part of their email, cut to leave room for a
+// suffix within the 20 characters usernames may have.
+func oidcUsername(claims *oidc.Claims) string {
+	name := claims.PreferredUsername
+	if name == "" {
+		name, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	name = usernameUnsafe.ReplaceAllString(name, "")
+	if len(name) > 16 {
+		name = name[:16]
+	}
+	if name == "" {
+		name = "user"
+	}
+	return name
+}
+
+// GetIdentities lists the identities linked to the user.
+func (app *application) GetIdentities(w http.ResponseWriter, r *http.Request) {
+	identities, err := app.store.Identities.GetByUser(r.Context(), getUserIdFromContext(r))
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to fetch identities: %w", err))
+		return
+	}
+	respondJSON(w, identities)
+}
+
+// DeleteIdentity unlinks an identity. The user can still log in with their
+// password, or set one through a password reset.
+func (app *application) DeleteIdentity(w http.ResponseWriter, r *http.Request) {
+	identityID, err := strconv.ParseInt(chi.URLParam(r, "identityID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid identity ID: %w", err))
+		return
+	}
+
+	if err := app.store.Identities.Delete(r.Context(), getUserIdFromContext(r), identityID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to delete identity: %w", err))
+		}
+		return
+	}
+	app.jsonResponse(w, http.StatusOK, nil)
+}
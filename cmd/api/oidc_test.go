@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"open-todo-go/internal/oidc"
+	"open-todo-go/internal/oidc/oidctest"
+	"open-todo-go/internal/store"
+)
+
+// newOIDCTestApplication is newTestApplication with a provider called mock
+// served by srv.
+func newOIDCTestApplication(t *testing.T) (*application, *oidctest.Server) {
+	t.Helper()
+
+	srv := oidctest.NewServer(t)
+	app := newTestApplication(t)
+	app.oidcProviders = map[string]*oidc.Provider{
+		"mock": oidc.NewProvider(srv.Config("mock", app.config.appURL+"/oidc/callback")),
+	}
+	return app, srv
+}
+
+// oidcLogin goes through a flow started at start as the provider's user
+// with claims, and returns the callback payload.
+func oidcLogin(t *testing.T, srv *oidctest.Server, c *testClient, start string, claims map[string]any) map[string]string {
+	t.Helper()
+
+	var flow struct {
+		AuthURL string `json:"authURL"`
+	}
+	c.expect(http.StatusOK, http.MethodPost, start, nil, &flow)
+	state, code := srv.Login(t, flow.AuthURL, claims)
+	return map[string]string{"state": state, "code": code}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	app, srv := newOIDCTestApplication(t)
+	c := newTestClient(t, app)
+	carol := map[string]any{"sub": "carol-at-mock", "email": "carol@example.com", "email_verified": true, "preferred_username": "carol"}
+
+	// The first login signs carol up.
+	callback := oidcLogin(t, srv, c, "/user/oidc/mock/start", carol)
+	var tokens struct {
+		Token string `json:"token"`
+	}
+	c.expect(http.StatusOK, http.MethodPost, "/user/oidc/mock/callback", callback, &tokens)
+	if tokens.Token == "" {
+		t.Fatal("OIDC login returned no token")
+	}
+	me := &testClient{t: t, handler: c.handler, token: tokens.Token}
+	var user store.User
+	me.expect(http.StatusOK, http.MethodGet, "/user/me", nil, &user)
+	if user.Email != "carol@example.com" || user.Username != "carol" || user.EmailVerifiedAt == nil {
+		t.Fatalf("signed up user = %+v", user)
+	}
+
+	// A state works once.
+	c.expect(http.StatusBadRequest, http.MethodPost, "/user/oidc/mock/callback", callback, nil)
+
+	// Later logins find the same user.
+	c.expect(http.StatusOK, http.MethodPost, "/user/oidc/mock/callback",
+		oidcLogin(t, srv, c, "/user/oidc/mock/start", carol), nil)
+	var identities []store.Identity
+	me.expect(http.StatusOK, http.MethodGet, "/user/identities", nil, &identities)
+	if len(identities) != 1 || identities[0].Subject != "carol-at-mock" {
+		t.Fatalf("identities = %+v", identities)
+	}
+
+	// An ID token for another login is refused.
+	carol["nonce"] = "another login's nonce"
+	c.expect(http.StatusUnauthorized, http.MethodPost, "/user/oidc/mock/callback",
+		oidcLogin(t, srv, c, "/user/oidc/mock/start", carol), nil)
+
+	c.expect(http.StatusNotFound, http.MethodPost, "/user/oidc/other/start", nil, nil)
+}
+
+func TestOIDCExistingEmail(t *testing.T) {
+	app, srv := newOIDCTestApplication(t)
+	alice := loginTestUser(t, app, "alice")
+	c := newTestClient(t, app)
+
+	// Logging in with a provider account that has alice's email does not
+	// hand over her account.
+	mallory := map[string]any{"sub": "mallory-at-mock", "email": "alice@example.com", "email_verified": true}
+	w := c.do(http.MethodPost, "/user/oidc/mock/callback", oidcLogin(t, srv, c, "/user/oidc/mock/start", mallory))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("callback = %d %s, want 409", w.Code, w.Body)
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	decodeResponse(t, w, &body)
+	if body.Error != errEmailTaken.Error() {
+		t.Fatalf("callback error = %q, want %q", body.Error, errEmailTaken)
+	}
+
+	var identities []store.Identity
+	alice.expect(http.StatusOK, http.MethodGet, "/user/identities", nil, &identities)
+	if len(identities) != 0 {
+		t.Fatalf("alice has identities %+v", identities)
+	}
+}
+
+func TestOIDCLink(t *testing.T) {
+	app, srv := newOIDCTestApplication(t)
+	alice := loginTestUser(t, app, "alice")
+	bob := loginTestUser(t, app, "bob")
+	c := newTestClient(t, app)
+	claims := map[string]any{"sub": "alice-at-mock", "email": "alice@work.example.com"}
+
+	var identity store.Identity
+	alice.expect(http.StatusCreated, http.MethodPost, "/user/oidc/mock/callback",
+		oidcLogin(t, srv, alice, "/user/oidc/mock/link", claims), &identity)
+	if identity.UserID != alice.userID || identity.Provider != "mock" || identity.Subject != "alice-at-mock" {
+		t.Fatalf("linked identity = %+v", identity)
+	}
+
+	// The identity now logs in as alice, and cannot be linked to bob too.
+	var tokens struct {
+		Token string `json:"token"`
+	}
+	c.expect(http.StatusOK, http.MethodPost, "/user/oidc/mock/callback",
+		oidcLogin(t, srv, c, "/user/oidc/mock/start", claims), &tokens)
+	var user store.User
+	(&testClient{t: t, handler: c.handler, token: tokens.Token}).expect(http.StatusOK, http.MethodGet, "/user/me", nil, &user)
+	if user.ID != alice.userID {
+		t.Fatalf("identity logged in as user %d, want %d", user.ID, alice.userID)
+	}
+	bob.expect(http.StatusConflict, http.MethodPost, "/user/oidc/mock/callback",
+		oidcLogin(t, srv, bob, "/user/oidc/mock/link", claims), nil)
+
+	// Unlinking it stops it logging in as alice.
+	alice.expect(http.StatusOK, http.MethodDelete, fmt.Sprintf("/user/identities/%d", identity.ID), nil, nil)
+	c.expect(http.StatusConflict, http.MethodPost, "/user/oidc/mock/callback",
+		oidcLogin(t, srv, c, "/user/oidc/mock/start", map[string]any{"sub": "alice-at-mock", "email": "alice@example.com"}), nil)
+}
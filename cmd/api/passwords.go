@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"open-todo-go/internal/store"
+)
+
+// setPassword hashes text with the current hasher and makes it the user's
+// password. Callers check text against app.passwordPolicy first.
+func (app *application) setPassword(user *store.User, text string) error {
+	hash, err := app.passwords.Hash(text)
+	if err != nil {
+		return err
+	}
+	user.Password.Hash = []byte(hash)
+	return nil
+}
+
+// checkPassword reports whether text is the user's password.
+func (app *application) checkPassword(user *store.User, text string) bool {
+	ok, _ := app.passwords.Verify(text, string(user.Password.Hash))
+	return ok
+}
+
+// rehashPassword replaces the user's password hash with one made by the
+// current hasher. A failure is only logged: the user has already proven
+// their password and can log in anyway.
+func (app *application) rehashPassword(ctx context.Context, user *store.User, text string) {
+	if err := app.setPassword(user, text); err != nil {
+		app.logger.Errorw("failed to rehash password", "userID", user.ID, "error", err)
+		return
+	}
+	if err := app.store.Users.UpdatePassword(ctx, user); err != nil {
+		app.logger.Errorw("failed to store rehashed password", "userID", user.ID, "error", err)
+		return
+	}
+	app.logger.Infow("rehashed password", "userID", user.ID)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"open-todo-go/internal/store"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var errDataRequestOpen = errors.New("a request of this kind is already in progress")
+
+type RequestErasurePayload struct {
+	Password string `json:"password" validate:"required"`
+}
+
+// dataRequestResponse is a data request with the download link of a ready
+// export.
+type dataRequestResponse struct {
+	store.DataRequest
+	DownloadURL string `json:"downloadURL,omitempty"`
+}
+
+func (app *application) dataRequestResponse(req store.DataRequest) dataRequestResponse {
+	resp := dataRequestResponse{DataRequest: req}
+	if req.Kind == store.DataExport && req.Status == store.DataRequestReady && req.ExpiresAt != nil {
+		resp.DownloadURL = app.exportLinks.URL(req.ID, *req.ExpiresAt)
+	}
+	return resp
+}
+
+// createDataRequest queues a data request of kind for the authenticated user
+// and answers with it, or writes an error and returns nil.
+func (app *application) createDataRequest(w http.ResponseWriter, r *http.Request, kind store.DataRequestKind) *store.DataRequest {
+	req := &store.DataRequest{UserID: getUserIdFromContext(r), Kind: kind}
+	if err := app.store.DataRequests.Create(r.Context(), req); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictResponse(w, r, errDataRequestOpen)
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to create data request: %w", err))
+		}
+		return nil
+	}
+	return req
+}
+
+// RequestExport queues an export of everything stored about the user. The
+// user is mailed a download link once it is ready; GetDataRequests shows
+// its progress.
+func (app *application) RequestExport(w http.ResponseWriter, r *http.Request) {
+	req := app.createDataRequest(w, r, store.DataExport)
+	if req == nil {
+		return
+	}
+	app.jsonResponse(w, http.StatusAccepted, app.dataRequestResponse(*req))
+}
+
+// RequestErasure queues the deletion of the user and every row tied to
+// them, after checking their password. The user is signed out everywhere
+// right away.
+func (app *application) RequestErasure(w http.ResponseWriter, r *http.Request) {
+	var payload RequestErasurePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	if !app.checkPassword(user, payload.Password) {
+		app.badRequestResponse(w, r, errIncorrectPassword)
+		return
+	}
+
+	req := app.createDataRequest(w, r, store.DataErasure)
+	if req == nil {
+		return
+	}
+
+	if err := app.store.Sessions.RevokeAll(r.Context(), user.ID); err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to revoke sessions: %w", err))
+		return
+	}
+
+	app.logger.Infow("erasure requested", "userID", user.ID, "requestID", req.ID)
+	app.jsonResponse(w, http.StatusAccepted, req)
+}
+
+// GetDataRequests lists the user's exports and erasures, newest first.
+// Ready exports carry their download link.
+func (app *application) GetDataRequests(w http.ResponseWriter, r *http.Request) {
+	requests, err := app.store.DataRequests.GetByUser(r.Context(), getUserIdFromContext(r))
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to fetch data requests: %w", err))
+		return
+	}
+
+	resp := make([]dataRequestResponse, len(requests))
+	for i, req := range requests {
+		resp[i] = app.dataRequestResponse(req)
+	}
+	respondJSON(w, resp)
+}
+
+// DownloadExport sends the archive of an export. It needs no token: the
+// signed link mailed to the user is the credential.
+func (app *application) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	exportID, err := strconv.ParseInt(chi.URLParam(r, "exportID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid export ID: %w", err))
+		return
+	}
+
+	qs := r.URL.Query()
+	now := time.Now()
+	if !app.exportLinks.Verify(exportID, qs.Get("expires"), qs.Get("signature"), now) {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	archive, err := app.store.DataRequests.GetArchive(r.Context(), exportID, now)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="open-todo-export-%d.zip"`, exportID))
+	w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(archive)
+}
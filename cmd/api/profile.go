@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"open-todo-go/internal/mailer"
+	"open-todo-go/internal/store"
+)
+
+var (
+	errIncorrectPassword  = errors.New("current password is incorrect")
+	errEmailNeedsPassword = errors.New("changing the email address needs the current password")
+	errEmailNeedsMFA      = errors.New("changing the email address needs a two-factor code")
+)
+
+type UpdateUserPayload struct {
+	Username *string `json:"username,omitempty" validate:"omitempty,min=1,max=20"`
+	Email    *string `json:"email,omitempty" validate:"omitempty,email,max=200"`
+	Timezone *string `json:"timezone,omitempty" validate:"omitempty,timezone"`
+	Locale   *string `json:"locale,omitempty" validate:"omitempty,bcp47_language_tag,max=35"`
+	// CurrentPassword, or a second factor for users with two-factor login
+	// on, is needed to change the email address.
+	CurrentPassword string `json:"currentPassword,omitempty"`
+	Code            string `json:"code,omitempty" validate:"omitempty,len=6,numeric"`
+	RecoveryCode    string `json:"recoveryCode,omitempty" validate:"omitempty,max=20"`
+}
+
+type ChangePasswordPayload struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required"`
+}
+
+type DeleteUserPayload struct {
+	Password string `json:"password" validate:"required"`
+	// Todos is what happens to the user's todos and projects: "delete"
+	// deletes them with the account, "anonymize" keeps them, so todos in
+	// projects shared with others survive, under an anonymous user.
+	Todos string `json:"todos" validate:"omitempty,oneof=delete anonymize"`
+}
+
+// GetCurrentUser returns the authenticated user's profile.
+func (app *application) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, getUserFromContext(r))
+}
+
+// UpdateCurrentUser changes the fields of the profile present in the
+// payload. Changing the email address takes the current password, or a
+// second factor if two-factor login is on, as whoever controls the address
+// can reset the password. A new address has to be verified again, links
+// already mailed to the account stop working, and the old address is told
+// about the change.
+func (app *application) UpdateCurrentUser(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateUserPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := *getUserFromContext(r)
+	oldEmail := user.Email
+	if payload.Username != nil {
+		user.Username = *payload.Username
+	}
+	if payload.Email != nil && *payload.Email != user.Email {
+		if err := app.checkEmailChange(ctx, &user, payload); err != nil {
+			switch {
+			case errors.Is(err, errEmailNeedsPassword), errors.Is(err, errEmailNeedsMFA),
+				errors.Is(err, errIncorrectPassword), errors.Is(err, errInvalidMFACode):
+				app.badRequestResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+		user.Email = *payload.Email
+		user.EmailVerifiedAt = nil
+	}
+	if payload.Timezone != nil {
+		user.Timezone = *payload.Timezone
+	}
+	if payload.Locale != nil {
+		user.Locale = *payload.Locale
+	}
+
+	if err := app.store.Users.UpdateProfile(ctx, &user); err != nil {
+		switch {
+		case errors.Is(err, store.ErrDuplicateEmail), errors.Is(err, store.ErrDuplicateUsername):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to update user: %w", err))
+		}
+		return
+	}
+
+	if user.Email != oldEmail {
+		if err := app.store.UserTokens.DeleteAll(ctx, user.ID); err != nil {
+			app.internalServerError(w, r, fmt.Errorf("failed to revoke mailed tokens: %w", err))
+			return
+		}
+		app.sendMail(mailer.EmailChanged(oldEmail, user.Username, user.Email))
+		if err := app.sendVerificationEmail(ctx, &user); err != nil {
+			app.logger.Errorw("failed to send verification email", "userID", user.ID, "error", err)
+		}
+	}
+
+	app.jsonResponse(w, http.StatusOK, &user)
+}
+
+// checkEmailChange checks the proof payload carries that the user may
+// change their email address: a second factor if they have two-factor login
+// on, their password otherwise.
+func (app *application) checkEmailChange(ctx context.Context, user *store.User, payload UpdateUserPayload) error {
+	enabled, err := app.mfaEnabled(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		if payload.Code == "" && payload.RecoveryCode == "" {
+			return errEmailNeedsMFA
+		}
+		return app.checkSecondFactor(ctx, user.ID, SecondFactorPayload{Code: payload.Code, RecoveryCode: payload.RecoveryCode})
+	}
+
+	if payload.CurrentPassword == "" {
+		return errEmailNeedsPassword
+	}
+	if !app.checkPassword(user, payload.CurrentPassword) {
+		return errIncorrectPassword
+	}
+	return nil
+}
+
+// ChangePassword sets a new password for a user who knows their current
+// one. Every session is signed out and every personal access token revoked,
+// and the response carries the tokens of a new session for the client that
+// made the change.
+func (app *application) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var payload ChangePasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.passwordPolicy.Check(payload.NewPassword); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	if !app.checkPassword(user, payload.CurrentPassword) {
+		app.badRequestResponse(w, r, errIncorrectPassword)
+		return
+	}
+
+	if err := app.setPassword(user, payload.NewPassword); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := app.store.Users.UpdatePassword(ctx, user); err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to update password: %w", err))
+		return
+	}
+
+	if err := app.store.Sessions.RevokeAll(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to revoke sessions: %w", err))
+		return
+	}
+
+	if err := app.store.AccessTokens.DeleteAll(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to revoke access tokens: %w", err))
+		return
+	}
+
+	app.startSession(w, r, user)
+}
+
+// DeleteCurrentUser deletes the authenticated user's account after checking
+// their password. Users who signed up through an identity provider set one
+// with a password reset first.
+func (app *application) DeleteCurrentUser(w http.ResponseWriter, r *http.Request) {
+	var payload DeleteUserPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	if !app.checkPassword(user, payload.Password) {
+		app.badRequestResponse(w, r, errIncorrectPassword)
+		return
+	}
+
+	ctx := r.Context()
+	var err error
+	if payload.Todos == "anonymize" {
+		err = app.store.Users.Anonymize(ctx, user.ID)
+	} else {
+		err = app.store.Users.Delete(ctx, user.ID)
+	}
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to delete user: %w", err))
+		return
+	}
+
+	app.logger.Infow("user deleted", "userID", user.ID, "todos", payload.Todos)
+	app.jsonResponse(w, http.StatusOK, nil)
+}
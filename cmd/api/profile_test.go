@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"open-todo-go/internal/store"
+)
+
+// mailUserTokens creates the reset and verification tokens that links
+// mailed to the user carry.
+func mailUserTokens(t *testing.T, app *application, userID int64) []*store.UserToken {
+	t.Helper()
+
+	var tokens []*store.UserToken
+	for _, purpose := range []store.TokenPurpose{store.PurposeResetPassword, store.PurposeVerifyEmail} {
+		token := &store.UserToken{UserID: userID, Purpose: purpose, ExpiresAt: time.Now().Add(time.Hour)}
+		if err := app.store.UserTokens.Create(context.Background(), token); err != nil {
+			t.Fatalf("Create %s token: %v", purpose, err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+func TestChangeEmail(t *testing.T) {
+	app := newTestApplication(t)
+	alice := loginTestUser(t, app, "alice")
+	mailed := mailUserTokens(t, app, alice.userID)
+
+	refused := []struct {
+		name    string
+		payload map[string]string
+		err     error
+	}{
+		{"no password", map[string]string{"email": "mallory@example.com"}, errEmailNeedsPassword},
+		{"wrong password", map[string]string{"email": "mallory@example.com", "currentPassword": "wrong horse battery"}, errIncorrectPassword},
+		{"second factor instead", map[string]string{"email": "mallory@example.com", "code": "123456"}, errEmailNeedsPassword},
+	}
+	for _, tt := range refused {
+		w := alice.do(http.MethodPatch, "/user/me", tt.payload)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.err.Error()) {
+			t.Fatalf("%s: PATCH /user/me = %d %s, want 400 %q", tt.name, w.Code, w.Body, tt.err)
+		}
+	}
+
+	// The rest of the profile, and the email address it already has, need
+	// no password.
+	var user store.User
+	alice.expect(http.StatusOK, http.MethodPatch, "/user/me", map[string]string{"username": "alice2", "email": "alice@example.com"}, &user)
+	if user.Username != "alice2" || user.Email != "alice@example.com" || user.EmailVerifiedAt == nil {
+		t.Fatalf("profile after renaming = %+v", user)
+	}
+	for _, token := range mailed {
+		if _, err := app.store.UserTokens.Consume(context.Background(), token.Purpose, token.Token); err != nil {
+			t.Fatalf("%s token revoked without an email change: %v", token.Purpose, err)
+		}
+	}
+
+	mailed = mailUserTokens(t, app, alice.userID)
+	alice.expect(http.StatusOK, http.MethodPatch, "/user/me",
+		map[string]string{"email": "alice@example.org", "currentPassword": "correct horse battery"}, &user)
+	if user.Email != "alice@example.org" || user.EmailVerifiedAt != nil {
+		t.Fatalf("profile after the email change = %+v, want alice@example.org unverified", user)
+	}
+
+	// Links mailed before the change no longer work.
+	newTestClient(t, app).expect(http.StatusBadRequest, http.MethodPost, "/user/password/reset",
+		map[string]string{"token": mailed[0].Token, "password": "another horse battery"}, nil)
+	newTestClient(t, app).expect(http.StatusBadRequest, http.MethodPost, "/user/verify-email",
+		map[string]string{"token": mailed[1].Token}, nil)
+}
+
+func TestChangeEmailWithMFA(t *testing.T) {
+	app := newTestApplication(t)
+	alice := loginTestUser(t, app, "alice")
+
+	var enrollment struct {
+		Secret string `json:"secret"`
+	}
+	alice.expect(http.StatusCreated, http.MethodPost, "/user/mfa/totp", nil, &enrollment)
+	var confirmed struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}
+	alice.expect(http.StatusOK, http.MethodPost, "/user/mfa/totp/confirm",
+		map[string]string{"code": totpCodeAt(t, enrollment.Secret, -1)}, &confirmed)
+
+	refused := []struct {
+		name    string
+		payload map[string]string
+		err     error
+	}{
+		{"password only", map[string]string{"email": "mallory@example.com", "currentPassword": "correct horse battery"}, errEmailNeedsMFA},
+		{"used code", map[string]string{"email": "mallory@example.com", "code": totpCodeAt(t, enrollment.Secret, -1)}, errInvalidMFACode},
+		{"wrong recovery code", map[string]string{"email": "mallory@example.com", "recoveryCode": "not-a-code"}, errInvalidMFACode},
+	}
+	for _, tt := range refused {
+		w := alice.do(http.MethodPatch, "/user/me", tt.payload)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.err.Error()) {
+			t.Fatalf("%s: PATCH /user/me = %d %s, want 400 %q", tt.name, w.Code, w.Body, tt.err)
+		}
+	}
+
+	var user store.User
+	alice.expect(http.StatusOK, http.MethodPatch, "/user/me",
+		map[string]string{"email": "alice@example.org", "code": totpCodeAt(t, enrollment.Secret, 0)}, &user)
+	if user.Email != "alice@example.org" {
+		t.Fatalf("email after the change = %q, want alice@example.org", user.Email)
+	}
+
+	recovery := map[string]string{"email": "alice@example.net", "recoveryCode": confirmed.RecoveryCodes[0]}
+	alice.expect(http.StatusOK, http.MethodPatch, "/user/me", recovery, &user)
+	if user.Email != "alice@example.net" {
+		t.Fatalf("email after the change = %q, want alice@example.net", user.Email)
+	}
+	recovery["email"] = "alice@example.com"
+	alice.expect(http.StatusBadRequest, http.MethodPatch, "/user/me", recovery, nil)
+}
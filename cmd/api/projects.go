@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"open-todo-go/internal/policy"
+	"open-todo-go/internal/store"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type projectKey string
+
+const projectCtx projectKey = "project"
+
+var (
+	errInvalidProject = errors.New("project not found")
+	errProjectOwner   = errors.New("a todo can only be in projects of the todo's owner")
+	errSubtaskProject = errors.New("a subtask is in its parent's project; move it to change its project")
+)
+
+type CreateProjectPayload struct {
+	Name  string `json:"name" validate:"required,max=100"`
+	Color string `json:"color" validate:"omitempty,hexcolor,max=7"`
+}
+
+type UpdateProjectPayload struct {
+	Name     *string `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	Color    *string `json:"color,omitempty" validate:"omitempty,hexcolor,max=7"`
+	Archived *bool   `json:"archived,omitempty"`
+	Position *int64  `json:"position,omitempty" validate:"omitempty,min=0"`
+}
+
+func (app *application) CreateProject(w http.ResponseWriter, r *http.Request) {
+	var payload CreateProjectPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	project := &store.Project{
+		UserID: getUserIdFromContext(r),
+		Name:   payload.Name,
+		Color:  payload.Color,
+	}
+	if err := app.store.Projects.Create(r.Context(), project); err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to create project: %w", err))
+		return
+	}
+
+	app.jsonResponse(w, http.StatusCreated, project)
+}
+
+// GetProjects lists the user's projects in their display order. Archived
+// projects are included when the archived query parameter is true.
+func (app *application) GetProjects(w http.ResponseWriter, r *http.Request) {
+	archived := false
+	if v := r.URL.Query().Get("archived"); v != "" {
+		var err error
+		if archived, err = strconv.ParseBool(v); err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid archived: %w", err))
+			return
+		}
+	}
+
+	projects, err := app.store.Projects.GetByUser(r.Context(), getUserIdFromContext(r), archived)
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to fetch projects: %w", err))
+		return
+	}
+	respondJSON(w, projects)
+}
+
+func (app *application) GetProject(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, getProjectFromContext(r))
+}
+
+func (app *application) UpdateProject(w http.ResponseWriter, r *http.Request) {
+	project := getProjectFromContext(r)
+	if err := policy.Project(getUserFromContext(r), project, policy.Manage); err != nil {
+		app.policyErrorResponse(w, r, err)
+		return
+	}
+
+	var payload UpdateProjectPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if payload.Name != nil {
+		updates["name"] = *payload.Name
+	}
+	if payload.Color != nil {
+		updates["color"] = *payload.Color
+	}
+	if payload.Archived != nil {
+		updates["archived"] = *payload.Archived
+	}
+	if payload.Position != nil {
+		updates["position"] = *payload.Position
+	}
+	if len(updates) == 0 {
+		app.badRequestResponse(w, r, fmt.Errorf("no fields to update"))
+		return
+	}
+
+	ctx := r.Context()
+	if err := app.store.Projects.Update(ctx, project.UserID, project.ID, updates); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to update project: %w", err))
+		}
+		return
+	}
+
+	updated, err := app.store.Projects.GetByID(ctx, project.UserID, project.ID)
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to fetch updated project: %w", err))
+		return
+	}
+	app.jsonResponse(w, http.StatusOK, updated)
+}
+
+// DeleteProject deletes the project. Its todos are kept without a project.
+func (app *application) DeleteProject(w http.ResponseWriter, r *http.Request) {
+	project := getProjectFromContext(r)
+	if err := policy.Project(getUserFromContext(r), project, policy.Manage); err != nil {
+		app.policyErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Projects.Delete(r.Context(), project.UserID, project.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to delete project: %w", err))
+		}
+		return
+	}
+	app.jsonResponse(w, http.StatusOK, nil)
+}
+
+// GetProjectTodos returns a page of the project's todos, taking the same
+// query parameters as GetAllTodos.
+func (app *application) GetProjectTodos(w http.ResponseWriter, r *http.Request) {
+	project := getProjectFromContext(r)
+
+	filter, err := parseTodoFilter(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(filter); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	filter.ProjectID = &project.ID
+	page, err := app.store.Todos.ListTodos(r.Context(), project.UserID, filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to fetch todos: %w", err))
+		}
+		return
+	}
+	respondJSON(w, page)
+}
+
+// projectsContextMiddleware loads the project named by the {projectID} URL
+// param, if the authenticated user owns it or is a member, and stores it on
+// the request context.
+func (app *application) projectsContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		projectID, err := strconv.ParseInt(chi.URLParam(r, "projectID"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid project ID: %w", err))
+			return
+		}
+
+		ctx := r.Context()
+		user := getUserFromContext(r)
+
+		project, err := app.store.Projects.GetByID(ctx, user.ID, projectID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		if err := policy.Project(user, project, policy.Read); err != nil {
+			app.policyErrorResponse(w, r, err)
+			return
+		}
+		if err := checkTokenProject(r, &project.ID); err != nil {
+			app.policyErrorResponse(w, r, err)
+			return
+		}
+
+		ctx = context.WithValue(ctx, projectCtx, project)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// getWritableProject loads a project the request's user may add todos to.
+func (app *application) getWritableProject(r *http.Request, projectID int64) (*store.Project, error) {
+	user := getUserFromContext(r)
+	project, err := app.store.Projects.GetByID(r.Context(), user.ID, projectID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, errInvalidProject
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := policy.Project(user, project, policy.Write); err != nil {
+		return nil, err
+	}
+	if err := checkTokenProject(r, &project.ID); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+func getProjectFromContext(r *http.Request) *store.Project {
+	project, _ := r.Context().Value(projectCtx).(*store.Project)
+	return project
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"open-todo-go/internal/recurrence"
+	"open-todo-go/internal/store"
+	"strconv"
+	"time"
+)
+
+type OccurrencesResponse struct {
+	Recurrence  string      `json:"recurrence"`
+	Timezone    string      `json:"timezone"`
+	Occurrences []time.Time `json:"occurrences"`
+}
+
+// GetOccurrences previews the next occurrences of a recurring todo after
+// its current due date. The count query parameter defaults to 5, at most 50.
+func (app *application) GetOccurrences(w http.ResponseWriter, r *http.Request) {
+	todo := getTodoFromContext(r)
+	if todo.Recurrence == "" || todo.DueAt == nil {
+		app.badRequestResponse(w, r, fmt.Errorf("todo %d does not recur", todo.ID))
+		return
+	}
+
+	count := 5
+	if v := r.URL.Query().Get("count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 50 {
+			app.badRequestResponse(w, r, fmt.Errorf("count must be between 1 and 50"))
+			return
+		}
+		count = n
+	}
+
+	occurrences, err := recurrence.Preview(todo.Recurrence, *todo.DueAt, todo.Location(), count)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	respondJSON(w, OccurrencesResponse{
+		Recurrence:  todo.Recurrence,
+		Timezone:    todo.Location().String(),
+		Occurrences: occurrences,
+	})
+}
+
+// spawnNextOccurrence creates the instance of a recurring todo that follows
+// the just completed todo. It returns nil when the series has ended.
+func (app *application) spawnNextOccurrence(ctx context.Context, todo *store.Todo) (*store.Todo, error) {
+	if todo.DueAt == nil {
+		return nil, nil
+	}
+
+	dueAt, rule, ok, err := recurrence.Next(todo.Recurrence, *todo.DueAt, todo.Location())
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	next := &store.Todo{
+		UserID:               todo.UserID,
+		Title:                todo.Title,
+		Description:          todo.Description,
+		Priority:             todo.Priority,
+		Tags:                 todo.Tags,
+		DueAt:                &dueAt,
+		Timezone:             todo.Timezone,
+		Recurrence:           rule,
+		ProjectID:            todo.ProjectID,
+		ParentID:             todo.ParentID,
+		CompleteWithChildren: todo.CompleteWithChildren,
+	}
+	if todo.StartAt != nil {
+		startAt := dueAt.Add(todo.StartAt.Sub(*todo.DueAt))
+		next.StartAt = &startAt
+	}
+
+	if err := app.store.Todos.Create(ctx, next); err != nil {
+		return nil, err
+	}
+	app.recordTodoVersion(ctx, nil, store.TodoCreated, nil, next)
+
+	return next, nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"open-todo-go/internal/store"
+)
+
+func TestNextOccurrenceStaysInProject(t *testing.T) {
+	app := newTestApplication(t)
+	alice := loginTestUser(t, app, "alice")
+	bob := loginTestUser(t, app, "bob")
+	project := shareProject(t, alice, bob, "bob@example.com", store.RoleEditor)
+
+	var parent, todo store.Todo
+	alice.expect(http.StatusCreated, http.MethodPost, "/todos/create",
+		map[string]any{"title": "chores", "projectID": project.ID}, &parent)
+	dueAt := time.Date(2030, 1, 6, 9, 0, 0, 0, time.UTC)
+	alice.expect(http.StatusCreated, http.MethodPost, "/todos/create", map[string]any{
+		"title":      "water plants",
+		"dueAt":      dueAt,
+		"recurrence": "FREQ=WEEKLY",
+		"projectID":  project.ID,
+		"parentID":   parent.ID,
+	}, &todo)
+
+	// Bob completes it, as a member of the project.
+	var updated UpdateTodoResponse
+	bob.expect(http.StatusOK, http.MethodPut, fmt.Sprintf("/todos/update/%d", todo.ID),
+		map[string]any{"completed": true}, &updated)
+	next := updated.Next
+	if next == nil {
+		t.Fatal("completing a recurring todo scheduled no next occurrence")
+	}
+	if next.ProjectID == nil || *next.ProjectID != project.ID {
+		t.Fatalf("next occurrence project = %v, want %d", next.ProjectID, project.ID)
+	}
+	if next.ParentID == nil || *next.ParentID != parent.ID {
+		t.Fatalf("next occurrence parent = %v, want %d", next.ParentID, parent.ID)
+	}
+	if next.UserID != alice.userID || next.DueAt == nil || !next.DueAt.Equal(dueAt.AddDate(0, 0, 7)) {
+		t.Fatalf("next occurrence = %+v", next)
+	}
+
+	var got store.Todo
+	bob.expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/todos/%d", next.ID), nil, &got)
+	if got.ID != next.ID {
+		t.Fatalf("bob GET next occurrence = %+v", got)
+	}
+}
+
+// failingCreate is a memory todo store whose Create always fails.
+type failingCreate struct {
+	*store.MemoryTodosStore
+}
+
+func (failingCreate) Create(context.Context, *store.Todo) error {
+	return errors.New("create failed")
+}
+
+// TestCompleteRecurringTodoWhenSchedulingFails checks that a completion
+// that was saved is reported as such even if the next occurrence could not
+// be created.
+func TestCompleteRecurringTodoWhenSchedulingFails(t *testing.T) {
+	app := newTestApplication(t)
+	alice := loginTestUser(t, app, "alice")
+
+	var todo store.Todo
+	alice.expect(http.StatusCreated, http.MethodPost, "/todos/create", map[string]any{
+		"title":      "water plants",
+		"dueAt":      time.Date(2030, 1, 6, 9, 0, 0, 0, time.UTC),
+		"recurrence": "FREQ=WEEKLY",
+	}, &todo)
+
+	app.store.Todos = failingCreate{app.store.Todos.(*store.MemoryTodosStore)}
+
+	var updated UpdateTodoResponse
+	alice.expect(http.StatusOK, http.MethodPut, fmt.Sprintf("/todos/update/%d", todo.ID),
+		map[string]any{"completed": true}, &updated)
+	if updated.Todo == nil || !updated.Todo.Completed || updated.Next != nil {
+		t.Fatalf("update response = %+v", updated)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"open-todo-go/internal/policy"
+	"open-todo-go/internal/store"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type CreateReminderPayload struct {
+	RemindAt time.Time `json:"remindAt" validate:"required"`
+}
+
+func (app *application) CreateReminder(w http.ResponseWriter, r *http.Request) {
+	if err := app.authorizeTodo(r, policy.Write); err != nil {
+		app.policyErrorResponse(w, r, err)
+		return
+	}
+
+	var payload CreateReminderPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !payload.RemindAt.After(time.Now()) {
+		app.badRequestResponse(w, r, fmt.Errorf("remindAt must be in the future"))
+		return
+	}
+
+	todo := getTodoFromContext(r)
+	reminder := &store.Reminder{
+		TodoID:   todo.ID,
+		UserID:   todo.UserID,
+		RemindAt: payload.RemindAt,
+	}
+	if err := app.store.Reminders.Create(r.Context(), reminder); err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to create reminder: %w", err))
+		return
+	}
+
+	app.jsonResponse(w, http.StatusCreated, reminder)
+}
+
+func (app *application) GetReminders(w http.ResponseWriter, r *http.Request) {
+	todo := getTodoFromContext(r)
+
+	reminders, err := app.store.Reminders.GetByTodo(r.Context(), todo.UserID, todo.ID)
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to fetch reminders: %w", err))
+		return
+	}
+	respondJSON(w, reminders)
+}
+
+func (app *application) DeleteReminder(w http.ResponseWriter, r *http.Request) {
+	if err := app.authorizeTodo(r, policy.Write); err != nil {
+		app.policyErrorResponse(w, r, err)
+		return
+	}
+
+	reminderID, err := strconv.ParseInt(chi.URLParam(r, "reminderID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid reminder ID: %w", err))
+		return
+	}
+
+	todo := getTodoFromContext(r)
+	if err := app.store.Reminders.Delete(r.Context(), todo.UserID, todo.ID, reminderID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to delete reminder: %w", err))
+		}
+		return
+	}
+	app.jsonResponse(w, http.StatusOK, nil)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"open-todo-go/internal/policy"
+	"open-todo-go/internal/store"
+)
+
+type MoveTodoPayload struct {
+	// ParentID is the todo to move under; null moves the todo to the top
+	// level.
+	ParentID *int64 `json:"parentID"`
+}
+
+// GetSubtree returns the todo with its subtasks nested under it.
+func (app *application) GetSubtree(w http.ResponseWriter, r *http.Request) {
+	todo := getTodoFromContext(r)
+
+	todos, err := app.store.Todos.GetSubtree(r.Context(), todo.UserID, todo.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to fetch subtree: %w", err))
+		}
+		return
+	}
+	respondJSON(w, store.BuildTodoTree(todos, todo.ID))
+}
+
+// MoveTodo moves a todo, along with its subtasks, under another todo.
+func (app *application) MoveTodo(w http.ResponseWriter, r *http.Request) {
+	todo := getTodoFromContext(r)
+	if err := app.authorizeTodo(r, policy.Write); err != nil {
+		app.policyErrorResponse(w, r, err)
+		return
+	}
+
+	var payload MoveTodoPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	if payload.ParentID != nil {
+		if _, err := app.getWritableParent(r, *payload.ParentID); err != nil {
+			app.placementErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if err := app.store.Todos.MoveTodo(ctx, todo.UserID, todo.ID, payload.ParentID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrInvalidParent):
+			app.badRequestResponse(w, r, err)
+		case errors.Is(err, store.ErrCycle):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to move todo: %w", err))
+		}
+		return
+	}
+
+	moved, err := app.store.Todos.GetTodoByID(ctx, todo.UserID, todo.ID)
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to fetch moved todo: %w", err))
+		return
+	}
+	app.recordTodoChange(r, store.TodoUpdated, todo, moved)
+
+	// A completed todo moved under a parent may have been its last open
+	// subtask.
+	if moved.Completed {
+		if _, err := app.completeParents(ctx, moved); err != nil {
+			app.internalServerError(w, r, fmt.Errorf("failed to complete parent todos: %w", err))
+			return
+		}
+	}
+
+	app.jsonResponse(w, http.StatusOK, moved)
+}
+
+// getWritableParent loads a todo the request's user may add subtasks to.
+func (app *application) getWritableParent(r *http.Request, parentID int64) (*store.Todo, error) {
+	user := getUserFromContext(r)
+	parent, role, err := app.getTodoForUser(r.Context(), user, parentID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, store.ErrInvalidParent
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := policy.Todo(user, parent, role, policy.Write); err != nil {
+		return nil, err
+	}
+	if err := checkTokenProject(r, parent.ProjectID); err != nil {
+		return nil, err
+	}
+	return parent, nil
+}
+
+// completeParents walks up from todo and marks each parent that has
+// CompleteWithChildren set completed once all of its direct subtasks are.
+// It returns the parents it completed, nearest first.
+func (app *application) completeParents(ctx context.Context, todo *store.Todo) ([]store.Todo, error) {
+	var completed []store.Todo
+
+	for todo.ParentID != nil {
+		parent, err := app.store.Todos.GetTodoByID(ctx, todo.UserID, *todo.ParentID)
+		if err != nil {
+			return completed, err
+		}
+		if parent.Completed || !parent.CompleteWithChildren {
+			break
+		}
+
+		open, err := app.store.Todos.CountOpenSubtasks(ctx, parent.UserID, parent.ID)
+		if err != nil {
+			return completed, err
+		}
+		if open > 0 {
+			break
+		}
+
+		err = app.store.Todos.UpdateTodo(ctx, parent.UserID, parent.ID, map[string]interface{}{"completed": true})
+		if err != nil {
+			return completed, err
+		}
+
+		if todo, err = app.store.Todos.GetTodoByID(ctx, parent.UserID, parent.ID); err != nil {
+			return completed, err
+		}
+		app.recordTodoVersion(ctx, nil, store.TodoUpdated, parent, todo)
+		completed = append(completed, *todo)
+	}
+
+	return completed, nil
+}
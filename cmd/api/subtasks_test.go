@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"open-todo-go/internal/store"
+)
+
+func TestCompleteParents(t *testing.T) {
+	app := newTestApplication(t)
+	alice := loginTestUser(t, app, "alice")
+
+	create := func(title string, parentID *int64) store.Todo {
+		t.Helper()
+		var todo store.Todo
+		alice.expect(http.StatusCreated, http.MethodPost, "/todos/create",
+			map[string]any{"title": title, "parentID": parentID, "completeWithChildren": true}, &todo)
+		return todo
+	}
+	complete := func(todo store.Todo) []store.Todo {
+		t.Helper()
+		var updated UpdateTodoResponse
+		alice.expect(http.StatusOK, http.MethodPut, fmt.Sprintf("/todos/update/%d", todo.ID),
+			map[string]any{"completed": true}, &updated)
+		return updated.CompletedParents
+	}
+
+	root := create("root", nil)
+	parent := create("parent", &root.ID)
+	first := create("first", &parent.ID)
+	second := create("second", &parent.ID)
+	trashed := create("trashed", &parent.ID)
+	alice.expect(http.StatusOK, http.MethodDelete, fmt.Sprintf("/todos/delete/%d", trashed.ID), nil, nil)
+
+	if parents := complete(first); len(parents) != 0 {
+		t.Fatalf("completing one of two subtasks completed %+v", parents)
+	}
+	parents := complete(second)
+	if len(parents) != 2 || parents[0].ID != parent.ID || parents[1].ID != root.ID || !parents[1].Completed {
+		t.Fatalf("completing the last subtask completed %+v, want parent then root", parents)
+	}
+}
+
+// TestSubtasksShareParentProject checks that members of a shared project
+// never reach subtasks kept out of it, through the subtree or by deleting
+// their parent.
+func TestSubtasksShareParentProject(t *testing.T) {
+	app := newTestApplication(t)
+	alice := loginTestUser(t, app, "alice")
+	bob := loginTestUser(t, app, "bob")
+	shared := shareProject(t, alice, bob, "bob@example.com", store.RoleEditor)
+
+	var private store.Project
+	alice.expect(http.StatusCreated, http.MethodPost, "/projects/", map[string]any{"name": "Private"}, &private)
+
+	var parent, child store.Todo
+	alice.expect(http.StatusCreated, http.MethodPost, "/todos/create",
+		map[string]any{"title": "parent", "projectID": shared.ID}, &parent)
+	alice.expect(http.StatusBadRequest, http.MethodPost, "/todos/create",
+		map[string]any{"title": "SECRET child", "parentID": parent.ID, "projectID": private.ID}, nil)
+	alice.expect(http.StatusCreated, http.MethodPost, "/todos/create",
+		map[string]any{"title": "child", "parentID": parent.ID}, &child)
+	if child.ProjectID == nil || *child.ProjectID != shared.ID {
+		t.Fatalf("child project = %v, want %d", child.ProjectID, shared.ID)
+	}
+
+	for _, projectID := range []int64{private.ID, 0} {
+		alice.expect(http.StatusBadRequest, http.MethodPut, fmt.Sprintf("/todos/update/%d", child.ID),
+			map[string]any{"projectID": projectID}, nil)
+	}
+
+	var moved store.Todo
+	alice.expect(http.StatusCreated, http.MethodPost, "/todos/create",
+		map[string]any{"title": "moved in", "projectID": private.ID}, &moved)
+	alice.expect(http.StatusOK, http.MethodPut, fmt.Sprintf("/todos/%d/move", moved.ID),
+		map[string]any{"parentID": parent.ID}, nil)
+
+	// Everything bob sees in the subtree is in the shared project.
+	var tree store.TodoNode
+	bob.expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/todos/%d/subtree", parent.ID), nil, &tree)
+	nodes := []*store.TodoNode{&tree}
+	for i := 0; i < len(nodes); i++ {
+		if nodes[i].ProjectID == nil || *nodes[i].ProjectID != shared.ID {
+			t.Fatalf("bob sees %q from project %v in the subtree", nodes[i].Title, nodes[i].ProjectID)
+		}
+		nodes = append(nodes, nodes[i].Children...)
+	}
+	if len(nodes) != 3 {
+		t.Fatalf("subtree has %d todos, want 3", len(nodes))
+	}
+
+	// Moving the parent out of the project takes its subtasks along.
+	alice.expect(http.StatusOK, http.MethodPut, fmt.Sprintf("/todos/update/%d", parent.ID),
+		map[string]any{"projectID": private.ID}, nil)
+	for _, todo := range []store.Todo{parent, child, moved} {
+		bob.expect(http.StatusNotFound, http.MethodGet, fmt.Sprintf("/todos/%d", todo.ID), nil, nil)
+	}
+	bob.expect(http.StatusNotFound, http.MethodGet, fmt.Sprintf("/todos/%d/subtree", parent.ID), nil, nil)
+	bob.expect(http.StatusNotFound, http.MethodDelete, fmt.Sprintf("/todos/delete/%d", parent.ID), nil, nil)
+
+	// Deleting a shared parent only trashes todos bob could see.
+	var other store.Todo
+	alice.expect(http.StatusCreated, http.MethodPost, "/todos/create",
+		map[string]any{"title": "other parent", "projectID": shared.ID}, &other)
+	bob.expect(http.StatusOK, http.MethodDelete, fmt.Sprintf("/todos/delete/%d", other.ID), nil, nil)
+	for _, todo := range []store.Todo{parent, child, moved} {
+		alice.expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/todos/%d", todo.ID), nil, nil)
+	}
+}
+
+// TestRemoveFromProjectIsOwnerOnly checks that members cannot hide a todo
+// from the project by taking it out.
+func TestRemoveFromProjectIsOwnerOnly(t *testing.T) {
+	app := newTestApplication(t)
+	alice := loginTestUser(t, app, "alice")
+	bob := loginTestUser(t, app, "bob")
+	shared := shareProject(t, alice, bob, "bob@example.com", store.RoleEditor)
+
+	var todo store.Todo
+	alice.expect(http.StatusCreated, http.MethodPost, "/todos/create",
+		map[string]any{"title": "shared", "projectID": shared.ID}, &todo)
+	path := fmt.Sprintf("/todos/update/%d", todo.ID)
+
+	bob.expect(http.StatusOK, http.MethodPut, path, map[string]any{"title": "edited by bob"}, nil)
+	bob.expect(http.StatusForbidden, http.MethodPut, path, map[string]any{"projectID": 0}, nil)
+	bob.expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/todos/%d", todo.ID), nil, nil)
+
+	var updated UpdateTodoResponse
+	alice.expect(http.StatusOK, http.MethodPut, path, map[string]any{"projectID": 0}, &updated)
+	if updated.Todo.ProjectID != nil {
+		t.Fatalf("project after removal = %v, want none", *updated.Todo.ProjectID)
+	}
+	bob.expect(http.StatusNotFound, http.MethodGet, fmt.Sprintf("/todos/%d", todo.ID), nil, nil)
+}
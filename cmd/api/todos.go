@@ -1,29 +1,63 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"open-todo-go/internal/policy"
 	"open-todo-go/internal/store"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/lib/pq"
 )
 
+type todoKey string
+
+const (
+	todoCtx     todoKey = "todo"
+	todoRoleCtx todoKey = "todoRole"
+)
+
+type UpdateTodoResponse struct {
+	Todo             *store.Todo  `json:"todo"`
+	Next             *store.Todo  `json:"next,omitempty"`
+	CompletedParents []store.Todo `json:"completedParents,omitempty"`
+}
+
 type CreateTodoPayload struct {
-	Title       string   `json:"title" validate:"required"`
-	Description string   `json:"description"`
-	Priority    int16    `json:"priority" validate:"min=0,max=5"`
-	Completed   bool     `json:"completed"`
-	Tags        []string `json:"tags"`
+	Title       string     `json:"title" validate:"required"`
+	Description string     `json:"description"`
+	Priority    int16      `json:"priority" validate:"min=0,max=5"`
+	Completed   bool       `json:"completed"`
+	Tags        []string   `json:"tags"`
+	StartAt     *time.Time `json:"startAt"`
+	DueAt       *time.Time `json:"dueAt"`
+	Timezone    string     `json:"timezone" validate:"omitempty,timezone"`
+	Recurrence  string     `json:"recurrence" validate:"omitempty,max=255,rrule"`
+	ProjectID   *int64     `json:"projectID"`
+	ParentID    *int64     `json:"parentID"`
+	// CompleteWithChildren completes the todo once all its subtasks are.
+	CompleteWithChildren bool `json:"completeWithChildren"`
 }
 
 type UpdatedTodoPayload struct {
-	Title       *string  `json:"title,omitempty"`
-	Description *string  `json:"description,omitempty"`
-	Priority    *int64   `json:"priority,omitempty"`
-	Completed   *bool    `json:"completed,omitempty"`
-	Tags        []string `json:"tags,omitempty"`
+	Title       *string    `json:"title,omitempty"`
+	Description *string    `json:"description,omitempty"`
+	Priority    *int64     `json:"priority,omitempty"`
+	Completed   *bool      `json:"completed,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	StartAt     *time.Time `json:"startAt,omitempty"`
+	DueAt       *time.Time `json:"dueAt,omitempty"`
+	Timezone    *string    `json:"timezone,omitempty" validate:"omitempty,timezone"`
+	Recurrence  *string    `json:"recurrence,omitempty" validate:"omitempty,max=255,rrule"`
+	// ProjectID moves the todo to another project; 0 removes it from its
+	// project.
+	ProjectID *int64 `json:"projectID,omitempty" validate:"omitempty,min=0"`
+	// CompleteWithChildren completes the todo once all its subtasks are.
+	CompleteWithChildren *bool `json:"completeWithChildren,omitempty"`
 }
 
 func (app *application) CreateTodo(w http.ResponseWriter, r *http.Request) {
@@ -31,58 +65,194 @@ func (app *application) CreateTodo(w http.ResponseWriter, r *http.Request) {
 
 	if err := readJSON(w, r, &payload); err != nil {
 		app.badRequestResponse(w, r, err)
+		return
 	}
 
 	if err := Validate.Struct(payload); err != nil {
 		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validateTodoDates(payload.StartAt, payload.DueAt, payload.Recurrence); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+	ownerID, projectID := user.ID, payload.ProjectID
+
+	// Subtasks belong to the owner of their parent and are in its project,
+	// so whoever can see the parent can see its whole subtree.
+	if payload.ParentID != nil {
+		parent, err := app.getWritableParent(r, *payload.ParentID)
+		if err == nil && projectID != nil && !sameProject(projectID, parent.ProjectID) {
+			err = errSubtaskProject
+		}
+		if err != nil {
+			app.placementErrorResponse(w, r, err)
+			return
+		}
+		ownerID, projectID = parent.UserID, parent.ProjectID
+	}
+
+	// Todos in a project belong to the project's owner, whoever adds them.
+	if projectID == nil {
+		if err := checkTokenProject(r, nil); err != nil {
+			app.placementErrorResponse(w, r, err)
+			return
+		}
+	} else {
+		project, err := app.getWritableProject(r, *projectID)
+		if err == nil && payload.ParentID != nil && project.UserID != ownerID {
+			err = errProjectOwner
+		}
+		if err != nil {
+			app.placementErrorResponse(w, r, err)
+			return
+		}
+		ownerID = project.UserID
 	}
 
-	userID := getUserIdFromContext(r)
 	todo := &store.Todo{
-		UserID:      userID,
-		Title:       payload.Title,
-		Description: payload.Description,
-		Priority:    payload.Priority,
-		Completed:   payload.Completed,
-		Tags:        payload.Tags,
-	}
-	if err := app.store.Todos.Create(r.Context(), todo); err != nil {
+		UserID:               ownerID,
+		Title:                payload.Title,
+		Description:          payload.Description,
+		Priority:             payload.Priority,
+		Completed:            payload.Completed,
+		Tags:                 payload.Tags,
+		StartAt:              payload.StartAt,
+		DueAt:                payload.DueAt,
+		Timezone:             payload.Timezone,
+		Recurrence:           payload.Recurrence,
+		ProjectID:            projectID,
+		ParentID:             payload.ParentID,
+		CompleteWithChildren: payload.CompleteWithChildren,
+	}
+	if err := app.store.Todos.Create(ctx, todo); err != nil {
 		app.badRequestResponse(w, r, fmt.Errorf("failed to create todo: %w", err))
 		return
 	}
+	app.recordTodoChange(r, store.TodoCreated, nil, todo)
+
+	app.jsonResponse(w, http.StatusCreated, todo)
 }
 
+// GetAllTodos returns a page of the user's todos. See store.TodoFilter for
+// the supported query parameters.
 func (app *application) GetAllTodos(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseTodoFilter(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(filter); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
 	userID := getUserIdFromContext(r)
-	todos, err := app.store.Todos.GetAllTodos(r.Context(), userID)
+	page, err := app.store.Todos.ListTodos(r.Context(), userID, filter)
 	if err != nil {
-		http.Error(w, "Failed to fetch todos", http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			app.badRequestResponse(w, r, err)
+		default:
+			http.Error(w, "Failed to fetch todos", http.StatusInternalServerError)
+		}
 		return
 	}
-	respondJSON(w, todos)
+	respondJSON(w, page)
 }
 
-func (app *application) GetTodoById(w http.ResponseWriter, r *http.Request) {
-	idParam := chi.URLParam(r, "todoID")
-	todoID, err := strconv.ParseInt(idParam, 10, 64)
+// SearchTodos runs a full-text search over the user's todos, given as the
+// q query parameter.
+func (app *application) SearchTodos(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" || len(q) > 200 {
+		app.badRequestResponse(w, r, fmt.Errorf("q must be between 1 and 200 characters"))
+		return
+	}
+
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			app.badRequestResponse(w, r, fmt.Errorf("limit must be between 1 and 100"))
+			return
+		}
+		limit = n
+	}
+
+	results, err := app.store.Todos.SearchTodos(r.Context(), getUserIdFromContext(r), q, limit)
 	if err != nil {
-		app.badRequestResponse(w, r, fmt.Errorf("failed to parse id: %w", err))
+		app.internalServerError(w, r, fmt.Errorf("failed to search todos: %w", err))
 		return
 	}
+	respondJSON(w, results)
+}
 
-	todo, err := app.store.Todos.GetTodoByID(r.Context(), todoID)
+// GetOverdueTodos lists incomplete todos whose due date has passed.
+func (app *application) GetOverdueTodos(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	app.respondTodosDue(w, r, nil, &now)
+}
 
+// GetTodosDueToday lists incomplete todos due during the current day in the
+// time zone given by the tz query parameter, UTC by default.
+func (app *application) GetTodosDueToday(w http.ResponseWriter, r *http.Request) {
+	loc := time.UTC
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid tz: %w", err))
+			return
+		}
+	}
+
+	now := time.Now().In(loc)
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	end := start.AddDate(0, 0, 1)
+	app.respondTodosDue(w, r, &start, &end)
+}
+
+// GetUpcomingTodos lists incomplete todos due within the next days (7 by
+// default, at most 90).
+func (app *application) GetUpcomingTodos(w http.ResponseWriter, r *http.Request) {
+	days := 7
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 90 {
+			app.badRequestResponse(w, r, fmt.Errorf("days must be between 1 and 90"))
+			return
+		}
+		days = n
+	}
+
+	now := time.Now()
+	end := now.AddDate(0, 0, days)
+	app.respondTodosDue(w, r, &now, &end)
+}
+
+func (app *application) respondTodosDue(w http.ResponseWriter, r *http.Request, from, to *time.Time) {
+	todos, err := app.store.Todos.GetTodosDue(r.Context(), getUserIdFromContext(r), from, to)
 	if err != nil {
-		http.Error(w, "Failed to fetch todo id", http.StatusInternalServerError)
+		app.internalServerError(w, r, fmt.Errorf("failed to fetch due todos: %w", err))
 		return
 	}
+	respondJSON(w, todos)
+}
+
+func (app *application) GetTodoById(w http.ResponseWriter, r *http.Request) {
+	todo := getTodoFromContext(r)
 	respondJSON(w, todo)
 }
 
 func (app *application) UpdateTodo(w http.ResponseWriter, r *http.Request) {
-	todoID, err := strconv.ParseInt(chi.URLParam(r, "todoID"), 10, 64)
-	if err != nil {
-		app.badRequestResponse(w, r, fmt.Errorf("invalid todo ID: %w", err))
+	todo := getTodoFromContext(r)
+	if err := app.authorizeTodo(r, policy.Write); err != nil {
+		app.policyErrorResponse(w, r, err)
 		return
 	}
 
@@ -97,32 +267,229 @@ func (app *application) UpdateTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	startAt, dueAt, rule := todo.StartAt, todo.DueAt, todo.Recurrence
+	if payload.StartAt != nil {
+		startAt = payload.StartAt
+	}
+	if payload.DueAt != nil {
+		dueAt = payload.DueAt
+	}
+	if payload.Recurrence != nil {
+		rule = *payload.Recurrence
+	}
+	if err := validateTodoDates(startAt, dueAt, rule); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Subtasks follow their parent, and moving a todo moves its subtasks
+	// with it.
+	switch {
+	case payload.ProjectID == nil:
+	case todo.ParentID != nil && !sameProject(todo.ProjectID, projectIDOrNil(*payload.ProjectID)):
+		app.badRequestResponse(w, r, errSubtaskProject)
+		return
+	case *payload.ProjectID == 0:
+		// Only the owner may take a todo out of a project the members can
+		// see it in.
+		if err := app.authorizeTodo(r, policy.Manage); err != nil {
+			app.policyErrorResponse(w, r, err)
+			return
+		}
+		if err := checkTokenProject(r, nil); err != nil {
+			app.placementErrorResponse(w, r, err)
+			return
+		}
+	default:
+		project, err := app.getWritableProject(r, *payload.ProjectID)
+		if err == nil && project.UserID != todo.UserID {
+			err = errProjectOwner
+		}
+		if err != nil {
+			app.placementErrorResponse(w, r, err)
+			return
+		}
+	}
+
 	updates := buildUpdatesMap(payload)
 
-	if err := app.store.Todos.UpdateTodo(r.Context(), todoID, updates); err != nil {
-		app.internalServerError(w, r, fmt.Errorf("failed to update todo: %w", err))
+	if err := app.store.Todos.UpdateTodo(r.Context(), todo.UserID, todo.ID, updates); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to update todo: %w", err))
+		}
 		return
 	}
 
-	app.jsonResponse(w, http.StatusOK, nil)
+	updated, err := app.store.Todos.GetTodoByID(r.Context(), todo.UserID, todo.ID)
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to fetch updated todo: %w", err))
+		return
+	}
+	app.recordTodoChange(r, store.TodoUpdated, todo, updated)
+
+	// Completing an instance of a recurring todo schedules the next one. The
+	// update is saved by then, so failing to schedule it is logged rather
+	// than reported as a failed update.
+	var next *store.Todo
+	if updated.Completed && !todo.Completed && updated.Recurrence != "" {
+		if next, err = app.spawnNextOccurrence(r.Context(), updated); err != nil {
+			app.logger.Errorw("failed to schedule next occurrence", "todoID", updated.ID, "error", err)
+		}
+	}
+
+	// Completing the last open subtask may complete its parents.
+	var parents []store.Todo
+	if updated.Completed && !todo.Completed {
+		if parents, err = app.completeParents(r.Context(), updated); err != nil {
+			app.internalServerError(w, r, fmt.Errorf("failed to complete parent todos: %w", err))
+			return
+		}
+	}
+
+	app.jsonResponse(w, http.StatusOK, UpdateTodoResponse{Todo: updated, Next: next, CompletedParents: parents})
 }
 
+// DeleteTodo moves a todo and its subtasks to its owner's trash, from where
+// they can be restored until the trash is emptied.
 func (app *application) DeleteTodo(w http.ResponseWriter, r *http.Request) {
-	idParam, err := strconv.ParseInt(chi.URLParam(r, "todoID"), 10, 64)
-	if err != nil {
-		app.badRequestResponse(w, r, fmt.Errorf("invalid todo ID: %w", err))
+	todo := getTodoFromContext(r)
+	if err := app.authorizeTodo(r, policy.Write); err != nil {
+		app.policyErrorResponse(w, r, err)
 		return
 	}
 
-	error := app.store.Todos.DeleteTodo(r.Context(), idParam)
-
-	if error != nil {
-		app.internalServerError(w, r, fmt.Errorf("failed to delete todo: %w", err))
+	// Subtasks go with the todo, so their history records it too.
+	subtree, err := app.store.Todos.GetSubtree(r.Context(), todo.UserID, todo.ID)
+	if err == nil {
+		err = app.store.Todos.DeleteTodo(r.Context(), todo.UserID, todo.ID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to delete todo: %w", err))
+		}
 		return
 	}
+	for i := range subtree {
+		app.recordTodoChange(r, store.TodoDeleted, &subtree[i], nil)
+	}
 	app.jsonResponse(w, http.StatusOK, nil)
 }
 
+// placementErrorResponse answers a request that tried to put a todo under a
+// parent or into a project it cannot go to.
+func (app *application) placementErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, policy.ErrForbidden):
+		app.forbiddenResponse(w, r)
+	case errors.Is(err, store.ErrInvalidParent), errors.Is(err, errInvalidProject), errors.Is(err, errProjectOwner),
+		errors.Is(err, errSubtaskProject):
+		app.badRequestResponse(w, r, err)
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
+
+// sameProject reports whether a and b name the same project, or both none.
+func sameProject(a, b *int64) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+// projectIDOrNil turns the 0 that UpdatedTodoPayload.ProjectID uses for no
+// project into nil.
+func projectIDOrNil(id int64) *int64 {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+func validateTodoDates(startAt, dueAt *time.Time, recurrence string) error {
+	if startAt != nil && dueAt != nil && startAt.After(*dueAt) {
+		return fmt.Errorf("startAt must not be after dueAt")
+	}
+	if recurrence != "" && dueAt == nil {
+		return fmt.Errorf("recurring todos need a dueAt")
+	}
+	return nil
+}
+
+// todosContextMiddleware loads the todo named by the {todoID} URL param and
+// stores it, with the user's role in its project, on the request context.
+// Todos the user can neither see as owner nor as project member are
+// reported as not found.
+func (app *application) todosContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		todoID, err := strconv.ParseInt(chi.URLParam(r, "todoID"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid todo ID: %w", err))
+			return
+		}
+
+		ctx := r.Context()
+		user := getUserFromContext(r)
+
+		todo, role, err := app.getTodoForUser(ctx, user, todoID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		if err := policy.Todo(user, todo, role, policy.Read); err != nil {
+			app.policyErrorResponse(w, r, err)
+			return
+		}
+		if err := checkTokenProject(r, todo.ProjectID); err != nil {
+			app.policyErrorResponse(w, r, err)
+			return
+		}
+
+		ctx = context.WithValue(ctx, todoCtx, todo)
+		ctx = context.WithValue(ctx, todoRoleCtx, role)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// getTodoForUser loads a todo the user owns or that is in a project shared
+// with them, along with their role in the todo's project.
+func (app *application) getTodoForUser(ctx context.Context, user *store.User, todoID int64) (*store.Todo, store.Role, error) {
+	todo, err := app.store.Todos.GetTodoByID(ctx, user.ID, todoID)
+	if err == nil {
+		return todo, store.RoleOwner, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, "", err
+	}
+
+	todo, err = app.store.Todos.GetProjectTodo(ctx, user.ID, todoID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	role, err := app.store.Members.GetRole(ctx, *todo.ProjectID, user.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	return todo, role, nil
+}
+
+// authorizeTodo checks the todo loaded by todosContextMiddleware against
+// the policy for action.
+func (app *application) authorizeTodo(r *http.Request, action policy.Action) error {
+	role, _ := r.Context().Value(todoRoleCtx).(store.Role)
+	return policy.Todo(getUserFromContext(r), getTodoFromContext(r), role, action)
+}
+
 // Helper function to build the updates map from the payload
 func buildUpdatesMap(payload UpdatedTodoPayload) map[string]interface{} {
 	updates := make(map[string]interface{})
@@ -141,13 +508,45 @@ func buildUpdatesMap(payload UpdatedTodoPayload) map[string]interface{} {
 		updates["completed"] = *payload.Completed
 	}
 	if payload.Tags != nil {
-		updates["tags"] = pq.Array(payload.Tags)
+		updates["tags"] = payload.Tags
+	}
+	if payload.StartAt != nil {
+		updates["start_at"] = *payload.StartAt
+	}
+	if payload.DueAt != nil {
+		updates["due_at"] = *payload.DueAt
+	}
+	if payload.Timezone != nil {
+		updates["timezone"] = *payload.Timezone
+	}
+	if payload.Recurrence != nil {
+		updates["recurrence"] = *payload.Recurrence
+	}
+	if payload.ProjectID != nil {
+		if *payload.ProjectID == 0 {
+			updates["project_id"] = nil
+		} else {
+			updates["project_id"] = *payload.ProjectID
+		}
+	}
+	if payload.CompleteWithChildren != nil {
+		updates["complete_with_children"] = *payload.CompleteWithChildren
 	}
 
 	return updates
 }
 
 func getUserIdFromContext(r *http.Request) int64 {
-	userID := r.Context().Value(userCtx).(*store.User).ID
+	userID := getUserFromContext(r).ID
 	return userID
 }
+
+func getUserFromContext(r *http.Request) *store.User {
+	user, _ := r.Context().Value(userCtx).(*store.User)
+	return user
+}
+
+func getTodoFromContext(r *http.Request) *store.Todo {
+	todo, _ := r.Context().Value(todoCtx).(*store.Todo)
+	return todo
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"open-todo-go/internal/store"
+)
+
+func TestTodoCRUD(t *testing.T) {
+	app := newTestApplication(t)
+	alice := loginTestUser(t, app, "alice")
+
+	var created store.Todo
+	alice.expect(http.StatusCreated, http.MethodPost, "/todos/create",
+		map[string]any{"title": "write tests", "priority": 3, "tags": []string{"dev"}}, &created)
+	if created.ID == 0 || created.UserID != alice.userID || created.Title != "write tests" {
+		t.Fatalf("created todo = %+v", created)
+	}
+	path := fmt.Sprintf("/todos/%d", created.ID)
+
+	var got store.Todo
+	alice.expect(http.StatusOK, http.MethodGet, path, nil, &got)
+	if got.ID != created.ID || got.Priority != 3 || len(got.Tags) != 1 {
+		t.Fatalf("GET %s = %+v", path, got)
+	}
+
+	var page store.TodoPage
+	alice.expect(http.StatusOK, http.MethodGet, "/todos/", nil, &page)
+	if page.Total != 1 || len(page.Todos) != 1 || page.Todos[0].ID != created.ID {
+		t.Fatalf("todo list = %+v", page)
+	}
+
+	var updated UpdateTodoResponse
+	alice.expect(http.StatusOK, http.MethodPut, fmt.Sprintf("/todos/update/%d", created.ID),
+		map[string]any{"title": "write more tests", "completed": true}, &updated)
+	if updated.Todo.Title != "write more tests" || !updated.Todo.Completed {
+		t.Fatalf("updated todo = %+v", updated.Todo)
+	}
+
+	alice.expect(http.StatusOK, http.MethodDelete, fmt.Sprintf("/todos/delete/%d", created.ID), nil, nil)
+	alice.expect(http.StatusNotFound, http.MethodGet, path, nil, nil)
+
+	var trash []store.Todo
+	alice.expect(http.StatusOK, http.MethodGet, "/todos/trash", nil, &trash)
+	if len(trash) != 1 || trash[0].ID != created.ID {
+		t.Fatalf("trash = %+v", trash)
+	}
+
+	anonymous := newTestClient(t, app)
+	anonymous.expect(http.StatusUnauthorized, http.MethodGet, "/todos/", nil, nil)
+}
+
+// TestTodoCrossUserAccess checks that another user's todo looks the same as
+// one that does not exist, whatever is done to it.
+func TestTodoCrossUserAccess(t *testing.T) {
+	app := newTestApplication(t)
+	alice := loginTestUser(t, app, "alice")
+	bob := loginTestUser(t, app, "bob")
+
+	var todo store.Todo
+	alice.expect(http.StatusCreated, http.MethodPost, "/todos/create", map[string]any{"title": "alice's"}, &todo)
+	alice.expect(http.StatusOK, http.MethodPut, fmt.Sprintf("/todos/update/%d", todo.ID), map[string]any{"title": "alice's, edited"}, nil)
+	id := todo.ID
+
+	requests := []struct {
+		method, path string
+		body         any
+	}{
+		{http.MethodGet, fmt.Sprintf("/todos/%d", id), nil},
+		{http.MethodPut, fmt.Sprintf("/todos/update/%d", id), map[string]any{"title": "bob was here"}},
+		{http.MethodDelete, fmt.Sprintf("/todos/delete/%d", id), nil},
+		{http.MethodGet, fmt.Sprintf("/todos/%d/subtree", id), nil},
+		{http.MethodGet, fmt.Sprintf("/todos/%d/occurrences", id), nil},
+		{http.MethodPut, fmt.Sprintf("/todos/%d/move", id), map[string]any{"parentID": nil}},
+		{http.MethodGet, fmt.Sprintf("/todos/%d/history", id), nil},
+		{http.MethodPost, fmt.Sprintf("/todos/%d/history/1/revert", id), nil},
+		{http.MethodGet, fmt.Sprintf("/todos/%d/reminders", id), nil},
+		{http.MethodPost, fmt.Sprintf("/todos/%d/reminders", id), map[string]any{"remindAt": "2030-01-01T09:00:00Z"}},
+		{http.MethodPost, fmt.Sprintf("/todos/trash/%d/restore", id), nil},
+		{http.MethodDelete, fmt.Sprintf("/todos/trash/%d", id), nil},
+	}
+	for _, req := range requests {
+		if w := bob.do(req.method, req.path, req.body); w.Code != http.StatusNotFound {
+			t.Errorf("bob %s %s = %d %s, want 404", req.method, req.path, w.Code, w.Body)
+		}
+	}
+
+	var got store.Todo
+	alice.expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/todos/%d", id), nil, &got)
+	if got.Title != "alice's, edited" {
+		t.Fatalf("alice's todo after bob's requests = %+v", got)
+	}
+	var history []store.TodoVersion
+	alice.expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/todos/%d/history", id), nil, &history)
+	if len(history) != 2 {
+		t.Fatalf("alice's todo has %d versions, want 2", len(history))
+	}
+}
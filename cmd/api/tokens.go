@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"open-todo-go/internal/store"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type CreateAccessTokenPayload struct {
+	Name      string           `json:"name" validate:"required,max=100"`
+	Scope     store.TokenScope `json:"scope" validate:"required,oneof=read write"`
+	ProjectID *int64           `json:"projectID,omitempty"`
+	ExpiresAt *time.Time       `json:"expiresAt,omitempty"`
+}
+
+// CreateAccessToken mints a personal access token. The response is the only
+// place the token is shown.
+func (app *application) CreateAccessToken(w http.ResponseWriter, r *http.Request) {
+	var payload CreateAccessTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.ExpiresAt != nil && !payload.ExpiresAt.After(time.Now()) {
+		app.badRequestResponse(w, r, fmt.Errorf("expiresAt must be in the future"))
+		return
+	}
+
+	ctx := r.Context()
+	userID := getUserIdFromContext(r)
+	if payload.ProjectID != nil {
+		if _, err := app.store.Projects.GetByID(ctx, userID, *payload.ProjectID); err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.badRequestResponse(w, r, errInvalidProject)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+	}
+
+	token := &store.AccessToken{
+		UserID:    userID,
+		Name:      payload.Name,
+		Scope:     payload.Scope,
+		ProjectID: payload.ProjectID,
+		ExpiresAt: payload.ExpiresAt,
+	}
+	if err := app.store.AccessTokens.Create(ctx, token); err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to create access token: %w", err))
+		return
+	}
+
+	app.jsonResponse(w, http.StatusCreated, token)
+}
+
+// GetAccessTokens lists the user's personal access tokens, without their
+// secrets.
+func (app *application) GetAccessTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := app.store.AccessTokens.GetByUser(r.Context(), getUserIdFromContext(r))
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to fetch access tokens: %w", err))
+		return
+	}
+	respondJSON(w, tokens)
+}
+
+// DeleteAccessToken revokes a personal access token.
+func (app *application) DeleteAccessToken(w http.ResponseWriter, r *http.Request) {
+	tokenID, err := strconv.ParseInt(chi.URLParam(r, "tokenID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid token ID: %w", err))
+		return
+	}
+
+	if err := app.store.AccessTokens.Delete(r.Context(), getUserIdFromContext(r), tokenID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to delete access token: %w", err))
+		}
+		return
+	}
+	app.jsonResponse(w, http.StatusOK, nil)
+}
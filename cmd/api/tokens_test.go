@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"open-todo-go/internal/store"
+)
+
+func TestPasswordChangesRevokeAccessTokens(t *testing.T) {
+	const newPassword = "another horse battery staple"
+
+	tests := []struct {
+		name   string
+		change func(t *testing.T, app *application, c *testClient)
+	}{
+		{"change", func(t *testing.T, app *application, c *testClient) {
+			c.expect(http.StatusOK, http.MethodPost, "/user/password", map[string]string{
+				"currentPassword": "correct horse battery",
+				"newPassword":     newPassword,
+			}, nil)
+		}},
+		{"reset", func(t *testing.T, app *application, c *testClient) {
+			reset := &store.UserToken{UserID: c.userID, Purpose: store.PurposeResetPassword, ExpiresAt: time.Now().Add(time.Hour)}
+			if err := app.store.UserTokens.Create(context.Background(), reset); err != nil {
+				t.Fatalf("Create reset token: %v", err)
+			}
+			newTestClient(t, app).expect(http.StatusOK, http.MethodPost, "/user/password/reset", map[string]string{
+				"token":    reset.Token,
+				"password": newPassword,
+			}, nil)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			alice := loginTestUser(t, app, "alice")
+			bob := loginTestUser(t, app, "bob")
+
+			var token, other store.AccessToken
+			alice.expect(http.StatusCreated, http.MethodPost, "/user/tokens", map[string]string{"name": "ci", "scope": "write"}, &token)
+			bob.expect(http.StatusCreated, http.MethodPost, "/user/tokens", map[string]string{"name": "ci", "scope": "write"}, &other)
+
+			tt.change(t, app, alice)
+
+			pat := &testClient{t: t, handler: alice.handler, token: token.Token}
+			pat.expect(http.StatusUnauthorized, http.MethodGet, "/todos/", nil, nil)
+			pat.token = other.Token
+			pat.expect(http.StatusOK, http.MethodGet, "/todos/", nil, nil)
+		})
+	}
+}
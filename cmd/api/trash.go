@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"open-todo-go/internal/store"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type EmptyTrashResponse struct {
+	// Purged is how many todos were deleted for good, not counting the
+	// subtasks that went with them.
+	Purged int `json:"purged"`
+}
+
+// GetTrash lists the todos in the user's trash, most recently deleted
+// first. Todos in the trash belong to their owner: a project member who
+// deletes someone else's todo puts it in the owner's trash.
+func (app *application) GetTrash(w http.ResponseWriter, r *http.Request) {
+	todos, err := app.store.Todos.GetTrash(r.Context(), getUserIdFromContext(r))
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to fetch trash: %w", err))
+		return
+	}
+	respondJSON(w, todos)
+}
+
+// RestoreTodo takes a todo out of the trash, with the subtasks deleted along
+// with it, and returns it with its subtasks nested under it.
+func (app *application) RestoreTodo(w http.ResponseWriter, r *http.Request) {
+	todoID, err := strconv.ParseInt(chi.URLParam(r, "todoID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid todo ID: %w", err))
+		return
+	}
+
+	ctx := r.Context()
+	userID := getUserIdFromContext(r)
+
+	// The todo as it is in the trash is what its history is restored from.
+	trash, err := app.store.Todos.GetTrash(ctx, userID)
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to fetch trash: %w", err))
+		return
+	}
+	var trashed *store.Todo
+	for i := range trash {
+		if trash[i].ID == todoID {
+			trashed = &trash[i]
+			break
+		}
+	}
+	if trashed == nil {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	if err := app.store.Todos.RestoreTodo(ctx, userID, todoID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to restore todo: %w", err))
+		}
+		return
+	}
+
+	restored, err := app.store.Todos.GetSubtree(ctx, userID, todoID)
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to fetch restored todo: %w", err))
+		return
+	}
+	for i := range restored {
+		before := restored[i]
+		before.DeletedAt = trashed.DeletedAt
+		if i == 0 {
+			before = *trashed
+		}
+		app.recordTodoChange(r, store.TodoUpdated, &before, &restored[i])
+	}
+
+	app.jsonResponse(w, http.StatusOK, store.BuildTodoTree(restored, todoID))
+}
+
+// PurgeTodo deletes a todo in the trash for good, with its subtasks. Its
+// history is kept.
+func (app *application) PurgeTodo(w http.ResponseWriter, r *http.Request) {
+	todoID, err := strconv.ParseInt(chi.URLParam(r, "todoID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid todo ID: %w", err))
+		return
+	}
+
+	if err := app.store.Todos.PurgeTodo(r.Context(), getUserIdFromContext(r), todoID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, fmt.Errorf("failed to purge todo: %w", err))
+		}
+		return
+	}
+	app.jsonResponse(w, http.StatusOK, nil)
+}
+
+// EmptyTrash deletes every todo in the user's trash for good.
+func (app *application) EmptyTrash(w http.ResponseWriter, r *http.Request) {
+	n, err := app.store.Todos.EmptyTrash(r.Context(), getUserIdFromContext(r))
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to empty trash: %w", err))
+		return
+	}
+	app.jsonResponse(w, http.StatusOK, EmptyTrashResponse{Purged: n})
+}
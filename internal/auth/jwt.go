@@ -1,29 +1,54 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
+// JWTAuthenticator signs tokens with one key and accepts tokens signed by
+// any of its keys, so a new signing key can be rolled out while tokens
+// signed by the previous one are still in use.
 type JWTAuthenticator struct {
-	secret string
-	aud    string
-	iss    string
+	signing *Key
+	// keys are all keys tokens are accepted from, the signing key first.
+	keys    []*Key
+	methods []string
+	aud     string
+	iss     string
 }
 
-func NewJWTAuthenticator(secret, aud, iss string) *JWTAuthenticator {
-	return &JWTAuthenticator{
-		secret,
-		iss,
-		aud,
+// NewJWTAuthenticator returns an authenticator that signs with signing and
+// also verifies with the retired keys in verify.
+func NewJWTAuthenticator(signing *Key, verify []*Key, aud, iss string) (*JWTAuthenticator, error) {
+	if signing == nil || !signing.CanSign() {
+		return nil, errors.New("signing key has no private key")
 	}
+
+	a := &JWTAuthenticator{
+		signing: signing,
+		aud:     aud,
+		iss:     iss,
+	}
+	for _, key := range append([]*Key{signing}, verify...) {
+		if a.key(key.ID) != nil {
+			return nil, fmt.Errorf("duplicate key %q", key.ID)
+		}
+		a.keys = append(a.keys, key)
+		a.methods = append(a.methods, key.Method.Alg())
+	}
+
+	return a, nil
 }
 
-func (auth *JWTAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(auth.secret))
+func (a *JWTAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(a.signing.Method, claims)
+	if a.signing.ID != "" {
+		token.Header["kid"] = a.signing.ID
+	}
+
+	tokenString, err := token.SignedString(a.signing.private)
 	if err != nil {
 		return "", err
 	}
@@ -33,26 +58,42 @@ func (auth *JWTAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
 
 func (a *JWTAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
 	return jwt.Parse(token, func(t *jwt.Token) (any, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := t.Header["kid"].(string)
+		key := a.key(kid)
+		if key == nil {
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+
+		if t.Method.Alg() != key.Method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
 		}
 
-		return []byte(a.secret), nil
+		return key.public, nil
 	},
 		jwt.WithExpirationRequired(),
 		jwt.WithAudience(a.aud),
-		jwt.WithIssuer(a.aud),
-		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}),
+		jwt.WithIssuer(a.iss),
+		jwt.WithValidMethods(a.methods),
 	)
 }
 
-func (a *JWTAuthenticator) VerifyPassword(plainPassword, hashedPassword string) bool {
-	// Compare the hashed password with the plain-text password
-	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(plainPassword))
-	fmt.Print(err)
-	if err != nil {
-		return false
-	} else {
-		return true
+// JWKS returns the public keys tokens may be signed with. HMAC keys are
+// secret and left out.
+func (a *JWTAuthenticator) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range a.keys {
+		if key.ID != "" {
+			set.Keys = append(set.Keys, key.JWK())
+		}
+	}
+	return set
+}
+
+func (a *JWTAuthenticator) key(id string) *Key {
+	for _, key := range a.keys {
+		if key.ID == id {
+			return key
+		}
 	}
+	return nil
 }
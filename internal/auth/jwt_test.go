@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testAud = "open-todo-go"
+	testIss = "open-todo-go"
+)
+
+func newTestAuthenticator(t *testing.T, signing *Key, verify ...*Key) *JWTAuthenticator {
+	t.Helper()
+	a, err := NewJWTAuthenticator(signing, verify, testAud, testIss)
+	if err != nil {
+		t.Fatalf("NewJWTAuthenticator: %v", err)
+	}
+	return a
+}
+
+func testClaims(exp time.Duration) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub": "1",
+		"aud": testAud,
+		"iss": testIss,
+		"exp": time.Now().Add(exp).Unix(),
+	}
+}
+
+// signToken signs claims with key as alg, bypassing the authenticator.
+func signToken(t *testing.T, alg jwt.SigningMethod, kid string, key any, claims jwt.Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(alg, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return s
+}
+
+func TestNewJWTAuthenticator(t *testing.T) {
+	ed := parseTestKey(t, pkcs8(t, testKeys.ed25519))
+	edPublic := parseTestKey(t, pkix(t, testKeys.ed25519.Public()))
+
+	if _, err := NewJWTAuthenticator(edPublic, nil, testAud, testIss); err == nil {
+		t.Fatal("NewJWTAuthenticator accepted a signing key without a private key")
+	}
+	if _, err := NewJWTAuthenticator(nil, nil, testAud, testIss); err == nil {
+		t.Fatal("NewJWTAuthenticator accepted no signing key")
+	}
+	if _, err := NewJWTAuthenticator(ed, []*Key{edPublic}, testAud, testIss); err == nil {
+		t.Fatal("NewJWTAuthenticator accepted the same key twice")
+	}
+}
+
+func TestValidateToken(t *testing.T) {
+	ed := parseTestKey(t, pkcs8(t, testKeys.ed25519))
+	rsaKey := parseTestKey(t, pkcs8(t, testKeys.rsa))
+	rsaPublic := parseTestKey(t, pkix(t, &testKeys.rsa.PublicKey))
+	hmacKey, err := NewHMACKey(strings.Repeat("s", MinSecretLength))
+	if err != nil {
+		t.Fatalf("NewHMACKey: %v", err)
+	}
+
+	// Tokens signed before the RSA key was retired for the Ed25519 one are
+	// still accepted.
+	a := newTestAuthenticator(t, ed, rsaPublic)
+	retired, err := newTestAuthenticator(t, rsaKey).GenerateToken(testClaims(time.Hour))
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	current, err := a.GenerateToken(testClaims(time.Hour))
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	hmacToken, err := newTestAuthenticator(t, hmacKey).GenerateToken(testClaims(time.Hour))
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	expired, err := a.GenerateToken(testClaims(-time.Hour))
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	wrongAud := testClaims(time.Hour)
+	wrongAud["aud"] = "someone-else"
+	noExp := testClaims(time.Hour)
+	delete(noExp, "exp")
+
+	tests := []struct {
+		name  string
+		a     *JWTAuthenticator
+		token string
+		ok    bool
+	}{
+		{"signing key", a, current, true},
+		{"retired key", a, retired, true},
+		{"retired key only verifies", newTestAuthenticator(t, rsaKey), current, false},
+		{"HMAC key", newTestAuthenticator(t, hmacKey), hmacToken, true},
+		{"unknown HMAC key", a, hmacToken, false},
+		{"expired", a, expired, false},
+		{"no expiry", a, signToken(t, jwt.SigningMethodEdDSA, ed.ID, testKeys.ed25519, noExp), false},
+		{"wrong audience", a, signToken(t, jwt.SigningMethodEdDSA, ed.ID, testKeys.ed25519, wrongAud), false},
+		{"unknown kid", a, signToken(t, jwt.SigningMethodEdDSA, "other", testKeys.ed25519, testClaims(time.Hour)), false},
+		// Signed with the Ed25519 key but naming the RSA key: both
+		// algorithms are accepted, just not with each other's key.
+		{"alg does not match kid", a, signToken(t, jwt.SigningMethodEdDSA, rsaKey.ID, testKeys.ed25519, testClaims(time.Hour)), false},
+		// The RSA public key used as an HMAC secret.
+		{"HS256 with public key", a, signToken(t, jwt.SigningMethodHS256, rsaKey.ID, pkix(t, &testKeys.rsa.PublicKey), testClaims(time.Hour)), false},
+		{"alg none", a, signToken(t, jwt.SigningMethodNone, ed.ID, jwt.UnsafeAllowNoneSignatureType, testClaims(time.Hour)), false},
+		{"tampered", a, current[:len(current)-4] + "AAAA", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tt.a.ValidateToken(tt.token)
+			if ok := err == nil && token.Valid; ok != tt.ok {
+				t.Fatalf("ValidateToken = %v, want valid %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestGenerateTokenKid(t *testing.T) {
+	ed := parseTestKey(t, pkcs8(t, testKeys.ed25519))
+	hmacKey, err := NewHMACKey(strings.Repeat("s", MinSecretLength))
+	if err != nil {
+		t.Fatalf("NewHMACKey: %v", err)
+	}
+
+	for _, key := range []*Key{ed, hmacKey} {
+		s, err := newTestAuthenticator(t, key).GenerateToken(testClaims(time.Hour))
+		if err != nil {
+			t.Fatalf("GenerateToken: %v", err)
+		}
+		token, _, err := jwt.NewParser().ParseUnverified(s, jwt.MapClaims{})
+		if err != nil {
+			t.Fatalf("ParseUnverified: %v", err)
+		}
+		kid, hasKid := token.Header["kid"]
+		if key.ID == "" && hasKid || key.ID != "" && kid != key.ID {
+			t.Fatalf("%s token kid = %v, want %q", key.Method.Alg(), kid, key.ID)
+		}
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	ed := parseTestKey(t, pkcs8(t, testKeys.ed25519))
+	rsaPublic := parseTestKey(t, pkix(t, &testKeys.rsa.PublicKey))
+	hmacKey, err := NewHMACKey(strings.Repeat("s", MinSecretLength))
+	if err != nil {
+		t.Fatalf("NewHMACKey: %v", err)
+	}
+
+	set := newTestAuthenticator(t, ed, hmacKey, rsaPublic).JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("JWKS has %d keys, want the 2 asymmetric ones: %+v", len(set.Keys), set.Keys)
+	}
+
+	// The kids are RFC 7638 thumbprints over the required members, written
+	// out here by hand.
+	canonical := []string{
+		fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":"%s"}`, set.Keys[0].X),
+		fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, set.Keys[1].E, set.Keys[1].N),
+	}
+	want := []struct{ kty, alg, kid string }{
+		{"OKP", "EdDSA", ed.ID},
+		{"RSA", "RS256", rsaPublic.ID},
+	}
+	for i, jwk := range set.Keys {
+		sum := sha256.Sum256([]byte(canonical[i]))
+		thumbprint := base64.RawURLEncoding.EncodeToString(sum[:])
+		if jwk.KeyType != want[i].kty || jwk.Algorithm != want[i].alg || jwk.Use != "sig" {
+			t.Fatalf("JWKS key %d = %+v, want %s %s", i, jwk, want[i].kty, want[i].alg)
+		}
+		if jwk.KeyID != want[i].kid || jwk.KeyID != thumbprint {
+			t.Fatalf("JWKS key %d kid = %q, want %q", i, jwk.KeyID, thumbprint)
+		}
+	}
+	if e := set.Keys[1].E; e != "AQAB" {
+		t.Fatalf("RSA exponent = %q, want AQAB", e)
+	}
+
+	if set := newTestAuthenticator(t, hmacKey).JWKS(); set.Keys == nil || len(set.Keys) != 0 {
+		t.Fatalf("JWKS of an HMAC key = %+v, want an empty list", set)
+	}
+}
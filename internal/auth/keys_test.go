@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testKeys are generated once, RSA ones being slow to make.
+var testKeys = struct {
+	ed25519  ed25519.PrivateKey
+	rsa      *rsa.PrivateKey
+	rsaSmall *rsa.PrivateKey
+	ecdsa    *ecdsa.PrivateKey
+}{}
+
+func init() {
+	var err error
+	if _, testKeys.ed25519, err = ed25519.GenerateKey(rand.Reader); err != nil {
+		panic(err)
+	}
+	if testKeys.rsa, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		panic(err)
+	}
+	if testKeys.rsaSmall, err = rsa.GenerateKey(rand.Reader, 1024); err != nil {
+		panic(err)
+	}
+	if testKeys.ecdsa, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		panic(err)
+	}
+}
+
+func pemBlock(t *testing.T, typ string, der []byte, err error) []byte {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("marshal %s: %v", typ, err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+}
+
+func pkcs8(t *testing.T, key any) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	return pemBlock(t, "PRIVATE KEY", der, err)
+}
+
+func pkix(t *testing.T, key any) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	return pemBlock(t, "PUBLIC KEY", der, err)
+}
+
+// parseTestKey parses a PEM key the test made and fails the test if it
+// cannot.
+func parseTestKey(t *testing.T, data []byte) *Key {
+	t.Helper()
+	key, err := ParseKey(data)
+	if err != nil {
+		t.Fatalf("ParseKey: %v", err)
+	}
+	return key
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		pem     []byte
+		alg     string
+		canSign bool
+	}{
+		{"Ed25519 private", pkcs8(t, testKeys.ed25519), "EdDSA", true},
+		{"Ed25519 public", pkix(t, testKeys.ed25519.Public()), "EdDSA", false},
+		{"RSA private PKCS #1", pemBlock(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(testKeys.rsa), nil), "RS256", true},
+		{"RSA private PKCS #8", pkcs8(t, testKeys.rsa), "RS256", true},
+		{"RSA public", pkix(t, &testKeys.rsa.PublicKey), "RS256", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := parseTestKey(t, tt.pem)
+			if key.Method.Alg() != tt.alg || key.CanSign() != tt.canSign {
+				t.Fatalf("ParseKey = %s, can sign %v, want %s, %v", key.Method.Alg(), key.CanSign(), tt.alg, tt.canSign)
+			}
+			if key.ID == "" || key.ID != key.JWK().thumbprint() {
+				t.Fatalf("ParseKey ID = %q, want the thumbprint %q", key.ID, key.JWK().thumbprint())
+			}
+		})
+	}
+
+	// A key pair has one ID, so tokens signed by the private key are
+	// matched to the public key verifying them.
+	if private, public := parseTestKey(t, pkcs8(t, testKeys.rsa)), parseTestKey(t, pkix(t, &testKeys.rsa.PublicKey)); private.ID != public.ID {
+		t.Fatalf("private key ID %q, public key ID %q", private.ID, public.ID)
+	}
+}
+
+func TestParseKeyErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		pem  []byte
+		want string
+	}{
+		{"not PEM", []byte("secret"), ErrNoKey.Error()},
+		{"certificate", pemBlock(t, "CERTIFICATE", []byte{1}, nil), "unsupported PEM block"},
+		{"corrupt", pemBlock(t, "PRIVATE KEY", []byte("garbage"), nil), "asn1"},
+		{"ECDSA", pkcs8(t, testKeys.ecdsa), "unsupported key type"},
+		{"small RSA", pkix(t, &testKeys.rsaSmall.PublicKey), "at least 2048 bits"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParseKey(tt.pem)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("ParseKey = %v, %v, want error containing %q", key, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHMACKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		want   error
+	}{
+		{"empty", "", ErrWeakSecret},
+		{"short", strings.Repeat("s", MinSecretLength-1), ErrWeakSecret},
+		{"long enough", strings.Repeat("s", MinSecretLength), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := NewHMACKey(tt.secret)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("NewHMACKey err = %v, want %v", err, tt.want)
+			}
+			if err == nil && (key.ID != "" || key.Method.Alg() != "HS256" || !key.CanSign()) {
+				t.Fatalf("NewHMACKey = %+v", key)
+			}
+		})
+	}
+}
+
+func TestThumbprint(t *testing.T) {
+	tests := []struct {
+		name string
+		jwk  JWK
+		want string
+	}{
+		// RFC 7638, section 3.1.
+		{"RSA", JWK{
+			KeyType: "RSA",
+			N:       "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+			E:       "AQAB",
+			KeyID:   "2011-04-29",
+		}, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"},
+		// RFC 8037, appendix A.3.
+		{"Ed25519", JWK{
+			KeyType: "OKP",
+			Curve:   "Ed25519",
+			X:       "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo",
+		}, "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.jwk.thumbprint(); got != tt.want {
+				t.Fatalf("thumbprint = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
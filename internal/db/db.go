@@ -3,11 +3,19 @@ package db
 import (
 	"context"
 	"database/sql"
+	"net/url"
+	"strings"
 	"time"
+
+	_ "modernc.org/sqlite"
 )
 
-func New(addr string, maxOpenConns, maxIdleConns int, maxIdleTime string) (*sql.DB, error) {
-	db, err := sql.Open("postgres", addr)
+func New(driver, addr string, maxOpenConns, maxIdleConns int, maxIdleTime string) (*sql.DB, error) {
+	if driver == "sqlite" {
+		addr = sqliteDSN(addr)
+	}
+
+	db, err := sql.Open(driver, addr)
 
 	if err != nil {
 		return nil, err
@@ -32,3 +40,32 @@ func New(addr string, maxOpenConns, maxIdleConns int, maxIdleTime string) (*sql.
 
 	return db, nil
 }
+
+// sqliteDSN adds the pragmas every SQLite connection needs: foreign keys are
+// off by default, writers should wait instead of failing with SQLITE_BUSY,
+// transactions take the write lock up front so read-then-write transactions
+// cannot deadlock, and times must be written in a format that sorts as text.
+func sqliteDSN(addr string) string {
+	path, rawQuery, _ := strings.Cut(addr, "?")
+
+	q, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return addr
+	}
+
+	pragmas := strings.Join(q["_pragma"], ",")
+	for _, p := range []string{"foreign_keys(1)", "busy_timeout(5000)", "journal_mode(WAL)"} {
+		name, _, _ := strings.Cut(p, "(")
+		if !strings.Contains(pragmas, name) {
+			q.Add("_pragma", p)
+		}
+	}
+	if q.Get("_txlock") == "" {
+		q.Set("_txlock", "immediate")
+	}
+	if q.Get("_time_format") == "" {
+		q.Set("_time_format", "sqlite")
+	}
+
+	return path + "?" + q.Encode()
+}
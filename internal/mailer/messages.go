@@ -0,0 +1,108 @@
+package mailer
+
+import (
+	"fmt"
+	"time"
+)
+
+func VerifyEmail(to, username, link string) Message {
+	return Message{
+		To:      to,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(`Hi %s,
+
+Please confirm this is your email address by opening the link below:
+
+%s
+
+If you did not sign up, you can ignore this email.
+`, username, link),
+	}
+}
+
+func ResetPassword(to, username, link string, ttl time.Duration) Message {
+	return Message{
+		To:      to,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(`Hi %s,
+
+Someone asked to reset the password of your account. To choose a new one,
+open the link below within %s:
+
+%s
+
+If it was not you, you can ignore this email; your password is unchanged.
+`, username, ttl, link),
+	}
+}
+
+func EmailChanged(to, username, newEmail string) Message {
+	return Message{
+		To:      to,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf(`Hi %s,
+
+The email address of your account was changed to %s. Emails will go
+there from now on.
+
+If it was not you, reset your password and contact us.
+`, username, newEmail),
+	}
+}
+
+func Invitation(to, inviter, project, role, link string) Message {
+	return Message{
+		To:      to,
+		Subject: fmt.Sprintf("%s shared %q with you", inviter, project),
+		Body: fmt.Sprintf(`Hi,
+
+%s invited you to the project %q as %s. To join, sign in with this email
+address and open the link below:
+
+%s
+`, inviter, project, role, link),
+	}
+}
+
+func Reminder(to, username, title string, dueAt *time.Time) Message {
+	due := ""
+	if dueAt != nil {
+		due = fmt.Sprintf("\nIt is due %s.\n", dueAt.UTC().Format(time.RFC1123))
+	}
+	return Message{
+		To:      to,
+		Subject: "Reminder: " + title,
+		Body: fmt.Sprintf(`Hi %s,
+
+This is your reminder for %q.
+%s`, username, title, due),
+	}
+}
+
+func DataExportReady(to, username, link string, expiresAt time.Time) Message {
+	return Message{
+		To:      to,
+		Subject: "Your data export is ready",
+		Body: fmt.Sprintf(`Hi %s,
+
+The export of your data you asked for is ready. Download it from the link
+below before %s:
+
+%s
+
+Anyone with the link can download the export, so do not share it.
+`, username, expiresAt.UTC().Format(time.RFC1123), link),
+	}
+}
+
+func AccountErased(to, username string) Message {
+	return Message{
+		To:      to,
+		Subject: "Your account was deleted",
+		Body: fmt.Sprintf(`Hi %s,
+
+As you asked, your account and all of your data have been deleted. This
+is the last email you will get from us.
+`, username),
+	}
+}
@@ -0,0 +1,50 @@
+package migrate_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"open-todo-go/internal/db"
+	"open-todo-go/internal/migrate"
+)
+
+// TestSQLiteRoundTrip applies every migration, rolls them all back and
+// applies them again, so each down migration undoes its up migration.
+func TestSQLiteRoundTrip(t *testing.T) {
+	d, err := db.New("sqlite", filepath.Join(t.TempDir(), "todos.db"), 1, 1, "1m")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer d.Close()
+
+	m, err := migrate.New(d, migrate.SQLite)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := context.Background()
+
+	up, err := m.Up(ctx, 0)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	down, err := m.Down(ctx, len(up))
+	if err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if len(down) != len(up) {
+		t.Fatalf("Down rolled back %d migrations, want %d", len(down), len(up))
+	}
+	if _, err := m.Up(ctx, 0); err != nil {
+		t.Fatalf("Up again: %v", err)
+	}
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	for _, s := range status {
+		if !s.Applied {
+			t.Fatalf("migration %d_%s not applied", s.Version, s.Name)
+		}
+	}
+}
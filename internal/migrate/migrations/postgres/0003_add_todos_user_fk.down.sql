@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS todos_user_id_created_at_idx;
+
+ALTER TABLE todos DROP CONSTRAINT IF EXISTS todos_user_id_fkey;
+
+ALTER TABLE todos ALTER COLUMN user_id TYPE INTEGER;
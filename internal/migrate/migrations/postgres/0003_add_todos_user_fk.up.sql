@@ -0,0 +1,7 @@
+ALTER TABLE todos ALTER COLUMN user_id TYPE BIGINT;
+
+ALTER TABLE todos
+    ADD CONSTRAINT todos_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS todos_user_id_created_at_idx ON todos (user_id, created_at DESC);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS todos_search_vector_idx;
+
+ALTER TABLE todos DROP COLUMN IF EXISTS search_vector;
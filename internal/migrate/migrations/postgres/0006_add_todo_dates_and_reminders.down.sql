@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS reminders;
+
+DROP INDEX IF EXISTS todos_user_id_due_at_idx;
+
+ALTER TABLE todos
+    DROP COLUMN IF EXISTS timezone,
+    DROP COLUMN IF EXISTS due_at,
+    DROP COLUMN IF EXISTS start_at;
@@ -0,0 +1,18 @@
+ALTER TABLE todos
+    ADD COLUMN IF NOT EXISTS start_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS due_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+CREATE INDEX IF NOT EXISTS todos_user_id_due_at_idx ON todos (user_id, due_at) WHERE NOT completed;
+
+CREATE TABLE IF NOT EXISTS reminders (
+    id BIGSERIAL PRIMARY KEY,
+    todo_id BIGINT NOT NULL REFERENCES todos (id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    remind_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    fired_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS reminders_todo_id_idx ON reminders (todo_id);
+CREATE INDEX IF NOT EXISTS reminders_pending_idx ON reminders (remind_at) WHERE fired_at IS NULL;
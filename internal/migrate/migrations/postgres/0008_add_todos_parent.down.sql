@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS todos_parent_id_idx;
+
+ALTER TABLE todos
+    DROP COLUMN IF EXISTS complete_with_children,
+    DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,5 @@
+ALTER TABLE todos
+    ADD COLUMN IF NOT EXISTS parent_id BIGINT REFERENCES todos (id) ON DELETE CASCADE,
+    ADD COLUMN IF NOT EXISTS complete_with_children BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS todos_parent_id_idx ON todos (parent_id);
@@ -0,0 +1,4 @@
+-- Failed logins since the last successful one. Past a threshold the account
+-- is locked until locked_until, for longer with every further failure.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS failed_logins INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE;
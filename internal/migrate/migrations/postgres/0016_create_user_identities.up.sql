@@ -0,0 +1,25 @@
+-- Accounts at external OpenID Connect providers that users can log in with.
+CREATE TABLE IF NOT EXISTS user_identities (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    provider VARCHAR(64) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS user_identities_user_id_idx ON user_identities (user_id);
+
+-- Logins in progress at a provider, found again by the hash of their state
+-- parameter. user_id is set when a logged-in user is linking an identity.
+CREATE TABLE IF NOT EXISTS oidc_flows (
+    id BIGSERIAL PRIMARY KEY,
+    provider VARCHAR(64) NOT NULL,
+    state_hash BYTEA NOT NULL UNIQUE,
+    nonce VARCHAR(64) NOT NULL,
+    verifier VARCHAR(128) NOT NULL,
+    user_id BIGINT REFERENCES users (id) ON DELETE CASCADE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
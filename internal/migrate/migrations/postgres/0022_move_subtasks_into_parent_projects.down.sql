@@ -0,0 +1,2 @@
+-- The projects subtasks were in before are not kept, and they are valid
+-- either way.
@@ -0,0 +1,11 @@
+-- Subtasks are in their parent's project. Earlier versions let them be put
+-- in another one, so move each subtree into the project of its top-level
+-- todo.
+WITH RECURSIVE roots (id, project_id) AS (
+  SELECT id, project_id FROM todos WHERE parent_id IS NULL
+  UNION ALL
+  SELECT t.id, r.project_id FROM todos t JOIN roots r ON t.parent_id = r.id
+)
+UPDATE todos SET project_id = roots.project_id
+FROM roots
+WHERE todos.id = roots.id AND todos.project_id IS DISTINCT FROM roots.project_id;
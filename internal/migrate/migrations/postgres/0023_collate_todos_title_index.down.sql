@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS todos_user_id_title_idx;
+CREATE INDEX IF NOT EXISTS todos_user_id_title_idx ON todos (user_id, title, id);
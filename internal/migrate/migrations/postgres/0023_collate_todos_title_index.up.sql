@@ -0,0 +1,4 @@
+-- Titles are sorted under the "C" collation, which an index on title under
+-- the database's default collation cannot serve.
+DROP INDEX IF EXISTS todos_user_id_title_idx;
+CREATE INDEX IF NOT EXISTS todos_user_id_title_idx ON todos (user_id, title COLLATE "C", id);
@@ -0,0 +1,3 @@
+-- SQLite cannot add constraints to an existing table, so the foreign key is
+-- declared in 0002; only the index is added here to keep versions aligned.
+CREATE INDEX IF NOT EXISTS todos_user_id_created_at_idx ON todos (user_id, created_at DESC);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS todos_user_id_title_idx;
+DROP INDEX IF EXISTS todos_user_id_priority_idx;
+DROP INDEX IF EXISTS todos_user_id_updated_at_idx;
@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS todos_user_id_updated_at_idx ON todos (user_id, updated_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS todos_user_id_priority_idx ON todos (user_id, priority, id);
+CREATE INDEX IF NOT EXISTS todos_user_id_title_idx ON todos (user_id, title, id);
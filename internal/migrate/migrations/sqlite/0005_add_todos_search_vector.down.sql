@@ -0,0 +1 @@
+-- SQLite searches todos in the store, there is no search vector to maintain.
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS reminders;
+
+DROP INDEX IF EXISTS todos_user_id_due_at_idx;
+
+ALTER TABLE todos DROP COLUMN timezone;
+ALTER TABLE todos DROP COLUMN due_at;
+ALTER TABLE todos DROP COLUMN start_at;
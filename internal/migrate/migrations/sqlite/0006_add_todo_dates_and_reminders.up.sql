@@ -0,0 +1,17 @@
+ALTER TABLE todos ADD COLUMN start_at TIMESTAMP;
+ALTER TABLE todos ADD COLUMN due_at TIMESTAMP;
+ALTER TABLE todos ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+CREATE INDEX IF NOT EXISTS todos_user_id_due_at_idx ON todos (user_id, due_at) WHERE NOT completed;
+
+CREATE TABLE IF NOT EXISTS reminders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    todo_id INTEGER NOT NULL REFERENCES todos (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    remind_at TIMESTAMP NOT NULL,
+    fired_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS reminders_todo_id_idx ON reminders (todo_id);
+CREATE INDEX IF NOT EXISTS reminders_pending_idx ON reminders (remind_at) WHERE fired_at IS NULL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS todos_parent_id_idx;
+
+ALTER TABLE todos DROP COLUMN complete_with_children;
+ALTER TABLE todos DROP COLUMN parent_id;
@@ -0,0 +1,4 @@
+ALTER TABLE todos ADD COLUMN parent_id INTEGER REFERENCES todos (id) ON DELETE CASCADE;
+ALTER TABLE todos ADD COLUMN complete_with_children BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS todos_parent_id_idx ON todos (parent_id);
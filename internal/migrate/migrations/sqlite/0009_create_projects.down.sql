@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS todos_project_id_idx;
+
+ALTER TABLE todos DROP COLUMN project_id;
+
+DROP TABLE IF EXISTS projects;
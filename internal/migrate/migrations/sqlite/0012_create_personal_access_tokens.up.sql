@@ -0,0 +1,15 @@
+-- Personal access tokens let scripts call the API without a password. A
+-- token with a project_id only reaches that project.
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    token_hash BLOB NOT NULL UNIQUE,
+    scope VARCHAR(16) NOT NULL CHECK (scope IN ('read', 'write')),
+    project_id INTEGER REFERENCES projects (id) ON DELETE CASCADE,
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS personal_access_tokens_user_id_idx ON personal_access_tokens (user_id);
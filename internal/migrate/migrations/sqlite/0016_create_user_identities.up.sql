@@ -0,0 +1,25 @@
+-- Accounts at external OpenID Connect providers that users can log in with.
+CREATE TABLE IF NOT EXISTS user_identities (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    provider VARCHAR(64) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS user_identities_user_id_idx ON user_identities (user_id);
+
+-- Logins in progress at a provider, found again by the hash of their state
+-- parameter. user_id is set when a logged-in user is linking an identity.
+CREATE TABLE IF NOT EXISTS oidc_flows (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    provider VARCHAR(64) NOT NULL,
+    state_hash BLOB NOT NULL UNIQUE,
+    nonce VARCHAR(64) NOT NULL,
+    verifier VARCHAR(128) NOT NULL,
+    user_id INTEGER REFERENCES users (id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,7 @@
+-- Preferences the web app shows dates and text to the user with.
+ALTER TABLE users ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+ALTER TABLE users ADD COLUMN locale VARCHAR(35) NOT NULL DEFAULT 'en';
+
+-- Set when a user deleted their account but kept their todos. The row then
+-- only holds what the todos need.
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP;
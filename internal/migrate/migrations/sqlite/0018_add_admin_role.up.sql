@@ -0,0 +1,17 @@
+ALTER TABLE users ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin'));
+-- Suspended users cannot log in until an admin lifts the suspension.
+ALTER TABLE users ADD COLUMN suspended_at TIMESTAMP;
+
+-- What admins did to whom. Entries outlive both the admin and the user they
+-- are about, so neither ID is a foreign key.
+CREATE TABLE IF NOT EXISTS admin_audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    actor_id INTEGER,
+    action VARCHAR(64) NOT NULL,
+    target_user_id INTEGER,
+    details TEXT NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS admin_audit_log_actor_id_idx ON admin_audit_log (actor_id);
+CREATE INDEX IF NOT EXISTS admin_audit_log_target_user_id_idx ON admin_audit_log (target_user_id);
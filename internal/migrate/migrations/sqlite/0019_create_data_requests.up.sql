@@ -0,0 +1,19 @@
+-- Exports and erasures of their data that users have asked for, run in the
+-- background. A finished export keeps its ZIP archive in archive until
+-- expires_at, when its download link stops working.
+CREATE TABLE IF NOT EXISTS data_requests (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    kind VARCHAR(16) NOT NULL CHECK (kind IN ('export', 'erasure')),
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'ready', 'failed')),
+    archive BLOB,
+    error TEXT NOT NULL DEFAULT '',
+    claimed_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A user has at most one open request of each kind.
+CREATE UNIQUE INDEX IF NOT EXISTS data_requests_open_idx ON data_requests (user_id, kind) WHERE status IN ('pending', 'running');
+CREATE INDEX IF NOT EXISTS data_requests_status_idx ON data_requests (status, created_at);
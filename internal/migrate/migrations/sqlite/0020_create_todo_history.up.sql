@@ -0,0 +1,19 @@
+-- Every version of every todo. A version holds the todo as it was after the
+-- change (before it, for deletions) and the fields that changed. History
+-- outlives the todo, so todo_id is not a foreign key; it goes with the
+-- todo's owner.
+CREATE TABLE IF NOT EXISTS todo_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    todo_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    actor_id INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    action VARCHAR(16) NOT NULL CHECK (action IN ('create', 'update', 'delete', 'revert')),
+    changes TEXT NOT NULL DEFAULT '{}',
+    snapshot TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (todo_id, version)
+);
+
+CREATE INDEX IF NOT EXISTS todo_history_user_id_idx ON todo_history (user_id);
+CREATE INDEX IF NOT EXISTS todo_history_actor_id_idx ON todo_history (actor_id);
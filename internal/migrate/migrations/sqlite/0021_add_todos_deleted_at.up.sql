@@ -0,0 +1,5 @@
+-- Set while a todo is in the trash. Todos in the trash are purged for good
+-- once they have been there longer than the retention period.
+ALTER TABLE todos ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS todos_deleted_at_idx ON todos (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Titles are sorted under BINARY, the collation todos_user_id_title_idx
+-- already has in SQLite. The version keeps the dialects in line.
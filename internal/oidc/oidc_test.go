@@ -0,0 +1,121 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"open-todo-go/internal/oidc"
+	"open-todo-go/internal/oidc/oidctest"
+)
+
+const redirectURL = "http://localhost:5174/oidc/callback"
+
+// startFlow begins a flow at p the way the API does.
+func startFlow(t *testing.T, p *oidc.Provider) (authURL, nonce, verifier string) {
+	t.Helper()
+
+	nonce, err := oidc.NewNonce()
+	if err != nil {
+		t.Fatalf("NewNonce: %v", err)
+	}
+	verifier = oidc.NewVerifier()
+	authURL, err = p.AuthCodeURL(context.Background(), "state", nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	return authURL, nonce, verifier
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	srv := oidctest.NewServer(t)
+	p := oidc.NewProvider(srv.Config("mock", redirectURL))
+
+	authURL, nonce, _ := startFlow(t, p)
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse auth URL: %v", err)
+	}
+	q := u.Query()
+	switch {
+	case u.Scheme+"://"+u.Host+u.Path != srv.URL+"/authorize":
+		t.Fatalf("auth URL %s is not the provider's authorization endpoint", authURL)
+	case q.Get("state") != "state", q.Get("nonce") != nonce, q.Get("redirect_uri") != redirectURL:
+		t.Fatalf("auth URL query = %v", q)
+	case q.Get("scope") != "openid email profile":
+		t.Fatalf("auth URL scope = %q", q.Get("scope"))
+	}
+}
+
+func TestExchange(t *testing.T) {
+	srv := oidctest.NewServer(t)
+	p := oidc.NewProvider(srv.Config("mock", redirectURL))
+	ctx := context.Background()
+	user := map[string]any{
+		"sub":                "alice-at-mock",
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"preferred_username": "alice",
+	}
+
+	authURL, nonce, verifier := startFlow(t, p)
+	_, code := srv.Login(t, authURL, user)
+	claims, err := p.Exchange(ctx, code, nonce, verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	want := oidc.Claims{Subject: "alice-at-mock", Email: "alice@example.com", EmailVerified: true, PreferredUsername: "alice"}
+	if *claims != want {
+		t.Fatalf("Exchange = %+v, want %+v", *claims, want)
+	}
+
+	// Codes work once.
+	if _, err := p.Exchange(ctx, code, nonce, verifier); err == nil {
+		t.Fatal("Exchange redeemed a code twice")
+	}
+}
+
+func TestExchangeErrors(t *testing.T) {
+	srv := oidctest.NewServer(t)
+	p := oidc.NewProvider(srv.Config("mock", redirectURL))
+	ctx := context.Background()
+
+	t.Run("PKCEVerifier", func(t *testing.T) {
+		// The provider only hands out tokens for the verifier the
+		// challenge in the auth URL was made from.
+		authURL, nonce, _ := startFlow(t, p)
+		_, code := srv.Login(t, authURL, map[string]any{"sub": "alice"})
+		if _, err := p.Exchange(ctx, code, nonce, oidc.NewVerifier()); err == nil {
+			t.Fatal("Exchange succeeded with another flow's verifier")
+		}
+	})
+
+	t.Run("NonceMismatch", func(t *testing.T) {
+		authURL, _, verifier := startFlow(t, p)
+		_, code := srv.Login(t, authURL, map[string]any{"sub": "alice"})
+		if _, err := p.Exchange(ctx, code, "another nonce", verifier); !errors.Is(err, oidc.ErrNonceMismatch) {
+			t.Fatalf("Exchange err = %v, want ErrNonceMismatch", err)
+		}
+	})
+
+	t.Run("ReplayedIDToken", func(t *testing.T) {
+		// An ID token made for another login carries that login's nonce.
+		authURL, nonce, verifier := startFlow(t, p)
+		_, code := srv.Login(t, authURL, map[string]any{"sub": "alice", "nonce": "stolen"})
+		if _, err := p.Exchange(ctx, code, nonce, verifier); !errors.Is(err, oidc.ErrNonceMismatch) {
+			t.Fatalf("Exchange err = %v, want ErrNonceMismatch", err)
+		}
+	})
+
+	t.Run("OtherClient", func(t *testing.T) {
+		cfg := srv.Config("mock", redirectURL)
+		cfg.ClientSecret = "wrong"
+		other := oidc.NewProvider(cfg)
+		authURL, nonce, verifier := startFlow(t, other)
+		_, code := srv.Login(t, authURL, map[string]any{"sub": "alice"})
+		if _, err := other.Exchange(ctx, code, nonce, verifier); err == nil {
+			t.Fatal("Exchange succeeded with the wrong client secret")
+		}
+	})
+}
@@ -0,0 +1,231 @@
+// Package password hashes and checks user passwords. Hashes are
+// self-describing strings, so the scheme and its parameters can change
+// while older hashes keep working until they are replaced.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var errMalformedHash = errors.New("malformed password hash")
+
+// Hasher hashes passwords with one scheme.
+type Hasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether password matches encoded, a hash made with
+	// the hasher's scheme but not necessarily its parameters.
+	Verify(password, encoded string) (bool, error)
+	// Identify reports whether encoded is a hash of the hasher's scheme.
+	Identify(encoded string) bool
+	// Current reports whether encoded was made with the hasher's
+	// parameters.
+	Current(encoded string) bool
+}
+
+// Bcrypt hashes passwords with bcrypt, in its usual $2a$ format. bcrypt only
+// uses the first 72 bytes of a password.
+type Bcrypt struct {
+	Cost int
+}
+
+func (b Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	return string(hash), err
+}
+
+func (b Bcrypt) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b Bcrypt) Identify(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (b Bcrypt) Current(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err == nil && cost == b.Cost
+}
+
+// Argon2id hashes passwords with Argon2id into PHC strings such as
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>.
+type Argon2id struct {
+	// Memory is in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// Limits on Argon2id parameters. A corrupt or hostile hash could otherwise
+// make verifying a login use unbounded memory or time, or panic.
+const (
+	// maxArgon2Memory is 1 GiB, in KiB.
+	maxArgon2Memory     = 1 << 20
+	maxArgon2Iterations = 32
+	// minArgon2SaltLength and minArgon2KeyLength are the minimums of RFC
+	// 9106.
+	minArgon2SaltLength = 8
+	minArgon2KeyLength  = 4
+)
+
+// DefaultArgon2id follows the second recommended option of RFC 9106 with
+// less memory, which suits a server hashing a few logins at a time.
+func DefaultArgon2id() Argon2id {
+	return Argon2id{Memory: 64 * 1024, Iterations: 3, Parallelism: 2, SaltLength: 16, KeyLength: 32}
+}
+
+// Validate returns an error if the parameters are outside what Argon2id
+// allows or what this package is willing to spend on one password.
+func (a Argon2id) Validate() error {
+	switch {
+	case a.Parallelism < 1:
+		return errors.New("argon2id parallelism must be at least 1")
+	case a.Iterations < 1 || a.Iterations > maxArgon2Iterations:
+		return fmt.Errorf("argon2id iterations must be between 1 and %d", maxArgon2Iterations)
+	case a.Memory < 8*uint32(a.Parallelism) || a.Memory > maxArgon2Memory:
+		return fmt.Errorf("argon2id memory must be between 8 KiB per lane and %d KiB", maxArgon2Memory)
+	case a.SaltLength < minArgon2SaltLength:
+		return fmt.Errorf("argon2id salt must be at least %d bytes", minArgon2SaltLength)
+	case a.KeyLength < minArgon2KeyLength:
+		return fmt.Errorf("argon2id key must be at least %d bytes", minArgon2KeyLength)
+	}
+	return nil
+}
+
+func (a Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, a.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, a.KeyLength)
+	return a.encode(salt, key), nil
+}
+
+func (a Argon2id) Verify(password, encoded string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (a Argon2id) Identify(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (a Argon2id) Current(encoded string) bool {
+	params, _, _, err := decodeArgon2id(encoded)
+	return err == nil && params == a
+}
+
+func (a Argon2id) encode(salt, key []byte) string {
+	b64 := base64.RawStdEncoding
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.Memory, a.Iterations, a.Parallelism, b64.EncodeToString(salt), b64.EncodeToString(key))
+}
+
+// decodeArgon2id splits a PHC string into its parameters, salt and key.
+func decodeArgon2id(encoded string) (Argon2id, []byte, []byte, error) {
+	var params Argon2id
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, errMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errMalformedHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, errMalformedHash
+	}
+
+	b64 := base64.RawStdEncoding
+	salt, err := b64.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, errMalformedHash
+	}
+	key, err := b64.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, errMalformedHash
+	}
+	params.SaltLength, params.KeyLength = uint32(len(salt)), uint32(len(key))
+	if err := params.Validate(); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %w", errMalformedHash, err)
+	}
+	return params, salt, key, nil
+}
+
+// Hashing hashes new passwords with one hasher and checks the hashes of
+// every supported scheme, so that users can still log in after the scheme
+// or its parameters change.
+type Hashing struct {
+	current Hasher
+	schemes []Hasher
+
+	// dummy is a hash made by current, which VerifyDummy checks passwords
+	// against.
+	dummyOnce sync.Once
+	dummy     string
+}
+
+// NewHashing returns a Hashing that hashes with current.
+func NewHashing(current Hasher) *Hashing {
+	return &Hashing{
+		current: current,
+		// current comes first, so its hashes are verified by it.
+		schemes: []Hasher{current, Bcrypt{Cost: bcrypt.DefaultCost}, DefaultArgon2id()},
+	}
+}
+
+func (h *Hashing) Hash(password string) (string, error) {
+	return h.current.Hash(password)
+}
+
+// Verify reports whether password matches encoded and, if so, whether
+// encoded should be replaced by a hash made with the current hasher. Hashes
+// of unknown schemes, such as the empty hash of a user without a password,
+// match nothing but take as long as VerifyDummy.
+func (h *Hashing) Verify(password, encoded string) (ok, rehash bool) {
+	for _, scheme := range h.schemes {
+		if !scheme.Identify(encoded) {
+			continue
+		}
+		ok, err := scheme.Verify(password, encoded)
+		if err != nil || !ok {
+			return false, false
+		}
+		return true, !h.current.Identify(encoded) || !h.current.Current(encoded)
+	}
+	h.VerifyDummy(password)
+	return false, false
+}
+
+// VerifyDummy checks password against a hash nobody has, which takes as
+// long as checking a real one. Logins for unknown accounts call it so the
+// response time does not tell which accounts exist.
+func (h *Hashing) VerifyDummy(password string) {
+	h.dummyOnce.Do(func() {
+		b := make([]byte, 16)
+		rand.Read(b)
+		h.dummy, _ = h.current.Hash(base64.RawStdEncoding.EncodeToString(b))
+	})
+	h.current.Verify(password, h.dummy)
+}
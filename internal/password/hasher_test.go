@@ -0,0 +1,296 @@
+package password
+
+import (
+	"encoding/base64"
+	"errors"
+	"regexp"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2id keeps the tests fast; the parameters are the smallest that
+// Validate allows for one lane.
+var testArgon2id = Argon2id{Memory: 8, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2idHash(t *testing.T) {
+	encoded, err := testArgon2id.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	phc := regexp.MustCompile(`^\$argon2id\$v=19\$m=8,t=1,p=1\$[A-Za-z0-9+/]{22}\$[A-Za-z0-9+/]{43}$`)
+	if !phc.MatchString(encoded) {
+		t.Fatalf("Hash = %q, not a PHC string with the parameters", encoded)
+	}
+	if other, _ := testArgon2id.Hash("correct horse"); other == encoded {
+		t.Error("two hashes of the same password share a salt")
+	}
+
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		t.Fatalf("decodeArgon2id: %v", err)
+	}
+	if params != testArgon2id {
+		t.Errorf("decoded parameters = %+v, want %+v", params, testArgon2id)
+	}
+	want := argon2.IDKey([]byte("correct horse"), salt, 1, 8, 1, 32)
+	if string(key) != string(want) {
+		t.Error("decoded key is not the Argon2id key of the password and salt")
+	}
+}
+
+func TestArgon2idVerify(t *testing.T) {
+	// A hash made with other parameters still verifies.
+	other := Argon2id{Memory: 16, Iterations: 2, Parallelism: 2, SaltLength: 8, KeyLength: 16}
+	for _, a := range []Argon2id{testArgon2id, other} {
+		encoded, err := a.Hash("correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := testArgon2id.Verify("correct horse", encoded); !ok || err != nil {
+			t.Errorf("Verify(right password, %s) = %v, %v; want true", encoded, ok, err)
+		}
+		if ok, err := testArgon2id.Verify("correct horsf", encoded); ok || err != nil {
+			t.Errorf("Verify(wrong password, %s) = %v, %v; want false, nil", encoded, ok, err)
+		}
+	}
+}
+
+func TestArgon2idCurrent(t *testing.T) {
+	encoded, err := testArgon2id.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !testArgon2id.Current(encoded) {
+		t.Error("Current = false for a hash made with the same parameters")
+	}
+
+	changed := []Argon2id{testArgon2id, testArgon2id, testArgon2id, testArgon2id, testArgon2id}
+	changed[0].Memory = 16
+	changed[1].Iterations = 2
+	changed[2].Parallelism = 2
+	changed[3].SaltLength = 8
+	changed[4].KeyLength = 16
+	for _, a := range changed {
+		if a.Current(encoded) {
+			t.Errorf("%+v: Current = true for a hash made with %+v", a, testArgon2id)
+		}
+	}
+	if testArgon2id.Current("$argon2id$garbage") {
+		t.Error("Current = true for a malformed hash")
+	}
+}
+
+func TestDecodeArgon2idMalformed(t *testing.T) {
+	b64 := base64.RawStdEncoding
+	salt, key := b64.EncodeToString(make([]byte, 16)), b64.EncodeToString(make([]byte, 32))
+	phc := func(version, params string) string {
+		return "$argon2id$" + version + "$" + params + "$" + salt + "$" + key
+	}
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"empty", ""},
+		{"too few fields", "$argon2id$v=19$m=8,t=1,p=1$" + salt},
+		{"too many fields", phc("v=19", "m=8,t=1,p=1") + "$"},
+		{"argon2i", "$argon2i$v=19$m=8,t=1,p=1$" + salt + "$" + key},
+		{"old version", phc("v=16", "m=8,t=1,p=1")},
+		{"missing version", phc("", "m=8,t=1,p=1")},
+		{"missing parameters", phc("v=19", "m=8,t=1")},
+		{"negative memory", phc("v=19", "m=-8,t=1,p=1")},
+		{"zero lanes", phc("v=19", "m=8,t=1,p=0")},
+		{"too many lanes", phc("v=19", "m=8,t=1,p=256")},
+		{"zero iterations", phc("v=19", "m=8,t=0,p=1")},
+		{"too many iterations", phc("v=19", "m=8,t=4294967295,p=1")},
+		{"zero memory", phc("v=19", "m=0,t=1,p=1")},
+		{"too little memory for the lanes", phc("v=19", "m=8,t=1,p=2")},
+		{"too much memory", phc("v=19", "m=4294967295,t=1,p=1")},
+		{"short salt", "$argon2id$v=19$m=8,t=1,p=1$" + b64.EncodeToString(make([]byte, 4)) + "$" + key},
+		{"empty key", "$argon2id$v=19$m=8,t=1,p=1$" + salt + "$"},
+		{"salt not base64", "$argon2id$v=19$m=8,t=1,p=1$!!!!$" + key},
+		{"key not base64", "$argon2id$v=19$m=8,t=1,p=1$" + salt + "$!!!!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, _, err := decodeArgon2id(tt.encoded); !errors.Is(err, errMalformedHash) {
+				t.Fatalf("decodeArgon2id(%q) error = %v, want errMalformedHash", tt.encoded, err)
+			}
+			// Verify must fail rather than panic or run for ages.
+			if ok, err := testArgon2id.Verify("correct horse", tt.encoded); ok || err == nil {
+				t.Fatalf("Verify(%q) = %v, %v; want an error", tt.encoded, ok, err)
+			}
+		})
+	}
+}
+
+func TestArgon2idValidate(t *testing.T) {
+	if err := DefaultArgon2id().Validate(); err != nil {
+		t.Errorf("DefaultArgon2id().Validate() = %v", err)
+	}
+	if err := testArgon2id.Validate(); err != nil {
+		t.Errorf("testArgon2id.Validate() = %v", err)
+	}
+
+	bad := []Argon2id{testArgon2id, testArgon2id, testArgon2id, testArgon2id, testArgon2id}
+	bad[0].Parallelism = 0
+	bad[1].Iterations = maxArgon2Iterations + 1
+	bad[2].Memory = maxArgon2Memory + 1
+	bad[3].SaltLength = minArgon2SaltLength - 1
+	bad[4].KeyLength = minArgon2KeyLength - 1
+	for _, a := range bad {
+		if err := a.Validate(); err == nil {
+			t.Errorf("%+v: Validate() = nil, want an error", a)
+		}
+	}
+}
+
+func TestBcrypt(t *testing.T) {
+	b := Bcrypt{Cost: bcrypt.MinCost}
+	encoded, err := b.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !b.Identify(encoded) {
+		t.Errorf("Identify(%q) = false", encoded)
+	}
+	if ok, err := b.Verify("correct horse", encoded); !ok || err != nil {
+		t.Errorf("Verify(right password) = %v, %v; want true", ok, err)
+	}
+	if ok, err := b.Verify("correct horsf", encoded); ok || err != nil {
+		t.Errorf("Verify(wrong password) = %v, %v; want false, nil", ok, err)
+	}
+	if ok, err := b.Verify("correct horse", "$2a$04$short"); ok || err == nil {
+		t.Errorf("Verify(malformed) = %v, %v; want an error", ok, err)
+	}
+
+	if !b.Current(encoded) {
+		t.Error("Current = false for a hash made with the same cost")
+	}
+	if (Bcrypt{Cost: bcrypt.MinCost + 1}).Current(encoded) {
+		t.Error("Current = true for a hash made with another cost")
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	tests := []struct {
+		encoded          string
+		bcrypt, argon2id bool
+	}{
+		{"$2a$10$abc", true, false},
+		{"$2b$10$abc", true, false},
+		{"$2y$10$abc", true, false},
+		{"$argon2id$v=19$m=8,t=1,p=1$abc$abc", false, true},
+		{"$argon2i$v=19$m=8,t=1,p=1$abc$abc", false, false},
+		{"$1$abc", false, false},
+		{"correct horse", false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		if got := (Bcrypt{}).Identify(tt.encoded); got != tt.bcrypt {
+			t.Errorf("Bcrypt.Identify(%q) = %v, want %v", tt.encoded, got, tt.bcrypt)
+		}
+		if got := (Argon2id{}).Identify(tt.encoded); got != tt.argon2id {
+			t.Errorf("Argon2id.Identify(%q) = %v, want %v", tt.encoded, got, tt.argon2id)
+		}
+	}
+}
+
+func TestHashingVerify(t *testing.T) {
+	hash := func(h Hasher) string {
+		t.Helper()
+		encoded, err := h.Hash("correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encoded
+	}
+	otherArgon2id := testArgon2id
+	otherArgon2id.Iterations = 2
+	minBcrypt := Bcrypt{Cost: bcrypt.MinCost}
+	otherBcrypt := Bcrypt{Cost: bcrypt.MinCost + 1}
+
+	tests := []struct {
+		name     string
+		current  Hasher
+		encoded  string
+		password string
+		ok       bool
+		rehash   bool
+	}{
+		{"current argon2id", testArgon2id, hash(testArgon2id), "correct horse", true, false},
+		{"argon2id with other parameters", testArgon2id, hash(otherArgon2id), "correct horse", true, true},
+		{"bcrypt when argon2id is current", testArgon2id, hash(minBcrypt), "correct horse", true, true},
+		{"current bcrypt", minBcrypt, hash(minBcrypt), "correct horse", true, false},
+		{"bcrypt with another cost", minBcrypt, hash(otherBcrypt), "correct horse", true, true},
+		{"argon2id when bcrypt is current", minBcrypt, hash(testArgon2id), "correct horse", true, true},
+		{"wrong password", testArgon2id, hash(testArgon2id), "correct horsf", false, false},
+		{"wrong password for an old scheme", testArgon2id, hash(minBcrypt), "correct horsf", false, false},
+		{"malformed hash", testArgon2id, "$argon2id$v=19$m=8,t=1,p=0$AAAAAAAAAAA$AAAAAAAAAAA", "correct horse", false, false},
+		{"unknown scheme", testArgon2id, "$1$abc$def", "correct horse", false, false},
+		{"plain text", testArgon2id, "correct horse", "correct horse", false, false},
+		{"empty", testArgon2id, "", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, rehash := NewHashing(tt.current).Verify(tt.password, tt.encoded)
+			if ok != tt.ok || rehash != tt.rehash {
+				t.Fatalf("Verify = %v, %v; want %v, %v", ok, rehash, tt.ok, tt.rehash)
+			}
+		})
+	}
+}
+
+func TestHashingHash(t *testing.T) {
+	h := NewHashing(testArgon2id)
+	encoded, err := h.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !testArgon2id.Identify(encoded) || !testArgon2id.Current(encoded) {
+		t.Fatalf("Hash = %q, not made with the current hasher", encoded)
+	}
+	if ok, rehash := h.Verify("correct horse", encoded); !ok || rehash {
+		t.Fatalf("Verify(own hash) = %v, %v; want true, false", ok, rehash)
+	}
+}
+
+// countingHasher is a Hasher that counts its hashes and checks.
+type countingHasher struct {
+	Argon2id
+	hashed, verified *int
+}
+
+func (h countingHasher) Hash(password string) (string, error) {
+	*h.hashed++
+	return h.Argon2id.Hash(password)
+}
+
+func (h countingHasher) Verify(password, encoded string) (bool, error) {
+	*h.verified++
+	return h.Argon2id.Verify(password, encoded)
+}
+
+func TestHashingVerifyDummy(t *testing.T) {
+	var hashed, verified int
+	h := NewHashing(countingHasher{testArgon2id, &hashed, &verified})
+
+	h.VerifyDummy("correct horse")
+	h.VerifyDummy("correct horse")
+	if hashed != 1 || verified != 2 {
+		t.Fatalf("two VerifyDummy calls made %d hashes and %d checks, want 1 and 2", hashed, verified)
+	}
+
+	// A hash no scheme knows, like the empty hash of a user without a
+	// password, costs a check too.
+	if ok, _ := h.Verify("correct horse", ""); ok {
+		t.Fatal("Verify of an empty hash = true")
+	}
+	if verified != 3 {
+		t.Fatalf("Verify of an empty hash made %d checks, want 1", verified-2)
+	}
+}
@@ -0,0 +1,90 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrPolicy is wrapped by the errors of Policy.Check.
+var ErrPolicy = errors.New("password does not meet the policy")
+
+// Policy is what new passwords must be like. Passwords that are already set
+// are not checked again, so tightening it never locks anyone out.
+type Policy struct {
+	// MinLength is in characters; MaxLength in bytes, as bcrypt only uses
+	// the first 72 bytes of a password.
+	MinLength int
+	MaxLength int
+	// breached holds the SHA-1 hashes of passwords known from data
+	// breaches.
+	breached map[[sha1.Size]byte]struct{}
+}
+
+// Check returns an error wrapping ErrPolicy if password may not be used.
+func (p *Policy) Check(password string) error {
+	if n := utf8.RuneCountInString(password); n < p.MinLength {
+		return fmt.Errorf("%w: it must be at least %d characters long", ErrPolicy, p.MinLength)
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		return fmt.Errorf("%w: it must be at most %d bytes long", ErrPolicy, p.MaxLength)
+	}
+	if _, ok := p.breached[sha1.Sum([]byte(password))]; ok {
+		return fmt.Errorf("%w: it has appeared in a data breach, choose another", ErrPolicy)
+	}
+	return nil
+}
+
+// LoadBreached reads the breached passwords in the file at path, one per
+// line. A line is either a password or, as in the Pwned Passwords
+// downloads, the hex SHA-1 hash of one, optionally followed by a colon and
+// a count. Blank lines and lines starting with # are skipped.
+func (p *Policy) LoadBreached(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if p.breached == nil {
+		p.breached = make(map[[sha1.Size]byte]struct{})
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if hash, ok := parseSHA1(line); ok {
+			p.breached[hash] = struct{}{}
+			continue
+		}
+		p.breached[sha1.Sum([]byte(line))] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// Breached returns how many breached passwords are loaded.
+func (p *Policy) Breached() int {
+	return len(p.breached)
+}
+
+// parseSHA1 parses a line of the form <40 hex digits>[:count].
+func parseSHA1(line string) ([sha1.Size]byte, bool) {
+	var hash [sha1.Size]byte
+	digits, _, _ := strings.Cut(line, ":")
+	if len(digits) != hex.EncodedLen(sha1.Size) {
+		return hash, false
+	}
+	if _, err := hex.Decode(hash[:], []byte(digits)); err != nil {
+		return hash, false
+	}
+	return hash, true
+}
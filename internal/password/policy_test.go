@@ -0,0 +1,109 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestCheck(t *testing.T) {
+	p := &Policy{MinLength: 8, MaxLength: 16}
+
+	tests := []struct {
+		password string
+		ok       bool
+	}{
+		{"1234567", false},
+		{"12345678", true},
+		{"1234567890123456", true},
+		{"12345678901234567", false},
+		// 8 characters in 16 bytes.
+		{"éééééééé", true},
+		// 6 characters in 18 bytes.
+		{"堀堀堀堀堀堀", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		err := p.Check(tt.password)
+		if (err == nil) != tt.ok {
+			t.Errorf("Check(%q) = %v, want ok %v", tt.password, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrPolicy) {
+			t.Errorf("Check(%q) = %v, does not wrap ErrPolicy", tt.password, err)
+		}
+	}
+
+	if err := (&Policy{MinLength: 1}).Check(strings.Repeat("a", 1000)); err != nil {
+		t.Errorf("Check with no MaxLength = %v, want nil", err)
+	}
+}
+
+func TestLoadBreached(t *testing.T) {
+	lines := []string{
+		"# passwords from a breach",
+		"",
+		"hunter2",
+		"correct horse\r",
+		sha1Hex("letmein123"),
+		strings.ToUpper(sha1Hex("trustno1!")) + ":42",
+		sha1Hex("qwertyuiop") + ":7\r",
+		"   ",
+		"#hashtag",
+		// 40 characters, but not hex: a password.
+		strings.Repeat("z", 40),
+	}
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Policy{MinLength: 1}
+	if err := p.LoadBreached(path); err != nil {
+		t.Fatalf("LoadBreached: %v", err)
+	}
+	if got := p.Breached(); got != 7 {
+		t.Errorf("Breached() = %d, want 7", got)
+	}
+
+	for _, breached := range []string{"hunter2", "correct horse", "letmein123", "trustno1!", "qwertyuiop", "   ", strings.Repeat("z", 40)} {
+		if err := p.Check(breached); !errors.Is(err, ErrPolicy) {
+			t.Errorf("Check(%q) = %v, want it refused as breached", breached, err)
+		}
+	}
+	for _, fine := range []string{
+		"hunter3",
+		"# passwords from a breach",
+		"#hashtag",
+		sha1Hex("letmein123"),
+		"correct horse\r",
+	} {
+		if err := p.Check(fine); err != nil {
+			t.Errorf("Check(%q) = %v, want nil", fine, err)
+		}
+	}
+
+	// A second file adds to the first.
+	more := filepath.Join(t.TempDir(), "more.txt")
+	if err := os.WriteFile(more, []byte("password1\nhunter2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.LoadBreached(more); err != nil {
+		t.Fatalf("LoadBreached: %v", err)
+	}
+	if got := p.Breached(); got != 8 {
+		t.Errorf("Breached() after a second file = %d, want 8", got)
+	}
+
+	if err := p.LoadBreached(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("LoadBreached of a missing file = nil, want an error")
+	}
+}
@@ -0,0 +1,75 @@
+// Package policy decides whether a user is allowed to act on a resource.
+package policy
+
+import (
+	"errors"
+
+	"open-todo-go/internal/store"
+)
+
+type Action int
+
+const (
+	Read Action = iota
+	Write
+	// Manage covers changing a project itself and who it is shared with,
+	// and taking a todo out of its project.
+	Manage
+)
+
+// ErrForbidden is returned when the user can see the resource but may not
+// perform the action on it.
+var ErrForbidden = errors.New("forbidden")
+
+// Todo reports whether user may perform action on todo. role is the user's
+// role in the todo's project, empty if they have none. Callers that cannot
+// see the todo get store.ErrNotFound so the API never leaks the existence of
+// another user's todos; members that may only read get ErrForbidden, as do
+// members asking to manage a todo, which only its owner may.
+func Todo(user *store.User, todo *store.Todo, role store.Role, action Action) error {
+	if user == nil || todo == nil {
+		return store.ErrNotFound
+	}
+
+	if todo.UserID == user.ID {
+		return nil
+	}
+
+	if todo.ProjectID == nil || role == "" {
+		return store.ErrNotFound
+	}
+
+	switch {
+	case action == Manage:
+		return ErrForbidden
+	case action == Write && !role.CanWrite():
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+// Project reports whether user may perform action on project, which must
+// have been loaded for user so its Role is theirs. Todos in the project can
+// be changed by editors, the project itself only by its owner.
+func Project(user *store.User, project *store.Project, action Action) error {
+	if user == nil || project == nil {
+		return store.ErrNotFound
+	}
+
+	role := project.Role
+	if project.UserID == user.ID {
+		role = store.RoleOwner
+	}
+
+	switch {
+	case role == "":
+		return store.ErrNotFound
+	case action == Manage && role != store.RoleOwner:
+		return ErrForbidden
+	case action == Write && !role.CanWrite():
+		return ErrForbidden
+	}
+
+	return nil
+}
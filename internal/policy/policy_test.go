@@ -0,0 +1,81 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"open-todo-go/internal/store"
+)
+
+func TestTodo(t *testing.T) {
+	alice := &store.User{ID: 1}
+	bob := &store.User{ID: 2}
+	projectID := int64(10)
+	private := &store.Todo{ID: 100, UserID: alice.ID}
+	shared := &store.Todo{ID: 101, UserID: alice.ID, ProjectID: &projectID}
+
+	tests := []struct {
+		name   string
+		user   *store.User
+		todo   *store.Todo
+		role   store.Role
+		action Action
+		want   error
+	}{
+		{"owner reads", alice, private, "", Read, nil},
+		{"owner writes", alice, private, "", Write, nil},
+		{"owner writes shared", alice, shared, store.RoleOwner, Write, nil},
+		{"stranger reads", bob, private, "", Read, store.ErrNotFound},
+		{"stranger writes", bob, private, "", Write, store.ErrNotFound},
+		{"role without project", bob, private, store.RoleEditor, Read, store.ErrNotFound},
+		{"non-member reads shared", bob, shared, "", Read, store.ErrNotFound},
+		{"viewer reads", bob, shared, store.RoleViewer, Read, nil},
+		{"viewer writes", bob, shared, store.RoleViewer, Write, ErrForbidden},
+		{"editor writes", bob, shared, store.RoleEditor, Write, nil},
+		{"owner manages shared", alice, shared, store.RoleOwner, Manage, nil},
+		{"editor manages", bob, shared, store.RoleEditor, Manage, ErrForbidden},
+		{"stranger manages", bob, private, "", Manage, store.ErrNotFound},
+		{"no user", nil, private, "", Read, store.ErrNotFound},
+		{"no todo", alice, nil, "", Read, store.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Todo(tt.user, tt.todo, tt.role, tt.action); !errors.Is(err, tt.want) {
+				t.Fatalf("Todo = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestProject(t *testing.T) {
+	alice := &store.User{ID: 1}
+	bob := &store.User{ID: 2}
+	project := func(role store.Role) *store.Project {
+		return &store.Project{ID: 10, UserID: alice.ID, Role: role}
+	}
+
+	tests := []struct {
+		name    string
+		user    *store.User
+		project *store.Project
+		action  Action
+		want    error
+	}{
+		{"owner manages", alice, project(""), Manage, nil},
+		{"owner writes", alice, project(""), Write, nil},
+		{"non-member reads", bob, project(""), Read, store.ErrNotFound},
+		{"viewer reads", bob, project(store.RoleViewer), Read, nil},
+		{"viewer writes", bob, project(store.RoleViewer), Write, ErrForbidden},
+		{"editor writes", bob, project(store.RoleEditor), Write, nil},
+		{"editor manages", bob, project(store.RoleEditor), Manage, ErrForbidden},
+		{"no user", nil, project(""), Read, store.ErrNotFound},
+		{"no project", alice, nil, Read, store.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Project(tt.user, tt.project, tt.action); !errors.Is(err, tt.want) {
+				t.Fatalf("Project = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
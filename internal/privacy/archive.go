@@ -0,0 +1,174 @@
+// Package privacy carries out the requests users make about their data:
+// exporting it as a downloadable archive and erasing it.
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"open-todo-go/internal/store"
+)
+
+// historyPage is how many audit entries or todo versions are read at a
+// time.
+const historyPage = 100
+
+// TagCount is how many of the user's todos have a tag.
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Todos int    `json:"todos"`
+}
+
+// BuildArchive collects everything stored about the user into a ZIP
+// archive of JSON files, one per kind of data. Secrets such as password
+// hashes and token hashes are left out, as the stores never return them.
+func BuildArchive(ctx context.Context, s store.Storage, userID int64) ([]byte, error) {
+	user, err := s.Users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("user: %w", err)
+	}
+
+	todos, err := s.Todos.GetAllTodos(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("todos: %w", err)
+	}
+
+	var reminders []store.Reminder
+	for _, todo := range todos {
+		r, err := s.Reminders.GetByTodo(ctx, userID, todo.ID)
+		if err != nil {
+			return nil, fmt.Errorf("reminders: %w", err)
+		}
+		reminders = append(reminders, r...)
+	}
+
+	projects, err := s.Projects.GetByUser(ctx, userID, true)
+	if err != nil {
+		return nil, fmt.Errorf("projects: %w", err)
+	}
+
+	tokens, err := s.AccessTokens.GetByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("access tokens: %w", err)
+	}
+
+	identities, err := s.Identities.GetByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("identities: %w", err)
+	}
+
+	history, err := auditHistory(ctx, s, userID)
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+
+	trash, err := s.Todos.GetTrash(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("trash: %w", err)
+	}
+
+	versions, err := todoHistory(ctx, s, userID)
+	if err != nil {
+		return nil, fmt.Errorf("todo history: %w", err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct {
+		name string
+		data any
+	}{
+		{"profile.json", user},
+		{"todos.json", orEmpty(todos)},
+		{"tags.json", countTags(todos)},
+		{"reminders.json", orEmpty(reminders)},
+		{"projects.json", orEmpty(projects)},
+		{"access_tokens.json", orEmpty(tokens)},
+		{"identities.json", orEmpty(identities)},
+		{"history.json", history},
+		{"trash.json", orEmpty(trash)},
+		{"todo_history.json", versions},
+	} {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.data); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// auditHistory returns every audit entry about the user, newest first.
+func auditHistory(ctx context.Context, s store.Storage, userID int64) ([]store.AuditEntry, error) {
+	history := []store.AuditEntry{}
+	filter := store.AuditFilter{TargetUserID: &userID, Limit: historyPage}
+	for {
+		entries, err := s.Audit.List(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, entries...)
+		if len(entries) < historyPage {
+			return history, nil
+		}
+		filter.Before = entries[len(entries)-1].ID
+	}
+}
+
+// todoHistory returns every version of the user's todos, newest first.
+func todoHistory(ctx context.Context, s store.Storage, userID int64) ([]store.TodoVersion, error) {
+	versions := []store.TodoVersion{}
+	filter := store.TodoHistoryFilter{UserID: &userID, Limit: historyPage}
+	for {
+		page, err := s.TodoHistory.List(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, page...)
+		if len(page) < historyPage {
+			return versions, nil
+		}
+		filter.Before = page[len(page)-1].ID
+	}
+}
+
+// countTags returns the tags of todos, most used first.
+func countTags(todos []store.Todo) []TagCount {
+	counts := make(map[string]int)
+	for _, todo := range todos {
+		for _, tag := range todo.Tags {
+			counts[tag]++
+		}
+	}
+
+	tags := make([]TagCount, 0, len(counts))
+	for tag, n := range counts {
+		tags = append(tags, TagCount{Tag: tag, Todos: n})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Todos != tags[j].Todos {
+			return tags[i].Todos > tags[j].Todos
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	return tags
+}
+
+// orEmpty makes a nil slice encode as [] rather than null.
+func orEmpty[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
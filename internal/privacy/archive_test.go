@@ -0,0 +1,238 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"open-todo-go/internal/password"
+	"open-todo-go/internal/store"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestBuildArchive(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryStorage()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	home := &store.Project{UserID: alice.ID, Name: "Home", Color: "#336699"}
+	if err := s.Projects.Create(ctx, home); err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	milk := createTodo(t, s, &store.Todo{UserID: alice.ID, Title: "Buy milk", Tags: []string{"groceries", "errands"}, ProjectID: &home.ID})
+	createTodo(t, s, &store.Todo{UserID: alice.ID, Title: "Pay rent", Tags: []string{"errands"}})
+	old := createTodo(t, s, &store.Todo{UserID: alice.ID, Title: "Old plan"})
+	if err := s.Todos.DeleteTodo(ctx, alice.ID, old.ID); err != nil {
+		t.Fatalf("DeleteTodo: %v", err)
+	}
+	if err := s.Reminders.Create(ctx, &store.Reminder{TodoID: milk.ID, UserID: alice.ID, RemindAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("create reminder: %v", err)
+	}
+	token := &store.AccessToken{UserID: alice.ID, Name: "cli", Scope: store.ScopeRead}
+	if err := s.AccessTokens.Create(ctx, token); err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	if err := s.Identities.Create(ctx, &store.Identity{UserID: alice.ID, Provider: "github", Subject: "alice-42"}); err != nil {
+		t.Fatalf("create identity: %v", err)
+	}
+	if err := s.Audit.Create(ctx, &store.AuditEntry{Action: "user.suspend", TargetUserID: &alice.ID, Details: json.RawMessage(`{}`)}); err != nil {
+		t.Fatalf("create audit entry: %v", err)
+	}
+	if err := s.TodoHistory.Create(ctx, &store.TodoVersion{ActorID: &alice.ID, Action: store.TodoCreated, Todo: *milk}); err != nil {
+		t.Fatalf("create todo version: %v", err)
+	}
+
+	// None of bob's data belongs in alice's archive.
+	createTodo(t, s, &store.Todo{UserID: bob.ID, Title: "Bob's surprise party", Tags: []string{"secret"}})
+	if err := s.Projects.Create(ctx, &store.Project{UserID: bob.ID, Name: "Bob's project", Color: "#336699"}); err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	if err := s.Audit.Create(ctx, &store.AuditEntry{Action: "user.unsuspend", TargetUserID: &bob.ID, Details: json.RawMessage(`{}`)}); err != nil {
+		t.Fatalf("create audit entry: %v", err)
+	}
+
+	data, err := BuildArchive(ctx, s, alice.ID)
+	if err != nil {
+		t.Fatalf("BuildArchive: %v", err)
+	}
+	files := readArchive(t, data)
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	want := []string{
+		"access_tokens.json", "history.json", "identities.json", "profile.json", "projects.json",
+		"reminders.json", "tags.json", "todo_history.json", "todos.json", "trash.json",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("archive files = %v, want %v", names, want)
+	}
+
+	var profile store.User
+	decodeFile(t, files, "profile.json", &profile)
+	if profile.ID != alice.ID || profile.Email != "alice@example.com" {
+		t.Errorf("profile = %+v, want alice", profile)
+	}
+
+	var todos []store.Todo
+	decodeFile(t, files, "todos.json", &todos)
+	if got := todoTitles(todos); !reflect.DeepEqual(got, []string{"Buy milk", "Pay rent"}) {
+		t.Errorf("todos = %v, want Buy milk and Pay rent", got)
+	}
+
+	var trash []store.Todo
+	decodeFile(t, files, "trash.json", &trash)
+	if got := todoTitles(trash); !reflect.DeepEqual(got, []string{"Old plan"}) {
+		t.Errorf("trash = %v, want Old plan", got)
+	}
+
+	var tags []TagCount
+	decodeFile(t, files, "tags.json", &tags)
+	if want := []TagCount{{"errands", 2}, {"groceries", 1}}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags = %v, want %v", tags, want)
+	}
+
+	var reminders []store.Reminder
+	decodeFile(t, files, "reminders.json", &reminders)
+	if len(reminders) != 1 || reminders[0].TodoID != milk.ID {
+		t.Errorf("reminders = %+v, want the one on Buy milk", reminders)
+	}
+
+	var projects []store.Project
+	decodeFile(t, files, "projects.json", &projects)
+	if len(projects) != 1 || projects[0].Name != "Home" {
+		t.Errorf("projects = %+v, want Home", projects)
+	}
+
+	var tokens []map[string]any
+	decodeFile(t, files, "access_tokens.json", &tokens)
+	if len(tokens) != 1 || tokens[0]["name"] != "cli" {
+		t.Errorf("access tokens = %+v, want cli", tokens)
+	} else if _, ok := tokens[0]["token"]; ok {
+		t.Error("access token exported with its secret")
+	}
+
+	var identities []store.Identity
+	decodeFile(t, files, "identities.json", &identities)
+	if len(identities) != 1 || identities[0].Subject != "alice-42" {
+		t.Errorf("identities = %+v, want github alice-42", identities)
+	}
+
+	var history []store.AuditEntry
+	decodeFile(t, files, "history.json", &history)
+	if len(history) != 1 || history[0].Action != "user.suspend" {
+		t.Errorf("history = %+v, want the suspension of alice", history)
+	}
+
+	var versions []store.TodoVersion
+	decodeFile(t, files, "todo_history.json", &versions)
+	if len(versions) != 1 || versions[0].TodoID != milk.ID || versions[0].Action != store.TodoCreated {
+		t.Errorf("todo history = %+v, want the creation of Buy milk", versions)
+	}
+
+	for name, content := range files {
+		for _, leak := range []string{"bob", "Bob", "secret", string(alice.Password.Hash), token.Token} {
+			if strings.Contains(content, leak) {
+				t.Errorf("%s contains %q", name, leak)
+			}
+		}
+	}
+}
+
+func TestBuildArchiveEmpty(t *testing.T) {
+	s := store.NewMemoryStorage()
+	alice := createUser(t, s, "alice")
+
+	data, err := BuildArchive(context.Background(), s, alice.ID)
+	if err != nil {
+		t.Fatalf("BuildArchive: %v", err)
+	}
+	for name, content := range readArchive(t, data) {
+		if name == "profile.json" {
+			continue
+		}
+		if got := strings.TrimSpace(content); got != "[]" {
+			t.Errorf("%s = %s, want []", name, got)
+		}
+	}
+
+	if _, err := BuildArchive(context.Background(), s, alice.ID+100); err == nil {
+		t.Error("BuildArchive of a missing user succeeded")
+	}
+}
+
+func createUser(t *testing.T, s store.Storage, username string) *store.User {
+	t.Helper()
+
+	hash, err := password.Bcrypt{Cost: bcrypt.MinCost}.Hash("password123")
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	user := &store.User{Username: username, Email: username + "@example.com"}
+	user.Password.Hash = []byte(hash)
+	if err := s.Users.Create(context.Background(), user); err != nil {
+		t.Fatalf("create user %q: %v", username, err)
+	}
+	return user
+}
+
+func createTodo(t *testing.T, s store.Storage, todo *store.Todo) *store.Todo {
+	t.Helper()
+
+	if err := s.Todos.Create(context.Background(), todo); err != nil {
+		t.Fatalf("create todo %q: %v", todo.Title, err)
+	}
+	return todo
+}
+
+// readArchive returns the contents of the files in a ZIP archive by name.
+func readArchive(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", f.Name, err)
+		}
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+func decodeFile(t *testing.T, files map[string]string, name string, dst any) {
+	t.Helper()
+
+	if err := json.Unmarshal([]byte(files[name]), dst); err != nil {
+		t.Fatalf("decode %s: %v", name, err)
+	}
+}
+
+func todoTitles(todos []store.Todo) []string {
+	titles := make([]string, 0, len(todos))
+	for _, todo := range todos {
+		titles = append(titles, todo.Title)
+	}
+	sort.Strings(titles)
+	return titles
+}
@@ -0,0 +1,47 @@
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Links makes and checks the signed links export archives are downloaded
+// from. A link names the export and when it expires, signed with Key, so
+// it works without logging in and cannot be changed to reach another
+// export or to last longer.
+type Links struct {
+	// BaseURL is where the API is served, such as https://api.example.com.
+	BaseURL string
+	Key     []byte
+}
+
+// URL returns the download link of export id, valid until expiresAt.
+func (l *Links) URL(id int64, expiresAt time.Time) string {
+	expires := expiresAt.Unix()
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("signature", l.sign(id, expires))
+	return fmt.Sprintf("%s/api/v1/exports/%d/download?%s", l.BaseURL, id, q.Encode())
+}
+
+// Verify reports whether expires and signature, taken from the query of a
+// download link, are a valid signature for export id that has not expired
+// at now.
+func (l *Links) Verify(id int64, expires, signature string, now time.Time) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || now.Unix() >= exp {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(l.sign(id, exp)))
+}
+
+func (l *Links) sign(id int64, expires int64) string {
+	mac := hmac.New(sha256.New, l.Key)
+	fmt.Fprintf(mac, "export:%d:%d", id, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,71 @@
+package privacy
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLinks(t *testing.T) {
+	links := &Links{BaseURL: "https://api.example.com", Key: []byte("0123456789abcdef0123456789abcdef")}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	expiresAt := now.Add(time.Hour)
+
+	u, err := url.Parse(links.URL(42, expiresAt))
+	if err != nil {
+		t.Fatalf("parse URL: %v", err)
+	}
+	if got, want := u.Scheme+"://"+u.Host+u.Path, "https://api.example.com/api/v1/exports/42/download"; got != want {
+		t.Fatalf("URL = %s, want %s", got, want)
+	}
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+	if expires != strconv.FormatInt(expiresAt.Unix(), 10) {
+		t.Fatalf("expires = %s, want %d", expires, expiresAt.Unix())
+	}
+
+	// flip changes the last character of s.
+	flip := func(s string) string {
+		last := s[len(s)-1]
+		if last == '0' {
+			return s[:len(s)-1] + "1"
+		}
+		return s[:len(s)-1] + "0"
+	}
+	later := strconv.FormatInt(expiresAt.Add(24*time.Hour).Unix(), 10)
+	otherKey := &Links{BaseURL: links.BaseURL, Key: []byte("fedcba9876543210fedcba9876543210")}
+	forged, _ := url.Parse(otherKey.URL(42, expiresAt))
+
+	tests := []struct {
+		name      string
+		links     *Links
+		id        int64
+		expires   string
+		signature string
+		now       time.Time
+		ok        bool
+	}{
+		{"valid", links, 42, expires, signature, now, true},
+		{"just before expiry", links, 42, expires, signature, expiresAt.Add(-time.Second), true},
+		{"at expiry", links, 42, expires, signature, expiresAt, false},
+		{"expired", links, 42, expires, signature, expiresAt.Add(time.Minute), false},
+		{"other export", links, 43, expires, signature, now, false},
+		{"extended expiry", links, 42, later, signature, now, false},
+		{"expiry not a number", links, 42, "tomorrow", signature, now, false},
+		{"no expiry", links, 42, "", signature, now, false},
+		{"tampered signature", links, 42, expires, flip(signature), now, false},
+		{"upper case signature", links, 42, expires, strings.ToUpper(signature), now, false},
+		{"truncated signature", links, 42, expires, signature[:len(signature)-2], now, false},
+		{"no signature", links, 42, expires, "", now, false},
+		{"signed with another key", links, 42, expires, forged.Query().Get("signature"), now, false},
+		{"checked with another key", otherKey, 42, expires, signature, now, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.links.Verify(tt.id, tt.expires, tt.signature, tt.now); got != tt.ok {
+				t.Fatalf("Verify = %v, want %v", got, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package privacy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"open-todo-go/internal/mailer"
+	"open-todo-go/internal/store"
+
+	"go.uber.org/zap"
+)
+
+// staleAfter is how long a request can run before it is assumed that the
+// worker running it stopped, and it is claimed again.
+const staleAfter = 30 * time.Minute
+
+// Worker runs the data requests users make: it builds export archives and
+// mails their download links, erases accounts, and deletes archives whose
+// links have expired.
+type Worker struct {
+	store    store.Storage
+	mailer   mailer.Mailer
+	links    *Links
+	logger   *zap.SugaredLogger
+	interval time.Duration
+	// ttl is how long an export can be downloaded.
+	ttl   time.Duration
+	batch int
+}
+
+func NewWorker(s store.Storage, m mailer.Mailer, links *Links, logger *zap.SugaredLogger, interval, ttl time.Duration) *Worker {
+	return &Worker{
+		store:    s,
+		mailer:   m,
+		links:    links,
+		logger:   logger,
+		interval: interval,
+		ttl:      ttl,
+		batch:    10,
+	}
+}
+
+// Run polls for data requests every interval until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.Tick(ctx, time.Now()); err != nil {
+			w.logger.Errorw("privacy worker", "error", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick deletes the exports that expired at now, then runs every pending
+// request and returns how many succeeded. A request that fails is marked
+// failed with the reason, and not retried.
+func (w *Worker) Tick(ctx context.Context, now time.Time) (int, error) {
+	expired, err := w.store.DataRequests.DeleteExpired(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	if expired > 0 {
+		w.logger.Infow("deleted expired exports", "count", expired)
+	}
+
+	done := 0
+	for {
+		requests, err := w.store.DataRequests.ClaimPending(ctx, now.Add(-staleAfter), w.batch)
+		if err != nil {
+			return done, err
+		}
+
+		for _, req := range requests {
+			if err := w.run(ctx, req, now); err != nil {
+				w.logger.Errorw("data request failed", "requestID", req.ID, "kind", req.Kind,
+					"userID", req.UserID, "error", err.Error())
+				if err := w.store.DataRequests.Fail(ctx, req.ID, err.Error()); err != nil {
+					w.logger.Errorw("failed to mark data request failed", "requestID", req.ID, "error", err.Error())
+				}
+				continue
+			}
+			done++
+		}
+
+		if len(requests) < w.batch {
+			return done, nil
+		}
+	}
+}
+
+func (w *Worker) run(ctx context.Context, req store.DataRequest, now time.Time) error {
+	user, err := w.store.Users.GetByID(ctx, req.UserID)
+	if err != nil {
+		return fmt.Errorf("user: %w", err)
+	}
+
+	switch req.Kind {
+	case store.DataExport:
+		archive, err := BuildArchive(ctx, w.store, user.ID)
+		if err != nil {
+			return err
+		}
+
+		expiresAt := now.Add(w.ttl)
+		if err := w.store.DataRequests.Complete(ctx, req.ID, archive, expiresAt); err != nil {
+			return err
+		}
+		w.logger.Infow("data exported", "requestID", req.ID, "userID", user.ID, "bytes", len(archive))
+		w.notify(ctx, mailer.DataExportReady(user.Email, user.Username, w.links.URL(req.ID, expiresAt), expiresAt))
+
+	case store.DataErasure:
+		if err := w.store.Users.Erase(ctx, user.ID); err != nil {
+			return err
+		}
+		w.logger.Infow("user erased", "requestID", req.ID, "userID", user.ID)
+		w.notify(ctx, mailer.AccountErased(user.Email, user.Username))
+
+	default:
+		return fmt.Errorf("unknown kind %q", req.Kind)
+	}
+	return nil
+}
+
+// notify sends msg, logging rather than failing a request that has already
+// been carried out.
+func (w *Worker) notify(ctx context.Context, msg mailer.Message) {
+	if err := w.mailer.Send(ctx, msg); err != nil {
+		w.logger.Errorw("failed to send email", "subject", msg.Subject, "error", err.Error())
+	}
+}
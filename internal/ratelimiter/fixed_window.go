@@ -0,0 +1,67 @@
+package ratelimiter
+
+import (
+	"sync"
+	"time"
+)
+
+// FixedWindowRateLimiter counts each key's requests in windows that start
+// with the key's first request.
+type FixedWindowRateLimiter struct {
+	sync.Mutex
+	windows map[string]window
+	limit   int
+	frame   time.Duration
+	// swept is when stale windows were last dropped.
+	swept time.Time
+	now   func() time.Time
+}
+
+type window struct {
+	start time.Time
+	count int
+}
+
+func NewFixedWindowLimiter(limit int, frame time.Duration) *FixedWindowRateLimiter {
+	return &FixedWindowRateLimiter{
+		windows: make(map[string]window),
+		limit:   limit,
+		frame:   frame,
+		swept:   time.Now(),
+		now:     time.Now,
+	}
+}
+
+func (rl *FixedWindowRateLimiter) Allow(key string) (bool, time.Duration) {
+	rl.Lock()
+	defer rl.Unlock()
+
+	now := rl.now()
+	rl.sweep(now)
+
+	w, ok := rl.windows[key]
+	if !ok || now.Sub(w.start) >= rl.frame {
+		w = window{start: now}
+	}
+	if w.count >= rl.limit {
+		return false, w.start.Add(rl.frame).Sub(now)
+	}
+
+	w.count++
+	rl.windows[key] = w
+	return true, 0
+}
+
+// sweep drops windows that have ended, at most once per frame, so keys that
+// stop sending requests do not pile up.
+func (rl *FixedWindowRateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.swept) < rl.frame {
+		return
+	}
+	for key, w := range rl.windows {
+		if now.Sub(w.start) >= rl.frame {
+			delete(rl.windows, key)
+		}
+	}
+	rl.swept = now
+}
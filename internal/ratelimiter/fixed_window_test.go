@@ -0,0 +1,64 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestFixedWindow(limit int, frame time.Duration) (*FixedWindowRateLimiter, *fakeClock) {
+	clock := newFakeClock()
+	rl := NewFixedWindowLimiter(limit, frame)
+	rl.now = clock.now
+	rl.swept = clock.t
+	return rl, clock
+}
+
+func TestFixedWindowLimit(t *testing.T) {
+	rl, clock := newTestFixedWindow(3, time.Minute)
+
+	for range 3 {
+		expect(t, rl, "a", true, 0)
+	}
+	expect(t, rl, "a", false, time.Minute)
+
+	// The window counts from its first request, not the latest one.
+	clock.advance(20 * time.Second)
+	expect(t, rl, "a", false, 40*time.Second)
+
+	// Other keys have windows of their own.
+	expect(t, rl, "b", true, 0)
+}
+
+func TestFixedWindowStartsOver(t *testing.T) {
+	rl, clock := newTestFixedWindow(2, time.Minute)
+
+	expect(t, rl, "a", true, 0)
+	clock.advance(30 * time.Second)
+	expect(t, rl, "a", true, 0)
+	expect(t, rl, "a", false, 30*time.Second)
+
+	clock.advance(30 * time.Second)
+	expect(t, rl, "a", true, 0)
+	expect(t, rl, "a", true, 0)
+	expect(t, rl, "a", false, time.Minute)
+}
+
+func TestFixedWindowSweep(t *testing.T) {
+	rl, clock := newTestFixedWindow(1, time.Minute)
+
+	expect(t, rl, "a", true, 0)
+	clock.advance(30 * time.Second)
+	expect(t, rl, "b", true, 0)
+
+	// A frame after the last sweep, windows that have ended are dropped
+	// and the rest are kept.
+	clock.advance(30 * time.Second)
+	expect(t, rl, "c", true, 0)
+	if _, ok := rl.windows["a"]; ok {
+		t.Fatal("window of a was not swept")
+	}
+	if _, ok := rl.windows["b"]; !ok {
+		t.Fatal("window of b was swept before it ended")
+	}
+	expect(t, rl, "b", false, 30*time.Second)
+}
@@ -0,0 +1,52 @@
+// Package ratelimiter limits how often a client may do something. Clients
+// are told apart by a key, such as their IP address or user ID.
+package ratelimiter
+
+import (
+	"fmt"
+	"time"
+)
+
+// Limiter decides whether a request from key may go ahead. When it may not,
+// it also returns how long until it would.
+type Limiter interface {
+	Allow(key string) (bool, time.Duration)
+}
+
+// Strategy is how a limiter counts requests.
+type Strategy string
+
+const (
+	// FixedWindow allows RequestsPerTimeFrame requests in each TimeFrame,
+	// counted from the first request of the window.
+	FixedWindow Strategy = "fixed_window"
+	// TokenBucket allows bursts of up to RequestsPerTimeFrame requests and
+	// refills that many evenly over TimeFrame.
+	TokenBucket Strategy = "token_bucket"
+)
+
+type Config struct {
+	Strategy             Strategy
+	RequestsPerTimeFrame int
+	TimeFrame            time.Duration
+	Enabled              bool
+}
+
+// New returns the limiter cfg describes, or nil if it is disabled.
+func New(cfg Config) (Limiter, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.RequestsPerTimeFrame <= 0 || cfg.TimeFrame <= 0 {
+		return nil, fmt.Errorf("rate limit must allow at least one request per time frame")
+	}
+
+	switch cfg.Strategy {
+	case FixedWindow:
+		return NewFixedWindowLimiter(cfg.RequestsPerTimeFrame, cfg.TimeFrame), nil
+	case TokenBucket:
+		return NewTokenBucketLimiter(cfg.RequestsPerTimeFrame, cfg.TimeFrame), nil
+	default:
+		return nil, fmt.Errorf("unsupported rate limit strategy %q", cfg.Strategy)
+	}
+}
@@ -0,0 +1,67 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock tests move by hand.
+type fakeClock struct {
+	t time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// expect checks the result of one limiter.Allow(key).
+func expect(t *testing.T, limiter Limiter, key string, allow bool, retryAfter time.Duration) {
+	t.Helper()
+
+	gotAllow, gotRetryAfter := limiter.Allow(key)
+	if gotAllow != allow || gotRetryAfter != retryAfter {
+		t.Fatalf("Allow(%q) = %v, %v, want %v, %v", key, gotAllow, gotRetryAfter, allow, retryAfter)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    Limiter
+		wantErr bool
+	}{
+		{"disabled", Config{Strategy: FixedWindow, RequestsPerTimeFrame: 1, TimeFrame: time.Second}, nil, false},
+		{"fixed window", Config{Strategy: FixedWindow, RequestsPerTimeFrame: 1, TimeFrame: time.Second, Enabled: true}, &FixedWindowRateLimiter{}, false},
+		{"token bucket", Config{Strategy: TokenBucket, RequestsPerTimeFrame: 1, TimeFrame: time.Second, Enabled: true}, &TokenBucketLimiter{}, false},
+		{"no requests", Config{Strategy: FixedWindow, TimeFrame: time.Second, Enabled: true}, nil, true},
+		{"no time frame", Config{Strategy: TokenBucket, RequestsPerTimeFrame: 1, Enabled: true}, nil, true},
+		{"unknown strategy", Config{Strategy: "leaky_bucket", RequestsPerTimeFrame: 1, TimeFrame: time.Second, Enabled: true}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New err = %v, want error %v", err, tt.wantErr)
+			}
+			switch tt.want.(type) {
+			case nil:
+				if got != nil {
+					t.Fatalf("New = %T, want nil", got)
+				}
+			case *FixedWindowRateLimiter:
+				if _, ok := got.(*FixedWindowRateLimiter); !ok {
+					t.Fatalf("New = %T, want *FixedWindowRateLimiter", got)
+				}
+			case *TokenBucketLimiter:
+				if _, ok := got.(*TokenBucketLimiter); !ok {
+					t.Fatalf("New = %T, want *TokenBucketLimiter", got)
+				}
+			}
+		})
+	}
+}
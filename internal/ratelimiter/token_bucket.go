@@ -0,0 +1,85 @@
+package ratelimiter
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenBucketLimiter gives each key a bucket of tokens that refills at a
+// steady rate. Every request takes a token, so short bursts are allowed as
+// long as the average rate stays under the limit.
+type TokenBucketLimiter struct {
+	sync.Mutex
+	buckets  map[string]bucket
+	capacity float64
+	// interval is how long one token takes to refill.
+	interval time.Duration
+	// swept is when full buckets were last dropped.
+	swept time.Time
+	now   func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewTokenBucketLimiter returns a limiter with buckets of capacity tokens
+// that refill completely over frame.
+func NewTokenBucketLimiter(capacity int, frame time.Duration) *TokenBucketLimiter {
+	return &TokenBucketLimiter{
+		buckets:  make(map[string]bucket),
+		capacity: float64(capacity),
+		interval: frame / time.Duration(capacity),
+		swept:    time.Now(),
+		now:      time.Now,
+	}
+}
+
+func (rl *TokenBucketLimiter) Allow(key string) (bool, time.Duration) {
+	rl.Lock()
+	defer rl.Unlock()
+
+	now := rl.now()
+	rl.sweep(now)
+
+	b := rl.refill(key, now)
+	if b.tokens < 1 {
+		rl.buckets[key] = b
+		return false, time.Duration((1 - b.tokens) * float64(rl.interval))
+	}
+
+	b.tokens--
+	rl.buckets[key] = b
+	return true, 0
+}
+
+// refill returns key's bucket with the tokens added since it was last used.
+func (rl *TokenBucketLimiter) refill(key string, now time.Time) bucket {
+	b, ok := rl.buckets[key]
+	if !ok {
+		return bucket{tokens: rl.capacity, updated: now}
+	}
+
+	b.tokens += float64(now.Sub(b.updated)) / float64(rl.interval)
+	if b.tokens > rl.capacity {
+		b.tokens = rl.capacity
+	}
+	b.updated = now
+	return b
+}
+
+// sweep drops buckets that have refilled completely, which behave the same
+// as missing ones, at most once per full refill.
+func (rl *TokenBucketLimiter) sweep(now time.Time) {
+	full := rl.interval * time.Duration(rl.capacity)
+	if now.Sub(rl.swept) < full {
+		return
+	}
+	for key, b := range rl.buckets {
+		if now.Sub(b.updated) >= full {
+			delete(rl.buckets, key)
+		}
+	}
+	rl.swept = now
+}
@@ -0,0 +1,78 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestTokenBucket(capacity int, frame time.Duration) (*TokenBucketLimiter, *fakeClock) {
+	clock := newFakeClock()
+	rl := NewTokenBucketLimiter(capacity, frame)
+	rl.now = clock.now
+	rl.swept = clock.t
+	return rl, clock
+}
+
+func TestTokenBucketBurst(t *testing.T) {
+	rl, _ := newTestTokenBucket(4, time.Minute)
+
+	for range 4 {
+		expect(t, rl, "a", true, 0)
+	}
+	expect(t, rl, "a", false, 15*time.Second)
+
+	// Other keys have buckets of their own.
+	expect(t, rl, "b", true, 0)
+}
+
+func TestTokenBucketRefill(t *testing.T) {
+	rl, clock := newTestTokenBucket(4, time.Minute)
+
+	for range 4 {
+		expect(t, rl, "a", true, 0)
+	}
+
+	// Part of a token is not enough, and the wait shrinks as it refills.
+	clock.advance(5 * time.Second)
+	expect(t, rl, "a", false, 10*time.Second)
+
+	clock.advance(10 * time.Second)
+	expect(t, rl, "a", true, 0)
+	expect(t, rl, "a", false, 15*time.Second)
+
+	clock.advance(30 * time.Second)
+	expect(t, rl, "a", true, 0)
+	expect(t, rl, "a", true, 0)
+	expect(t, rl, "a", false, 15*time.Second)
+}
+
+func TestTokenBucketCapacity(t *testing.T) {
+	rl, clock := newTestTokenBucket(2, time.Minute)
+
+	expect(t, rl, "a", true, 0)
+
+	// A long wait refills the bucket but no more than full.
+	clock.advance(time.Hour)
+	expect(t, rl, "a", true, 0)
+	expect(t, rl, "a", true, 0)
+	expect(t, rl, "a", false, 30*time.Second)
+}
+
+func TestTokenBucketSweep(t *testing.T) {
+	rl, clock := newTestTokenBucket(2, time.Minute)
+
+	expect(t, rl, "a", true, 0)
+	clock.advance(30 * time.Second)
+	expect(t, rl, "b", true, 0)
+
+	// A full refill after the last sweep, buckets that have filled up are
+	// dropped and the rest are kept.
+	clock.advance(30 * time.Second)
+	expect(t, rl, "c", true, 0)
+	if _, ok := rl.buckets["a"]; ok {
+		t.Fatal("full bucket of a was not swept")
+	}
+	if _, ok := rl.buckets["b"]; !ok {
+		t.Fatal("bucket of b was swept before it filled up")
+	}
+}
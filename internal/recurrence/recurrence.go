@@ -0,0 +1,106 @@
+// Package recurrence computes the occurrences of recurring todos from their
+// iCalendar RRULE. Occurrences are generated in the todo's own time zone so
+// a todo due at 09:00 stays due at 09:00 local time across DST changes.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+// Parse parses an RRULE value such as "FREQ=WEEKLY;BYDAY=MO,TH". The start of
+// the series always comes from the todo, so DTSTART is not accepted, and
+// rules firing more often than hourly are rejected.
+func Parse(rule string) (*rrule.ROption, error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if strings.Contains(strings.ToUpper(rule), "DTSTART") {
+		return nil, fmt.Errorf("%w: DTSTART is taken from the todo", ErrInvalidRule)
+	}
+
+	opt, err := rrule.StrToROption(rule)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+	}
+	if opt.Freq == rrule.MINUTELY || opt.Freq == rrule.SECONDLY {
+		return nil, fmt.Errorf("%w: FREQ must be HOURLY or longer", ErrInvalidRule)
+	}
+
+	return opt, nil
+}
+
+// Next returns the first occurrence of rule after the occurrence at start,
+// in loc. It also returns the rule the next instance should carry: a COUNT
+// limit is reduced by the instance that was just completed. ok is false
+// when the series has ended.
+func Next(rule string, start time.Time, loc *time.Location) (next time.Time, nextRule string, ok bool, err error) {
+	opt, err := Parse(rule)
+	if err != nil {
+		return time.Time{}, "", false, err
+	}
+
+	if opt.Count == 1 {
+		return time.Time{}, "", false, nil
+	}
+
+	r, err := newRule(opt, start, loc)
+	if err != nil {
+		return time.Time{}, "", false, err
+	}
+
+	next = r.After(start.In(loc), false)
+	if next.IsZero() {
+		return time.Time{}, "", false, nil
+	}
+
+	if opt.Count > 1 {
+		opt.Count--
+	}
+	opt.Dtstart = time.Time{}
+
+	return next, opt.RRuleString(), true, nil
+}
+
+// Preview returns up to n occurrences of rule that follow start, in loc.
+func Preview(rule string, start time.Time, loc *time.Location, n int) ([]time.Time, error) {
+	opt, err := Parse(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := newRule(opt, start, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	// COUNT includes the instance at start, which is not part of the preview.
+	var occurrences []time.Time
+	next := r.Iterator()
+	for len(occurrences) < n {
+		t, ok := next()
+		if !ok {
+			break
+		}
+		if t.After(start) {
+			occurrences = append(occurrences, t)
+		}
+	}
+
+	return occurrences, nil
+}
+
+func newRule(opt *rrule.ROption, start time.Time, loc *time.Location) (*rrule.RRule, error) {
+	o := *opt
+	o.Dtstart = start.In(loc)
+
+	r, err := rrule.NewRRule(o)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+	}
+	return r, nil
+}
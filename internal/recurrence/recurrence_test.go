@@ -0,0 +1,205 @@
+package recurrence
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+func newYork(t *testing.T) *time.Location {
+	t.Helper()
+
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone data: %v", err)
+	}
+	return loc
+}
+
+func TestParse(t *testing.T) {
+	valid := []struct {
+		rule string
+		freq rrule.Frequency
+	}{
+		{"FREQ=WEEKLY;BYDAY=MO,TH", rrule.WEEKLY},
+		{"RRULE:FREQ=DAILY;COUNT=3", rrule.DAILY},
+		{"  FREQ=MONTHLY;BYMONTHDAY=-1\n", rrule.MONTHLY},
+		{"FREQ=HOURLY;INTERVAL=6", rrule.HOURLY},
+		{"FREQ=YEARLY;UNTIL=20300101T000000Z", rrule.YEARLY},
+	}
+	for _, tt := range valid {
+		opt, err := Parse(tt.rule)
+		if err != nil {
+			t.Errorf("Parse(%q) = %v", tt.rule, err)
+			continue
+		}
+		if opt.Freq != tt.freq {
+			t.Errorf("Parse(%q) freq = %v, want %v", tt.rule, opt.Freq, tt.freq)
+		}
+	}
+
+	invalid := []string{
+		"DTSTART:20260301T090000Z\nRRULE:FREQ=DAILY",
+		"FREQ=DAILY;DTSTART=20260301T090000Z",
+		"freq=daily;dtstart=20260301T090000Z",
+		"FREQ=MINUTELY",
+		"FREQ=MINUTELY;INTERVAL=90",
+		"FREQ=SECONDLY",
+		"FREQ=SOMETIMES",
+		"FREQ=DAILY;COUNT=many",
+		"every day",
+	}
+	for _, rule := range invalid {
+		if _, err := Parse(rule); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("Parse(%q) = %v, want ErrInvalidRule", rule, err)
+		}
+	}
+}
+
+func TestNextAcrossDST(t *testing.T) {
+	loc := newYork(t)
+	at := func(year int, month time.Month, day, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, loc)
+	}
+
+	// In 2026, New York springs forward on 8 March and falls back on
+	// 1 November. Starts are in UTC, as the stores return them.
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		want  time.Time
+	}{
+		{"daily into summer time", "FREQ=DAILY", at(2026, 3, 7, 9), at(2026, 3, 8, 9)},
+		{"daily in summer time", "FREQ=DAILY", at(2026, 3, 8, 9), at(2026, 3, 9, 9)},
+		{"weekly into summer time", "FREQ=WEEKLY", at(2026, 3, 2, 9), at(2026, 3, 9, 9)},
+		{"weekly by day into summer time", "FREQ=WEEKLY;BYDAY=MO,TH", at(2026, 3, 5, 9), at(2026, 3, 9, 9)},
+		{"daily into winter time", "FREQ=DAILY", at(2026, 10, 31, 9), at(2026, 11, 1, 9)},
+		{"weekly into winter time", "FREQ=WEEKLY", at(2026, 10, 26, 9), at(2026, 11, 2, 9)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, nextRule, ok, err := Next(tt.rule, tt.start.UTC(), loc)
+			if err != nil || !ok {
+				t.Fatalf("Next = %v, %v, %v", next, ok, err)
+			}
+			if !next.Equal(tt.want) {
+				t.Fatalf("Next = %v, want %v", next.In(loc), tt.want)
+			}
+			if h, m, _ := next.In(loc).Clock(); h != 9 || m != 0 {
+				t.Fatalf("Next is at %02d:%02d local time, want 09:00", h, m)
+			}
+			if _, err := Parse(nextRule); err != nil {
+				t.Fatalf("next rule %q does not parse: %v", nextRule, err)
+			}
+		})
+	}
+
+	// The wall clock stays put, so the UTC gap is a day give or take the
+	// hour that the clocks change by.
+	next, _, _, _ := Next("FREQ=DAILY", at(2026, 3, 7, 9).UTC(), loc)
+	if gap := next.Sub(at(2026, 3, 7, 9)); gap != 23*time.Hour {
+		t.Errorf("gap across spring-forward = %v, want 23h", gap)
+	}
+	next, _, _, _ = Next("FREQ=DAILY", at(2026, 10, 31, 9).UTC(), loc)
+	if gap := next.Sub(at(2026, 10, 31, 9)); gap != 25*time.Hour {
+		t.Errorf("gap across fall-back = %v, want 25h", gap)
+	}
+}
+
+func TestNextCount(t *testing.T) {
+	loc := newYork(t)
+	start := time.Date(2026, 3, 6, 9, 0, 0, 0, loc)
+
+	// COUNT=3 is the instance at start and two more.
+	rule := "FREQ=DAILY;COUNT=3"
+	for i, wantCount := range []int{2, 1} {
+		next, nextRule, ok, err := Next(rule, start, loc)
+		if err != nil || !ok {
+			t.Fatalf("Next(%q) = %v, %v, %v", rule, next, ok, err)
+		}
+		if want := start.AddDate(0, 0, 1); !next.Equal(want) {
+			t.Fatalf("instance %d: Next = %v, want %v", i+2, next, want)
+		}
+		opt, err := Parse(nextRule)
+		if err != nil {
+			t.Fatalf("next rule %q does not parse: %v", nextRule, err)
+		}
+		if opt.Count != wantCount {
+			t.Fatalf("next rule %q has COUNT %d, want %d", nextRule, opt.Count, wantCount)
+		}
+		rule, start = nextRule, next
+	}
+
+	if next, nextRule, ok, err := Next(rule, start, loc); ok || err != nil {
+		t.Fatalf("Next(%q) after the last instance = %v, %q, %v, %v; want the series ended", rule, next, nextRule, ok, err)
+	}
+}
+
+func TestNextUntil(t *testing.T) {
+	loc := newYork(t)
+	// 10 March 09:00 in New York is 13:00 UTC.
+	rule := "FREQ=DAILY;UNTIL=20260310T130000Z"
+	start := time.Date(2026, 3, 8, 9, 0, 0, 0, loc)
+
+	for _, day := range []int{9, 10} {
+		next, nextRule, ok, err := Next(rule, start, loc)
+		if err != nil || !ok {
+			t.Fatalf("Next from %v = %v, %v, %v", start, next, ok, err)
+		}
+		if want := time.Date(2026, 3, day, 9, 0, 0, 0, loc); !next.Equal(want) {
+			t.Fatalf("Next = %v, want %v", next, want)
+		}
+		opt, err := Parse(nextRule)
+		if err != nil {
+			t.Fatalf("next rule %q does not parse: %v", nextRule, err)
+		}
+		if !opt.Until.Equal(time.Date(2026, 3, 10, 13, 0, 0, 0, time.UTC)) {
+			t.Fatalf("next rule %q lost UNTIL", nextRule)
+		}
+		rule, start = nextRule, next
+	}
+
+	if next, _, ok, err := Next(rule, start, loc); ok || err != nil {
+		t.Fatalf("Next after UNTIL = %v, %v, %v; want the series ended", next, ok, err)
+	}
+}
+
+func TestNextInvalid(t *testing.T) {
+	if _, _, _, err := Next("FREQ=MINUTELY", time.Now(), time.UTC); !errors.Is(err, ErrInvalidRule) {
+		t.Fatalf("Next of an invalid rule = %v, want ErrInvalidRule", err)
+	}
+}
+
+func TestPreview(t *testing.T) {
+	loc := newYork(t)
+	start := time.Date(2026, 3, 6, 9, 0, 0, 0, loc)
+
+	got, err := Preview("FREQ=DAILY", start.UTC(), loc, 4)
+	if err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("Preview returned %d occurrences, want 4", len(got))
+	}
+	for i, occurrence := range got {
+		if want := time.Date(2026, 3, 7+i, 9, 0, 0, 0, loc); !occurrence.Equal(want) {
+			t.Errorf("occurrence %d = %v, want %v", i, occurrence.In(loc), want)
+		}
+	}
+
+	// COUNT includes the instance at start.
+	got, err = Preview("FREQ=DAILY;COUNT=3", start, loc, 10)
+	if err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Preview of COUNT=3 returned %d occurrences, want 2", len(got))
+	}
+
+	if _, err := Preview("DTSTART:20260301T090000Z\nRRULE:FREQ=DAILY", start, loc, 3); !errors.Is(err, ErrInvalidRule) {
+		t.Fatalf("Preview of an invalid rule = %v, want ErrInvalidRule", err)
+	}
+}
@@ -0,0 +1,133 @@
+// Package reminder fires todo reminders once their time has come.
+package reminder
+
+import (
+	"context"
+	"time"
+
+	"open-todo-go/internal/mailer"
+	"open-todo-go/internal/store"
+
+	"go.uber.org/zap"
+)
+
+// Notification is everything a Notifier needs to tell a user about a
+// reminder.
+type Notification struct {
+	Reminder store.Reminder
+	Todo     *store.Todo
+	User     *store.User
+}
+
+type Notifier interface {
+	Notify(context.Context, Notification) error
+}
+
+// LogNotifier only logs reminders. It is the default until a real delivery
+// channel is configured.
+type LogNotifier struct {
+	Logger *zap.SugaredLogger
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, msg Notification) error {
+	n.Logger.Infow("reminder fired",
+		"reminderID", msg.Reminder.ID,
+		"todoID", msg.Todo.ID,
+		"userID", msg.User.ID,
+		"title", msg.Todo.Title,
+		"dueAt", msg.Todo.DueAt,
+	)
+	return nil
+}
+
+// MailNotifier emails reminders to the user.
+type MailNotifier struct {
+	Mailer mailer.Mailer
+}
+
+func (n *MailNotifier) Notify(ctx context.Context, msg Notification) error {
+	return n.Mailer.Send(ctx, mailer.Reminder(msg.User.Email, msg.User.Username, msg.Todo.Title, msg.Todo.DueAt))
+}
+
+type Worker struct {
+	store    store.Storage
+	notifier Notifier
+	logger   *zap.SugaredLogger
+	interval time.Duration
+	batch    int
+}
+
+func NewWorker(s store.Storage, notifier Notifier, logger *zap.SugaredLogger, interval time.Duration) *Worker {
+	return &Worker{
+		store:    s,
+		notifier: notifier,
+		logger:   logger,
+		interval: interval,
+		batch:    100,
+	}
+}
+
+// Run polls for due reminders every interval until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.Tick(ctx, time.Now()); err != nil {
+			w.logger.Errorw("reminder worker", "error", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick fires every reminder due at now and returns how many were sent.
+// Reminders are claimed before they are sent, so a failed notification is
+// logged and not retried rather than risking duplicates.
+func (w *Worker) Tick(ctx context.Context, now time.Time) (int, error) {
+	sent := 0
+	for {
+		reminders, err := w.store.Reminders.ClaimDue(ctx, now, w.batch)
+		if err != nil {
+			return sent, err
+		}
+
+		for _, r := range reminders {
+			if w.fire(ctx, r) {
+				sent++
+			}
+		}
+
+		if len(reminders) < w.batch {
+			return sent, nil
+		}
+	}
+}
+
+func (w *Worker) fire(ctx context.Context, r store.Reminder) bool {
+	todo, err := w.store.Todos.GetTodoByID(ctx, r.UserID, r.TodoID)
+	if err != nil {
+		w.logger.Warnw("reminder for missing todo", "reminderID", r.ID, "error", err.Error())
+		return false
+	}
+	if todo.Completed {
+		return false
+	}
+
+	user, err := w.store.Users.GetByID(ctx, r.UserID)
+	if err != nil {
+		w.logger.Warnw("reminder for missing user", "reminderID", r.ID, "error", err.Error())
+		return false
+	}
+
+	if err := w.notifier.Notify(ctx, Notification{Reminder: r, Todo: todo, User: user}); err != nil {
+		w.logger.Errorw("reminder notification failed", "reminderID", r.ID, "error", err.Error())
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,156 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// AccessTokenPrefix starts every personal access token, which tells them
+// apart from JWTs in the Authorization header.
+const AccessTokenPrefix = "otg_pat_"
+
+// TokenScope is what a personal access token may do.
+type TokenScope string
+
+const (
+	ScopeRead  TokenScope = "read"
+	ScopeWrite TokenScope = "write"
+)
+
+// AccessToken is a personal access token. Tokens with a ProjectID only
+// reach that project; tokens without an ExpiresAt never expire.
+type AccessToken struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"userID"`
+	Name       string     `json:"name"`
+	Scope      TokenScope `json:"scope"`
+	ProjectID  *int64     `json:"projectID"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	// Token is only known right after Create; the store keeps a hash of it.
+	Token string `json:"token,omitempty"`
+}
+
+// AllowsProject reports whether the token reaches projectID, which is nil
+// for todos outside any project and routes not about one.
+func (t *AccessToken) AllowsProject(projectID *int64) bool {
+	return t.ProjectID == nil || (projectID != nil && *projectID == *t.ProjectID)
+}
+
+// AccessTokensStore keeps personal access tokens. Its queries are portable,
+// so the SQLite storage uses it too.
+type AccessTokensStore struct {
+	db *sql.DB
+}
+
+const accessTokenColumns = `id, user_id, name, scope, project_id, expires_at, last_used_at, created_at`
+
+func accessTokenScanDest(t *AccessToken) []any {
+	return []any{&t.ID, &t.UserID, &t.Name, &t.Scope, &t.ProjectID, &t.ExpiresAt, &t.LastUsedAt, &t.CreatedAt}
+}
+
+// Create stores token with a new random secret and sets token.Token.
+func (s *AccessTokensStore) Create(ctx context.Context, token *AccessToken) error {
+	secret, hash, err := newAccessToken()
+	if err != nil {
+		return err
+	}
+
+	token.ExpiresAt = copyTime(token.ExpiresAt)
+	token.LastUsedAt = nil
+	token.CreatedAt = now()
+	query := `
+    INSERT INTO personal_access_tokens (user_id, name, token_hash, scope, project_id, expires_at, created_at)
+    VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id
+  `
+	err = s.db.QueryRowContext(ctx, query,
+		token.UserID, token.Name, hash, token.Scope, token.ProjectID, token.ExpiresAt, token.CreatedAt,
+	).Scan(&token.ID)
+	if err != nil {
+		return err
+	}
+
+	token.Token = secret
+	return nil
+}
+
+// GetByUser lists the user's tokens, expired ones included, newest first.
+func (s *AccessTokensStore) GetByUser(ctx context.Context, userID int64) ([]AccessToken, error) {
+	query := `
+    SELECT ` + accessTokenColumns + `
+    FROM personal_access_tokens
+    WHERE user_id = $1
+    ORDER BY created_at DESC, id DESC
+  `
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []AccessToken
+	for rows.Next() {
+		var t AccessToken
+		if err := rows.Scan(accessTokenScanDest(&t)...); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	return tokens, rows.Err()
+}
+
+// Authenticate returns the token with the given secret and records that it
+// was used. Unknown and expired tokens are ErrNotFound.
+func (s *AccessTokensStore) Authenticate(ctx context.Context, secret string) (*AccessToken, error) {
+	query := `
+    UPDATE personal_access_tokens SET last_used_at = $1
+    WHERE token_hash = $2 AND (expires_at IS NULL OR expires_at > $1)
+    RETURNING ` + accessTokenColumns
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var token AccessToken
+	err := s.db.QueryRowContext(ctx, query, now(), hashToken(secret)).Scan(accessTokenScanDest(&token)...)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (s *AccessTokensStore) Delete(ctx context.Context, userID, tokenID int64) error {
+	query := `DELETE FROM personal_access_tokens WHERE id = $1 AND user_id = $2`
+	return execAffectingOne(ctx, s.db, query, tokenID, userID)
+}
+
+// DeleteAll deletes every token of the user.
+func (s *AccessTokensStore) DeleteAll(ctx context.Context, userID int64) error {
+	query := `DELETE FROM personal_access_tokens WHERE user_id = $1`
+	_, err := s.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+// newAccessToken is newToken with AccessTokenPrefix in front.
+func newAccessToken() (string, []byte, error) {
+	token, _, err := newToken()
+	if err != nil {
+		return "", nil, err
+	}
+	token = AccessTokenPrefix + token
+	return token, hashToken(token), nil
+}
+
+// IsAccessToken reports whether s looks like a personal access token.
+func IsAccessToken(s string) bool {
+	return strings.HasPrefix(s, AccessTokenPrefix)
+}
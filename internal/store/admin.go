@@ -0,0 +1,207 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UserFilter narrows and pages the users returned by List. Anonymized users
+// are never listed.
+type UserFilter struct {
+	Limit     int      `json:"limit" validate:"gte=1,lte=100"`
+	Cursor    string   `json:"cursor"`
+	Search    string   `json:"q" validate:"max=100"`
+	Role      UserRole `json:"role" validate:"omitempty,oneof=user admin"`
+	Suspended *bool    `json:"suspended"`
+}
+
+type UserPage struct {
+	Users      []User `json:"users"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	Total      int    `json:"total"`
+}
+
+// DefaultUserFilter is the first page of all users, oldest first.
+func DefaultUserFilter() UserFilter {
+	return UserFilter{Limit: 50}
+}
+
+// decodeUserCursor returns the ID of the last user of the previous page, or
+// 0 for the first page. Users are paged in ID order.
+func decodeUserCursor(f UserFilter) (int64, error) {
+	if f.Cursor == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(f.Cursor, 10, 64)
+	if err != nil || id < 1 {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
+
+// UserUsage is how much of the service a user takes up.
+type UserUsage struct {
+	Todos          int `json:"todos"`
+	CompletedTodos int `json:"completedTodos"`
+	Projects       int `json:"projects"`
+	// SharedProjects counts the projects of others the user is a member of.
+	SharedProjects int        `json:"sharedProjects"`
+	AccessTokens   int        `json:"accessTokens"`
+	ActiveSessions int        `json:"activeSessions"`
+	LastLoginAt    *time.Time `json:"lastLoginAt"`
+}
+
+// listUsers is List for the SQL stores.
+func listUsers(ctx context.Context, db *sql.DB, f UserFilter) (*UserPage, error) {
+	after, err := decodeUserCursor(f)
+	if err != nil {
+		return nil, err
+	}
+
+	conds := []string{"deleted_at IS NULL"}
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if f.Search != "" {
+		p := arg("%" + escapeLike(strings.ToLower(f.Search)) + "%")
+		conds = append(conds, fmt.Sprintf(`(lower(username) LIKE %[1]s ESCAPE '\' OR lower(email) LIKE %[1]s ESCAPE '\')`, p))
+	}
+	if f.Role != "" {
+		conds = append(conds, "role = "+arg(f.Role))
+	}
+	if f.Suspended != nil {
+		if *f.Suspended {
+			conds = append(conds, "suspended_at IS NOT NULL")
+		} else {
+			conds = append(conds, "suspended_at IS NULL")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	page := &UserPage{Users: []User{}}
+	where := strings.Join(conds, " AND ")
+	if err := db.QueryRowContext(ctx, `SELECT count(*) FROM users WHERE `+where, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	if after > 0 {
+		where += " AND id > " + arg(after)
+	}
+	query := `SELECT ` + userColumns + ` FROM users WHERE ` + where + ` ORDER BY id LIMIT ` + arg(f.Limit+1)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(userScanDest(&user)...); err != nil {
+			return nil, err
+		}
+		page.Users = append(page.Users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Users) > f.Limit {
+		page.Users = page.Users[:f.Limit]
+		page.NextCursor = strconv.FormatInt(page.Users[f.Limit-1].ID, 10)
+	}
+	return page, nil
+}
+
+// userUsage is Usage for the SQL stores.
+func userUsage(ctx context.Context, db *sql.DB, userID int64) (*UserUsage, error) {
+	query := `
+    SELECT
+      (SELECT count(*) FROM todos WHERE user_id = $1 AND deleted_at IS NULL),
+      (SELECT count(*) FROM todos WHERE user_id = $1 AND deleted_at IS NULL AND completed),
+      (SELECT count(*) FROM projects WHERE user_id = $1),
+      (SELECT count(*) FROM project_members WHERE user_id = $1),
+      (SELECT count(*) FROM personal_access_tokens WHERE user_id = $1),
+      (SELECT count(*) FROM sessions
+       WHERE user_id = $1 AND revoked_at IS NULL AND EXISTS (
+         SELECT 1 FROM refresh_tokens
+         WHERE session_id = sessions.id AND used_at IS NULL AND expires_at > $2))
+  `
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var usage UserUsage
+	err := db.QueryRowContext(ctx, query, userID, now()).Scan(
+		&usage.Todos, &usage.CompletedTodos, &usage.Projects, &usage.SharedProjects,
+		&usage.AccessTokens, &usage.ActiveSessions,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastLogin time.Time
+	err = db.QueryRowContext(ctx,
+		`SELECT created_at FROM sessions WHERE user_id = $1 ORDER BY created_at DESC LIMIT 1`, userID,
+	).Scan(&lastLogin)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return nil, err
+	default:
+		usage.LastLoginAt = &lastLogin
+	}
+	return &usage, nil
+}
+
+// setUserRole is SetRole for the SQL stores.
+func setUserRole(ctx context.Context, db *sql.DB, userID int64, role UserRole) error {
+	query := `UPDATE users SET role = $1 WHERE id = $2 AND deleted_at IS NULL`
+	return execAffectingOne(ctx, db, query, role, userID)
+}
+
+// setUserSuspended is SetSuspended for the SQL stores. Suspending a user
+// ends their sessions; suspending a suspended user keeps the time of the
+// first suspension.
+func setUserSuspended(ctx context.Context, db *sql.DB, userID int64, suspended bool) error {
+	if !suspended {
+		query := `UPDATE users SET suspended_at = NULL WHERE id = $1 AND deleted_at IS NULL`
+		return execAffectingOne(ctx, db, query, userID)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	suspendedAt := now()
+	query := `UPDATE users SET suspended_at = coalesce(suspended_at, $1) WHERE id = $2 AND deleted_at IS NULL`
+	res, err := tx.ExecContext(ctx, query, suspendedAt, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+
+	query = `UPDATE sessions SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL`
+	if _, err := tx.ExecContext(ctx, query, suspendedAt, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AuditEntry records one thing an admin did.
+type AuditEntry struct {
+	ID int64 `json:"id"`
+	// ActorID is the admin, or nil for changes made from the command line.
+	ActorID      *int64 `json:"actorID"`
+	Action       string `json:"action"`
+	TargetUserID *int64 `json:"targetUserID"`
+	// Details is a JSON object with whatever else is worth knowing about the
+	// action.
+	Details   json.RawMessage `json:"details"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// AuditFilter narrows and pages the entries returned by List, newest first.
+type AuditFilter struct {
+	ActorID      *int64
+	TargetUserID *int64
+	// Before is the ID of the last entry of the previous page.
+	Before int64
+	Limit  int
+}
+
+// AuditStore keeps the admin audit log. Its queries are portable, so the
+// SQLite storage uses it too.
+type AuditStore struct {
+	db *sql.DB
+}
+
+func (s *AuditStore) Create(ctx context.Context, entry *AuditEntry) error {
+	if len(entry.Details) == 0 {
+		entry.Details = json.RawMessage("{}")
+	}
+	entry.CreatedAt = now()
+	query := `
+    INSERT INTO admin_audit_log (actor_id, action, target_user_id, details, created_at)
+    VALUES ($1, $2, $3, $4, $5) RETURNING id
+  `
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query,
+		entry.ActorID, entry.Action, entry.TargetUserID, string(entry.Details), entry.CreatedAt,
+	).Scan(&entry.ID)
+}
+
+func (s *AuditStore) List(ctx context.Context, f AuditFilter) ([]AuditEntry, error) {
+	var conds []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if f.ActorID != nil {
+		conds = append(conds, "actor_id = "+arg(*f.ActorID))
+	}
+	if f.TargetUserID != nil {
+		conds = append(conds, "target_user_id = "+arg(*f.TargetUserID))
+	}
+	if f.Before > 0 {
+		conds = append(conds, "id < "+arg(f.Before))
+	}
+
+	query := `SELECT id, actor_id, action, target_user_id, details, created_at FROM admin_audit_log`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT " + arg(f.Limit)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var details []byte
+		if err := rows.Scan(&entry.ID, &entry.ActorID, &entry.Action, &entry.TargetUserID, &details, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entry.Details = details
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
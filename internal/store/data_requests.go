@@ -0,0 +1,188 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// DataRequestKind is what a user asked to have done with their data.
+type DataRequestKind string
+
+const (
+	// DataExport bundles the user's data into an archive they can
+	// download.
+	DataExport DataRequestKind = "export"
+	// DataErasure deletes the user and everything tied to them.
+	DataErasure DataRequestKind = "erasure"
+)
+
+type DataRequestStatus string
+
+const (
+	DataRequestPending DataRequestStatus = "pending"
+	DataRequestRunning DataRequestStatus = "running"
+	// DataRequestReady is a finished export whose archive can be
+	// downloaded. Finished erasures leave nothing behind.
+	DataRequestReady  DataRequestStatus = "ready"
+	DataRequestFailed DataRequestStatus = "failed"
+)
+
+// DataRequest is an export or erasure of a user's data, run in the
+// background by the privacy worker.
+type DataRequest struct {
+	ID          int64             `json:"id"`
+	UserID      int64             `json:"userID"`
+	Kind        DataRequestKind   `json:"kind"`
+	Status      DataRequestStatus `json:"status"`
+	Error       string            `json:"error,omitempty"`
+	CompletedAt *time.Time        `json:"completedAt"`
+	// ExpiresAt is when the archive of a ready export is deleted.
+	ExpiresAt *time.Time `json:"expiresAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+const dataRequestColumns = `id, user_id, kind, status, error, completed_at, expires_at, created_at`
+
+func dataRequestScanDest(d *DataRequest) []any {
+	return []any{&d.ID, &d.UserID, &d.Kind, &d.Status, &d.Error, &d.CompletedAt, &d.ExpiresAt, &d.CreatedAt}
+}
+
+// DataRequestsStore keeps data requests. Only ClaimPending differs on
+// SQLite; see SQLiteDataRequestsStore.
+type DataRequestsStore struct {
+	db *sql.DB
+}
+
+// Create queues a request. A user who already has an open request of the
+// same kind gets ErrConflict.
+func (s *DataRequestsStore) Create(ctx context.Context, req *DataRequest) error {
+	req.Status = DataRequestPending
+	req.CreatedAt = now()
+	query := `
+    INSERT INTO data_requests (user_id, kind, status, created_at)
+    VALUES ($1, $2, $3, $4)
+    ON CONFLICT DO NOTHING
+    RETURNING id
+  `
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, req.UserID, req.Kind, req.Status, req.CreatedAt).Scan(&req.ID)
+	if err == sql.ErrNoRows {
+		return ErrConflict
+	}
+	return err
+}
+
+// GetByUser lists the user's requests, newest first.
+func (s *DataRequestsStore) GetByUser(ctx context.Context, userID int64) ([]DataRequest, error) {
+	query := `SELECT ` + dataRequestColumns + ` FROM data_requests WHERE user_id = $1 ORDER BY id DESC`
+	return queryDataRequests(ctx, s.db, query, userID)
+}
+
+// ClaimPending marks up to limit pending requests as running and returns
+// them. Requests that have been running since before staleBefore are
+// claimed again, as the worker running them must have stopped.
+func (s *DataRequestsStore) ClaimPending(ctx context.Context, staleBefore time.Time, limit int) ([]DataRequest, error) {
+	query := `
+    UPDATE data_requests SET status = 'running', claimed_at = $1
+    WHERE id IN (
+      SELECT id FROM data_requests
+      WHERE status = 'pending' OR (status = 'running' AND claimed_at < $2)
+      ORDER BY created_at ASC, id ASC
+      LIMIT $3
+      FOR UPDATE SKIP LOCKED
+    )
+    RETURNING ` + dataRequestColumns
+	return queryDataRequests(ctx, s.db, query, now(), staleBefore.UTC(), limit)
+}
+
+// Complete stores the archive of an export and makes it downloadable until
+// expiresAt.
+func (s *DataRequestsStore) Complete(ctx context.Context, id int64, archive []byte, expiresAt time.Time) error {
+	query := `
+    UPDATE data_requests SET status = 'ready', archive = $1, completed_at = $2, expires_at = $3
+    WHERE id = $4 AND status = 'running'
+  `
+	return execAffectingOne(ctx, s.db, query, archive, now(), expiresAt.UTC(), id)
+}
+
+// Fail records why a request could not be carried out.
+func (s *DataRequestsStore) Fail(ctx context.Context, id int64, reason string) error {
+	query := `
+    UPDATE data_requests SET status = 'failed', error = $1, completed_at = $2
+    WHERE id = $3 AND status = 'running'
+  `
+	return execAffectingOne(ctx, s.db, query, reason, now(), id)
+}
+
+// GetArchive returns the archive of the ready export id, if it has not
+// expired at at.
+func (s *DataRequestsStore) GetArchive(ctx context.Context, id int64, at time.Time) ([]byte, error) {
+	query := `SELECT archive FROM data_requests WHERE id = $1 AND status = 'ready' AND expires_at > $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var archive []byte
+	err := s.db.QueryRowContext(ctx, query, id, at.UTC()).Scan(&archive)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return archive, err
+}
+
+// DeleteExpired deletes the exports that expired at or before at and returns
+// how many there were.
+func (s *DataRequestsStore) DeleteExpired(ctx context.Context, at time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM data_requests WHERE expires_at <= $1`, at.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func queryDataRequests(ctx context.Context, db *sql.DB, query string, args ...any) ([]DataRequest, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []DataRequest{}
+	for rows.Next() {
+		var req DataRequest
+		if err := rows.Scan(dataRequestScanDest(&req)...); err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+	return requests, rows.Err()
+}
+
+// SQLiteDataRequestsStore is DataRequestsStore without row locks, which
+// SQLite does not need as it allows a single writer at a time.
+type SQLiteDataRequestsStore struct {
+	DataRequestsStore
+}
+
+func (s *SQLiteDataRequestsStore) ClaimPending(ctx context.Context, staleBefore time.Time, limit int) ([]DataRequest, error) {
+	query := `
+    UPDATE data_requests SET status = 'running', claimed_at = $1
+    WHERE id IN (
+      SELECT id FROM data_requests
+      WHERE status = 'pending' OR (status = 'running' AND claimed_at < $2)
+      ORDER BY created_at ASC, id ASC
+      LIMIT $3
+    )
+    RETURNING ` + dataRequestColumns
+	return queryDataRequests(ctx, s.db, query, now(), staleBefore.UTC(), limit)
+}
@@ -0,0 +1,176 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Identity links a user to their account at an OpenID Connect provider.
+type Identity struct {
+	ID       int64  `json:"id"`
+	UserID   int64  `json:"userID"`
+	Provider string `json:"provider"`
+	// Subject is the provider's ID for the account.
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// OIDCFlow is a login in progress at a provider. Flows with a UserID link
+// the identity to that user instead of logging in with it.
+type OIDCFlow struct {
+	ID        int64
+	Provider  string
+	Nonce     string
+	Verifier  string
+	UserID    *int64
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	// State is only known right after CreateFlow; the store keeps a hash of
+	// it.
+	State string
+}
+
+// IdentitiesStore keeps linked identities and login flows. Its queries are
+// portable, so the SQLite storage uses it too.
+type IdentitiesStore struct {
+	db *sql.DB
+}
+
+const identityColumns = `id, user_id, provider, subject, email, created_at`
+
+func identityScanDest(i *Identity) []any {
+	return []any{&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt}
+}
+
+// Create links identity to identity.UserID. An identity that is already
+// linked, to anyone, is ErrConflict.
+func (s *IdentitiesStore) Create(ctx context.Context, identity *Identity) error {
+	identity.CreatedAt = now()
+	query := `
+    INSERT INTO user_identities (user_id, provider, subject, email, created_at)
+    VALUES ($1, $2, $3, $4, $5)
+    ON CONFLICT (provider, subject) DO NOTHING
+    RETURNING id
+  `
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query,
+		identity.UserID, identity.Provider, identity.Subject, identity.Email, identity.CreatedAt,
+	).Scan(&identity.ID)
+	if err == sql.ErrNoRows {
+		return ErrConflict
+	}
+	return err
+}
+
+// GetBySubject returns the identity with the provider's account ID subject.
+func (s *IdentitiesStore) GetBySubject(ctx context.Context, provider, subject string) (*Identity, error) {
+	query := `SELECT ` + identityColumns + ` FROM user_identities WHERE provider = $1 AND subject = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var identity Identity
+	err := s.db.QueryRowContext(ctx, query, provider, subject).Scan(identityScanDest(&identity)...)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// GetByUser lists the user's identities, oldest first.
+func (s *IdentitiesStore) GetByUser(ctx context.Context, userID int64) ([]Identity, error) {
+	query := `
+    SELECT ` + identityColumns + `
+    FROM user_identities
+    WHERE user_id = $1
+    ORDER BY created_at, id
+  `
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identities []Identity
+	for rows.Next() {
+		var identity Identity
+		if err := rows.Scan(identityScanDest(&identity)...); err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+
+	return identities, rows.Err()
+}
+
+// Delete unlinks one of the user's identities.
+func (s *IdentitiesStore) Delete(ctx context.Context, userID, identityID int64) error {
+	query := `DELETE FROM user_identities WHERE id = $1 AND user_id = $2`
+	return execAffectingOne(ctx, s.db, query, identityID, userID)
+}
+
+// CreateFlow stores flow with a new random state and sets flow.State.
+// Expired flows are cleared out on the way.
+func (s *IdentitiesStore) CreateFlow(ctx context.Context, flow *OIDCFlow) error {
+	state, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	flow.ExpiresAt = flow.ExpiresAt.UTC()
+	flow.CreatedAt = now()
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM oidc_flows WHERE expires_at <= $1`, flow.CreatedAt); err != nil {
+		return err
+	}
+
+	query := `
+    INSERT INTO oidc_flows (provider, state_hash, nonce, verifier, user_id, expires_at, created_at)
+    VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id
+  `
+	err = s.db.QueryRowContext(ctx, query,
+		flow.Provider, hash, flow.Nonce, flow.Verifier, flow.UserID, flow.ExpiresAt, flow.CreatedAt,
+	).Scan(&flow.ID)
+	if err != nil {
+		return err
+	}
+
+	flow.State = state
+	return nil
+}
+
+// ConsumeFlow uses up the provider's flow with the given state. Unknown and
+// expired flows are ErrNotFound.
+func (s *IdentitiesStore) ConsumeFlow(ctx context.Context, provider, state string) (*OIDCFlow, error) {
+	query := `
+    DELETE FROM oidc_flows
+    WHERE state_hash = $1 AND provider = $2 AND expires_at > $3
+    RETURNING id, provider, nonce, verifier, user_id, expires_at, created_at
+  `
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var flow OIDCFlow
+	err := s.db.QueryRowContext(ctx, query, hashToken(state), provider, now()).Scan(
+		&flow.ID, &flow.Provider, &flow.Nonce, &flow.Verifier, &flow.UserID, &flow.ExpiresAt, &flow.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &flow, nil
+}
@@ -0,0 +1,247 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrInvitationEmail = errors.New("invitation was sent to a different email address")
+
+// Role is what a user may do in a project. The owner is the user the project
+// belongs to; editors and viewers are members it has been shared with.
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleEditor Role = "editor"
+	RoleViewer Role = "viewer"
+)
+
+// CanWrite reports whether the role may change the project's todos.
+func (r Role) CanWrite() bool {
+	return r == RoleOwner || r == RoleEditor
+}
+
+type ProjectMember struct {
+	ProjectID int64     `json:"projectID"`
+	UserID    int64     `json:"userID"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Invitation struct {
+	ID        int64     `json:"id"`
+	ProjectID int64     `json:"projectID"`
+	Email     string    `json:"email"`
+	Role      Role      `json:"role"`
+	InvitedBy int64     `json:"invitedBy"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Token is only known right after CreateInvitation; the store keeps a
+	// hash of it.
+	Token string `json:"token,omitempty"`
+}
+
+// MembersStore keeps project memberships and invitations. Its queries are
+// portable, so the SQLite storage uses it too.
+type MembersStore struct {
+	db *sql.DB
+}
+
+const memberColumns = `m.project_id, m.user_id, u.username, u.email, m.role, m.created_at`
+
+func memberScanDest(m *ProjectMember) []any {
+	return []any{&m.ProjectID, &m.UserID, &m.Username, &m.Email, &m.Role, &m.CreatedAt}
+}
+
+const invitationColumns = `id, project_id, email, role, invited_by, expires_at, created_at`
+
+func invitationScanDest(inv *Invitation) []any {
+	return []any{&inv.ID, &inv.ProjectID, &inv.Email, &inv.Role, &inv.InvitedBy, &inv.ExpiresAt, &inv.CreatedAt}
+}
+
+// GetRole returns the role userID has in projectID, or ErrNotFound if the
+// project is not shared with them.
+func (s *MembersStore) GetRole(ctx context.Context, projectID, userID int64) (Role, error) {
+	query := `
+    SELECT 'owner' FROM projects WHERE id = $1 AND user_id = $2
+    UNION ALL
+    SELECT role FROM project_members WHERE project_id = $1 AND user_id = $2
+  `
+	var role Role
+	err := s.db.QueryRowContext(ctx, query, projectID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	return role, err
+}
+
+func (s *MembersStore) GetMembers(ctx context.Context, projectID int64) ([]ProjectMember, error) {
+	query := `
+    SELECT ` + memberColumns + `
+    FROM project_members m JOIN users u ON u.id = m.user_id
+    WHERE m.project_id = $1
+    ORDER BY m.created_at ASC, m.user_id ASC
+  `
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []ProjectMember
+	for rows.Next() {
+		var m ProjectMember
+		if err := rows.Scan(memberScanDest(&m)...); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+func (s *MembersStore) UpdateMember(ctx context.Context, projectID, userID int64, role Role) error {
+	query := `UPDATE project_members SET role = $1 WHERE project_id = $2 AND user_id = $3`
+	return execAffectingOne(ctx, s.db, query, role, projectID, userID)
+}
+
+func (s *MembersStore) RemoveMember(ctx context.Context, projectID, userID int64) error {
+	query := `DELETE FROM project_members WHERE project_id = $1 AND user_id = $2`
+	return execAffectingOne(ctx, s.db, query, projectID, userID)
+}
+
+// CreateInvitation stores inv with a new random token and sets inv.Token.
+func (s *MembersStore) CreateInvitation(ctx context.Context, inv *Invitation) error {
+	token, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	inv.Email = strings.ToLower(inv.Email)
+	inv.CreatedAt = now()
+	query := `
+    INSERT INTO project_invitations (project_id, email, role, token_hash, invited_by, expires_at, created_at)
+    VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id
+  `
+	err = s.db.QueryRowContext(ctx, query,
+		inv.ProjectID, inv.Email, inv.Role, hash, inv.InvitedBy, inv.ExpiresAt.UTC(), inv.CreatedAt,
+	).Scan(&inv.ID)
+	if err != nil {
+		return err
+	}
+
+	inv.Token = token
+	return nil
+}
+
+// GetInvitations returns the project's invitations that have not expired.
+func (s *MembersStore) GetInvitations(ctx context.Context, projectID int64) ([]Invitation, error) {
+	query := `
+    SELECT ` + invitationColumns + `
+    FROM project_invitations
+    WHERE project_id = $1 AND expires_at > $2
+    ORDER BY created_at ASC, id ASC
+  `
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, projectID, now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invitations []Invitation
+	for rows.Next() {
+		var inv Invitation
+		if err := rows.Scan(invitationScanDest(&inv)...); err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, inv)
+	}
+
+	return invitations, rows.Err()
+}
+
+func (s *MembersStore) DeleteInvitation(ctx context.Context, projectID, invitationID int64) error {
+	query := `DELETE FROM project_invitations WHERE id = $1 AND project_id = $2`
+	return execAffectingOne(ctx, s.db, query, invitationID, projectID)
+}
+
+// AcceptInvitation makes user a member of the project token invites them to
+// and uses up the invitation. Unknown and expired tokens are ErrNotFound,
+// tokens sent to another address ErrInvitationEmail, and users already in
+// the project ErrConflict.
+func (s *MembersStore) AcceptInvitation(ctx context.Context, token string, user *User) (*ProjectMember, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var inv Invitation
+	query := `
+    DELETE FROM project_invitations
+    WHERE token_hash = $1 AND expires_at > $2
+    RETURNING ` + invitationColumns
+	err = tx.QueryRowContext(ctx, query, hashToken(token), now()).Scan(invitationScanDest(&inv)...)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.EqualFold(inv.Email, user.Email) {
+		return nil, ErrInvitationEmail
+	}
+
+	member := &ProjectMember{
+		ProjectID: inv.ProjectID,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		Role:      inv.Role,
+		CreatedAt: now(),
+	}
+	var owner bool
+	query = `SELECT EXISTS (SELECT 1 FROM projects WHERE id = $1 AND user_id = $2)`
+	if err := tx.QueryRowContext(ctx, query, member.ProjectID, member.UserID).Scan(&owner); err != nil {
+		return nil, err
+	}
+	if owner {
+		return nil, ErrConflict
+	}
+
+	query = `
+    INSERT INTO project_members (project_id, user_id, role, created_at)
+    VALUES ($1, $2, $3, $4)
+    ON CONFLICT DO NOTHING
+  `
+	result, err := tx.ExecContext(ctx, query, member.ProjectID, member.UserID, member.Role, member.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrConflict
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
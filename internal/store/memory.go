@@ -0,0 +1,124 @@
+package store
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// NewMemoryStorage returns a Storage that keeps everything in process memory.
+// It is meant for tests and local development; nothing survives a restart.
+func NewMemoryStorage() Storage {
+	users := &MemoryUserStore{users: make(map[int64]User)}
+	members := &MemoryMembersStore{
+		members:     make(map[memberKey]ProjectMember),
+		invitations: make(map[int64]memoryInvitation),
+		users:       users,
+	}
+	todos := &MemoryTodosStore{todos: make(map[int64]Todo), members: members}
+	accessTokens := &MemoryAccessTokensStore{tokens: make(map[int64]memoryAccessToken)}
+	projects := &MemoryProjectsStore{
+		projects:     make(map[int64]Project),
+		todos:        todos,
+		members:      members,
+		accessTokens: accessTokens,
+	}
+	members.projects = projects
+	reminders := &MemoryRemindersStore{reminders: make(map[int64]Reminder), todos: todos}
+	sessions := &MemorySessionsStore{sessions: make(map[int64]Session), tokens: make(map[string]memoryRefreshToken)}
+	userTokens := &MemoryUserTokensStore{tokens: make(map[string]UserToken)}
+	totp := &MemoryTOTPStore{totps: make(map[int64]TOTP), codes: make(map[int64][][]byte)}
+	identities := &MemoryIdentitiesStore{identities: make(map[int64]Identity), flows: make(map[string]OIDCFlow)}
+	audit := &MemoryAuditStore{}
+	dataRequests := &MemoryDataRequestsStore{requests: make(map[int64]memoryDataRequest)}
+	todoHistory := &MemoryTodoHistoryStore{}
+	users.todos, users.projects, users.members = todos, projects, members
+	users.accessTokens, users.reminders, users.sessions = accessTokens, reminders, sessions
+	users.userTokens, users.totp, users.identities = userTokens, totp, identities
+	users.audit, users.dataRequests, users.todoHistory = audit, dataRequests, todoHistory
+
+	return Storage{
+		Todos:        todos,
+		Projects:     projects,
+		Members:      members,
+		Sessions:     sessions,
+		AccessTokens: accessTokens,
+		Reminders:    reminders,
+		Users:        users,
+		UserTokens:   userTokens,
+		TOTP:         totp,
+		Identities:   identities,
+		Audit:        audit,
+		DataRequests: dataRequests,
+		TodoHistory:  todoHistory,
+	}
+}
+
+type MemoryTodosStore struct {
+	mu      sync.RWMutex
+	todos   map[int64]Todo
+	nextID  int64
+	members *MemoryMembersStore
+}
+
+// MemoryUserStore deletes the rest of a deleted account from the stores it
+// points to, and counts what users have in them. It never holds its lock
+// while calling them.
+type MemoryUserStore struct {
+	mu     sync.RWMutex
+	users  map[int64]User
+	nextID int64
+
+	todos        *MemoryTodosStore
+	projects     *MemoryProjectsStore
+	members      *MemoryMembersStore
+	accessTokens *MemoryAccessTokensStore
+	reminders    *MemoryRemindersStore
+	sessions     *MemorySessionsStore
+	userTokens   *MemoryUserTokensStore
+	totp         *MemoryTOTPStore
+	identities   *MemoryIdentitiesStore
+	audit        *MemoryAuditStore
+	dataRequests *MemoryDataRequestsStore
+	todoHistory  *MemoryTodoHistoryStore
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+func copyTodo(todo Todo) Todo {
+	if todo.Tags != nil {
+		todo.Tags = append([]string(nil), todo.Tags...)
+	}
+	todo.StartAt = copyTime(todo.StartAt)
+	todo.DueAt = copyTime(todo.DueAt)
+	todo.DeletedAt = copyTime(todo.DeletedAt)
+	if todo.ParentID != nil {
+		id := *todo.ParentID
+		todo.ParentID = &id
+	}
+	if todo.ProjectID != nil {
+		id := *todo.ProjectID
+		todo.ProjectID = &id
+	}
+	return todo
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := t.UTC()
+	return &c
+}
+
+// sortTodos orders todos the way the SQL stores do: newest first.
+func sortTodos(todos []Todo) {
+	sort.Slice(todos, func(i, j int) bool {
+		if !todos[i].CreatedAt.Equal(todos[j].CreatedAt) {
+			return todos[i].CreatedAt.After(todos[j].CreatedAt)
+		}
+		return todos[i].ID > todos[j].ID
+	})
+}
@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+type MemoryAccessTokensStore struct {
+	mu     sync.Mutex
+	tokens map[int64]memoryAccessToken
+	nextID int64
+}
+
+type memoryAccessToken struct {
+	AccessToken
+	tokenHash string
+}
+
+func copyAccessToken(t AccessToken) AccessToken {
+	if t.ProjectID != nil {
+		id := *t.ProjectID
+		t.ProjectID = &id
+	}
+	t.ExpiresAt = copyTime(t.ExpiresAt)
+	t.LastUsedAt = copyTime(t.LastUsedAt)
+	return t
+}
+
+func (s *MemoryAccessTokensStore) Create(ctx context.Context, token *AccessToken) error {
+	secret, hash, err := newAccessToken()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	token.ID = s.nextID
+	token.ExpiresAt = copyTime(token.ExpiresAt)
+	token.LastUsedAt = nil
+	token.CreatedAt = now()
+
+	s.tokens[token.ID] = memoryAccessToken{AccessToken: copyAccessToken(*token), tokenHash: string(hash)}
+	token.Token = secret
+	return nil
+}
+
+func (s *MemoryAccessTokensStore) GetByUser(ctx context.Context, userID int64) ([]AccessToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tokens []AccessToken
+	for _, t := range s.tokens {
+		if t.UserID == userID {
+			tokens = append(tokens, copyAccessToken(t.AccessToken))
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		if !tokens[i].CreatedAt.Equal(tokens[j].CreatedAt) {
+			return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+		}
+		return tokens[i].ID > tokens[j].ID
+	})
+
+	return tokens, nil
+}
+
+func (s *MemoryAccessTokensStore) Authenticate(ctx context.Context, secret string) (*AccessToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := string(hashToken(secret))
+	for id, t := range s.tokens {
+		if t.tokenHash != hash {
+			continue
+		}
+		usedAt := now()
+		if t.ExpiresAt != nil && !t.ExpiresAt.After(usedAt) {
+			return nil, ErrNotFound
+		}
+
+		t.LastUsedAt = &usedAt
+		s.tokens[id] = t
+		token := copyAccessToken(t.AccessToken)
+		return &token, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryAccessTokensStore) Delete(ctx context.Context, userID, tokenID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[tokenID]
+	if !ok || t.UserID != userID {
+		return ErrNotFound
+	}
+	delete(s.tokens, tokenID)
+	return nil
+}
+
+func (s *MemoryAccessTokensStore) DeleteAll(ctx context.Context, userID int64) error {
+	s.deleteUser(userID)
+	return nil
+}
+
+// deleteProject drops the tokens limited to a deleted project, like ON
+// DELETE CASCADE in the SQL stores.
+func (s *MemoryAccessTokensStore) deleteProject(projectID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, t := range s.tokens {
+		if t.ProjectID != nil && *t.ProjectID == projectID {
+			delete(s.tokens, id)
+		}
+	}
+}
+
+// deleteUser drops the tokens of a deleted user.
+func (s *MemoryAccessTokensStore) deleteUser(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, t := range s.tokens {
+		if t.UserID == userID {
+			delete(s.tokens, id)
+		}
+	}
+}
+
+// countUser returns how many tokens userID has.
+func (s *MemoryAccessTokensStore) countUser(userID int64) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, t := range s.tokens {
+		if t.UserID == userID {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+type MemoryAuditStore struct {
+	mu sync.Mutex
+	// entries are in ID order.
+	entries []AuditEntry
+	nextID  int64
+}
+
+func (s *MemoryAuditStore) Create(ctx context.Context, entry *AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(entry.Details) == 0 {
+		entry.Details = json.RawMessage("{}")
+	}
+	s.nextID++
+	entry.ID = s.nextID
+	entry.CreatedAt = now()
+	s.entries = append(s.entries, copyAuditEntry(*entry))
+	return nil
+}
+
+func (s *MemoryAuditStore) List(ctx context.Context, f AuditFilter) ([]AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := []AuditEntry{}
+	for i := len(s.entries) - 1; i >= 0 && len(entries) < f.Limit; i-- {
+		e := s.entries[i]
+		switch {
+		case f.Before > 0 && e.ID >= f.Before,
+			f.ActorID != nil && (e.ActorID == nil || *e.ActorID != *f.ActorID),
+			f.TargetUserID != nil && (e.TargetUserID == nil || *e.TargetUserID != *f.TargetUserID):
+			continue
+		}
+		entries = append(entries, copyAuditEntry(e))
+	}
+	return entries, nil
+}
+
+func copyAuditEntry(e AuditEntry) AuditEntry {
+	if e.ActorID != nil {
+		id := *e.ActorID
+		e.ActorID = &id
+	}
+	if e.TargetUserID != nil {
+		id := *e.TargetUserID
+		e.TargetUserID = &id
+	}
+	e.Details = append(json.RawMessage(nil), e.Details...)
+	return e
+}
+
+// deleteUser drops the entries about an erased user and forgets the ones
+// they made as an admin.
+func (s *MemoryAuditStore) deleteUser(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := s.entries[:0]
+	for _, e := range s.entries {
+		if e.TargetUserID != nil && *e.TargetUserID == userID {
+			continue
+		}
+		if e.ActorID != nil && *e.ActorID == userID {
+			e.ActorID = nil
+		}
+		entries = append(entries, e)
+	}
+	s.entries = entries
+}
@@ -0,0 +1,156 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type MemoryDataRequestsStore struct {
+	mu       sync.Mutex
+	requests map[int64]memoryDataRequest
+	nextID   int64
+}
+
+type memoryDataRequest struct {
+	DataRequest
+	archive   []byte
+	claimedAt time.Time
+}
+
+func copyDataRequest(req DataRequest) DataRequest {
+	req.CompletedAt = copyTime(req.CompletedAt)
+	req.ExpiresAt = copyTime(req.ExpiresAt)
+	return req
+}
+
+func (s *MemoryDataRequestsStore) Create(ctx context.Context, req *DataRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range s.requests {
+		if r.UserID == req.UserID && r.Kind == req.Kind &&
+			(r.Status == DataRequestPending || r.Status == DataRequestRunning) {
+			return ErrConflict
+		}
+	}
+
+	s.nextID++
+	req.ID = s.nextID
+	req.Status = DataRequestPending
+	req.CreatedAt = now()
+	s.requests[req.ID] = memoryDataRequest{DataRequest: copyDataRequest(*req)}
+	return nil
+}
+
+func (s *MemoryDataRequestsStore) GetByUser(ctx context.Context, userID int64) ([]DataRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	requests := []DataRequest{}
+	for _, r := range s.requests {
+		if r.UserID == userID {
+			requests = append(requests, copyDataRequest(r.DataRequest))
+		}
+	}
+	sort.Slice(requests, func(i, j int) bool { return requests[i].ID > requests[j].ID })
+	return requests, nil
+}
+
+func (s *MemoryDataRequestsStore) ClaimPending(ctx context.Context, staleBefore time.Time, limit int) ([]DataRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []int64
+	for id, r := range s.requests {
+		if r.Status == DataRequestPending || (r.Status == DataRequestRunning && r.claimedAt.Before(staleBefore)) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	claimed := []DataRequest{}
+	for _, id := range ids {
+		r := s.requests[id]
+		r.Status = DataRequestRunning
+		r.claimedAt = now()
+		s.requests[id] = r
+		claimed = append(claimed, copyDataRequest(r.DataRequest))
+	}
+	return claimed, nil
+}
+
+func (s *MemoryDataRequestsStore) Complete(ctx context.Context, id int64, archive []byte, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.requests[id]
+	if !ok || r.Status != DataRequestRunning {
+		return ErrNotFound
+	}
+	completedAt := now()
+	r.Status = DataRequestReady
+	r.archive = bytes.Clone(archive)
+	r.CompletedAt = &completedAt
+	r.ExpiresAt = copyTime(&expiresAt)
+	s.requests[id] = r
+	return nil
+}
+
+func (s *MemoryDataRequestsStore) Fail(ctx context.Context, id int64, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.requests[id]
+	if !ok || r.Status != DataRequestRunning {
+		return ErrNotFound
+	}
+	completedAt := now()
+	r.Status = DataRequestFailed
+	r.Error = reason
+	r.CompletedAt = &completedAt
+	s.requests[id] = r
+	return nil
+}
+
+func (s *MemoryDataRequestsStore) GetArchive(ctx context.Context, id int64, at time.Time) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.requests[id]
+	if !ok || r.Status != DataRequestReady || !r.ExpiresAt.After(at) {
+		return nil, ErrNotFound
+	}
+	return bytes.Clone(r.archive), nil
+}
+
+func (s *MemoryDataRequestsStore) DeleteExpired(ctx context.Context, at time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for id, r := range s.requests {
+		if r.ExpiresAt != nil && !r.ExpiresAt.After(at) {
+			delete(s.requests, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// deleteUser drops the requests of an erased user.
+func (s *MemoryDataRequestsStore) deleteUser(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, r := range s.requests {
+		if r.UserID == userID {
+			delete(s.requests, id)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+type MemoryIdentitiesStore struct {
+	mu         sync.Mutex
+	identities map[int64]Identity
+	flows      map[string]OIDCFlow
+	nextID     int64
+}
+
+func (s *MemoryIdentitiesStore) Create(ctx context.Context, identity *Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, i := range s.identities {
+		if i.Provider == identity.Provider && i.Subject == identity.Subject {
+			return ErrConflict
+		}
+	}
+
+	s.nextID++
+	identity.ID = s.nextID
+	identity.CreatedAt = now()
+	s.identities[identity.ID] = *identity
+	return nil
+}
+
+func (s *MemoryIdentitiesStore) GetBySubject(ctx context.Context, provider, subject string) (*Identity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, i := range s.identities {
+		if i.Provider == provider && i.Subject == subject {
+			return &i, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryIdentitiesStore) GetByUser(ctx context.Context, userID int64) ([]Identity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var identities []Identity
+	for _, i := range s.identities {
+		if i.UserID == userID {
+			identities = append(identities, i)
+		}
+	}
+	sort.Slice(identities, func(a, b int) bool {
+		return identities[a].ID < identities[b].ID
+	})
+	return identities, nil
+}
+
+func (s *MemoryIdentitiesStore) Delete(ctx context.Context, userID, identityID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.identities[identityID]
+	if !ok || i.UserID != userID {
+		return ErrNotFound
+	}
+	delete(s.identities, identityID)
+	return nil
+}
+
+func (s *MemoryIdentitiesStore) CreateFlow(ctx context.Context, flow *OIDCFlow) error {
+	state, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flow.ExpiresAt = flow.ExpiresAt.UTC()
+	flow.CreatedAt = now()
+	for h, f := range s.flows {
+		if !f.ExpiresAt.After(flow.CreatedAt) {
+			delete(s.flows, h)
+		}
+	}
+
+	s.nextID++
+	flow.ID = s.nextID
+	stored := *flow
+	if flow.UserID != nil {
+		userID := *flow.UserID
+		stored.UserID = &userID
+	}
+	s.flows[string(hash)] = stored
+
+	flow.State = state
+	return nil
+}
+
+func (s *MemoryIdentitiesStore) ConsumeFlow(ctx context.Context, provider, state string) (*OIDCFlow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := string(hashToken(state))
+	flow, ok := s.flows[hash]
+	if !ok || flow.Provider != provider || !flow.ExpiresAt.After(now()) {
+		return nil, ErrNotFound
+	}
+	delete(s.flows, hash)
+	return &flow, nil
+}
+
+// deleteUser drops the identities of an erased user and the links they had
+// started.
+func (s *MemoryIdentitiesStore) deleteUser(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, identity := range s.identities {
+		if identity.UserID == userID {
+			delete(s.identities, id)
+		}
+	}
+	for hash, flow := range s.flows {
+		if flow.UserID != nil && *flow.UserID == userID {
+			delete(s.flows, hash)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type memberKey struct {
+	projectID, userID int64
+}
+
+// MemoryMembersStore reads project owners from projects and member names
+// from users. Callers that also hold the projects or todos lock must take
+// that one first.
+type MemoryMembersStore struct {
+	mu          sync.RWMutex
+	members     map[memberKey]ProjectMember
+	invitations map[int64]memoryInvitation
+	nextID      int64
+	projects    *MemoryProjectsStore
+	users       *MemoryUserStore
+}
+
+type memoryInvitation struct {
+	Invitation
+	tokenHash string
+}
+
+// role returns the role of a member that is not the owner. The caller must
+// hold s.mu.
+func (s *MemoryMembersStore) role(projectID, userID int64) Role {
+	return s.members[memberKey{projectID, userID}].Role
+}
+
+func (s *MemoryMembersStore) GetRole(ctx context.Context, projectID, userID int64) (Role, error) {
+	s.projects.mu.RLock()
+	p, ok := s.projects.projects[projectID]
+	s.projects.mu.RUnlock()
+	if ok && p.UserID == userID {
+		return RoleOwner, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if role := s.role(projectID, userID); role != "" {
+		return role, nil
+	}
+	return "", ErrNotFound
+}
+
+func (s *MemoryMembersStore) GetMembers(ctx context.Context, projectID int64) ([]ProjectMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	s.users.mu.RLock()
+	defer s.users.mu.RUnlock()
+
+	var members []ProjectMember
+	for _, m := range s.members {
+		if m.ProjectID == projectID {
+			m.Username, m.Email = s.users.users[m.UserID].Username, s.users.users[m.UserID].Email
+			members = append(members, m)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if !members[i].CreatedAt.Equal(members[j].CreatedAt) {
+			return members[i].CreatedAt.Before(members[j].CreatedAt)
+		}
+		return members[i].UserID < members[j].UserID
+	})
+
+	return members, nil
+}
+
+func (s *MemoryMembersStore) UpdateMember(ctx context.Context, projectID, userID int64, role Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.members[memberKey{projectID, userID}]
+	if !ok {
+		return ErrNotFound
+	}
+	m.Role = role
+	s.members[memberKey{projectID, userID}] = m
+	return nil
+}
+
+func (s *MemoryMembersStore) RemoveMember(ctx context.Context, projectID, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.members[memberKey{projectID, userID}]; !ok {
+		return ErrNotFound
+	}
+	delete(s.members, memberKey{projectID, userID})
+	return nil
+}
+
+func (s *MemoryMembersStore) CreateInvitation(ctx context.Context, inv *Invitation) error {
+	token, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	inv.ID = s.nextID
+	inv.Email = strings.ToLower(inv.Email)
+	inv.ExpiresAt = inv.ExpiresAt.UTC()
+	inv.CreatedAt = now()
+
+	s.invitations[inv.ID] = memoryInvitation{Invitation: *inv, tokenHash: string(hash)}
+	inv.Token = token
+	return nil
+}
+
+func (s *MemoryMembersStore) GetInvitations(ctx context.Context, projectID int64) ([]Invitation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var invitations []Invitation
+	for _, inv := range s.invitations {
+		if inv.ProjectID == projectID && inv.ExpiresAt.After(now()) {
+			invitations = append(invitations, inv.Invitation)
+		}
+	}
+	sort.Slice(invitations, func(i, j int) bool {
+		return invitations[i].ID < invitations[j].ID
+	})
+
+	return invitations, nil
+}
+
+func (s *MemoryMembersStore) DeleteInvitation(ctx context.Context, projectID, invitationID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inv, ok := s.invitations[invitationID]
+	if !ok || inv.ProjectID != projectID {
+		return ErrNotFound
+	}
+	delete(s.invitations, invitationID)
+	return nil
+}
+
+func (s *MemoryMembersStore) AcceptInvitation(ctx context.Context, token string, user *User) (*ProjectMember, error) {
+	s.projects.mu.RLock()
+	defer s.projects.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := string(hashToken(token))
+	var inv memoryInvitation
+	for _, i := range s.invitations {
+		if i.tokenHash == hash && i.ExpiresAt.After(now()) {
+			inv = i
+		}
+	}
+	if inv.ID == 0 {
+		return nil, ErrNotFound
+	}
+
+	if !strings.EqualFold(inv.Email, user.Email) {
+		return nil, ErrInvitationEmail
+	}
+
+	key := memberKey{inv.ProjectID, user.ID}
+	if _, ok := s.members[key]; ok || s.projects.projects[inv.ProjectID].UserID == user.ID {
+		return nil, ErrConflict
+	}
+
+	member := ProjectMember{
+		ProjectID: inv.ProjectID,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		Role:      inv.Role,
+		CreatedAt: now(),
+	}
+	s.members[key] = member
+	delete(s.invitations, inv.ID)
+
+	return &member, nil
+}
+
+// deleteProject drops the memberships and invitations of a deleted project.
+func (s *MemoryMembersStore) deleteProject(projectID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.members {
+		if key.projectID == projectID {
+			delete(s.members, key)
+		}
+	}
+	for id, inv := range s.invitations {
+		if inv.ProjectID == projectID {
+			delete(s.invitations, id)
+		}
+	}
+}
+
+// deleteUser drops the memberships of a deleted user and the invitations
+// they sent.
+func (s *MemoryMembersStore) deleteUser(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.members {
+		if key.userID == userID {
+			delete(s.members, key)
+		}
+	}
+	for id, inv := range s.invitations {
+		if inv.InvitedBy == userID {
+			delete(s.invitations, id)
+		}
+	}
+}
+
+// countUser returns how many projects userID is a member of.
+func (s *MemoryMembersStore) countUser(userID int64) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := 0
+	for key := range s.members {
+		if key.userID == userID {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+type MemoryProjectsStore struct {
+	mu       sync.RWMutex
+	projects map[int64]Project
+	nextID   int64
+	todos    *MemoryTodosStore
+	members  *MemoryMembersStore
+	// accessTokens limited to a project are deleted with it.
+	accessTokens *MemoryAccessTokensStore
+}
+
+func (s *MemoryProjectsStore) Create(ctx context.Context, project *Project) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project.Position = 0
+	for _, p := range s.projects {
+		if p.UserID == project.UserID && p.Position >= project.Position {
+			project.Position = p.Position + 1
+		}
+	}
+
+	s.nextID++
+	project.ID = s.nextID
+	project.Role = RoleOwner
+	project.CreatedAt = now()
+	project.UpdatedAt = project.CreatedAt
+
+	s.projects[project.ID] = *project
+	return nil
+}
+
+func (s *MemoryProjectsStore) GetByUser(ctx context.Context, userID int64, archived bool) ([]Project, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.members.mu.RLock()
+	defer s.members.mu.RUnlock()
+
+	var projects []Project
+	for _, p := range s.projects {
+		if p, ok := s.visible(p, userID); ok && (archived || !p.Archived) {
+			projects = append(projects, p)
+		}
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		if projects[i].Position != projects[j].Position {
+			return projects[i].Position < projects[j].Position
+		}
+		return projects[i].ID < projects[j].ID
+	})
+
+	return projects, nil
+}
+
+func (s *MemoryProjectsStore) GetByID(ctx context.Context, userID, projectID int64) (*Project, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.members.mu.RLock()
+	defer s.members.mu.RUnlock()
+
+	p, ok := s.visible(s.projects[projectID], userID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &p, nil
+}
+
+// visible sets the role of userID on p and reports whether they have one.
+// The caller must hold s.mu and s.members.mu.
+func (s *MemoryProjectsStore) visible(p Project, userID int64) (Project, bool) {
+	switch {
+	case p.ID == 0:
+		return p, false
+	case p.UserID == userID:
+		p.Role = RoleOwner
+	default:
+		p.Role = s.members.role(p.ID, userID)
+	}
+	return p, p.Role != ""
+}
+
+func (s *MemoryProjectsStore) Update(ctx context.Context, userID, projectID int64, updates map[string]interface{}) error {
+	if len(updates) == 0 {
+		return fmt.Errorf("no fields to update")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.projects[projectID]
+	if !ok || p.UserID != userID {
+		return ErrNotFound
+	}
+
+	for field, value := range updates {
+		var ok bool
+		switch field {
+		case "name":
+			p.Name, ok = value.(string)
+		case "color":
+			p.Color, ok = value.(string)
+		case "archived":
+			p.Archived, ok = value.(bool)
+		case "position":
+			var pos int64
+			pos, ok = toInt64(value)
+			p.Position = int(pos)
+		default:
+			return fmt.Errorf("unknown column %q", field)
+		}
+		if !ok {
+			return fmt.Errorf("invalid value %v for column %q", value, field)
+		}
+	}
+	p.UpdatedAt = now()
+
+	s.projects[projectID] = p
+	return nil
+}
+
+func (s *MemoryProjectsStore) Delete(ctx context.Context, userID, projectID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.projects[projectID]
+	if !ok || p.UserID != userID {
+		return ErrNotFound
+	}
+	delete(s.projects, projectID)
+	s.members.deleteProject(projectID)
+	s.accessTokens.deleteProject(projectID)
+
+	// Like ON DELETE SET NULL in the SQL stores.
+	s.todos.mu.Lock()
+	defer s.todos.mu.Unlock()
+	for id, todo := range s.todos.todos {
+		if todo.ProjectID != nil && *todo.ProjectID == projectID {
+			todo.ProjectID = nil
+			s.todos.todos[id] = todo
+		}
+	}
+
+	return nil
+}
+
+// deleteUser deletes the projects userID owns.
+func (s *MemoryProjectsStore) deleteUser(userID int64) {
+	s.mu.RLock()
+	var ids []int64
+	for id, p := range s.projects {
+		if p.UserID == userID {
+			ids = append(ids, id)
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, id := range ids {
+		s.Delete(context.Background(), userID, id)
+	}
+}
+
+// countUser returns how many projects userID owns.
+func (s *MemoryProjectsStore) countUser(userID int64) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := 0
+	for _, p := range s.projects {
+		if p.UserID == userID {
+			n++
+		}
+	}
+	return n
+}
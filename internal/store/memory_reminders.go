@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type MemoryRemindersStore struct {
+	mu        sync.Mutex
+	reminders map[int64]Reminder
+	nextID    int64
+	todos     *MemoryTodosStore
+}
+
+func (s *MemoryRemindersStore) Create(ctx context.Context, reminder *Reminder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	reminder.ID = s.nextID
+	reminder.RemindAt = reminder.RemindAt.UTC()
+	reminder.CreatedAt = now()
+
+	s.reminders[reminder.ID] = *reminder
+	return nil
+}
+
+func (s *MemoryRemindersStore) GetByTodo(ctx context.Context, userID, todoID int64) ([]Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var reminders []Reminder
+	for _, r := range s.reminders {
+		if r.UserID == userID && r.TodoID == todoID {
+			reminders = append(reminders, r)
+		}
+	}
+	sortReminders(reminders)
+
+	return reminders, nil
+}
+
+func (s *MemoryRemindersStore) Delete(ctx context.Context, userID, todoID, reminderID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.reminders[reminderID]
+	if !ok || r.UserID != userID || r.TodoID != todoID {
+		return ErrNotFound
+	}
+
+	delete(s.reminders, reminderID)
+	return nil
+}
+
+func (s *MemoryRemindersStore) ClaimDue(ctx context.Context, at time.Time, limit int) ([]Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []Reminder
+	for id, r := range s.reminders {
+		// Reminders go away with their todo, like the ON DELETE CASCADE
+		// of the SQL schemas, and wait while it is in the trash.
+		exists, trashed := s.todoState(r.TodoID)
+		if !exists {
+			delete(s.reminders, id)
+			continue
+		}
+		if r.FiredAt == nil && !trashed && !r.RemindAt.After(at) {
+			due = append(due, r)
+		}
+	}
+	sortReminders(due)
+
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for i := range due {
+		fired := at.UTC()
+		due[i].FiredAt = &fired
+		s.reminders[due[i].ID] = due[i]
+	}
+
+	return due, nil
+}
+
+// deleteUser drops the reminders of a deleted user.
+func (s *MemoryRemindersStore) deleteUser(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, r := range s.reminders {
+		if r.UserID == userID {
+			delete(s.reminders, id)
+		}
+	}
+}
+
+func (s *MemoryRemindersStore) todoState(todoID int64) (exists, trashed bool) {
+	s.todos.mu.RLock()
+	defer s.todos.mu.RUnlock()
+
+	todo, ok := s.todos.todos[todoID]
+	return ok, ok && todo.DeletedAt != nil
+}
+
+func sortReminders(reminders []Reminder) {
+	sort.Slice(reminders, func(i, j int) bool {
+		if !reminders[i].RemindAt.Equal(reminders[j].RemindAt) {
+			return reminders[i].RemindAt.Before(reminders[j].RemindAt)
+		}
+		return reminders[i].ID < reminders[j].ID
+	})
+}
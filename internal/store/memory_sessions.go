@@ -0,0 +1,168 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type MemorySessionsStore struct {
+	mu       sync.Mutex
+	sessions map[int64]Session
+	tokens   map[string]memoryRefreshToken
+	nextID   int64
+}
+
+type memoryRefreshToken struct {
+	sessionID int64
+	expiresAt time.Time
+	used      bool
+}
+
+func (s *MemorySessionsStore) Create(ctx context.Context, session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	session.ID = s.nextID
+	session.RevokedAt = nil
+	session.CreatedAt = now()
+	s.sessions[session.ID] = *session
+
+	return s.issue(session)
+}
+
+func (s *MemorySessionsStore) Get(ctx context.Context, sessionID int64) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	session.RevokedAt = copyTime(session.RevokedAt)
+	return &session, nil
+}
+
+func (s *MemorySessionsStore) Refresh(ctx context.Context, token string, expiresAt time.Time) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := string(hashToken(token))
+	t, ok := s.tokens[hash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	session := s.sessions[t.sessionID]
+	if t.used {
+		if session.RevokedAt == nil {
+			revokedAt := now()
+			session.RevokedAt = &revokedAt
+			s.sessions[session.ID] = session
+		}
+		return nil, ErrTokenReused
+	}
+	if session.RevokedAt != nil || !t.expiresAt.After(now()) {
+		return nil, ErrNotFound
+	}
+
+	t.used = true
+	s.tokens[hash] = t
+
+	session.RefreshExpiresAt = expiresAt
+	if err := s.issue(&session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s *MemorySessionsStore) Revoke(ctx context.Context, userID, sessionID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok || session.UserID != userID || session.RevokedAt != nil {
+		return ErrNotFound
+	}
+	revokedAt := now()
+	session.RevokedAt = &revokedAt
+	s.sessions[sessionID] = session
+	return nil
+}
+
+func (s *MemorySessionsStore) RevokeAll(ctx context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revokedAt := now()
+	for id, session := range s.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &revokedAt
+			s.sessions[id] = session
+		}
+	}
+	return nil
+}
+
+// issue gives session a new refresh token. The caller must hold s.mu.
+func (s *MemorySessionsStore) issue(session *Session) error {
+	token, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	session.RefreshExpiresAt = session.RefreshExpiresAt.UTC()
+	s.tokens[string(hash)] = memoryRefreshToken{
+		sessionID: session.ID,
+		expiresAt: session.RefreshExpiresAt,
+	}
+	session.RefreshToken = token
+	return nil
+}
+
+// usage returns how many sessions of userID can still be refreshed, and when
+// the newest one was created.
+func (s *MemorySessionsStore) usage(userID int64) (active int, lastLogin *time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	live := make(map[int64]bool)
+	t := now()
+	for _, rt := range s.tokens {
+		if !rt.used && rt.expiresAt.After(t) {
+			live[rt.sessionID] = true
+		}
+	}
+
+	for _, session := range s.sessions {
+		if session.UserID != userID {
+			continue
+		}
+		if session.RevokedAt == nil && live[session.ID] {
+			active++
+		}
+		if lastLogin == nil || session.CreatedAt.After(*lastLogin) {
+			createdAt := session.CreatedAt
+			lastLogin = &createdAt
+		}
+	}
+	return active, lastLogin
+}
+
+// deleteUser drops the sessions of an erased user and their refresh tokens.
+func (s *MemorySessionsStore) deleteUser(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, session := range s.sessions {
+		if session.UserID == userID {
+			delete(s.sessions, id)
+		}
+	}
+	for hash, rt := range s.tokens {
+		if _, ok := s.sessions[rt.sessionID]; !ok {
+			delete(s.tokens, hash)
+		}
+	}
+}
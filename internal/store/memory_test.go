@@ -0,0 +1,14 @@
+package store_test
+
+import (
+	"testing"
+
+	"open-todo-go/internal/store"
+	"open-todo-go/internal/store/storetest"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Storage {
+		return store.NewMemoryStorage()
+	})
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+type MemoryTodoHistoryStore struct {
+	mu sync.Mutex
+	// versions are in ID order.
+	versions []TodoVersion
+	nextID   int64
+}
+
+func (s *MemoryTodoHistoryStore) Create(ctx context.Context, v *TodoVersion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if v.Changes == nil {
+		v.Changes = map[string]FieldChange{}
+	}
+	v.TodoID, v.UserID, v.CreatedAt = v.Todo.ID, v.Todo.UserID, now()
+	v.Version = 1
+	for _, other := range s.versions {
+		if other.TodoID == v.TodoID && other.Version >= v.Version {
+			v.Version = other.Version + 1
+		}
+	}
+	s.nextID++
+	v.ID = s.nextID
+	s.versions = append(s.versions, copyTodoVersion(*v))
+	return nil
+}
+
+func (s *MemoryTodoHistoryStore) List(ctx context.Context, f TodoHistoryFilter) ([]TodoVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	versions := []TodoVersion{}
+	for i := len(s.versions) - 1; i >= 0 && len(versions) < f.Limit; i-- {
+		v := s.versions[i]
+		switch {
+		case f.Before > 0 && v.ID >= f.Before,
+			f.TodoID != nil && v.TodoID != *f.TodoID,
+			f.UserID != nil && v.UserID != *f.UserID:
+			continue
+		}
+		versions = append(versions, copyTodoVersion(v))
+	}
+	return versions, nil
+}
+
+func (s *MemoryTodoHistoryStore) Get(ctx context.Context, todoID int64, version int) (*TodoVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, v := range s.versions {
+		if v.TodoID == todoID && v.Version == version {
+			c := copyTodoVersion(v)
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func copyTodoVersion(v TodoVersion) TodoVersion {
+	if v.ActorID != nil {
+		id := *v.ActorID
+		v.ActorID = &id
+	}
+	changes := make(map[string]FieldChange, len(v.Changes))
+	for name, c := range v.Changes {
+		changes[name] = FieldChange{
+			From: append(json.RawMessage(nil), c.From...),
+			To:   append(json.RawMessage(nil), c.To...),
+		}
+	}
+	v.Changes = changes
+	v.Todo = copyTodo(v.Todo)
+	return v
+}
+
+// deleteUser drops the history of a deleted user's todos and forgets the
+// changes they made to others', like the foreign keys of the SQL stores.
+func (s *MemoryTodoHistoryStore) deleteUser(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	versions := s.versions[:0]
+	for _, v := range s.versions {
+		if v.UserID == userID {
+			continue
+		}
+		if v.ActorID != nil && *v.ActorID == userID {
+			v.ActorID = nil
+		}
+		versions = append(versions, v)
+	}
+	s.versions = versions
+}
@@ -0,0 +1,496 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+func (s *MemoryTodosStore) Create(ctx context.Context, todo *Todo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if todo.Timezone == "" {
+		todo.Timezone = "UTC"
+	}
+	if todo.ParentID != nil {
+		if parent, ok := s.todos[*todo.ParentID]; !ok || parent.UserID != todo.UserID || parent.DeletedAt != nil {
+			return ErrInvalidParent
+		}
+	}
+
+	s.nextID++
+	todo.ID = s.nextID
+	todo.CreatedAt = now()
+	todo.UpdatedAt = todo.CreatedAt.Format(time.RFC3339Nano)
+
+	s.todos[todo.ID] = copyTodo(*todo)
+	return nil
+}
+
+func (s *MemoryTodosStore) GetAllTodos(ctx context.Context, userID int64) ([]Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var todos []Todo
+	for _, todo := range s.todos {
+		if todo.UserID == userID && todo.DeletedAt == nil {
+			todos = append(todos, copyTodo(todo))
+		}
+	}
+	sortTodos(todos)
+
+	return todos, nil
+}
+
+func (s *MemoryTodosStore) ListTodos(ctx context.Context, userID int64, f TodoFilter) (*TodoPage, error) {
+	todos, err := s.GetAllTodos(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return filterTodos(todos, f)
+}
+
+func (s *MemoryTodosStore) SearchTodos(ctx context.Context, userID int64, q string, limit int) ([]TodoSearchResult, error) {
+	todos, err := s.GetAllTodos(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return rankTodos(todos, q, limit), nil
+}
+
+func (s *MemoryTodosStore) GetTodoByID(ctx context.Context, userID, todoID int64) (*Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	todo, ok := s.todos[todoID]
+	if !ok || todo.UserID != userID || todo.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+
+	todo = copyTodo(todo)
+	return &todo, nil
+}
+
+func (s *MemoryTodosStore) GetProjectTodo(ctx context.Context, memberID, todoID int64) (*Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.members.mu.RLock()
+	defer s.members.mu.RUnlock()
+
+	todo, ok := s.todos[todoID]
+	if !ok || todo.DeletedAt != nil || todo.ProjectID == nil || s.members.role(*todo.ProjectID, memberID) == "" {
+		return nil, ErrNotFound
+	}
+
+	todo = copyTodo(todo)
+	return &todo, nil
+}
+
+func (s *MemoryTodosStore) UpdateTodo(ctx context.Context, userID, todoID int64, updates map[string]interface{}) error {
+	if len(updates) == 0 {
+		return fmt.Errorf("no fields to update")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	todo, ok := s.todos[todoID]
+	if !ok || todo.UserID != userID || todo.DeletedAt != nil {
+		return ErrNotFound
+	}
+
+	for field, value := range updates {
+		if err := applyTodoUpdate(&todo, field, value); err != nil {
+			return fmt.Errorf("error updating todo: %w", err)
+		}
+	}
+	updatedAt := now().Format(time.RFC3339Nano)
+	todo.UpdatedAt = updatedAt
+
+	s.todos[todoID] = copyTodo(todo)
+
+	// Subtasks are in their parent's project, so they follow the todo to
+	// its new one.
+	if _, ok := updates["project_id"]; ok {
+		s.setSubtreeProject(todoID, todo.ProjectID, updatedAt)
+	}
+	return nil
+}
+
+func (s *MemoryTodosStore) GetTodosByTag(ctx context.Context, userID int64, tag string) ([]Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var todos []Todo
+	for _, todo := range s.todos {
+		if todo.UserID == userID && todo.DeletedAt == nil && slices.Contains(todo.Tags, tag) {
+			todos = append(todos, copyTodo(todo))
+		}
+	}
+	sortTodos(todos)
+
+	return todos, nil
+}
+
+func (s *MemoryTodosStore) GetTodosDue(ctx context.Context, userID int64, from, to *time.Time) ([]Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var todos []Todo
+	for _, todo := range s.todos {
+		switch {
+		case todo.UserID != userID, todo.DeletedAt != nil, todo.Completed, todo.DueAt == nil,
+			from != nil && todo.DueAt.Before(*from),
+			to != nil && !todo.DueAt.Before(*to):
+			continue
+		}
+		todos = append(todos, copyTodo(todo))
+	}
+
+	sort.Slice(todos, func(i, j int) bool {
+		if !todos[i].DueAt.Equal(*todos[j].DueAt) {
+			return todos[i].DueAt.Before(*todos[j].DueAt)
+		}
+		return todos[i].ID < todos[j].ID
+	})
+
+	return todos, nil
+}
+
+func (s *MemoryTodosStore) GetSubtree(ctx context.Context, userID, todoID int64) ([]Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	root, ok := s.todos[todoID]
+	if !ok || root.UserID != userID || root.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+
+	ids := s.subtreeIDs(todoID)
+	todos := make([]Todo, 0, len(ids))
+	for _, id := range ids {
+		// The subtasks of a todo in the trash are in it too.
+		if todo := s.todos[id]; todo.DeletedAt == nil {
+			todos = append(todos, copyTodo(todo))
+		}
+	}
+	// subtreeIDs starts with the root, which stays first.
+	descendants := todos[1:]
+	sort.Slice(descendants, func(i, j int) bool {
+		if !descendants[i].CreatedAt.Equal(descendants[j].CreatedAt) {
+			return descendants[i].CreatedAt.Before(descendants[j].CreatedAt)
+		}
+		return descendants[i].ID < descendants[j].ID
+	})
+
+	return todos, nil
+}
+
+func (s *MemoryTodosStore) CountOpenSubtasks(ctx context.Context, userID, parentID int64) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := 0
+	for _, todo := range s.todos {
+		if todo.ParentID != nil && *todo.ParentID == parentID && todo.UserID == userID && !todo.Completed && todo.DeletedAt == nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *MemoryTodosStore) MoveTodo(ctx context.Context, userID, todoID int64, parentID *int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	todo, ok := s.todos[todoID]
+	if !ok || todo.UserID != userID || todo.DeletedAt != nil {
+		return ErrNotFound
+	}
+
+	if parentID != nil {
+		parent, ok := s.todos[*parentID]
+		if !ok || parent.UserID != userID || parent.DeletedAt != nil {
+			return ErrInvalidParent
+		}
+		for id := parentID; id != nil; id = s.todos[*id].ParentID {
+			if *id == todoID {
+				return ErrCycle
+			}
+		}
+		parentID = &parent.ID
+	}
+
+	updatedAt := now().Format(time.RFC3339Nano)
+	todo.ParentID = parentID
+	todo.UpdatedAt = updatedAt
+	s.todos[todoID] = todo
+
+	// Subtasks are in their parent's project, so the moved todos join the
+	// new parent's.
+	if parentID != nil {
+		s.setSubtreeProject(todoID, s.todos[*parentID].ProjectID, updatedAt)
+	}
+	return nil
+}
+
+// setSubtreeProject puts todoID and all of its subtasks in projectID.
+func (s *MemoryTodosStore) setSubtreeProject(todoID int64, projectID *int64, updatedAt string) {
+	for _, id := range s.subtreeIDs(todoID) {
+		todo := s.todos[id]
+		if !sameID(todo.ProjectID, projectID) {
+			todo.ProjectID = projectID
+			todo.UpdatedAt = updatedAt
+			s.todos[id] = copyTodo(todo)
+		}
+	}
+}
+
+// DeleteTodo moves the todo and its subtasks to the trash.
+func (s *MemoryTodosStore) DeleteTodo(ctx context.Context, userID, todoID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	todo, ok := s.todos[todoID]
+	if !ok || todo.UserID != userID || todo.DeletedAt != nil {
+		return ErrNotFound
+	}
+
+	deletedAt := now()
+	for _, id := range s.subtreeIDs(todoID) {
+		if todo := s.todos[id]; todo.DeletedAt == nil {
+			todo.DeletedAt = &deletedAt
+			s.todos[id] = todo
+		}
+	}
+	return nil
+}
+
+func (s *MemoryTodosStore) GetTrash(ctx context.Context, userID int64) ([]Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var todos []Todo
+	for _, todo := range s.todos {
+		if todo.UserID == userID && todo.DeletedAt != nil {
+			todos = append(todos, copyTodo(todo))
+		}
+	}
+	sort.Slice(todos, func(i, j int) bool {
+		if !todos[i].DeletedAt.Equal(*todos[j].DeletedAt) {
+			return todos[i].DeletedAt.After(*todos[j].DeletedAt)
+		}
+		return todos[i].ID > todos[j].ID
+	})
+
+	return todos, nil
+}
+
+// RestoreTodo takes the todo out of the trash along with the subtasks that
+// were deleted with it. A todo whose parent is still in the trash is
+// restored to the top level.
+func (s *MemoryTodosStore) RestoreTodo(ctx context.Context, userID, todoID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	todo, ok := s.todos[todoID]
+	if !ok || todo.UserID != userID || todo.DeletedAt == nil {
+		return ErrNotFound
+	}
+
+	deletedAt := *todo.DeletedAt
+	if todo.ParentID != nil && s.todos[*todo.ParentID].DeletedAt != nil {
+		todo.ParentID = nil
+		s.todos[todoID] = todo
+	}
+
+	// Subtasks deleted on their own, earlier, stay in the trash, and so do
+	// theirs.
+	ids := []int64{todoID}
+	for i := 0; i < len(ids); i++ {
+		for id, child := range s.todos {
+			if child.ParentID != nil && *child.ParentID == ids[i] && child.DeletedAt != nil && child.DeletedAt.Equal(deletedAt) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	for _, id := range ids {
+		todo := s.todos[id]
+		todo.DeletedAt = nil
+		s.todos[id] = todo
+	}
+	return nil
+}
+
+// PurgeTodo deletes a todo in the trash for good. Subtasks go with their
+// parent, like the ON DELETE CASCADE of the SQL stores.
+func (s *MemoryTodosStore) PurgeTodo(ctx context.Context, userID, todoID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	todo, ok := s.todos[todoID]
+	if !ok || todo.UserID != userID || todo.DeletedAt == nil {
+		return ErrNotFound
+	}
+
+	for _, id := range s.subtreeIDs(todoID) {
+		delete(s.todos, id)
+	}
+	return nil
+}
+
+func (s *MemoryTodosStore) EmptyTrash(ctx context.Context, userID int64) (int, error) {
+	return s.purge(func(todo Todo) bool { return todo.UserID == userID }), nil
+}
+
+func (s *MemoryTodosStore) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	return s.purge(func(todo Todo) bool { return todo.DeletedAt.Before(before) }), nil
+}
+
+// purge deletes the todos in the trash that match and returns how many
+// there were.
+func (s *MemoryTodosStore) purge(match func(Todo) bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matched []int64
+	for id, todo := range s.todos {
+		if todo.DeletedAt != nil && match(todo) {
+			matched = append(matched, id)
+		}
+	}
+	for _, id := range matched {
+		for _, id := range s.subtreeIDs(id) {
+			delete(s.todos, id)
+		}
+	}
+	return len(matched)
+}
+
+// deleteUser deletes the todos of a deleted user, with their subtasks.
+func (s *MemoryTodosStore) deleteUser(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, todo := range s.todos {
+		if todo.UserID != userID {
+			continue
+		}
+		for _, id := range s.subtreeIDs(id) {
+			delete(s.todos, id)
+		}
+	}
+}
+
+// subtreeIDs returns todoID and the IDs of all its descendants. The caller
+// must hold s.mu.
+func (s *MemoryTodosStore) subtreeIDs(todoID int64) []int64 {
+	children := make(map[int64][]int64)
+	for _, todo := range s.todos {
+		if todo.ParentID != nil {
+			children[*todo.ParentID] = append(children[*todo.ParentID], todo.ID)
+		}
+	}
+
+	ids := []int64{todoID}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids
+}
+
+// applyTodoUpdate sets a single column from an UpdateTodo map on todo.
+func applyTodoUpdate(todo *Todo, field string, value interface{}) error {
+	var ok bool
+	switch field {
+	case "title":
+		todo.Title, ok = value.(string)
+	case "description":
+		todo.Description, ok = value.(string)
+	case "completed":
+		todo.Completed, ok = value.(bool)
+	case "priority":
+		var p int64
+		p, ok = toInt64(value)
+		todo.Priority = int16(p)
+	case "tags":
+		todo.Tags, ok = value.([]string)
+	case "start_at", "due_at":
+		var t *time.Time
+		if value == nil {
+			ok = true
+		} else if v, isTime := value.(time.Time); isTime {
+			t, ok = &v, true
+		}
+		if field == "start_at" {
+			todo.StartAt = t
+		} else {
+			todo.DueAt = t
+		}
+	case "timezone":
+		todo.Timezone, ok = value.(string)
+	case "recurrence":
+		todo.Recurrence, ok = value.(string)
+	case "project_id":
+		if value == nil {
+			todo.ProjectID, ok = nil, true
+		} else {
+			var id int64
+			id, ok = toInt64(value)
+			todo.ProjectID = &id
+		}
+	case "complete_with_children":
+		todo.CompleteWithChildren, ok = value.(bool)
+	default:
+		return fmt.Errorf("unknown column %q", field)
+	}
+
+	if !ok {
+		return fmt.Errorf("invalid value %v for column %q", value, field)
+	}
+	return nil
+}
+
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
+
+// countUser returns how many todos userID has and how many of them are
+// completed.
+func (s *MemoryTodosStore) countUser(userID int64) (total, completed int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, todo := range s.todos {
+		if todo.UserID == userID && todo.DeletedAt == nil {
+			total++
+			if todo.Completed {
+				completed++
+			}
+		}
+	}
+	return total, completed
+}
+
+// sameID reports whether two optional IDs are both unset or equal.
+func sameID(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"sync"
+)
+
+type MemoryTOTPStore struct {
+	mu    sync.Mutex
+	totps map[int64]TOTP
+	// codes are the hashes of each user's unused recovery codes.
+	codes map[int64][][]byte
+}
+
+func copyTOTP(t TOTP) TOTP {
+	t.Secret = bytes.Clone(t.Secret)
+	t.ConfirmedAt = copyTime(t.ConfirmedAt)
+	return t
+}
+
+func (s *MemoryTOTPStore) Get(ctx context.Context, userID int64) (*TOTP, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.totps[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	t = copyTOTP(t)
+	return &t, nil
+}
+
+func (s *MemoryTOTPStore) Enroll(ctx context.Context, userID int64, secret []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.totps[userID]; ok && t.ConfirmedAt != nil {
+		return ErrConflict
+	}
+	s.totps[userID] = TOTP{UserID: userID, Secret: bytes.Clone(secret), CreatedAt: now()}
+	return nil
+}
+
+func (s *MemoryTOTPStore) Confirm(ctx context.Context, userID, step int64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.totps[userID]
+	if !ok || t.ConfirmedAt != nil {
+		return nil, ErrNotFound
+	}
+
+	codes, err := s.replaceCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	confirmedAt := now()
+	t.ConfirmedAt = &confirmedAt
+	t.LastUsedStep = step
+	s.totps[userID] = t
+	return codes, nil
+}
+
+func (s *MemoryTOTPStore) UseStep(ctx context.Context, userID, step int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.totps[userID]
+	if !ok || t.ConfirmedAt == nil || t.LastUsedStep >= step {
+		return ErrNotFound
+	}
+	t.LastUsedStep = step
+	s.totps[userID] = t
+	return nil
+}
+
+func (s *MemoryTOTPStore) Delete(ctx context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.totps[userID]; !ok {
+		return ErrNotFound
+	}
+	delete(s.totps, userID)
+	delete(s.codes, userID)
+	return nil
+}
+
+func (s *MemoryTOTPStore) RegenerateRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.replaceCodes(userID)
+}
+
+func (s *MemoryTOTPStore) UseRecoveryCode(ctx context.Context, userID int64, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := hashToken(normalizeRecoveryCode(code))
+	codes := s.codes[userID]
+	for i, h := range codes {
+		if bytes.Equal(h, hash) {
+			s.codes[userID] = append(codes[:i:i], codes[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryTOTPStore) CountRecoveryCodes(ctx context.Context, userID int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.codes[userID]), nil
+}
+
+func (s *MemoryTOTPStore) replaceCodes(userID int64) ([]string, error) {
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([][]byte, len(codes))
+	for i, code := range codes {
+		hashes[i] = hashToken(normalizeRecoveryCode(code))
+	}
+	s.codes[userID] = hashes
+	return codes, nil
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+	"sync"
+)
+
+type MemoryUserTokensStore struct {
+	mu     sync.Mutex
+	tokens map[string]UserToken
+	nextID int64
+}
+
+func (s *MemoryUserTokensStore) Create(ctx context.Context, token *UserToken) error {
+	secret, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for h, t := range s.tokens {
+		if t.UserID == token.UserID && t.Purpose == token.Purpose {
+			delete(s.tokens, h)
+		}
+	}
+
+	s.nextID++
+	token.ID = s.nextID
+	token.ExpiresAt = token.ExpiresAt.UTC()
+	token.CreatedAt = now()
+	s.tokens[string(hash)] = *token
+
+	token.Token = secret
+	return nil
+}
+
+func (s *MemoryUserTokensStore) Consume(ctx context.Context, purpose TokenPurpose, secret string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := string(hashToken(secret))
+	t, ok := s.tokens[hash]
+	if !ok || t.Purpose != purpose || !t.ExpiresAt.After(now()) {
+		return 0, ErrNotFound
+	}
+	delete(s.tokens, hash)
+	return t.UserID, nil
+}
+
+func (s *MemoryUserTokensStore) DeleteAll(ctx context.Context, userID int64) error {
+	s.deleteUser(userID)
+	return nil
+}
+
+// deleteUser drops the tokens of an erased user.
+func (s *MemoryUserTokensStore) deleteUser(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, t := range s.tokens {
+		if t.UserID == userID {
+			delete(s.tokens, hash)
+		}
+	}
+}
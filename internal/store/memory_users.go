@@ -0,0 +1,318 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (s *MemoryUserStore) Create(ctx context.Context, user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkDuplicate(user); err != nil {
+		return err
+	}
+
+	user.setDefaults()
+	s.nextID++
+	user.ID = s.nextID
+	user.CreatedAt = now().Format(time.RFC3339Nano)
+	user.EmailVerifiedAt = nil
+	user.FailedLogins = 0
+	user.LockedUntil = nil
+	user.DeletedAt = nil
+	user.SuspendedAt = nil
+
+	s.users[user.ID] = *user
+	return nil
+}
+
+// checkDuplicate returns ErrDuplicateEmail or ErrDuplicateUsername if
+// another user has user's email address or username. The caller must hold
+// s.mu.
+func (s *MemoryUserStore) checkDuplicate(user *User) error {
+	for _, u := range s.users {
+		if u.ID == user.ID {
+			continue
+		}
+		if u.Email == user.Email {
+			return ErrDuplicateEmail
+		}
+		if u.Username == user.Username {
+			return ErrDuplicateUsername
+		}
+	}
+	return nil
+}
+
+func (s *MemoryUserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return &user, nil
+}
+
+func (s *MemoryUserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (s *MemoryUserStore) SetEmailVerified(ctx context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	if user.EmailVerifiedAt == nil {
+		verifiedAt := now()
+		user.EmailVerifiedAt = &verifiedAt
+		s.users[userID] = user
+	}
+	return nil
+}
+
+func (s *MemoryUserStore) UpdatePassword(ctx context.Context, user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[user.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	u.Password = password{Hash: append([]byte(nil), user.Password.Hash...)}
+	s.users[user.ID] = u
+	return nil
+}
+
+func (s *MemoryUserStore) RecordFailedLogin(ctx context.Context, userID int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	user.FailedLogins++
+	s.users[userID] = user
+	return user.FailedLogins, nil
+}
+
+func (s *MemoryUserStore) LockLogin(ctx context.Context, userID int64, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	until = until.UTC()
+	user.LockedUntil = &until
+	s.users[userID] = user
+	return nil
+}
+
+func (s *MemoryUserStore) ResetFailedLogins(ctx context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	user.FailedLogins = 0
+	user.LockedUntil = nil
+	s.users[userID] = user
+	return nil
+}
+
+func (s *MemoryUserStore) UpdateProfile(ctx context.Context, user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[user.ID]
+	if !ok || u.DeletedAt != nil {
+		return ErrNotFound
+	}
+	if err := s.checkDuplicate(user); err != nil {
+		return err
+	}
+
+	u.Username = user.Username
+	u.Email = user.Email
+	u.EmailVerifiedAt = copyTime(user.EmailVerifiedAt)
+	u.Timezone = user.Timezone
+	u.Locale = user.Locale
+	s.users[user.ID] = u
+	return nil
+}
+
+// Delete deletes the user along with their projects, todos, memberships,
+// access tokens and todo history, like ON DELETE CASCADE in the SQL stores.
+func (s *MemoryUserStore) Delete(ctx context.Context, userID int64) error {
+	s.mu.Lock()
+	_, ok := s.users[userID]
+	delete(s.users, userID)
+	s.mu.Unlock()
+	if !ok {
+		return ErrNotFound
+	}
+
+	s.projects.deleteUser(userID)
+	s.todos.deleteUser(userID)
+	s.members.deleteUser(userID)
+	s.accessTokens.deleteUser(userID)
+	s.todoHistory.deleteUser(userID)
+	return nil
+}
+
+// Erase deletes the user and everything tied to them from every store.
+func (s *MemoryUserStore) Erase(ctx context.Context, userID int64) error {
+	if err := s.Delete(ctx, userID); err != nil {
+		return err
+	}
+
+	s.reminders.deleteUser(userID)
+	s.sessions.deleteUser(userID)
+	s.userTokens.deleteUser(userID)
+	if err := s.totp.Delete(ctx, userID); err != nil && err != ErrNotFound {
+		return err
+	}
+	s.identities.deleteUser(userID)
+	s.audit.deleteUser(userID)
+	s.dataRequests.deleteUser(userID)
+	return nil
+}
+
+// Anonymize strips the user of personal data, ends their sessions and drops
+// their memberships, access tokens and reminders, keeping their todos and
+// projects. Second factors and identities need no clearing: a user without
+// an email address or password cannot log in, and deleted users are refused
+// everywhere else.
+func (s *MemoryUserStore) Anonymize(ctx context.Context, userID int64) error {
+	s.mu.Lock()
+	user, ok := s.users[userID]
+	if ok && user.DeletedAt == nil {
+		deletedAt := now()
+		s.users[userID] = User{
+			ID:          user.ID,
+			Username:    fmt.Sprintf("deleted-%d", userID),
+			Email:       fmt.Sprintf("deleted-%d@deleted.invalid", userID),
+			CreatedAt:   user.CreatedAt,
+			Timezone:    DefaultTimezone,
+			Locale:      DefaultLocale,
+			DeletedAt:   &deletedAt,
+			Role:        UserRoleUser,
+			SuspendedAt: user.SuspendedAt,
+		}
+	}
+	s.mu.Unlock()
+	if !ok || user.DeletedAt != nil {
+		return ErrNotFound
+	}
+
+	s.members.deleteUser(userID)
+	s.accessTokens.deleteUser(userID)
+	s.reminders.deleteUser(userID)
+	return s.sessions.RevokeAll(ctx, userID)
+}
+
+func (s *MemoryUserStore) List(ctx context.Context, f UserFilter) (*UserPage, error) {
+	after, err := decodeUserCursor(f)
+	if err != nil {
+		return nil, err
+	}
+	search := strings.ToLower(f.Search)
+
+	s.mu.RLock()
+	var matched []User
+	for _, u := range s.users {
+		switch {
+		case u.DeletedAt != nil,
+			search != "" && !strings.Contains(strings.ToLower(u.Username), search) && !strings.Contains(strings.ToLower(u.Email), search),
+			f.Role != "" && u.Role != f.Role,
+			f.Suspended != nil && *f.Suspended != (u.SuspendedAt != nil):
+			continue
+		}
+		matched = append(matched, u)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID < matched[j].ID })
+
+	page := &UserPage{Users: []User{}, Total: len(matched)}
+	for _, u := range matched {
+		if u.ID > after {
+			page.Users = append(page.Users, u)
+		}
+	}
+	if len(page.Users) > f.Limit {
+		page.Users = page.Users[:f.Limit]
+		page.NextCursor = strconv.FormatInt(page.Users[f.Limit-1].ID, 10)
+	}
+	return page, nil
+}
+
+func (s *MemoryUserStore) Usage(ctx context.Context, userID int64) (*UserUsage, error) {
+	var usage UserUsage
+	usage.Todos, usage.CompletedTodos = s.todos.countUser(userID)
+	usage.Projects = s.projects.countUser(userID)
+	usage.SharedProjects = s.members.countUser(userID)
+	usage.AccessTokens = s.accessTokens.countUser(userID)
+	usage.ActiveSessions, usage.LastLoginAt = s.sessions.usage(userID)
+	return &usage, nil
+}
+
+func (s *MemoryUserStore) SetRole(ctx context.Context, userID int64, role UserRole) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok || user.DeletedAt != nil {
+		return ErrNotFound
+	}
+	user.Role = role
+	s.users[userID] = user
+	return nil
+}
+
+func (s *MemoryUserStore) SetSuspended(ctx context.Context, userID int64, suspended bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok || user.DeletedAt != nil {
+		return ErrNotFound
+	}
+	switch {
+	case !suspended:
+		user.SuspendedAt = nil
+	case user.SuspendedAt == nil:
+		suspendedAt := now()
+		user.SuspendedAt = &suspendedAt
+	}
+	s.users[userID] = user
+
+	if suspended {
+		return s.sessions.RevokeAll(ctx, userID)
+	}
+	return nil
+}
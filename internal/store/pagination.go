@@ -0,0 +1,279 @@
+package store
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// TodoFilter narrows, orders and pages the todos returned by ListTodos.
+type TodoFilter struct {
+	Limit         int        `json:"limit" validate:"gte=1,lte=100"`
+	Cursor        string     `json:"cursor"`
+	Sort          string     `json:"sort" validate:"oneof=created_at updated_at priority title"`
+	Order         string     `json:"order" validate:"oneof=asc desc"`
+	Completed     *bool      `json:"completed"`
+	MinPriority   *int16     `json:"minPriority" validate:"omitempty,min=0,max=5"`
+	MaxPriority   *int16     `json:"maxPriority" validate:"omitempty,min=0,max=5"`
+	Tags          []string   `json:"tags" validate:"max=10"`
+	CreatedAfter  *time.Time `json:"createdAfter"`
+	CreatedBefore *time.Time `json:"createdBefore"`
+	UpdatedAfter  *time.Time `json:"updatedAfter"`
+	UpdatedBefore *time.Time `json:"updatedBefore"`
+	Search        string     `json:"q" validate:"max=100"`
+	// ProjectID limits the list to one project. It is set from the URL of
+	// the project-scoped listing rather than the query string.
+	ProjectID *int64 `json:"-"`
+}
+
+type TodoPage struct {
+	Todos      []Todo `json:"todos"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	Total      int    `json:"total"`
+}
+
+// DefaultTodoFilter is the first page of a user's todos, newest first.
+func DefaultTodoFilter() TodoFilter {
+	return TodoFilter{Limit: 20, Sort: "created_at", Order: "desc"}
+}
+
+// todoCursor is the position after the last todo of a page. It is handed to
+// clients base64 encoded and only valid for the sort it was created with.
+type todoCursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+func encodeTodoCursor(f TodoFilter, todo Todo) string {
+	c := todoCursor{Sort: f.Sort, Order: f.Order, ID: todo.ID}
+	switch f.Sort {
+	case "created_at":
+		c.Value = todo.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case "updated_at":
+		c.Value = todo.UpdatedAt
+	case "priority":
+		c.Value = strconv.Itoa(int(todo.Priority))
+	case "title":
+		c.Value = todo.Title
+	}
+
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeTodoCursor returns the cursor in f, or nil when f has none. The value
+// is converted to the Go type of the sort column.
+func decodeTodoCursor(f TodoFilter) (*todoCursor, any, error) {
+	if f.Cursor == "" {
+		return nil, nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(f.Cursor)
+	if err != nil {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	var c todoCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.Sort != f.Sort || c.Order != f.Order {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	var value any
+	switch c.Sort {
+	case "created_at", "updated_at":
+		value, err = time.Parse(time.RFC3339Nano, c.Value)
+	case "priority":
+		value, err = strconv.Atoi(c.Value)
+	default:
+		value = c.Value
+	}
+	if err != nil {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	return &c, value, nil
+}
+
+// todoFilterSQL builds the WHERE conditions shared by the SQL backends for
+// everything in f except the cursor. Placeholders start at $2; $1 is the
+// user ID.
+func todoFilterSQL(f TodoFilter, tagsCond func(arg string) (string, any), likeOp string) ([]string, []any) {
+	conds := []string{"user_id = $1", "deleted_at IS NULL"}
+	var args []any
+
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args)+1)
+	}
+
+	if f.ProjectID != nil {
+		conds = append(conds, "project_id = "+arg(*f.ProjectID))
+	}
+	if f.Completed != nil {
+		conds = append(conds, "completed = "+arg(*f.Completed))
+	}
+	if f.MinPriority != nil {
+		conds = append(conds, "priority >= "+arg(*f.MinPriority))
+	}
+	if f.MaxPriority != nil {
+		conds = append(conds, "priority <= "+arg(*f.MaxPriority))
+	}
+	if len(f.Tags) > 0 {
+		cond, v := tagsCond(fmt.Sprintf("$%d", len(args)+2))
+		args = append(args, v)
+		conds = append(conds, cond)
+	}
+	if f.CreatedAfter != nil {
+		conds = append(conds, "created_at >= "+arg(f.CreatedAfter.UTC()))
+	}
+	if f.CreatedBefore != nil {
+		conds = append(conds, "created_at < "+arg(f.CreatedBefore.UTC()))
+	}
+	if f.UpdatedAfter != nil {
+		conds = append(conds, "updated_at >= "+arg(f.UpdatedAfter.UTC()))
+	}
+	if f.UpdatedBefore != nil {
+		conds = append(conds, "updated_at < "+arg(f.UpdatedBefore.UTC()))
+	}
+	if f.Search != "" {
+		p := arg("%" + escapeLike(f.Search) + "%")
+		conds = append(conds, fmt.Sprintf(`(title %[1]s %[2]s ESCAPE '\' OR description %[1]s %[2]s ESCAPE '\')`, likeOp, p))
+	}
+
+	return conds, args
+}
+
+// todoSortSQL is the expression f sorts by. Titles are compared byte by byte
+// under collation, the dialect's binary collation, so that every backend
+// orders them like strings.Compare whatever the database's default.
+func todoSortSQL(f TodoFilter, collation string) string {
+	if f.Sort == "title" {
+		return "title COLLATE " + collation
+	}
+	return f.Sort
+}
+
+// todoCursorSQL returns the keyset condition that skips everything up to and
+// including the cursor position, using placeholder numbers after n.
+func todoCursorSQL(f TodoFilter, collation string, value any, id int64, n int) (string, []any) {
+	op := "<"
+	if f.Order == "asc" {
+		op = ">"
+	}
+	cond := fmt.Sprintf("(%[1]s %[2]s $%[3]d OR (%[1]s = $%[3]d AND id %[2]s $%[4]d))", todoSortSQL(f, collation), op, n+1, n+2)
+	return cond, []any{value, id}
+}
+
+func todoOrderSQL(f TodoFilter, collation string) string {
+	return fmt.Sprintf("%[1]s %[2]s, id %[2]s", todoSortSQL(f, collation), strings.ToUpper(f.Order))
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// filterTodos applies f to todos in memory the same way the SQL stores do.
+func filterTodos(todos []Todo, f TodoFilter) (*TodoPage, error) {
+	cursor, cursorValue, err := decodeTodoCursor(f)
+	if err != nil {
+		return nil, err
+	}
+
+	search := strings.ToLower(f.Search)
+
+	var matched []Todo
+	for _, todo := range todos {
+		updatedAt, _ := time.Parse(time.RFC3339Nano, todo.UpdatedAt)
+
+		switch {
+		case f.ProjectID != nil && (todo.ProjectID == nil || *todo.ProjectID != *f.ProjectID),
+			f.Completed != nil && todo.Completed != *f.Completed,
+			f.MinPriority != nil && todo.Priority < *f.MinPriority,
+			f.MaxPriority != nil && todo.Priority > *f.MaxPriority,
+			!hasAllTags(todo.Tags, f.Tags),
+			f.CreatedAfter != nil && todo.CreatedAt.Before(*f.CreatedAfter),
+			f.CreatedBefore != nil && !todo.CreatedAt.Before(*f.CreatedBefore),
+			f.UpdatedAfter != nil && updatedAt.Before(*f.UpdatedAfter),
+			f.UpdatedBefore != nil && !updatedAt.Before(*f.UpdatedBefore),
+			search != "" && !strings.Contains(strings.ToLower(todo.Title), search) &&
+				!strings.Contains(strings.ToLower(todo.Description), search):
+			continue
+		}
+		matched = append(matched, todo)
+	}
+
+	less := func(a, b Todo) int {
+		var c int
+		switch f.Sort {
+		case "created_at":
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		case "updated_at":
+			ta, _ := time.Parse(time.RFC3339Nano, a.UpdatedAt)
+			tb, _ := time.Parse(time.RFC3339Nano, b.UpdatedAt)
+			c = ta.Compare(tb)
+		case "priority":
+			c = cmp.Compare(a.Priority, b.Priority)
+		case "title":
+			c = strings.Compare(a.Title, b.Title)
+		}
+		if c == 0 {
+			c = cmp.Compare(a.ID, b.ID)
+		}
+		if f.Order == "desc" {
+			c = -c
+		}
+		return c
+	}
+	sort.Slice(matched, func(i, j int) bool { return less(matched[i], matched[j]) < 0 })
+
+	page := &TodoPage{Total: len(matched)}
+
+	start := 0
+	if cursor != nil {
+		pivot := Todo{ID: cursor.ID}
+		switch v := cursorValue.(type) {
+		case time.Time:
+			pivot.CreatedAt = v
+			pivot.UpdatedAt = v.Format(time.RFC3339Nano)
+		case int:
+			pivot.Priority = int16(v)
+		case string:
+			pivot.Title = v
+		}
+		start = sort.Search(len(matched), func(i int) bool { return less(matched[i], pivot) > 0 })
+	}
+
+	end := min(start+f.Limit, len(matched))
+	page.Todos = matched[start:end]
+	if end < len(matched) && len(page.Todos) > 0 {
+		page.NextCursor = encodeTodoCursor(f, page.Todos[len(page.Todos)-1])
+	}
+
+	return page, nil
+}
+
+func hasAllTags(tags, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, t := range tags {
+			if t == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"open-todo-go/internal/db"
+	"open-todo-go/internal/migrate"
+	"open-todo-go/internal/store"
+	"open-todo-go/internal/store/storetest"
+
+	_ "github.com/lib/pq"
+)
+
+var pgSchemas atomic.Int64
+
+// TestPostgresStorage runs the suite against the Postgres database at
+// DB_ADDR, giving every subtest a schema of its own. It is skipped when
+// DB_ADDR is not set.
+func TestPostgresStorage(t *testing.T) {
+	addr := os.Getenv("DB_ADDR")
+	if addr == "" {
+		t.Skip("DB_ADDR not set")
+	}
+
+	admin, err := db.New("postgres", addr, 2, 2, "1m")
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+	defer admin.Close()
+
+	storetest.Run(t, func(t *testing.T) store.Storage {
+		return store.NewStorage(newPostgresDB(t, admin, addr))
+	})
+}
+
+func newPostgresDB(t *testing.T, admin *sql.DB, addr string) *sql.DB {
+	t.Helper()
+	ctx := context.Background()
+
+	schema := fmt.Sprintf("storetest_%d_%d", time.Now().UnixNano(), pgSchemas.Add(1))
+	if _, err := admin.ExecContext(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.ExecContext(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+	})
+
+	d, err := db.New("postgres", withSearchPath(addr, schema), 4, 4, "1m")
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	m, err := migrate.New(d, migrate.Postgres)
+	if err != nil {
+		t.Fatalf("migrate.New: %v", err)
+	}
+	if _, err := m.Up(ctx, 0); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	return d
+}
+
+// withSearchPath points every connection opened with addr, a URL or a list
+// of key=value settings, at schema.
+func withSearchPath(addr, schema string) string {
+	if !strings.Contains(addr, "://") {
+		return addr + " search_path=" + schema
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return addr
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
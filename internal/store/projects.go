@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// model
+type Project struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"userID"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	Archived  bool      `json:"archived"`
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Role is the role of the user the project was loaded for.
+	Role Role `json:"role"`
+}
+
+type ProjectsStore struct {
+	db *sql.DB
+}
+
+// visibleProjectsSQL selects the projects user $1 owns or is a member of,
+// along with the user's role in each. It is shared by the SQL stores.
+const visibleProjectsSQL = `
+    SELECT p.id, p.user_id, p.name, p.color, p.archived, p.position, p.created_at, p.updated_at,
+      coalesce(m.role, 'owner')
+    FROM projects p
+    LEFT JOIN project_members m ON m.project_id = p.id AND m.user_id = $1
+    WHERE (p.user_id = $1 OR m.user_id IS NOT NULL)`
+
+func projectScanDest(p *Project) []any {
+	return []any{&p.ID, &p.UserID, &p.Name, &p.Color, &p.Archived, &p.Position, &p.CreatedAt, &p.UpdatedAt, &p.Role}
+}
+
+// Create adds the project after the user's existing ones.
+func (s *ProjectsStore) Create(ctx context.Context, project *Project) error {
+	query := `
+    INSERT INTO projects (user_id, name, color, archived, position)
+    VALUES ($1, $2, $3, $4, (SELECT coalesce(max(position), -1) + 1 FROM projects WHERE user_id = $1))
+    RETURNING id, position, created_at, updated_at
+  `
+	project.Role = RoleOwner
+	return s.db.QueryRowContext(ctx, query, project.UserID, project.Name, project.Color, project.Archived).Scan(
+		&project.ID,
+		&project.Position,
+		&project.CreatedAt,
+		&project.UpdatedAt,
+	)
+}
+
+// GetByUser returns the projects the user owns or is a member of, in their
+// display order. Archived projects are left out unless archived is set.
+func (s *ProjectsStore) GetByUser(ctx context.Context, userID int64, archived bool) ([]Project, error) {
+	query := visibleProjectsSQL + `
+      AND ($2 OR NOT p.archived)
+    ORDER BY p.position ASC, p.id ASC
+  `
+	return queryProjects(ctx, s.db, query, userID, archived)
+}
+
+// GetByID returns the project if the user owns it or is a member of it.
+func (s *ProjectsStore) GetByID(ctx context.Context, userID, projectID int64) (*Project, error) {
+	query := visibleProjectsSQL + `
+      AND p.id = $2
+  `
+	var project Project
+	err := s.db.QueryRowContext(ctx, query, userID, projectID).Scan(projectScanDest(&project)...)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// Update sets the given columns (name, color, archived, position) of a
+// project owned by userID.
+func (s *ProjectsStore) Update(ctx context.Context, userID, projectID int64, updates map[string]interface{}) error {
+	query, args, err := updateProjectSQL(updates, time.Now())
+	if err != nil {
+		return err
+	}
+	return execAffectingOne(ctx, s.db, query, append(args, projectID, userID)...)
+}
+
+// Delete removes a project owned by userID. Its todos are kept and no
+// longer belong to a project.
+func (s *ProjectsStore) Delete(ctx context.Context, userID, projectID int64) error {
+	query := `DELETE FROM projects WHERE id = $1 AND user_id = $2`
+	return execAffectingOne(ctx, s.db, query, projectID, userID)
+}
+
+// updateProjectSQL builds the UPDATE statement shared by the SQL stores. The
+// project and user IDs are the last two placeholders.
+func updateProjectSQL(updates map[string]interface{}, updatedAt time.Time) (string, []any, error) {
+	if len(updates) == 0 {
+		return "", nil, fmt.Errorf("no fields to update")
+	}
+
+	var fields []string
+	var args []any
+	for field, value := range updates {
+		switch field {
+		case "name", "color", "archived", "position":
+		default:
+			return "", nil, fmt.Errorf("unknown column %q", field)
+		}
+		args = append(args, value)
+		fields = append(fields, fmt.Sprintf(`"%s" = $%d`, field, len(args)))
+	}
+	args = append(args, updatedAt)
+	fields = append(fields, fmt.Sprintf("updated_at = $%d", len(args)))
+
+	query := fmt.Sprintf("UPDATE projects SET %s WHERE id = $%d AND user_id = $%d", strings.Join(fields, ", "), len(args)+1, len(args)+2)
+	return query, args, nil
+}
+
+func queryProjects(ctx context.Context, db *sql.DB, query string, args ...any) ([]Project, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var projects []Project
+	for rows.Next() {
+		var p Project
+		if err := rows.Scan(projectScanDest(&p)...); err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+
+	return projects, rows.Err()
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// model
+type Reminder struct {
+	ID        int64      `json:"id"`
+	TodoID    int64      `json:"todoID"`
+	UserID    int64      `json:"userID"`
+	RemindAt  time.Time  `json:"remindAt"`
+	FiredAt   *time.Time `json:"firedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+type RemindersStore struct {
+	db *sql.DB
+}
+
+const reminderColumns = `id, todo_id, user_id, remind_at, fired_at, created_at`
+
+func reminderScanDest(r *Reminder) []any {
+	return []any{&r.ID, &r.TodoID, &r.UserID, &r.RemindAt, &r.FiredAt, &r.CreatedAt}
+}
+
+func (s *RemindersStore) Create(ctx context.Context, reminder *Reminder) error {
+	query := `
+    INSERT INTO reminders (todo_id, user_id, remind_at)
+    VALUES ($1, $2, $3) RETURNING id, created_at
+  `
+	return s.db.QueryRowContext(ctx, query, reminder.TodoID, reminder.UserID, reminder.RemindAt).Scan(
+		&reminder.ID,
+		&reminder.CreatedAt,
+	)
+}
+
+func (s *RemindersStore) GetByTodo(ctx context.Context, userID, todoID int64) ([]Reminder, error) {
+	query := `
+    SELECT ` + reminderColumns + `
+    FROM reminders
+    WHERE user_id = $1 AND todo_id = $2
+    ORDER BY remind_at ASC, id ASC
+  `
+	return queryReminders(ctx, s.db, query, userID, todoID)
+}
+
+func (s *RemindersStore) Delete(ctx context.Context, userID, todoID, reminderID int64) error {
+	query := `DELETE FROM reminders WHERE id = $1 AND todo_id = $2 AND user_id = $3`
+	return execAffectingOne(ctx, s.db, query, reminderID, todoID, userID)
+}
+
+// ClaimDue marks up to limit unfired reminders due at or before now as fired
+// and returns them. Rows locked by another instance are skipped, so each
+// reminder is claimed exactly once. Reminders of todos in the trash wait
+// until the todo is restored.
+func (s *RemindersStore) ClaimDue(ctx context.Context, now time.Time, limit int) ([]Reminder, error) {
+	query := `
+    UPDATE reminders SET fired_at = $1
+    WHERE id IN (
+      SELECT id FROM reminders
+      WHERE fired_at IS NULL AND remind_at <= $1
+        AND todo_id IN (SELECT id FROM todos WHERE deleted_at IS NULL)
+      ORDER BY remind_at ASC
+      LIMIT $2
+      FOR UPDATE SKIP LOCKED
+    )
+    RETURNING ` + reminderColumns
+	return queryReminders(ctx, s.db, query, now, limit)
+}
+
+func queryReminders(ctx context.Context, db *sql.DB, query string, args ...any) ([]Reminder, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []Reminder
+	for rows.Next() {
+		var r Reminder
+		if err := rows.Scan(reminderScanDest(&r)...); err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, r)
+	}
+
+	return reminders, rows.Err()
+}
+
+// execAffectingOne runs query and returns ErrNotFound if it touched no rows.
+func execAffectingOne(ctx context.Context, db *sql.DB, query string, args ...any) error {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	highlightStart = "<mark>"
+	highlightStop  = "</mark>"
+	snippetRadius  = 60
+)
+
+type TodoSearchResult struct {
+	Todo
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// searchTerms splits a free-text query into lower-cased words.
+func searchTerms(q string) []string {
+	return strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// rankTodos is the search used by backends without Postgres full-text
+// search. A todo matches when every term starts a word in its title or
+// description; title hits weigh more than description hits, mirroring the
+// A/B weights of the Postgres search vector.
+func rankTodos(todos []Todo, q string, limit int) []TodoSearchResult {
+	terms := searchTerms(q)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	var results []TodoSearchResult
+	for _, todo := range todos {
+		titleWords := searchTerms(todo.Title)
+		descWords := searchTerms(todo.Description)
+
+		var rank float64
+		matched := true
+		for _, term := range terms {
+			hits := 1.0*float64(countPrefixed(titleWords, term)) + 0.4*float64(countPrefixed(descWords, term))
+			if hits == 0 {
+				matched = false
+				break
+			}
+			rank += hits
+		}
+		if !matched {
+			continue
+		}
+
+		rank /= float64(len(titleWords) + len(descWords))
+		results = append(results, TodoSearchResult{
+			Todo:    todo,
+			Rank:    rank,
+			Snippet: highlight(todo.Title+" "+todo.Description, terms),
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].ID > results[j].ID
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+func countPrefixed(words []string, term string) int {
+	n := 0
+	for _, w := range words {
+		if strings.HasPrefix(w, term) {
+			n++
+		}
+	}
+	return n
+}
+
+// highlight returns the part of text around the first matching word with
+// every matching word wrapped in <mark> tags, like ts_headline does.
+func highlight(text string, terms []string) string {
+	type span struct{ start, end int }
+
+	var spans []span
+	start := -1
+	for i, r := range text + " " {
+		word := unicode.IsLetter(r) || unicode.IsNumber(r)
+		switch {
+		case word && start < 0:
+			start = i
+		case !word && start >= 0:
+			lower := strings.ToLower(text[start:i])
+			for _, term := range terms {
+				if strings.HasPrefix(lower, term) {
+					spans = append(spans, span{start, i})
+					break
+				}
+			}
+			start = -1
+		}
+	}
+	if len(spans) == 0 {
+		return ""
+	}
+
+	// Widen the snippet to whole words. Runes are decoded rather than bytes
+	// read, so neither end lands inside a multi-byte character.
+	from := max(0, spans[0].start-snippetRadius)
+	to := min(len(text), spans[0].end+snippetRadius)
+	for from > 0 {
+		r, size := utf8.DecodeLastRuneInString(text[:from])
+		if unicode.IsSpace(r) {
+			break
+		}
+		from -= size
+	}
+	for to < len(text) {
+		r, size := utf8.DecodeRuneInString(text[to:])
+		if unicode.IsSpace(r) {
+			break
+		}
+		to += size
+	}
+
+	var b strings.Builder
+	pos := from
+	for _, sp := range spans {
+		if sp.start < from || sp.end > to {
+			continue
+		}
+		b.WriteString(text[pos:sp.start])
+		b.WriteString(highlightStart)
+		b.WriteString(text[sp.start:sp.end])
+		b.WriteString(highlightStop)
+		pos = sp.end
+	}
+	b.WriteString(text[pos:to])
+
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,177 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrTokenReused is returned when a refresh token that was already exchanged
+// is presented again. The token has most likely been stolen, so the store
+// revokes its session before returning it.
+var ErrTokenReused = errors.New("refresh token was already used")
+
+// Session is one login of a user. Its refresh tokens rotate on every use;
+// revoking the session invalidates all of them and the access tokens issued
+// with them.
+type Session struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"userID"`
+	RevokedAt *time.Time `json:"revokedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+	// RefreshToken is the session's newest refresh token, only known right
+	// after Create or Refresh; the store keeps a hash of it.
+	RefreshToken     string    `json:"-"`
+	RefreshExpiresAt time.Time `json:"-"`
+}
+
+// SessionsStore keeps sessions and their refresh tokens. Its queries are
+// portable, so the SQLite storage uses it too.
+type SessionsStore struct {
+	db *sql.DB
+}
+
+const sessionColumns = `id, user_id, revoked_at, created_at`
+
+func sessionScanDest(s *Session) []any {
+	return []any{&s.ID, &s.UserID, &s.RevokedAt, &s.CreatedAt}
+}
+
+// Create starts a session for session.UserID with a refresh token that
+// expires at session.RefreshExpiresAt.
+func (s *SessionsStore) Create(ctx context.Context, session *Session) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	session.CreatedAt = now()
+	query := `INSERT INTO sessions (user_id, created_at) VALUES ($1, $2) RETURNING id`
+	if err := tx.QueryRowContext(ctx, query, session.UserID, session.CreatedAt).Scan(&session.ID); err != nil {
+		return err
+	}
+
+	if err := insertRefreshToken(ctx, tx, session); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SessionsStore) Get(ctx context.Context, sessionID int64) (*Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var session Session
+	err := s.db.QueryRowContext(ctx, query, sessionID).Scan(sessionScanDest(&session)...)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Refresh exchanges token for a new refresh token of the same session that
+// expires at expiresAt. Unknown and expired tokens and tokens of revoked
+// sessions are ErrNotFound; a token that was already exchanged revokes the
+// session and is ErrTokenReused.
+func (s *SessionsStore) Refresh(ctx context.Context, token string, expiresAt time.Time) (*Session, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	hash, t := hashToken(token), now()
+
+	var sessionID int64
+	query := `
+    UPDATE refresh_tokens SET used_at = $1
+    WHERE token_hash = $2 AND used_at IS NULL AND expires_at > $1
+      AND session_id IN (SELECT id FROM sessions WHERE revoked_at IS NULL)
+    RETURNING session_id
+  `
+	err = tx.QueryRowContext(ctx, query, t, hash).Scan(&sessionID)
+	if err == sql.ErrNoRows {
+		var used bool
+		query = `SELECT session_id, used_at IS NOT NULL FROM refresh_tokens WHERE token_hash = $1`
+		err = tx.QueryRowContext(ctx, query, hash).Scan(&sessionID, &used)
+		if err == sql.ErrNoRows || (err == nil && !used) {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		query = `UPDATE sessions SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL`
+		if _, err := tx.ExecContext(ctx, query, t, sessionID); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return nil, ErrTokenReused
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{RefreshExpiresAt: expiresAt}
+	query = `SELECT ` + sessionColumns + ` FROM sessions WHERE id = $1`
+	if err := tx.QueryRowContext(ctx, query, sessionID).Scan(sessionScanDest(session)...); err != nil {
+		return nil, err
+	}
+
+	if err := insertRefreshToken(ctx, tx, session); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Revoke ends one of the user's sessions.
+func (s *SessionsStore) Revoke(ctx context.Context, userID, sessionID int64) error {
+	query := `UPDATE sessions SET revoked_at = $1 WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL`
+	return execAffectingOne(ctx, s.db, query, now(), sessionID, userID)
+}
+
+// RevokeAll ends every session of the user.
+func (s *SessionsStore) RevokeAll(ctx context.Context, userID int64) error {
+	query := `UPDATE sessions SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL`
+	_, err := s.db.ExecContext(ctx, query, now(), userID)
+	return err
+}
+
+// insertRefreshToken gives session a new refresh token expiring at
+// session.RefreshExpiresAt and sets session.RefreshToken.
+func insertRefreshToken(ctx context.Context, tx *sql.Tx, session *Session) error {
+	token, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	session.RefreshExpiresAt = session.RefreshExpiresAt.UTC()
+	query := `
+    INSERT INTO refresh_tokens (session_id, token_hash, expires_at, created_at)
+    VALUES ($1, $2, $3, $4)
+  `
+	if _, err := tx.ExecContext(ctx, query, session.ID, hash, session.RefreshExpiresAt, now()); err != nil {
+		return err
+	}
+
+	session.RefreshToken = token
+	return nil
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// NewSQLiteStorage returns a Storage backed by a SQLite database opened with
+// the "sqlite" driver and migrated with the sqlite migrations.
+func NewSQLiteStorage(db *sql.DB) Storage {
+	return Storage{
+		Todos:        &SQLiteTodosStore{db},
+		Projects:     &SQLiteProjectsStore{db},
+		Members:      &MembersStore{db},
+		Sessions:     &SessionsStore{db},
+		AccessTokens: &AccessTokensStore{db},
+		Reminders:    &SQLiteRemindersStore{db},
+		Users:        &SQLiteUserStore{db},
+		UserTokens:   &UserTokensStore{db},
+		TOTP:         &TOTPStore{db},
+		Identities:   &IdentitiesStore{db},
+		Audit:        &AuditStore{db},
+		DataRequests: &SQLiteDataRequestsStore{DataRequestsStore{db}},
+		TodoHistory:  &TodoHistoryStore{db},
+	}
+}
+
+// sqliteStrings stores a string slice as a JSON array, standing in for the
+// TEXT[] columns used on Postgres. A nil slice is stored as NULL.
+type sqliteStrings []string
+
+func (a sqliteStrings) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal([]string(a))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (a *sqliteStrings) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return fmt.Errorf("cannot scan %T into string slice", src)
+	}
+
+	return json.Unmarshal(b, (*[]string)(a))
+}
+
+// utcTime converts t to UTC so it is stored in the same text format as every
+// other timestamp and compares correctly.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// isSQLiteUniqueViolation reports whether err is a UNIQUE constraint failure
+// on column, given as "table.column".
+func isSQLiteUniqueViolation(err error, column string) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed: "+column)
+}
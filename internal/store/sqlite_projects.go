@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+type SQLiteProjectsStore struct {
+	db *sql.DB
+}
+
+func (s *SQLiteProjectsStore) Create(ctx context.Context, project *Project) error {
+	query := `
+    INSERT INTO projects (user_id, name, color, archived, position, created_at, updated_at)
+    VALUES ($1, $2, $3, $4, (SELECT coalesce(max(position), -1) + 1 FROM projects WHERE user_id = $1), $5, $5)
+    RETURNING id, position, created_at, updated_at
+  `
+	project.Role = RoleOwner
+	return s.db.QueryRowContext(ctx, query, project.UserID, project.Name, project.Color, project.Archived, now()).Scan(
+		&project.ID,
+		&project.Position,
+		&project.CreatedAt,
+		&project.UpdatedAt,
+	)
+}
+
+func (s *SQLiteProjectsStore) GetByUser(ctx context.Context, userID int64, archived bool) ([]Project, error) {
+	query := visibleProjectsSQL + `
+      AND ($2 OR NOT p.archived)
+    ORDER BY p.position ASC, p.id ASC
+  `
+	return queryProjects(ctx, s.db, query, userID, archived)
+}
+
+func (s *SQLiteProjectsStore) GetByID(ctx context.Context, userID, projectID int64) (*Project, error) {
+	query := visibleProjectsSQL + `
+      AND p.id = $2
+  `
+	var project Project
+	err := s.db.QueryRowContext(ctx, query, userID, projectID).Scan(projectScanDest(&project)...)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+func (s *SQLiteProjectsStore) Update(ctx context.Context, userID, projectID int64, updates map[string]interface{}) error {
+	query, args, err := updateProjectSQL(updates, now())
+	if err != nil {
+		return err
+	}
+	return execAffectingOne(ctx, s.db, query, append(args, projectID, userID)...)
+}
+
+func (s *SQLiteProjectsStore) Delete(ctx context.Context, userID, projectID int64) error {
+	query := `DELETE FROM projects WHERE id = $1 AND user_id = $2`
+	return execAffectingOne(ctx, s.db, query, projectID, userID)
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type SQLiteRemindersStore struct {
+	db *sql.DB
+}
+
+func (s *SQLiteRemindersStore) Create(ctx context.Context, reminder *Reminder) error {
+	query := `
+    INSERT INTO reminders (todo_id, user_id, remind_at, created_at)
+    VALUES ($1, $2, $3, $4) RETURNING id, created_at
+  `
+	return s.db.QueryRowContext(ctx, query, reminder.TodoID, reminder.UserID, reminder.RemindAt.UTC(), now()).Scan(
+		&reminder.ID,
+		&reminder.CreatedAt,
+	)
+}
+
+func (s *SQLiteRemindersStore) GetByTodo(ctx context.Context, userID, todoID int64) ([]Reminder, error) {
+	query := `
+    SELECT ` + reminderColumns + `
+    FROM reminders
+    WHERE user_id = $1 AND todo_id = $2
+    ORDER BY remind_at ASC, id ASC
+  `
+	return queryReminders(ctx, s.db, query, userID, todoID)
+}
+
+func (s *SQLiteRemindersStore) Delete(ctx context.Context, userID, todoID, reminderID int64) error {
+	query := `DELETE FROM reminders WHERE id = $1 AND todo_id = $2 AND user_id = $3`
+	return execAffectingOne(ctx, s.db, query, reminderID, todoID, userID)
+}
+
+// ClaimDue relies on SQLite allowing a single writer at a time instead of
+// row locks.
+func (s *SQLiteRemindersStore) ClaimDue(ctx context.Context, now time.Time, limit int) ([]Reminder, error) {
+	query := `
+    UPDATE reminders SET fired_at = $1
+    WHERE id IN (
+      SELECT id FROM reminders
+      WHERE fired_at IS NULL AND remind_at <= $1
+        AND todo_id IN (SELECT id FROM todos WHERE deleted_at IS NULL)
+      ORDER BY remind_at ASC
+      LIMIT $2
+    )
+    RETURNING ` + reminderColumns
+	return queryReminders(ctx, s.db, query, now.UTC(), limit)
+}
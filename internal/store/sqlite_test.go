@@ -0,0 +1,41 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"open-todo-go/internal/db"
+	"open-todo-go/internal/migrate"
+	"open-todo-go/internal/store"
+	"open-todo-go/internal/store/storetest"
+)
+
+func TestSQLiteStorage(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Storage {
+		return store.NewSQLiteStorage(newSQLiteDB(t))
+	})
+}
+
+// newSQLiteDB returns a migrated database in a file that is removed when the
+// test ends. A file rather than :memory: so every pooled connection sees the
+// same database.
+func newSQLiteDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	d, err := db.New("sqlite", filepath.Join(t.TempDir(), "todos.db"), 4, 4, "1m")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	m, err := migrate.New(d, migrate.SQLite)
+	if err != nil {
+		t.Fatalf("migrate.New: %v", err)
+	}
+	if _, err := m.Up(context.Background(), 0); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	return d
+}
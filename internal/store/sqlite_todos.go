@@ -0,0 +1,345 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type SQLiteTodosStore struct {
+	db *sql.DB
+}
+
+const sqliteTodoColumns = `id, user_id, title, coalesce(description, ''), completed, priority, tags, start_at, due_at, timezone, recurrence, project_id, parent_id, complete_with_children, created_at, updated_at, deleted_at`
+
+func sqliteTodoScanDest(todo *Todo) []any {
+	return []any{
+		&todo.ID,
+		&todo.UserID,
+		&todo.Title,
+		&todo.Description,
+		&todo.Completed,
+		&todo.Priority,
+		(*sqliteStrings)(&todo.Tags),
+		&todo.StartAt,
+		&todo.DueAt,
+		&todo.Timezone,
+		&todo.Recurrence,
+		&todo.ProjectID,
+		&todo.ParentID,
+		&todo.CompleteWithChildren,
+		&todo.CreatedAt,
+		&todo.UpdatedAt,
+		&todo.DeletedAt,
+	}
+}
+
+func (s *SQLiteTodosStore) Create(ctx context.Context, todo *Todo) error {
+	if todo.Timezone == "" {
+		todo.Timezone = "UTC"
+	}
+
+	if err := checkTodoParent(ctx, s.db, todo.UserID, todo.ParentID); err != nil {
+		return err
+	}
+
+	query := `
+	 INSERT INTO todos (user_id, title, description, completed, priority, tags, start_at, due_at, timezone, recurrence, project_id, parent_id, complete_with_children, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $14) RETURNING id, created_at, updated_at
+	`
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		todo.UserID,
+		todo.Title,
+		todo.Description,
+		todo.Completed,
+		todo.Priority,
+		sqliteStrings(todo.Tags),
+		utcTime(todo.StartAt),
+		utcTime(todo.DueAt),
+		todo.Timezone,
+		todo.Recurrence,
+		todo.ProjectID,
+		todo.ParentID,
+		todo.CompleteWithChildren,
+		now(),
+	).Scan(
+		&todo.ID,
+		&todo.CreatedAt,
+		&todo.UpdatedAt,
+	)
+}
+
+func (s *SQLiteTodosStore) GetAllTodos(ctx context.Context, userID int64) ([]Todo, error) {
+	query := `
+      SELECT ` + sqliteTodoColumns + `
+      FROM todos
+      WHERE user_id = $1 AND deleted_at IS NULL
+      ORDER BY created_at DESC, id DESC
+      `
+	return s.queryTodos(ctx, query, userID)
+}
+
+func (s *SQLiteTodosStore) ListTodos(ctx context.Context, userID int64, f TodoFilter) (*TodoPage, error) {
+	cursor, cursorValue, err := decodeTodoCursor(f)
+	if err != nil {
+		return nil, err
+	}
+
+	conds, args := todoFilterSQL(f, func(p string) (string, any) {
+		cond := `NOT EXISTS (
+			SELECT 1 FROM json_each(` + p + `) AS want
+			WHERE want.value NOT IN (SELECT value FROM json_each(todos.tags))
+		)`
+		return cond, sqliteStrings(f.Tags)
+	}, "LIKE")
+	args = append([]any{userID}, args...)
+
+	page := &TodoPage{}
+	countQuery := "SELECT COUNT(*) FROM todos WHERE " + strings.Join(conds, " AND ")
+	if err := s.db.QueryRowContext(ctx, countQuery, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	if cursor != nil {
+		cond, cursorArgs := todoCursorSQL(f, "BINARY", cursorValue, cursor.ID, len(args))
+		conds = append(conds, cond)
+		args = append(args, cursorArgs...)
+	}
+
+	query := fmt.Sprintf(`
+      SELECT `+sqliteTodoColumns+`
+      FROM todos
+      WHERE %s
+      ORDER BY %s
+      LIMIT %d
+      `, strings.Join(conds, " AND "), todoOrderSQL(f, "BINARY"), f.Limit+1)
+
+	page.Todos, err = s.queryTodos(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(page.Todos) > f.Limit {
+		page.Todos = page.Todos[:f.Limit]
+		page.NextCursor = encodeTodoCursor(f, page.Todos[f.Limit-1])
+	}
+
+	return page, nil
+}
+
+// SearchTodos narrows the candidates with LIKE and ranks them in Go, since
+// SQLite has no equivalent of the Postgres search vector.
+func (s *SQLiteTodosStore) SearchTodos(ctx context.Context, userID int64, q string, limit int) ([]TodoSearchResult, error) {
+	terms := searchTerms(q)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	conds := []string{"user_id = $1", "deleted_at IS NULL"}
+	args := []any{userID}
+	for _, term := range terms {
+		args = append(args, "%"+escapeLike(term)+"%")
+		conds = append(conds, fmt.Sprintf(`(title LIKE $%[1]d ESCAPE '\' OR description LIKE $%[1]d ESCAPE '\')`, len(args)))
+	}
+
+	query := `
+    SELECT ` + sqliteTodoColumns + `
+    FROM todos
+    WHERE ` + strings.Join(conds, " AND ")
+
+	todos, err := s.queryTodos(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return rankTodos(todos, q, limit), nil
+}
+
+func (s *SQLiteTodosStore) GetTodoByID(ctx context.Context, userID, todoID int64) (*Todo, error) {
+	query := `
+    SELECT ` + sqliteTodoColumns + `
+    FROM todos
+    WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+    `
+	var todo Todo
+	err := s.db.QueryRowContext(ctx, query, todoID, userID).Scan(sqliteTodoScanDest(&todo)...)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+func (s *SQLiteTodosStore) GetProjectTodo(ctx context.Context, memberID, todoID int64) (*Todo, error) {
+	query := `
+    SELECT ` + sqliteTodoColumns + `
+    FROM todos
+    WHERE id = $1 AND deleted_at IS NULL AND project_id IN (SELECT project_id FROM project_members WHERE user_id = $2)
+    `
+	var todo Todo
+	err := s.db.QueryRowContext(ctx, query, todoID, memberID).Scan(sqliteTodoScanDest(&todo)...)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+func (s *SQLiteTodosStore) UpdateTodo(ctx context.Context, userID, todoID int64, updates map[string]interface{}) error {
+	if len(updates) == 0 {
+		return fmt.Errorf("no fields to update")
+	}
+
+	var queryFields []string
+	var args []interface{}
+	argCounter := 1
+
+	for field, value := range updates {
+		queryFields = append(queryFields, fmt.Sprintf(`"%s" = $%d`, field, argCounter))
+		switch v := value.(type) {
+		case []string:
+			value = sqliteStrings(v)
+		case time.Time:
+			value = v.UTC()
+		}
+		args = append(args, value)
+		argCounter++
+	}
+	queryFields = append(queryFields, fmt.Sprintf("updated_at = $%d", argCounter))
+	args = append(args, now())
+	argCounter++
+
+	query := fmt.Sprintf("UPDATE todos SET %s WHERE id = $%d AND user_id = $%d AND deleted_at IS NULL", strings.Join(queryFields, ", "), argCounter, argCounter+1)
+	args = append(args, todoID, userID)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("error updating todo: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	// Subtasks are in their parent's project, so they follow the todo to
+	// its new one.
+	if _, ok := updates["project_id"]; ok {
+		if err := setSubtreeProject(ctx, tx, todoID, todoID, now()); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteTodosStore) GetTodosByTag(ctx context.Context, userID int64, tag string) ([]Todo, error) {
+	query := `
+    SELECT ` + sqliteTodoColumns + `
+    FROM todos
+    WHERE user_id = $1 AND deleted_at IS NULL AND EXISTS (SELECT 1 FROM json_each(todos.tags) WHERE json_each.value = $2)
+    ORDER BY created_at DESC, id DESC
+    `
+	return s.queryTodos(ctx, query, userID, tag)
+}
+
+func (s *SQLiteTodosStore) GetTodosDue(ctx context.Context, userID int64, from, to *time.Time) ([]Todo, error) {
+	query := `
+    SELECT ` + sqliteTodoColumns + `
+    FROM todos
+    WHERE user_id = $1 AND deleted_at IS NULL AND NOT completed AND due_at IS NOT NULL
+      AND ($2 IS NULL OR due_at >= $2)
+      AND ($3 IS NULL OR due_at < $3)
+    ORDER BY due_at ASC, id ASC
+    `
+	return s.queryTodos(ctx, query, userID, utcTime(from), utcTime(to))
+}
+
+func (s *SQLiteTodosStore) GetSubtree(ctx context.Context, userID, todoID int64) ([]Todo, error) {
+	todos, err := s.queryTodos(ctx, subtreeSQL(sqliteTodoColumns), todoID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(todos) == 0 {
+		return nil, ErrNotFound
+	}
+	return todos, nil
+}
+
+func (s *SQLiteTodosStore) CountOpenSubtasks(ctx context.Context, userID, parentID int64) (int, error) {
+	return countOpenSubtasks(ctx, s.db, userID, parentID)
+}
+
+// MoveTodo needs no extra locking: transactions begin IMMEDIATE, so moves
+// are serialized by SQLite's write lock.
+func (s *SQLiteTodosStore) MoveTodo(ctx context.Context, userID, todoID int64, parentID *int64) error {
+	return moveTodo(ctx, s.db, nil, userID, todoID, parentID, now())
+}
+
+// DeleteTodo moves the todo and its subtasks to the trash.
+func (s *SQLiteTodosStore) DeleteTodo(ctx context.Context, userID, todoID int64) error {
+	return trashTodo(ctx, s.db, userID, todoID, now())
+}
+
+func (s *SQLiteTodosStore) GetTrash(ctx context.Context, userID int64) ([]Todo, error) {
+	query := `
+    SELECT ` + sqliteTodoColumns + `
+    FROM todos
+    WHERE user_id = $1 AND deleted_at IS NOT NULL
+    ORDER BY deleted_at DESC, id DESC
+    `
+	return s.queryTodos(ctx, query, userID)
+}
+
+func (s *SQLiteTodosStore) RestoreTodo(ctx context.Context, userID, todoID int64) error {
+	return restoreTodo(ctx, s.db, userID, todoID)
+}
+
+func (s *SQLiteTodosStore) PurgeTodo(ctx context.Context, userID, todoID int64) error {
+	return purgeTodo(ctx, s.db, userID, todoID)
+}
+
+func (s *SQLiteTodosStore) EmptyTrash(ctx context.Context, userID int64) (int, error) {
+	return emptyTrash(ctx, s.db, userID)
+}
+
+func (s *SQLiteTodosStore) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	return purgeDeleted(ctx, s.db, before)
+}
+
+func (s *SQLiteTodosStore) queryTodos(ctx context.Context, query string, args ...any) ([]Todo, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var todos []Todo
+	for rows.Next() {
+		var todo Todo
+		if err := rows.Scan(sqliteTodoScanDest(&todo)...); err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+
+	return todos, rows.Err()
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type SQLiteUserStore struct {
+	db *sql.DB
+}
+
+func (s *SQLiteUserStore) Create(ctx context.Context, user *User) error {
+	user.setDefaults()
+	query := `
+    INSERT INTO users (username, password, email, timezone, locale, role, created_at) VALUES($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at
+  `
+	err := s.db.QueryRowContext(ctx, query, user.Username, user.Password.Hash, user.Email, user.Timezone, user.Locale, user.Role, now()).Scan(&user.ID, &user.CreatedAt)
+	return sqliteDuplicateUserError(err)
+}
+
+func sqliteDuplicateUserError(err error) error {
+	switch {
+	case isSQLiteUniqueViolation(err, "users.email"):
+		return ErrDuplicateEmail
+	case isSQLiteUniqueViolation(err, "users.username"):
+		return ErrDuplicateUsername
+	default:
+		return err
+	}
+}
+
+func (s *SQLiteUserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE email = $1
+	`
+	return s.getUser(ctx, query, email)
+}
+
+func (s *SQLiteUserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE id = $1
+	`
+	return s.getUser(ctx, query, userID)
+}
+
+func (s *SQLiteUserStore) getUser(ctx context.Context, query string, args ...any) (*User, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	user := &User{}
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(userScanDest(user)...)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+func (s *SQLiteUserStore) SetEmailVerified(ctx context.Context, userID int64) error {
+	query := `UPDATE users SET email_verified_at = coalesce(email_verified_at, $1) WHERE id = $2`
+	return execAffectingOne(ctx, s.db, query, now(), userID)
+}
+
+func (s *SQLiteUserStore) UpdatePassword(ctx context.Context, user *User) error {
+	query := `UPDATE users SET password = $1 WHERE id = $2`
+	return execAffectingOne(ctx, s.db, query, user.Password.Hash, user.ID)
+}
+
+func (s *SQLiteUserStore) RecordFailedLogin(ctx context.Context, userID int64) (int, error) {
+	return recordFailedLogin(ctx, s.db, userID)
+}
+
+func (s *SQLiteUserStore) LockLogin(ctx context.Context, userID int64, until time.Time) error {
+	query := `UPDATE users SET locked_until = $1 WHERE id = $2`
+	return execAffectingOne(ctx, s.db, query, until.UTC(), userID)
+}
+
+func (s *SQLiteUserStore) ResetFailedLogins(ctx context.Context, userID int64) error {
+	query := `UPDATE users SET failed_logins = 0, locked_until = NULL WHERE id = $1`
+	return execAffectingOne(ctx, s.db, query, userID)
+}
+
+func (s *SQLiteUserStore) UpdateProfile(ctx context.Context, user *User) error {
+	return sqliteDuplicateUserError(updateProfile(ctx, s.db, user))
+}
+
+func (s *SQLiteUserStore) Delete(ctx context.Context, userID int64) error {
+	return execAffectingOne(ctx, s.db, `DELETE FROM users WHERE id = $1`, userID)
+}
+
+func (s *SQLiteUserStore) Anonymize(ctx context.Context, userID int64) error {
+	return anonymizeUser(ctx, s.db, userID)
+}
+
+func (s *SQLiteUserStore) Erase(ctx context.Context, userID int64) error {
+	return eraseUser(ctx, s.db, userID)
+}
+
+func (s *SQLiteUserStore) List(ctx context.Context, f UserFilter) (*UserPage, error) {
+	return listUsers(ctx, s.db, f)
+}
+
+func (s *SQLiteUserStore) Usage(ctx context.Context, userID int64) (*UserUsage, error) {
+	return userUsage(ctx, s.db, userID)
+}
+
+func (s *SQLiteUserStore) SetRole(ctx context.Context, userID int64, role UserRole) error {
+	return setUserRole(ctx, s.db, userID, role)
+}
+
+func (s *SQLiteUserStore) SetSuspended(ctx context.Context, userID int64, suspended bool) error {
+	return setUserSuspended(ctx, s.db, userID, suspended)
+}
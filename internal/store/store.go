@@ -17,21 +17,135 @@ type Storage struct {
 	Todos interface {
 		Create(context.Context, *Todo) error
 		GetAllTodos(context.Context, int64) ([]Todo, error)
-		GetTodoByID(context.Context, int64) (*Todo, error)
-		UpdateTodo(context.Context, int64, map[string]interface{}) error
+		ListTodos(context.Context, int64, TodoFilter) (*TodoPage, error)
+		SearchTodos(context.Context, int64, string, int) ([]TodoSearchResult, error)
+		GetTodoByID(context.Context, int64, int64) (*Todo, error)
+		GetProjectTodo(context.Context, int64, int64) (*Todo, error)
+		UpdateTodo(context.Context, int64, int64, map[string]interface{}) error
 		GetTodosByTag(context.Context, int64, string) ([]Todo, error)
-		DeleteTodo(context.Context, int64) error
+		GetTodosDue(context.Context, int64, *time.Time, *time.Time) ([]Todo, error)
+		GetSubtree(context.Context, int64, int64) ([]Todo, error)
+		CountOpenSubtasks(context.Context, int64, int64) (int, error)
+		MoveTodo(context.Context, int64, int64, *int64) error
+		DeleteTodo(context.Context, int64, int64) error
+		GetTrash(context.Context, int64) ([]Todo, error)
+		RestoreTodo(context.Context, int64, int64) error
+		PurgeTodo(context.Context, int64, int64) error
+		EmptyTrash(context.Context, int64) (int, error)
+		PurgeDeleted(context.Context, time.Time) (int, error)
+	}
+	Projects interface {
+		Create(context.Context, *Project) error
+		GetByUser(context.Context, int64, bool) ([]Project, error)
+		GetByID(context.Context, int64, int64) (*Project, error)
+		Update(context.Context, int64, int64, map[string]interface{}) error
+		Delete(context.Context, int64, int64) error
+	}
+	Members interface {
+		GetRole(context.Context, int64, int64) (Role, error)
+		GetMembers(context.Context, int64) ([]ProjectMember, error)
+		UpdateMember(context.Context, int64, int64, Role) error
+		RemoveMember(context.Context, int64, int64) error
+		CreateInvitation(context.Context, *Invitation) error
+		GetInvitations(context.Context, int64) ([]Invitation, error)
+		DeleteInvitation(context.Context, int64, int64) error
+		AcceptInvitation(context.Context, string, *User) (*ProjectMember, error)
+	}
+	Sessions interface {
+		Create(context.Context, *Session) error
+		Get(context.Context, int64) (*Session, error)
+		Refresh(context.Context, string, time.Time) (*Session, error)
+		Revoke(context.Context, int64, int64) error
+		RevokeAll(context.Context, int64) error
+	}
+	AccessTokens interface {
+		Create(context.Context, *AccessToken) error
+		GetByUser(context.Context, int64) ([]AccessToken, error)
+		Authenticate(context.Context, string) (*AccessToken, error)
+		Delete(context.Context, int64, int64) error
+		DeleteAll(context.Context, int64) error
+	}
+	Reminders interface {
+		Create(context.Context, *Reminder) error
+		GetByTodo(context.Context, int64, int64) ([]Reminder, error)
+		Delete(context.Context, int64, int64, int64) error
+		ClaimDue(context.Context, time.Time, int) ([]Reminder, error)
 	}
 	Users interface {
 		Create(context.Context, *User) error
 		GetByID(context.Context, int64) (*User, error)
 		GetByEmail(context.Context, string) (*User, error)
+		SetEmailVerified(context.Context, int64) error
+		UpdatePassword(context.Context, *User) error
+		RecordFailedLogin(context.Context, int64) (int, error)
+		LockLogin(context.Context, int64, time.Time) error
+		ResetFailedLogins(context.Context, int64) error
+		UpdateProfile(context.Context, *User) error
+		Delete(context.Context, int64) error
+		Anonymize(context.Context, int64) error
+		Erase(context.Context, int64) error
+		List(context.Context, UserFilter) (*UserPage, error)
+		Usage(context.Context, int64) (*UserUsage, error)
+		SetRole(context.Context, int64, UserRole) error
+		SetSuspended(context.Context, int64, bool) error
+	}
+	UserTokens interface {
+		Create(context.Context, *UserToken) error
+		Consume(context.Context, TokenPurpose, string) (int64, error)
+		DeleteAll(context.Context, int64) error
+	}
+	TOTP interface {
+		Get(context.Context, int64) (*TOTP, error)
+		Enroll(context.Context, int64, []byte) error
+		Confirm(context.Context, int64, int64) ([]string, error)
+		UseStep(context.Context, int64, int64) error
+		Delete(context.Context, int64) error
+		RegenerateRecoveryCodes(context.Context, int64) ([]string, error)
+		UseRecoveryCode(context.Context, int64, string) error
+		CountRecoveryCodes(context.Context, int64) (int, error)
+	}
+	Identities interface {
+		Create(context.Context, *Identity) error
+		GetBySubject(context.Context, string, string) (*Identity, error)
+		GetByUser(context.Context, int64) ([]Identity, error)
+		Delete(context.Context, int64, int64) error
+		CreateFlow(context.Context, *OIDCFlow) error
+		ConsumeFlow(context.Context, string, string) (*OIDCFlow, error)
+	}
+	Audit interface {
+		Create(context.Context, *AuditEntry) error
+		List(context.Context, AuditFilter) ([]AuditEntry, error)
+	}
+	DataRequests interface {
+		Create(context.Context, *DataRequest) error
+		GetByUser(context.Context, int64) ([]DataRequest, error)
+		ClaimPending(context.Context, time.Time, int) ([]DataRequest, error)
+		Complete(context.Context, int64, []byte, time.Time) error
+		Fail(context.Context, int64, string) error
+		GetArchive(context.Context, int64, time.Time) ([]byte, error)
+		DeleteExpired(context.Context, time.Time) (int, error)
+	}
+	TodoHistory interface {
+		Create(context.Context, *TodoVersion) error
+		List(context.Context, TodoHistoryFilter) ([]TodoVersion, error)
+		Get(context.Context, int64, int) (*TodoVersion, error)
 	}
 }
 
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
-		Todos: &TodosStore{db},
-		Users: &UserStore{db},
+		Todos:        &TodosStore{db},
+		Projects:     &ProjectsStore{db},
+		Members:      &MembersStore{db},
+		Sessions:     &SessionsStore{db},
+		AccessTokens: &AccessTokensStore{db},
+		Reminders:    &RemindersStore{db},
+		Users:        &UserStore{db},
+		UserTokens:   &UserTokensStore{db},
+		TOTP:         &TOTPStore{db},
+		Identities:   &IdentitiesStore{db},
+		Audit:        &AuditStore{db},
+		DataRequests: &DataRequestsStore{db},
+		TodoHistory:  &TodoHistoryStore{db},
 	}
 }
@@ -0,0 +1,2686 @@
+// Package storetest is a conformance suite for store.Storage implementations.
+// Every backend should pass Run so handlers behave the same whichever one is
+// configured.
+package storetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"open-todo-go/internal/password"
+	"open-todo-go/internal/store"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Factory returns a new, empty Storage. It is called once per subtest.
+type Factory func(t *testing.T) store.Storage
+
+func Run(t *testing.T, newStorage Factory) {
+	t.Run("Users", func(t *testing.T) {
+		testUsers(t, newStorage)
+	})
+	t.Run("Todos", func(t *testing.T) {
+		testTodos(t, newStorage)
+	})
+	t.Run("Projects", func(t *testing.T) {
+		testProjects(t, newStorage)
+	})
+	t.Run("Members", func(t *testing.T) {
+		testMembers(t, newStorage)
+	})
+	t.Run("Sessions", func(t *testing.T) {
+		testSessions(t, newStorage)
+	})
+	t.Run("AccessTokens", func(t *testing.T) {
+		testAccessTokens(t, newStorage)
+	})
+	t.Run("UserTokens", func(t *testing.T) {
+		testUserTokens(t, newStorage)
+	})
+	t.Run("TOTP", func(t *testing.T) {
+		testTOTP(t, newStorage)
+	})
+	t.Run("Identities", func(t *testing.T) {
+		testIdentities(t, newStorage)
+	})
+	t.Run("Audit", func(t *testing.T) {
+		testAudit(t, newStorage)
+	})
+	t.Run("DataRequests", func(t *testing.T) {
+		testDataRequests(t, newStorage)
+	})
+	t.Run("TodoHistory", func(t *testing.T) {
+		testTodoHistory(t, newStorage)
+	})
+	t.Run("Reminders", func(t *testing.T) {
+		testReminders(t, newStorage)
+	})
+}
+
+func testUsers(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		s := newStorage(t)
+		user := createUser(t, s, "alice")
+
+		if user.ID == 0 {
+			t.Fatal("Create did not set ID")
+		}
+		if user.CreatedAt == "" {
+			t.Fatal("Create did not set CreatedAt")
+		}
+
+		byID, err := s.Users.GetByID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if byID.Username != "alice" || byID.Email != "alice@example.com" {
+			t.Fatalf("GetByID = %+v", byID)
+		}
+		if string(byID.Password.Hash) != string(user.Password.Hash) {
+			t.Fatal("GetByID did not return the password hash")
+		}
+
+		byEmail, err := s.Users.GetByEmail(ctx, "alice@example.com")
+		if err != nil {
+			t.Fatalf("GetByEmail: %v", err)
+		}
+		if byEmail.ID != user.ID {
+			t.Fatalf("GetByEmail ID = %d, want %d", byEmail.ID, user.ID)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		s := newStorage(t)
+
+		if _, err := s.Users.GetByID(ctx, 4242); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("GetByID err = %v, want ErrNotFound", err)
+		}
+		if _, err := s.Users.GetByEmail(ctx, "nobody@example.com"); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("GetByEmail err = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("VerifyEmailAndPassword", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		if alice.EmailVerifiedAt != nil {
+			t.Fatal("new user has a verified email")
+		}
+
+		if err := s.Users.SetEmailVerified(ctx, alice.ID); err != nil {
+			t.Fatalf("SetEmailVerified: %v", err)
+		}
+		got, err := s.Users.GetByID(ctx, alice.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if got.EmailVerifiedAt == nil {
+			t.Fatal("SetEmailVerified did not set EmailVerifiedAt")
+		}
+		if err := s.Users.SetEmailVerified(ctx, 4242); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("SetEmailVerified unknown user err = %v, want ErrNotFound", err)
+		}
+
+		got.Password.Hash = hashPassword(t, "n3w-password")
+		if err := s.Users.UpdatePassword(ctx, got); err != nil {
+			t.Fatalf("UpdatePassword: %v", err)
+		}
+		byEmail, err := s.Users.GetByEmail(ctx, alice.Email)
+		if err != nil {
+			t.Fatalf("GetByEmail: %v", err)
+		}
+		if string(byEmail.Password.Hash) != string(got.Password.Hash) || byEmail.EmailVerifiedAt == nil {
+			t.Fatalf("GetByEmail = %+v, want the new password and a verified email", byEmail)
+		}
+	})
+
+	t.Run("FailedLogins", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+
+		for want := 1; want <= 3; want++ {
+			n, err := s.Users.RecordFailedLogin(ctx, alice.ID)
+			if err != nil {
+				t.Fatalf("RecordFailedLogin: %v", err)
+			}
+			if n != want {
+				t.Fatalf("RecordFailedLogin = %d, want %d", n, want)
+			}
+		}
+
+		until := time.Now().Add(time.Minute)
+		if err := s.Users.LockLogin(ctx, alice.ID, until); err != nil {
+			t.Fatalf("LockLogin: %v", err)
+		}
+		got, err := s.Users.GetByEmail(ctx, alice.Email)
+		if err != nil {
+			t.Fatalf("GetByEmail: %v", err)
+		}
+		if got.FailedLogins != 3 || !got.Locked(time.Now()) || got.Locked(until.Add(time.Second)) {
+			t.Fatalf("GetByEmail = %+v, want 3 failed logins and locked until %v", got, until)
+		}
+
+		if err := s.Users.ResetFailedLogins(ctx, alice.ID); err != nil {
+			t.Fatalf("ResetFailedLogins: %v", err)
+		}
+		got, err = s.Users.GetByID(ctx, alice.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if got.FailedLogins != 0 || got.LockedUntil != nil {
+			t.Fatalf("GetByID after reset = %+v", got)
+		}
+		if _, err := s.Users.RecordFailedLogin(ctx, 4242); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("RecordFailedLogin unknown user err = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("UpdateProfile", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		createUser(t, s, "bob")
+		if alice.Timezone != store.DefaultTimezone || alice.Locale != store.DefaultLocale {
+			t.Fatalf("new user preferences = %q, %q", alice.Timezone, alice.Locale)
+		}
+		if err := s.Users.SetEmailVerified(ctx, alice.ID); err != nil {
+			t.Fatalf("SetEmailVerified: %v", err)
+		}
+
+		alice.Username = "alice2"
+		alice.Email = "alice2@example.com"
+		alice.EmailVerifiedAt = nil
+		alice.Timezone = "Europe/Berlin"
+		alice.Locale = "de-DE"
+		if err := s.Users.UpdateProfile(ctx, alice); err != nil {
+			t.Fatalf("UpdateProfile: %v", err)
+		}
+		got, err := s.Users.GetByEmail(ctx, "alice2@example.com")
+		if err != nil {
+			t.Fatalf("GetByEmail: %v", err)
+		}
+		if got.Username != "alice2" || got.EmailVerifiedAt != nil || got.Timezone != "Europe/Berlin" || got.Locale != "de-DE" {
+			t.Fatalf("GetByEmail = %+v", got)
+		}
+
+		alice.Username = "bob"
+		if err := s.Users.UpdateProfile(ctx, alice); !errors.Is(err, store.ErrDuplicateUsername) {
+			t.Fatalf("UpdateProfile taken username err = %v, want ErrDuplicateUsername", err)
+		}
+		alice.Username = "alice2"
+		alice.Email = "bob@example.com"
+		if err := s.Users.UpdateProfile(ctx, alice); !errors.Is(err, store.ErrDuplicateEmail) {
+			t.Fatalf("UpdateProfile taken email err = %v, want ErrDuplicateEmail", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+
+		carol := createUser(t, s, "carol")
+
+		work := createProject(t, s, alice.ID, "Work")
+		for _, u := range []*store.User{bob, carol} {
+			inv := createInvitation(t, s, work.ID, alice.ID, u.Email, store.RoleEditor, time.Hour)
+			if _, err := s.Members.AcceptInvitation(ctx, inv.Token, u); err != nil {
+				t.Fatalf("AcceptInvitation: %v", err)
+			}
+		}
+		shared := &store.Todo{UserID: bob.ID, Title: "shared", ProjectID: &work.ID}
+		if err := s.Todos.Create(ctx, shared); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		bobs := createProject(t, s, bob.ID, "Bob's")
+		token := createAccessToken(t, s, bob.ID, "cli", store.ScopeWrite, nil, nil)
+
+		if err := s.Users.Delete(ctx, bob.ID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := s.Users.GetByID(ctx, bob.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("GetByID deleted user err = %v, want ErrNotFound", err)
+		}
+		if _, err := s.Todos.GetProjectTodo(ctx, carol.ID, shared.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("GetProjectTodo of deleted user err = %v, want ErrNotFound", err)
+		}
+		if _, err := s.Projects.GetByID(ctx, bob.ID, bobs.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("GetByID project of deleted user err = %v, want ErrNotFound", err)
+		}
+		if _, err := s.AccessTokens.Authenticate(ctx, token.Token); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Authenticate token of deleted user err = %v, want ErrNotFound", err)
+		}
+		members, err := s.Members.GetMembers(ctx, work.ID)
+		if err != nil {
+			t.Fatalf("GetMembers: %v", err)
+		}
+		for _, m := range members {
+			if m.UserID == bob.ID {
+				t.Fatalf("deleted user is still a member: %+v", members)
+			}
+		}
+		if err := s.Users.Delete(ctx, bob.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Delete again err = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Anonymize", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+
+		carol := createUser(t, s, "carol")
+
+		work := createProject(t, s, alice.ID, "Work")
+		for _, u := range []*store.User{bob, carol} {
+			inv := createInvitation(t, s, work.ID, alice.ID, u.Email, store.RoleEditor, time.Hour)
+			if _, err := s.Members.AcceptInvitation(ctx, inv.Token, u); err != nil {
+				t.Fatalf("AcceptInvitation: %v", err)
+			}
+		}
+		shared := &store.Todo{UserID: bob.ID, Title: "shared", ProjectID: &work.ID}
+		if err := s.Todos.Create(ctx, shared); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		token := createAccessToken(t, s, bob.ID, "cli", store.ScopeWrite, nil, nil)
+		session := createSession(t, s, bob.ID, time.Hour)
+
+		if err := s.Users.Anonymize(ctx, bob.ID); err != nil {
+			t.Fatalf("Anonymize: %v", err)
+		}
+		if _, err := s.Sessions.Refresh(ctx, session.RefreshToken, time.Now().Add(time.Hour)); err == nil {
+			t.Fatal("session of an anonymized user can still be refreshed")
+		}
+		got, err := s.Users.GetByID(ctx, bob.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if got.DeletedAt == nil || got.Username == "bob" || got.Email == bob.Email || len(got.Password.Hash) != 0 {
+			t.Fatalf("anonymized user = %+v", got)
+		}
+		if _, err := s.Users.GetByEmail(ctx, bob.Email); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("GetByEmail old address err = %v, want ErrNotFound", err)
+		}
+		if todo, err := s.Todos.GetProjectTodo(ctx, carol.ID, shared.ID); err != nil || todo.Title != "shared" {
+			t.Fatalf("GetProjectTodo of anonymized user = %+v, %v", todo, err)
+		}
+		if _, err := s.AccessTokens.Authenticate(ctx, token.Token); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Authenticate token of anonymized user err = %v, want ErrNotFound", err)
+		}
+		if role, _ := s.Members.GetRole(ctx, work.ID, bob.ID); role != "" {
+			t.Fatalf("anonymized user role = %q, want none", role)
+		}
+
+		if err := s.Users.Anonymize(ctx, bob.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Anonymize again err = %v, want ErrNotFound", err)
+		}
+		if err := s.Users.UpdateProfile(ctx, bob); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("UpdateProfile anonymized user err = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Erase", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+
+		work := createProject(t, s, alice.ID, "Work")
+		inv := createInvitation(t, s, work.ID, alice.ID, bob.Email, store.RoleEditor, time.Hour)
+		if _, err := s.Members.AcceptInvitation(ctx, inv.Token, bob); err != nil {
+			t.Fatalf("AcceptInvitation: %v", err)
+		}
+		kept := &store.Todo{UserID: bob.ID, Title: "kept", ProjectID: &work.ID}
+		if err := s.Todos.Create(ctx, kept); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		todo := createTodo(t, s, alice.ID, "mine")
+		createReminder(t, s, alice.ID, todo.ID, time.Now().Add(-time.Minute))
+		session := createSession(t, s, alice.ID, time.Hour)
+		token := createAccessToken(t, s, alice.ID, "cli", store.ScopeWrite, nil, nil)
+		confirmTOTP(t, s, alice.ID, 1)
+		if err := s.Identities.Create(ctx, &store.Identity{UserID: alice.ID, Provider: "google", Subject: "a"}); err != nil {
+			t.Fatalf("Identities.Create: %v", err)
+		}
+		if err := s.DataRequests.Create(ctx, &store.DataRequest{UserID: alice.ID, Kind: store.DataErasure}); err != nil {
+			t.Fatalf("DataRequests.Create: %v", err)
+		}
+		for _, e := range []*store.AuditEntry{
+			{Action: "user.role", TargetUserID: &alice.ID},
+			{ActorID: &alice.ID, Action: "user.suspend", TargetUserID: &bob.ID},
+		} {
+			if err := s.Audit.Create(ctx, e); err != nil {
+				t.Fatalf("Audit.Create: %v", err)
+			}
+		}
+		for _, v := range []*store.TodoVersion{
+			{ActorID: &alice.ID, Action: store.TodoCreated, Todo: *todo},
+			{ActorID: &alice.ID, Action: store.TodoUpdated, Todo: *kept},
+		} {
+			if err := s.TodoHistory.Create(ctx, v); err != nil {
+				t.Fatalf("TodoHistory.Create: %v", err)
+			}
+		}
+
+		if err := s.Users.Erase(ctx, alice.ID); err != nil {
+			t.Fatalf("Erase: %v", err)
+		}
+
+		if _, err := s.Users.GetByID(ctx, alice.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("GetByID erased user err = %v, want ErrNotFound", err)
+		}
+		if got, err := s.Todos.GetTodoByID(ctx, bob.ID, kept.ID); err != nil || got.ProjectID != nil {
+			t.Fatalf("todo of member in erased user's project = %+v, %v; want it in the inbox", got, err)
+		}
+		if due, err := s.Reminders.ClaimDue(ctx, time.Now(), 10); err != nil || len(due) != 0 {
+			t.Fatalf("ClaimDue = %v, %v; want no reminders", due, err)
+		}
+		if _, err := s.Sessions.Get(ctx, session.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Sessions.Get err = %v, want ErrNotFound", err)
+		}
+		if _, err := s.AccessTokens.Authenticate(ctx, token.Token); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Authenticate err = %v, want ErrNotFound", err)
+		}
+		if _, err := s.TOTP.Get(ctx, alice.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("TOTP.Get err = %v, want ErrNotFound", err)
+		}
+		if _, err := s.Identities.GetBySubject(ctx, "google", "a"); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("GetBySubject err = %v, want ErrNotFound", err)
+		}
+		if reqs, err := s.DataRequests.GetByUser(ctx, alice.ID); err != nil || len(reqs) != 0 {
+			t.Fatalf("DataRequests.GetByUser = %v, %v; want none", reqs, err)
+		}
+		entries, err := s.Audit.List(ctx, store.AuditFilter{Limit: 10})
+		if err != nil {
+			t.Fatalf("Audit.List: %v", err)
+		}
+		if len(entries) != 1 || entries[0].ActorID != nil || *entries[0].TargetUserID != bob.ID {
+			t.Fatalf("audit log after erasure = %+v", entries)
+		}
+		versions, err := s.TodoHistory.List(ctx, store.TodoHistoryFilter{Limit: 10})
+		if err != nil {
+			t.Fatalf("TodoHistory.List: %v", err)
+		}
+		if len(versions) != 1 || versions[0].TodoID != kept.ID || versions[0].ActorID != nil {
+			t.Fatalf("todo history after erasure = %+v", versions)
+		}
+
+		if err := s.Users.Erase(ctx, alice.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Erase again err = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("RoleAndSuspension", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		if alice.Role != store.UserRoleUser {
+			t.Fatalf("new user role = %q, want user", alice.Role)
+		}
+
+		if err := s.Users.SetRole(ctx, alice.ID, store.UserRoleAdmin); err != nil {
+			t.Fatalf("SetRole: %v", err)
+		}
+		session := createSession(t, s, alice.ID, time.Hour)
+		if err := s.Users.SetSuspended(ctx, alice.ID, true); err != nil {
+			t.Fatalf("SetSuspended: %v", err)
+		}
+		if _, err := s.Sessions.Refresh(ctx, session.RefreshToken, time.Now().Add(time.Hour)); err == nil {
+			t.Fatal("session of a suspended user can still be refreshed")
+		}
+		got, err := s.Users.GetByEmail(ctx, alice.Email)
+		if err != nil {
+			t.Fatalf("GetByEmail: %v", err)
+		}
+		if got.Role != store.UserRoleAdmin || got.SuspendedAt == nil {
+			t.Fatalf("GetByEmail = %+v, want a suspended admin", got)
+		}
+
+		if err := s.Users.SetSuspended(ctx, alice.ID, false); err != nil {
+			t.Fatalf("SetSuspended: %v", err)
+		}
+		if got, _ = s.Users.GetByID(ctx, alice.ID); got.SuspendedAt != nil {
+			t.Fatal("SetSuspended(false) did not lift the suspension")
+		}
+		if err := s.Users.SetRole(ctx, 4242, store.UserRoleAdmin); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("SetRole unknown user err = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+		carol := createUser(t, s, "carol")
+		dave := createUser(t, s, "dave")
+		if err := s.Users.SetRole(ctx, bob.ID, store.UserRoleAdmin); err != nil {
+			t.Fatalf("SetRole: %v", err)
+		}
+		if err := s.Users.SetSuspended(ctx, carol.ID, true); err != nil {
+			t.Fatalf("SetSuspended: %v", err)
+		}
+		if err := s.Users.Anonymize(ctx, dave.ID); err != nil {
+			t.Fatalf("Anonymize: %v", err)
+		}
+
+		ids := func(f store.UserFilter) ([]int64, *store.UserPage) {
+			t.Helper()
+			page, err := s.Users.List(ctx, f)
+			if err != nil {
+				t.Fatalf("List(%+v): %v", f, err)
+			}
+			var ids []int64
+			for _, u := range page.Users {
+				ids = append(ids, u.ID)
+			}
+			return ids, page
+		}
+
+		f := store.DefaultUserFilter()
+		f.Limit = 2
+		got, page := ids(f)
+		if !reflect.DeepEqual(got, []int64{alice.ID, bob.ID}) || page.Total != 3 || page.NextCursor == "" {
+			t.Fatalf("first page = %v, total %d, cursor %q", got, page.Total, page.NextCursor)
+		}
+		f.Cursor = page.NextCursor
+		got, page = ids(f)
+		if !reflect.DeepEqual(got, []int64{carol.ID}) || page.NextCursor != "" {
+			t.Fatalf("second page = %v, cursor %q", got, page.NextCursor)
+		}
+
+		f = store.DefaultUserFilter()
+		f.Search = "ALI"
+		if got, _ := ids(f); !reflect.DeepEqual(got, []int64{alice.ID}) {
+			t.Fatalf("search = %v, want alice", got)
+		}
+		f = store.DefaultUserFilter()
+		f.Role = store.UserRoleAdmin
+		if got, _ := ids(f); !reflect.DeepEqual(got, []int64{bob.ID}) {
+			t.Fatalf("admins = %v, want bob", got)
+		}
+		suspended := true
+		f = store.DefaultUserFilter()
+		f.Suspended = &suspended
+		if got, _ := ids(f); !reflect.DeepEqual(got, []int64{carol.ID}) {
+			t.Fatalf("suspended = %v, want carol", got)
+		}
+
+		f = store.DefaultUserFilter()
+		f.Cursor = "nope"
+		if _, err := s.Users.List(ctx, f); !errors.Is(err, store.ErrInvalidCursor) {
+			t.Fatalf("List bad cursor err = %v, want ErrInvalidCursor", err)
+		}
+	})
+
+	t.Run("Usage", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+
+		done := createTodo(t, s, alice.ID, "done")
+		setTodo(t, s, alice.ID, done.ID, map[string]interface{}{"completed": true})
+		createTodo(t, s, alice.ID, "open")
+		work := createProject(t, s, bob.ID, "Work")
+		createProject(t, s, alice.ID, "Home")
+		inv := createInvitation(t, s, work.ID, bob.ID, alice.Email, store.RoleViewer, time.Hour)
+		if _, err := s.Members.AcceptInvitation(ctx, inv.Token, alice); err != nil {
+			t.Fatalf("AcceptInvitation: %v", err)
+		}
+		createAccessToken(t, s, alice.ID, "cli", store.ScopeRead, nil, nil)
+		createSession(t, s, alice.ID, time.Hour)
+		revoked := createSession(t, s, alice.ID, time.Hour)
+		if err := s.Sessions.Revoke(ctx, alice.ID, revoked.ID); err != nil {
+			t.Fatalf("Revoke: %v", err)
+		}
+
+		usage, err := s.Users.Usage(ctx, alice.ID)
+		if err != nil {
+			t.Fatalf("Usage: %v", err)
+		}
+		want := store.UserUsage{Todos: 2, CompletedTodos: 1, Projects: 1, SharedProjects: 1, AccessTokens: 1, ActiveSessions: 1}
+		lastLogin := usage.LastLoginAt
+		usage.LastLoginAt = nil
+		if *usage != want || lastLogin == nil || lastLogin.Sub(revoked.CreatedAt).Abs() > time.Second {
+			t.Fatalf("Usage = %+v, last login %v; want %+v, %v", *usage, lastLogin, want, revoked.CreatedAt)
+		}
+
+		usage, err = s.Users.Usage(ctx, bob.ID)
+		if err != nil {
+			t.Fatalf("Usage: %v", err)
+		}
+		if usage.Projects != 1 || usage.Todos != 0 || usage.LastLoginAt != nil {
+			t.Fatalf("Usage of bob = %+v", usage)
+		}
+	})
+
+	t.Run("Duplicates", func(t *testing.T) {
+		s := newStorage(t)
+		createUser(t, s, "alice")
+
+		dup := newUser(t, "bob")
+		dup.Email = "alice@example.com"
+		if err := s.Users.Create(ctx, dup); !errors.Is(err, store.ErrDuplicateEmail) {
+			t.Fatalf("duplicate email err = %v, want ErrDuplicateEmail", err)
+		}
+
+		dup = newUser(t, "alice")
+		dup.Email = "other@example.com"
+		if err := s.Users.Create(ctx, dup); !errors.Is(err, store.ErrDuplicateUsername) {
+			t.Fatalf("duplicate username err = %v, want ErrDuplicateUsername", err)
+		}
+	})
+}
+
+func testTodos(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+
+		todo := createTodo(t, s, alice.ID, "write docs", "work", "docs")
+		if todo.ID == 0 || todo.CreatedAt.IsZero() || todo.UpdatedAt == "" {
+			t.Fatalf("Create did not fill generated fields: %+v", todo)
+		}
+
+		got, err := s.Todos.GetTodoByID(ctx, alice.ID, todo.ID)
+		if err != nil {
+			t.Fatalf("GetTodoByID: %v", err)
+		}
+		if got.Title != "write docs" || got.UserID != alice.ID || got.Priority != 2 {
+			t.Fatalf("GetTodoByID = %+v", got)
+		}
+		if len(got.Tags) != 2 || got.Tags[0] != "work" || got.Tags[1] != "docs" {
+			t.Fatalf("GetTodoByID tags = %v", got.Tags)
+		}
+	})
+
+	t.Run("GetAllTodosNewestFirst", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+
+		first := createTodo(t, s, alice.ID, "first")
+		second := createTodo(t, s, alice.ID, "second")
+		createTodo(t, s, bob.ID, "bob's")
+
+		todos, err := s.Todos.GetAllTodos(ctx, alice.ID)
+		if err != nil {
+			t.Fatalf("GetAllTodos: %v", err)
+		}
+		if len(todos) != 2 {
+			t.Fatalf("GetAllTodos returned %d todos, want 2", len(todos))
+		}
+		if todos[0].ID != second.ID || todos[1].ID != first.ID {
+			t.Fatalf("GetAllTodos order = [%d %d], want [%d %d]", todos[0].ID, todos[1].ID, second.ID, first.ID)
+		}
+	})
+
+	t.Run("OwnerScoping", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+		todo := createTodo(t, s, alice.ID, "private")
+
+		if _, err := s.Todos.GetTodoByID(ctx, bob.ID, todo.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("cross-user GetTodoByID err = %v, want ErrNotFound", err)
+		}
+		err := s.Todos.UpdateTodo(ctx, bob.ID, todo.ID, map[string]interface{}{"title": "mine now"})
+		if !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("cross-user UpdateTodo err = %v, want ErrNotFound", err)
+		}
+		if err := s.Todos.DeleteTodo(ctx, bob.ID, todo.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("cross-user DeleteTodo err = %v, want ErrNotFound", err)
+		}
+
+		got, err := s.Todos.GetTodoByID(ctx, alice.ID, todo.ID)
+		if err != nil {
+			t.Fatalf("GetTodoByID: %v", err)
+		}
+		if got.Title != "private" {
+			t.Fatalf("todo was modified by another user: %+v", got)
+		}
+	})
+
+	t.Run("PartialUpdate", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		todo := createTodo(t, s, alice.ID, "before", "keep")
+
+		err := s.Todos.UpdateTodo(ctx, alice.ID, todo.ID, map[string]interface{}{
+			"title":     "after",
+			"completed": true,
+		})
+		if err != nil {
+			t.Fatalf("UpdateTodo: %v", err)
+		}
+
+		got, err := s.Todos.GetTodoByID(ctx, alice.ID, todo.ID)
+		if err != nil {
+			t.Fatalf("GetTodoByID: %v", err)
+		}
+		if got.Title != "after" || !got.Completed {
+			t.Fatalf("updated fields not applied: %+v", got)
+		}
+		if got.Description != todo.Description || got.Priority != todo.Priority || len(got.Tags) != 1 || got.Tags[0] != "keep" {
+			t.Fatalf("fields outside the update changed: %+v", got)
+		}
+
+		err = s.Todos.UpdateTodo(ctx, alice.ID, todo.ID, map[string]interface{}{
+			"priority": int64(5),
+			"tags":     []string{"a", "b"},
+		})
+		if err != nil {
+			t.Fatalf("UpdateTodo: %v", err)
+		}
+		got, err = s.Todos.GetTodoByID(ctx, alice.ID, todo.ID)
+		if err != nil {
+			t.Fatalf("GetTodoByID: %v", err)
+		}
+		if got.Priority != 5 || len(got.Tags) != 2 || got.Title != "after" {
+			t.Fatalf("second update = %+v", got)
+		}
+
+		if err := s.Todos.UpdateTodo(ctx, alice.ID, todo.ID, map[string]interface{}{}); err == nil {
+			t.Fatal("UpdateTodo with no fields succeeded")
+		}
+		err = s.Todos.UpdateTodo(ctx, alice.ID, 4242, map[string]interface{}{"title": "x"})
+		if !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("UpdateTodo of missing todo err = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		todo := createTodo(t, s, alice.ID, "doomed")
+
+		if err := s.Todos.DeleteTodo(ctx, alice.ID, todo.ID); err != nil {
+			t.Fatalf("DeleteTodo: %v", err)
+		}
+		if _, err := s.Todos.GetTodoByID(ctx, alice.ID, todo.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("GetTodoByID after delete err = %v, want ErrNotFound", err)
+		}
+		if err := s.Todos.DeleteTodo(ctx, alice.ID, todo.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("second DeleteTodo err = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("ListTodosPagination", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+		createTodo(t, s, bob.ID, "bob's")
+
+		var want []int64
+		for i := 0; i < 5; i++ {
+			todo := createTodo(t, s, alice.ID, fmt.Sprintf("todo %d", i))
+			want = append([]int64{todo.ID}, want...)
+		}
+
+		f := store.DefaultTodoFilter()
+		f.Limit = 2
+
+		var got []int64
+		for pages := 0; ; pages++ {
+			if pages > 5 {
+				t.Fatal("ListTodos never ran out of pages")
+			}
+			page, err := s.Todos.ListTodos(ctx, alice.ID, f)
+			if err != nil {
+				t.Fatalf("ListTodos: %v", err)
+			}
+			if page.Total != 5 {
+				t.Fatalf("ListTodos total = %d, want 5", page.Total)
+			}
+			for _, todo := range page.Todos {
+				got = append(got, todo.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			f.Cursor = page.NextCursor
+		}
+
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("paged IDs = %v, want %v", got, want)
+		}
+
+		f.Order = "asc"
+		if _, err := s.Todos.ListTodos(ctx, alice.ID, f); !errors.Is(err, store.ErrInvalidCursor) {
+			t.Fatalf("cursor reused with another order err = %v, want ErrInvalidCursor", err)
+		}
+		f.Cursor = "not-a-cursor"
+		if _, err := s.Todos.ListTodos(ctx, alice.ID, f); !errors.Is(err, store.ErrInvalidCursor) {
+			t.Fatalf("garbage cursor err = %v, want ErrInvalidCursor", err)
+		}
+	})
+
+	t.Run("ListTodosCursorEverySort", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+
+		// Priorities tie so the id tiebreak is paged through too, and
+		// updated_at is set in a different order from created_at.
+		var todos []*store.Todo
+		for i, p := range []int64{1, 3, 1, 3, 2} {
+			todo := createTodo(t, s, alice.ID, fmt.Sprintf("todo %c", 'e'-i), "tag")
+			setTodo(t, s, alice.ID, todo.ID, map[string]interface{}{"priority": p})
+			todos = append(todos, todo)
+		}
+		for _, i := range []int{2, 0, 4, 1, 3} {
+			setTodo(t, s, alice.ID, todos[i].ID, map[string]interface{}{"description": "touched"})
+		}
+
+		ids := func(page *store.TodoPage) []int64 {
+			var ids []int64
+			for _, todo := range page.Todos {
+				ids = append(ids, todo.ID)
+			}
+			return ids
+		}
+
+		for _, by := range []string{"created_at", "updated_at", "priority", "title"} {
+			for _, order := range []string{"asc", "desc"} {
+				f := store.DefaultTodoFilter()
+				f.Sort, f.Order, f.Limit = by, order, 100
+				f.Tags, f.Search = []string{"tag"}, "touched"
+				all, err := s.Todos.ListTodos(ctx, alice.ID, f)
+				if err != nil {
+					t.Fatalf("ListTodos %s %s: %v", by, order, err)
+				}
+				want := ids(all)
+				if len(want) != len(todos) {
+					t.Fatalf("ListTodos %s %s = %v, want %d todos", by, order, want, len(todos))
+				}
+
+				f.Limit = 2
+				var got []int64
+				for pages := 0; ; pages++ {
+					if pages > len(todos) {
+						t.Fatalf("ListTodos %s %s never ran out of pages", by, order)
+					}
+					page, err := s.Todos.ListTodos(ctx, alice.ID, f)
+					if err != nil {
+						t.Fatalf("ListTodos %s %s: %v", by, order, err)
+					}
+					got = append(got, ids(page)...)
+					if page.NextCursor == "" {
+						break
+					}
+					f.Cursor = page.NextCursor
+				}
+				if fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Fatalf("paged %s %s = %v, want %v", by, order, got, want)
+				}
+			}
+		}
+
+		f := store.DefaultTodoFilter()
+		f.Sort, f.Order, f.Limit = "updated_at", "desc", 100
+		page, err := s.Todos.ListTodos(ctx, alice.ID, f)
+		if err != nil {
+			t.Fatalf("ListTodos: %v", err)
+		}
+		want := []int64{todos[3].ID, todos[1].ID, todos[4].ID, todos[0].ID, todos[2].ID}
+		if got := ids(page); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("sort by updated_at = %v, want %v", got, want)
+		}
+		f.Sort = "priority"
+		if page, err = s.Todos.ListTodos(ctx, alice.ID, f); err != nil {
+			t.Fatalf("ListTodos: %v", err)
+		}
+		want = []int64{todos[3].ID, todos[1].ID, todos[4].ID, todos[2].ID, todos[0].ID}
+		if got := ids(page); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("sort by priority = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("ListTodosFiltersAndSort", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+
+		low := createTodo(t, s, alice.ID, "buy milk", "home")
+		high := createTodo(t, s, alice.ID, "File taxes", "home", "money")
+		mid := createTodo(t, s, alice.ID, "Call 100% of clients", "work")
+		setTodo(t, s, alice.ID, low.ID, map[string]interface{}{"priority": int64(1)})
+		setTodo(t, s, alice.ID, high.ID, map[string]interface{}{"priority": int64(5), "completed": true})
+		setTodo(t, s, alice.ID, mid.ID, map[string]interface{}{"priority": int64(3)})
+
+		list := func(f store.TodoFilter) []int64 {
+			t.Helper()
+			page, err := s.Todos.ListTodos(ctx, alice.ID, f)
+			if err != nil {
+				t.Fatalf("ListTodos: %v", err)
+			}
+			var ids []int64
+			for _, todo := range page.Todos {
+				ids = append(ids, todo.ID)
+			}
+			if page.Total != len(ids) {
+				t.Fatalf("ListTodos total = %d, want %d", page.Total, len(ids))
+			}
+			return ids
+		}
+		expect := func(name string, got []int64, want ...int64) {
+			t.Helper()
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("%s = %v, want %v", name, got, want)
+			}
+		}
+
+		f := store.DefaultTodoFilter()
+		f.Sort, f.Order = "priority", "desc"
+		expect("sort by priority", list(f), high.ID, mid.ID, low.ID)
+
+		// Titles sort byte by byte, so capitals come first on every backend.
+		f.Sort, f.Order = "title", "asc"
+		expect("sort by title", list(f), mid.ID, high.ID, low.ID)
+		f.Limit = 1
+		var paged []int64
+		for {
+			page, err := s.Todos.ListTodos(ctx, alice.ID, f)
+			if err != nil {
+				t.Fatalf("ListTodos: %v", err)
+			}
+			for _, todo := range page.Todos {
+				paged = append(paged, todo.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			f.Cursor = page.NextCursor
+		}
+		expect("paged by title", paged, mid.ID, high.ID, low.ID)
+
+		f = store.DefaultTodoFilter()
+		done := false
+		f.Completed = &done
+		expect("completed=false", list(f), mid.ID, low.ID)
+
+		f = store.DefaultTodoFilter()
+		minP, maxP := int16(2), int16(4)
+		f.MinPriority, f.MaxPriority = &minP, &maxP
+		expect("priority range", list(f), mid.ID)
+
+		f = store.DefaultTodoFilter()
+		f.Tags = []string{"home", "money"}
+		expect("tags", list(f), high.ID)
+
+		f = store.DefaultTodoFilter()
+		f.Search = "MILK"
+		expect("search", list(f), low.ID)
+		f.Search = "100%"
+		expect("search with wildcard", list(f), mid.ID)
+
+		f = store.DefaultTodoFilter()
+		after := high.CreatedAt
+		f.CreatedAfter = &after
+		expect("created after", list(f), mid.ID, high.ID)
+		f.CreatedAfter, f.CreatedBefore = nil, &after
+		expect("created before", list(f), low.ID)
+	})
+
+	t.Run("SearchTodos", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+
+		inTitle := createTodo(t, s, alice.ID, "Renew passport")
+		inDescription := createTodo(t, s, alice.ID, "Errands")
+		setTodo(t, s, alice.ID, inDescription.ID, map[string]interface{}{"description": "pick up the passport photos"})
+		createTodo(t, s, alice.ID, "Water plants")
+		createTodo(t, s, bob.ID, "Renew bob's passport")
+
+		results, err := s.Todos.SearchTodos(ctx, alice.ID, "passport", 10)
+		if err != nil {
+			t.Fatalf("SearchTodos: %v", err)
+		}
+		if len(results) != 2 {
+			t.Fatalf("SearchTodos returned %d results, want 2", len(results))
+		}
+		if results[0].ID != inTitle.ID || results[1].ID != inDescription.ID {
+			t.Fatalf("SearchTodos order = [%d %d], want title match first", results[0].ID, results[1].ID)
+		}
+		for _, r := range results {
+			if r.Rank <= 0 || !strings.Contains(r.Snippet, "<mark>") {
+				t.Fatalf("result %d missing rank or highlighted snippet: %+v", r.ID, r)
+			}
+		}
+
+		results, err = s.Todos.SearchTodos(ctx, alice.ID, "passport photos", 10)
+		if err != nil {
+			t.Fatalf("SearchTodos: %v", err)
+		}
+		if len(results) != 1 || results[0].ID != inDescription.ID {
+			t.Fatalf("multi-term SearchTodos = %+v, want only todo %d", results, inDescription.ID)
+		}
+
+		results, err = s.Todos.SearchTodos(ctx, alice.ID, "passport", 1)
+		if err != nil {
+			t.Fatalf("SearchTodos: %v", err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("SearchTodos ignored limit, got %d results", len(results))
+		}
+
+		// The snippet is cut from text with no spaces, in characters whose
+		// UTF-8 encoding holds bytes that read alone are spaces (U+5800 is
+		// E5 A0 80), so cutting by bytes splits a character.
+		cjk := strings.Repeat("堀", 40)
+		nonASCII := createTodo(t, s, alice.ID, "Visa")
+		setTodo(t, s, alice.ID, nonASCII.ID, map[string]interface{}{"description": cjk + " embassy " + cjk})
+		results, err = s.Todos.SearchTodos(ctx, alice.ID, "embassy", 10)
+		if err != nil {
+			t.Fatalf("SearchTodos: %v", err)
+		}
+		if len(results) != 1 || results[0].ID != nonASCII.ID {
+			t.Fatalf("SearchTodos(embassy) = %+v, want only todo %d", results, nonASCII.ID)
+		}
+		if snippet := results[0].Snippet; !utf8.ValidString(snippet) || !strings.Contains(snippet, "<mark>embassy</mark>") {
+			t.Fatalf("snippet %q is not valid UTF-8 with embassy highlighted", snippet)
+		}
+	})
+
+	t.Run("DueDates", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+
+		base := time.Date(2030, 3, 10, 9, 30, 0, 0, time.UTC)
+		at := func(hours int) *time.Time {
+			t := base.Add(time.Duration(hours) * time.Hour)
+			return &t
+		}
+
+		overdue := &store.Todo{UserID: alice.ID, Title: "overdue", DueAt: at(-2), StartAt: at(-5), Timezone: "Europe/Berlin", Recurrence: "FREQ=WEEKLY;BYDAY=MO"}
+		soon := &store.Todo{UserID: alice.ID, Title: "soon", DueAt: at(3)}
+		later := &store.Todo{UserID: alice.ID, Title: "later", DueAt: at(48)}
+		done := &store.Todo{UserID: alice.ID, Title: "done", DueAt: at(1), Completed: true}
+		undated := &store.Todo{UserID: alice.ID, Title: "undated"}
+		for _, todo := range []*store.Todo{overdue, soon, later, done, undated} {
+			if err := s.Todos.Create(ctx, todo); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+		}
+
+		got, err := s.Todos.GetTodoByID(ctx, alice.ID, overdue.ID)
+		if err != nil {
+			t.Fatalf("GetTodoByID: %v", err)
+		}
+		if got.DueAt == nil || !got.DueAt.Equal(*overdue.DueAt) || got.StartAt == nil || !got.StartAt.Equal(*overdue.StartAt) {
+			t.Fatalf("dates did not round trip: start %v due %v", got.StartAt, got.DueAt)
+		}
+		if got.Timezone != "Europe/Berlin" || got.Recurrence != "FREQ=WEEKLY;BYDAY=MO" {
+			t.Fatalf("Timezone, Recurrence = %q, %q", got.Timezone, got.Recurrence)
+		}
+		if got, _ := s.Todos.GetTodoByID(ctx, alice.ID, undated.ID); got.DueAt != nil || got.Timezone != "UTC" {
+			t.Fatalf("undated todo = due %v tz %q, want no due date in UTC", got.DueAt, got.Timezone)
+		}
+
+		ids := func(from, to *time.Time) string {
+			t.Helper()
+			todos, err := s.Todos.GetTodosDue(ctx, alice.ID, from, to)
+			if err != nil {
+				t.Fatalf("GetTodosDue: %v", err)
+			}
+			var titles []string
+			for _, todo := range todos {
+				titles = append(titles, todo.Title)
+			}
+			return strings.Join(titles, ",")
+		}
+
+		if got := ids(nil, &base); got != "overdue" {
+			t.Fatalf("overdue = %q", got)
+		}
+		if got := ids(&base, at(24)); got != "soon" {
+			t.Fatalf("next day = %q", got)
+		}
+		if got := ids(nil, nil); got != "overdue,soon,later" {
+			t.Fatalf("all due = %q", got)
+		}
+
+		err = s.Todos.UpdateTodo(ctx, alice.ID, soon.ID, map[string]interface{}{"due_at": *at(100)})
+		if err != nil {
+			t.Fatalf("UpdateTodo: %v", err)
+		}
+		if got := ids(nil, nil); got != "overdue,later,soon" {
+			t.Fatalf("after moving due date = %q", got)
+		}
+	})
+
+	t.Run("Subtasks", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+
+		root := createTodo(t, s, alice.ID, "root")
+		child := createSubtask(t, s, alice.ID, root.ID, "child")
+		grandchild := createSubtask(t, s, alice.ID, child.ID, "grandchild")
+		sibling := createSubtask(t, s, alice.ID, root.ID, "sibling")
+		other := createTodo(t, s, alice.ID, "other")
+		bobs := createTodo(t, s, bob.ID, "bob's")
+
+		err := s.Todos.Create(ctx, &store.Todo{UserID: alice.ID, Title: "orphan", ParentID: &bobs.ID})
+		if !errors.Is(err, store.ErrInvalidParent) {
+			t.Fatalf("Create under another user's todo err = %v, want ErrInvalidParent", err)
+		}
+
+		titles := func(todoID int64) string {
+			t.Helper()
+			todos, err := s.Todos.GetSubtree(ctx, alice.ID, todoID)
+			if err != nil {
+				t.Fatalf("GetSubtree: %v", err)
+			}
+			var titles []string
+			for _, todo := range todos {
+				titles = append(titles, todo.Title)
+			}
+			return strings.Join(titles, ",")
+		}
+
+		if got := titles(root.ID); got != "root,child,grandchild,sibling" {
+			t.Fatalf("subtree = %q", got)
+		}
+		if got := titles(child.ID); got != "child,grandchild" {
+			t.Fatalf("child subtree = %q", got)
+		}
+		if _, err := s.Todos.GetSubtree(ctx, bob.ID, root.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("GetSubtree by another user err = %v, want ErrNotFound", err)
+		}
+
+		todos, _ := s.Todos.GetSubtree(ctx, alice.ID, root.ID)
+		tree := store.BuildTodoTree(todos, root.ID)
+		if len(tree.Children) != 2 || tree.Children[0].ID != child.ID || len(tree.Children[0].Children) != 1 ||
+			tree.Children[0].Children[0].ID != grandchild.ID {
+			t.Fatalf("BuildTodoTree = %+v", tree)
+		}
+
+		for _, parentID := range []int64{root.ID, child.ID, grandchild.ID} {
+			if err := s.Todos.MoveTodo(ctx, alice.ID, root.ID, &parentID); !errors.Is(err, store.ErrCycle) {
+				t.Fatalf("MoveTodo under %d err = %v, want ErrCycle", parentID, err)
+			}
+		}
+		if err := s.Todos.MoveTodo(ctx, alice.ID, child.ID, &bobs.ID); !errors.Is(err, store.ErrInvalidParent) {
+			t.Fatalf("MoveTodo under another user's todo err = %v, want ErrInvalidParent", err)
+		}
+		if err := s.Todos.MoveTodo(ctx, bob.ID, child.ID, nil); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("MoveTodo by another user err = %v, want ErrNotFound", err)
+		}
+		if got := titles(root.ID); got != "root,child,grandchild,sibling" {
+			t.Fatalf("subtree after failed moves = %q", got)
+		}
+
+		if err := s.Todos.MoveTodo(ctx, alice.ID, child.ID, &other.ID); err != nil {
+			t.Fatalf("MoveTodo: %v", err)
+		}
+		if got := titles(root.ID); got != "root,sibling" {
+			t.Fatalf("subtree after move = %q", got)
+		}
+		if got := titles(other.ID); got != "other,child,grandchild" {
+			t.Fatalf("new parent subtree = %q", got)
+		}
+
+		if err := s.Todos.MoveTodo(ctx, alice.ID, child.ID, nil); err != nil {
+			t.Fatalf("MoveTodo to top level: %v", err)
+		}
+		got, err := s.Todos.GetTodoByID(ctx, alice.ID, child.ID)
+		if err != nil {
+			t.Fatalf("GetTodoByID: %v", err)
+		}
+		if got.ParentID != nil {
+			t.Fatalf("ParentID = %d, want none", *got.ParentID)
+		}
+
+		if err := s.Todos.DeleteTodo(ctx, alice.ID, child.ID); err != nil {
+			t.Fatalf("DeleteTodo: %v", err)
+		}
+		if _, err := s.Todos.GetTodoByID(ctx, alice.ID, grandchild.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("subtask survived its parent, err = %v", err)
+		}
+		if _, err := s.Todos.GetTodoByID(ctx, alice.ID, sibling.ID); err != nil {
+			t.Fatalf("unrelated subtask deleted: %v", err)
+		}
+	})
+
+	t.Run("MoveAcrossProjects", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		work := createProject(t, s, alice.ID, "Work")
+
+		inProject := &store.Todo{UserID: alice.ID, Title: "in project", ProjectID: &work.ID}
+		if err := s.Todos.Create(ctx, inProject); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		inbox := createTodo(t, s, alice.ID, "inbox")
+		moved := createTodo(t, s, alice.ID, "moved")
+		child := createSubtask(t, s, alice.ID, moved.ID, "child")
+		trashed := createSubtask(t, s, alice.ID, child.ID, "trashed")
+		if err := s.Todos.DeleteTodo(ctx, alice.ID, trashed.ID); err != nil {
+			t.Fatalf("DeleteTodo: %v", err)
+		}
+
+		projects := func() []string {
+			t.Helper()
+			todos, err := s.Todos.GetSubtree(ctx, alice.ID, moved.ID)
+			if err != nil {
+				t.Fatalf("GetSubtree: %v", err)
+			}
+			var got []string
+			for _, todo := range todos {
+				got = append(got, fmt.Sprintf("%s:%v", todo.Title, todo.ProjectID != nil && *todo.ProjectID == work.ID))
+			}
+			return got
+		}
+
+		if err := s.Todos.MoveTodo(ctx, alice.ID, moved.ID, &inProject.ID); err != nil {
+			t.Fatalf("MoveTodo: %v", err)
+		}
+		if got := projects(); fmt.Sprint(got) != "[moved:true child:true]" {
+			t.Fatalf("projects after moving into the project = %v", got)
+		}
+		if err := s.Todos.RestoreTodo(ctx, alice.ID, trashed.ID); err != nil {
+			t.Fatalf("RestoreTodo: %v", err)
+		}
+		if got := projects(); fmt.Sprint(got) != "[moved:true child:true trashed:true]" {
+			t.Fatalf("projects after restoring a subtask = %v", got)
+		}
+
+		// Moving to the top level keeps the project.
+		if err := s.Todos.MoveTodo(ctx, alice.ID, moved.ID, nil); err != nil {
+			t.Fatalf("MoveTodo: %v", err)
+		}
+		if got := projects(); fmt.Sprint(got) != "[moved:true child:true trashed:true]" {
+			t.Fatalf("projects after moving to the top level = %v", got)
+		}
+
+		if err := s.Todos.MoveTodo(ctx, alice.ID, moved.ID, &inbox.ID); err != nil {
+			t.Fatalf("MoveTodo: %v", err)
+		}
+		if got := projects(); fmt.Sprint(got) != "[moved:false child:false trashed:false]" {
+			t.Fatalf("projects after moving out of the project = %v", got)
+		}
+	})
+
+	t.Run("UpdateProjectMovesSubtree", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		work := createProject(t, s, alice.ID, "Work")
+
+		root := createTodo(t, s, alice.ID, "root")
+		child := createSubtask(t, s, alice.ID, root.ID, "child")
+		trashed := createSubtask(t, s, alice.ID, child.ID, "trashed")
+		if err := s.Todos.DeleteTodo(ctx, alice.ID, trashed.ID); err != nil {
+			t.Fatalf("DeleteTodo: %v", err)
+		}
+		other := createTodo(t, s, alice.ID, "other")
+
+		inWork := func(todoID int64) bool {
+			t.Helper()
+			todo, err := s.Todos.GetTodoByID(ctx, alice.ID, todoID)
+			if err != nil {
+				t.Fatalf("GetTodoByID: %v", err)
+			}
+			return todo.ProjectID != nil && *todo.ProjectID == work.ID
+		}
+
+		if err := s.Todos.UpdateTodo(ctx, alice.ID, root.ID, map[string]interface{}{"project_id": work.ID}); err != nil {
+			t.Fatalf("UpdateTodo: %v", err)
+		}
+		if err := s.Todos.RestoreTodo(ctx, alice.ID, trashed.ID); err != nil {
+			t.Fatalf("RestoreTodo: %v", err)
+		}
+		if !inWork(root.ID) || !inWork(child.ID) || !inWork(trashed.ID) || inWork(other.ID) {
+			t.Fatal("subtasks did not follow their parent into the project")
+		}
+
+		if err := s.Todos.UpdateTodo(ctx, alice.ID, root.ID, map[string]interface{}{"project_id": nil}); err != nil {
+			t.Fatalf("UpdateTodo: %v", err)
+		}
+		if inWork(root.ID) || inWork(child.ID) || inWork(trashed.ID) {
+			t.Fatal("subtasks did not follow their parent out of the project")
+		}
+	})
+
+	t.Run("CountOpenSubtasks", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+
+		root := createTodo(t, s, alice.ID, "root")
+		done := createSubtask(t, s, alice.ID, root.ID, "done")
+		open := createSubtask(t, s, alice.ID, root.ID, "open")
+		trashed := createSubtask(t, s, alice.ID, root.ID, "trashed")
+		createSubtask(t, s, alice.ID, open.ID, "grandchild")
+		setTodo(t, s, alice.ID, done.ID, map[string]interface{}{"completed": true})
+		if err := s.Todos.DeleteTodo(ctx, alice.ID, trashed.ID); err != nil {
+			t.Fatalf("DeleteTodo: %v", err)
+		}
+
+		count := func(userID, parentID int64) int {
+			t.Helper()
+			n, err := s.Todos.CountOpenSubtasks(ctx, userID, parentID)
+			if err != nil {
+				t.Fatalf("CountOpenSubtasks: %v", err)
+			}
+			return n
+		}
+
+		if n := count(alice.ID, root.ID); n != 1 {
+			t.Fatalf("open subtasks = %d, want 1", n)
+		}
+		if n := count(bob.ID, root.ID); n != 0 {
+			t.Fatalf("open subtasks of another user's todo = %d, want 0", n)
+		}
+		setTodo(t, s, alice.ID, open.ID, map[string]interface{}{"completed": true})
+		if n := count(alice.ID, root.ID); n != 0 {
+			t.Fatalf("open subtasks after completing them = %d, want 0", n)
+		}
+	})
+
+	t.Run("GetTodosByTag", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+
+		tagged := createTodo(t, s, alice.ID, "tagged", "home", "urgent")
+		createTodo(t, s, alice.ID, "untagged", "work")
+		createTodo(t, s, bob.ID, "bob's", "urgent")
+
+		todos, err := s.Todos.GetTodosByTag(ctx, alice.ID, "urgent")
+		if err != nil {
+			t.Fatalf("GetTodosByTag: %v", err)
+		}
+		if len(todos) != 1 || todos[0].ID != tagged.ID {
+			t.Fatalf("GetTodosByTag = %+v, want only todo %d", todos, tagged.ID)
+		}
+	})
+
+	t.Run("Trash", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+		parent := createTodo(t, s, alice.ID, "parent")
+		child := createSubtask(t, s, alice.ID, parent.ID, "child")
+		kept := createTodo(t, s, alice.ID, "kept")
+		createTodo(t, s, bob.ID, "bob's")
+
+		trashIDs := func(userID int64) []int64 {
+			t.Helper()
+			todos, err := s.Todos.GetTrash(ctx, userID)
+			if err != nil {
+				t.Fatalf("GetTrash: %v", err)
+			}
+			var ids []int64
+			for _, todo := range todos {
+				if todo.DeletedAt == nil {
+					t.Fatalf("GetTrash returned todo %d without DeletedAt", todo.ID)
+				}
+				ids = append(ids, todo.ID)
+			}
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+			return ids
+		}
+
+		if err := s.Todos.DeleteTodo(ctx, alice.ID, parent.ID); err != nil {
+			t.Fatalf("DeleteTodo: %v", err)
+		}
+		for _, id := range []int64{parent.ID, child.ID} {
+			if _, err := s.Todos.GetTodoByID(ctx, alice.ID, id); !errors.Is(err, store.ErrNotFound) {
+				t.Fatalf("GetTodoByID trashed todo %d err = %v, want ErrNotFound", id, err)
+			}
+		}
+		todos, err := s.Todos.GetAllTodos(ctx, alice.ID)
+		if err != nil {
+			t.Fatalf("GetAllTodos: %v", err)
+		}
+		if len(todos) != 1 || todos[0].ID != kept.ID {
+			t.Fatalf("GetAllTodos = %+v, want only todo %d", todos, kept.ID)
+		}
+		if got := trashIDs(alice.ID); !reflect.DeepEqual(got, []int64{parent.ID, child.ID}) {
+			t.Fatalf("trash = %v, want %d and %d", got, parent.ID, child.ID)
+		}
+		if got := trashIDs(bob.ID); len(got) != 0 {
+			t.Fatalf("bob's trash = %v, want empty", got)
+		}
+
+		if err := s.Todos.RestoreTodo(ctx, bob.ID, parent.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("RestoreTodo other user err = %v, want ErrNotFound", err)
+		}
+		if err := s.Todos.RestoreTodo(ctx, alice.ID, kept.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("RestoreTodo live todo err = %v, want ErrNotFound", err)
+		}
+
+		// A subtask restored without its parent goes to the top level.
+		if err := s.Todos.RestoreTodo(ctx, alice.ID, child.ID); err != nil {
+			t.Fatalf("RestoreTodo subtask: %v", err)
+		}
+		got, err := s.Todos.GetTodoByID(ctx, alice.ID, child.ID)
+		if err != nil {
+			t.Fatalf("GetTodoByID restored subtask: %v", err)
+		}
+		if got.ParentID != nil || got.DeletedAt != nil {
+			t.Fatalf("restored subtask = %+v, want a top-level live todo", got)
+		}
+
+		// Restoring a todo brings back the subtasks deleted with it.
+		if err := s.Todos.DeleteTodo(ctx, alice.ID, child.ID); err != nil {
+			t.Fatalf("DeleteTodo: %v", err)
+		}
+		other := createSubtask(t, s, alice.ID, kept.ID, "other")
+		if err := s.Todos.DeleteTodo(ctx, alice.ID, kept.ID); err != nil {
+			t.Fatalf("DeleteTodo: %v", err)
+		}
+		if err := s.Todos.RestoreTodo(ctx, alice.ID, kept.ID); err != nil {
+			t.Fatalf("RestoreTodo: %v", err)
+		}
+		subtree, err := s.Todos.GetSubtree(ctx, alice.ID, kept.ID)
+		if err != nil {
+			t.Fatalf("GetSubtree: %v", err)
+		}
+		if len(subtree) != 2 || subtree[1].ID != other.ID {
+			t.Fatalf("restored subtree = %+v, want todo %d with subtask %d", subtree, kept.ID, other.ID)
+		}
+
+		if err := s.Todos.PurgeTodo(ctx, alice.ID, kept.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("PurgeTodo live todo err = %v, want ErrNotFound", err)
+		}
+		if err := s.Todos.PurgeTodo(ctx, alice.ID, child.ID); err != nil {
+			t.Fatalf("PurgeTodo: %v", err)
+		}
+		if err := s.Todos.RestoreTodo(ctx, alice.ID, child.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("RestoreTodo purged todo err = %v, want ErrNotFound", err)
+		}
+		if got := trashIDs(alice.ID); !reflect.DeepEqual(got, []int64{parent.ID}) {
+			t.Fatalf("trash after purge = %v, want %d", got, parent.ID)
+		}
+
+		n, err := s.Todos.EmptyTrash(ctx, alice.ID)
+		if err != nil {
+			t.Fatalf("EmptyTrash: %v", err)
+		}
+		if n != 1 || len(trashIDs(alice.ID)) != 0 {
+			t.Fatalf("EmptyTrash = %d, trash = %v", n, trashIDs(alice.ID))
+		}
+	})
+
+	t.Run("PurgeDeleted", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+		old := createTodo(t, s, alice.ID, "old")
+		bobs := createTodo(t, s, bob.ID, "bob's")
+		for _, todo := range []*store.Todo{old, bobs} {
+			if err := s.Todos.DeleteTodo(ctx, todo.UserID, todo.ID); err != nil {
+				t.Fatalf("DeleteTodo: %v", err)
+			}
+		}
+
+		n, err := s.Todos.PurgeDeleted(ctx, time.Now().Add(-time.Hour))
+		if err != nil {
+			t.Fatalf("PurgeDeleted: %v", err)
+		}
+		if n != 0 {
+			t.Fatalf("PurgeDeleted before the deletions = %d, want 0", n)
+		}
+
+		recent := createTodo(t, s, alice.ID, "recent")
+		n, err = s.Todos.PurgeDeleted(ctx, time.Now().Add(time.Second))
+		if err != nil {
+			t.Fatalf("PurgeDeleted: %v", err)
+		}
+		if n != 2 {
+			t.Fatalf("PurgeDeleted = %d, want 2", n)
+		}
+		for _, userID := range []int64{alice.ID, bob.ID} {
+			if trash, err := s.Todos.GetTrash(ctx, userID); err != nil || len(trash) != 0 {
+				t.Fatalf("GetTrash after PurgeDeleted = %+v, %v", trash, err)
+			}
+		}
+		if _, err := s.Todos.GetTodoByID(ctx, alice.ID, recent.ID); err != nil {
+			t.Fatalf("live todo purged: %v", err)
+		}
+	})
+}
+
+func testProjects(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+
+	t.Run("CRUD", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+
+		work := createProject(t, s, alice.ID, "Work")
+		home := createProject(t, s, alice.ID, "Home")
+		createProject(t, s, bob.ID, "Bob's")
+
+		if work.ID == 0 || work.CreatedAt.IsZero() {
+			t.Fatalf("Create did not set ID or CreatedAt: %+v", work)
+		}
+		if work.Position != 0 || home.Position != 1 {
+			t.Fatalf("positions = %d, %d, want 0, 1", work.Position, home.Position)
+		}
+
+		got, err := s.Projects.GetByID(ctx, alice.ID, work.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if got.Name != "Work" || got.Color != "#336699" || got.Archived {
+			t.Fatalf("GetByID = %+v", got)
+		}
+		if _, err := s.Projects.GetByID(ctx, bob.ID, work.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("GetByID by another user err = %v, want ErrNotFound", err)
+		}
+
+		err = s.Projects.Update(ctx, alice.ID, work.ID, map[string]interface{}{"name": "Office", "position": int64(5)})
+		if err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if err := s.Projects.Update(ctx, bob.ID, work.ID, map[string]interface{}{"name": "Mine"}); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Update by another user err = %v, want ErrNotFound", err)
+		}
+
+		names := func(archived bool) string {
+			t.Helper()
+			projects, err := s.Projects.GetByUser(ctx, alice.ID, archived)
+			if err != nil {
+				t.Fatalf("GetByUser: %v", err)
+			}
+			var names []string
+			for _, p := range projects {
+				names = append(names, p.Name)
+			}
+			return strings.Join(names, ",")
+		}
+
+		if got := names(false); got != "Home,Office" {
+			t.Fatalf("projects = %q", got)
+		}
+
+		if err := s.Projects.Update(ctx, alice.ID, home.ID, map[string]interface{}{"archived": true}); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if got := names(false); got != "Office" {
+			t.Fatalf("unarchived projects = %q", got)
+		}
+		if got := names(true); got != "Home,Office" {
+			t.Fatalf("all projects = %q", got)
+		}
+
+		if err := s.Projects.Delete(ctx, bob.ID, work.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Delete by another user err = %v, want ErrNotFound", err)
+		}
+		if err := s.Projects.Delete(ctx, alice.ID, work.ID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := s.Projects.GetByID(ctx, alice.ID, work.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("GetByID after Delete err = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Todos", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+
+		work := createProject(t, s, alice.ID, "Work")
+		home := createProject(t, s, alice.ID, "Home")
+
+		report := &store.Todo{UserID: alice.ID, Title: "report", Priority: 4, ProjectID: &work.ID}
+		slides := &store.Todo{UserID: alice.ID, Title: "slides", Priority: 1, ProjectID: &work.ID}
+		for _, todo := range []*store.Todo{report, slides} {
+			if err := s.Todos.Create(ctx, todo); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+		}
+		dishes := createTodo(t, s, alice.ID, "dishes")
+		setTodo(t, s, alice.ID, dishes.ID, map[string]interface{}{"project_id": home.ID})
+		createTodo(t, s, alice.ID, "inbox")
+
+		list := func(f store.TodoFilter) string {
+			t.Helper()
+			page, err := s.Todos.ListTodos(ctx, alice.ID, f)
+			if err != nil {
+				t.Fatalf("ListTodos: %v", err)
+			}
+			var titles []string
+			for _, todo := range page.Todos {
+				titles = append(titles, todo.Title)
+			}
+			return strings.Join(titles, ",")
+		}
+
+		f := store.DefaultTodoFilter()
+		f.ProjectID = &work.ID
+		if got := list(f); got != "slides,report" {
+			t.Fatalf("work todos = %q", got)
+		}
+		minPriority := int16(3)
+		f.MinPriority = &minPriority
+		if got := list(f); got != "report" {
+			t.Fatalf("filtered work todos = %q", got)
+		}
+		f = store.DefaultTodoFilter()
+		f.ProjectID = &home.ID
+		if got := list(f); got != "dishes" {
+			t.Fatalf("home todos = %q", got)
+		}
+
+		setTodo(t, s, alice.ID, dishes.ID, map[string]interface{}{"project_id": nil})
+		if got := list(f); got != "" {
+			t.Fatalf("home todos after removing dishes = %q", got)
+		}
+
+		if err := s.Projects.Delete(ctx, alice.ID, work.ID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		got, err := s.Todos.GetTodoByID(ctx, alice.ID, report.ID)
+		if err != nil {
+			t.Fatalf("todo deleted with its project: %v", err)
+		}
+		if got.ProjectID != nil {
+			t.Fatalf("ProjectID = %d after deleting the project, want none", *got.ProjectID)
+		}
+	})
+}
+
+func testMembers(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+
+	t.Run("Invitations", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+		carol := createUser(t, s, "carol")
+
+		work := createProject(t, s, alice.ID, "Work")
+		inv := createInvitation(t, s, work.ID, alice.ID, "Bob@Example.com", store.RoleEditor, time.Hour)
+		if inv.ID == 0 || inv.Token == "" {
+			t.Fatalf("CreateInvitation did not set ID or Token: %+v", inv)
+		}
+		expired := createInvitation(t, s, work.ID, alice.ID, "carol@example.com", store.RoleViewer, -time.Hour)
+
+		invitations, err := s.Members.GetInvitations(ctx, work.ID)
+		if err != nil {
+			t.Fatalf("GetInvitations: %v", err)
+		}
+		if len(invitations) != 1 || invitations[0].ID != inv.ID || invitations[0].Email != "bob@example.com" || invitations[0].Token != "" {
+			t.Fatalf("GetInvitations = %+v, want only the pending one without its token", invitations)
+		}
+
+		if _, err := s.Members.AcceptInvitation(ctx, expired.Token, carol); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("accept expired invitation err = %v, want ErrNotFound", err)
+		}
+		if _, err := s.Members.AcceptInvitation(ctx, "bogus", bob); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("accept unknown token err = %v, want ErrNotFound", err)
+		}
+		if _, err := s.Members.AcceptInvitation(ctx, inv.Token, carol); !errors.Is(err, store.ErrInvitationEmail) {
+			t.Fatalf("accept someone else's invitation err = %v, want ErrInvitationEmail", err)
+		}
+
+		member, err := s.Members.AcceptInvitation(ctx, inv.Token, bob)
+		if err != nil {
+			t.Fatalf("AcceptInvitation: %v", err)
+		}
+		if member.ProjectID != work.ID || member.UserID != bob.ID || member.Role != store.RoleEditor {
+			t.Fatalf("AcceptInvitation = %+v", member)
+		}
+		if _, err := s.Members.AcceptInvitation(ctx, inv.Token, bob); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("accept used invitation err = %v, want ErrNotFound", err)
+		}
+
+		again := createInvitation(t, s, work.ID, alice.ID, "bob@example.com", store.RoleViewer, time.Hour)
+		if _, err := s.Members.AcceptInvitation(ctx, again.Token, bob); !errors.Is(err, store.ErrConflict) {
+			t.Fatalf("accept as member err = %v, want ErrConflict", err)
+		}
+		own := createInvitation(t, s, work.ID, alice.ID, "alice@example.com", store.RoleViewer, time.Hour)
+		if _, err := s.Members.AcceptInvitation(ctx, own.Token, alice); !errors.Is(err, store.ErrConflict) {
+			t.Fatalf("accept as owner err = %v, want ErrConflict", err)
+		}
+
+		if err := s.Members.DeleteInvitation(ctx, work.ID, again.ID); err != nil {
+			t.Fatalf("DeleteInvitation: %v", err)
+		}
+		if err := s.Members.DeleteInvitation(ctx, work.ID, again.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("DeleteInvitation twice err = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Roles", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+		carol := createUser(t, s, "carol")
+
+		work := createProject(t, s, alice.ID, "Work")
+		createProject(t, s, alice.ID, "Private")
+		shared := &store.Todo{UserID: alice.ID, Title: "shared", ProjectID: &work.ID}
+		if err := s.Todos.Create(ctx, shared); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		private := createTodo(t, s, alice.ID, "private")
+
+		inv := createInvitation(t, s, work.ID, alice.ID, bob.Email, store.RoleEditor, time.Hour)
+		if _, err := s.Members.AcceptInvitation(ctx, inv.Token, bob); err != nil {
+			t.Fatalf("AcceptInvitation: %v", err)
+		}
+
+		role := func(userID int64) store.Role {
+			t.Helper()
+			role, err := s.Members.GetRole(ctx, work.ID, userID)
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				t.Fatalf("GetRole: %v", err)
+			}
+			return role
+		}
+		if role(alice.ID) != store.RoleOwner || role(bob.ID) != store.RoleEditor || role(carol.ID) != "" {
+			t.Fatalf("roles = %q, %q, %q", role(alice.ID), role(bob.ID), role(carol.ID))
+		}
+
+		projects, err := s.Projects.GetByUser(ctx, bob.ID, false)
+		if err != nil {
+			t.Fatalf("GetByUser: %v", err)
+		}
+		if len(projects) != 1 || projects[0].ID != work.ID || projects[0].Role != store.RoleEditor {
+			t.Fatalf("shared projects = %+v", projects)
+		}
+		if p, err := s.Projects.GetByID(ctx, bob.ID, work.ID); err != nil || p.Role != store.RoleEditor {
+			t.Fatalf("GetByID by member = %+v, %v", p, err)
+		}
+		if p, err := s.Projects.GetByID(ctx, alice.ID, work.ID); err != nil || p.Role != store.RoleOwner {
+			t.Fatalf("GetByID by owner = %+v, %v", p, err)
+		}
+		if _, err := s.Projects.GetByID(ctx, carol.ID, work.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("GetByID by stranger err = %v, want ErrNotFound", err)
+		}
+		if err := s.Projects.Update(ctx, bob.ID, work.ID, map[string]interface{}{"name": "Mine"}); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Update by member err = %v, want ErrNotFound", err)
+		}
+
+		if got, err := s.Todos.GetProjectTodo(ctx, bob.ID, shared.ID); err != nil || got.ID != shared.ID {
+			t.Fatalf("GetProjectTodo = %+v, %v", got, err)
+		}
+		if _, err := s.Todos.GetProjectTodo(ctx, bob.ID, private.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("GetProjectTodo outside the project err = %v, want ErrNotFound", err)
+		}
+		if _, err := s.Todos.GetProjectTodo(ctx, carol.ID, shared.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("GetProjectTodo by stranger err = %v, want ErrNotFound", err)
+		}
+
+		members, err := s.Members.GetMembers(ctx, work.ID)
+		if err != nil {
+			t.Fatalf("GetMembers: %v", err)
+		}
+		if len(members) != 1 || members[0].UserID != bob.ID || members[0].Username != "bob" || members[0].Role != store.RoleEditor {
+			t.Fatalf("GetMembers = %+v", members)
+		}
+
+		if err := s.Members.UpdateMember(ctx, work.ID, bob.ID, store.RoleViewer); err != nil {
+			t.Fatalf("UpdateMember: %v", err)
+		}
+		if role(bob.ID) != store.RoleViewer {
+			t.Fatalf("role after UpdateMember = %q", role(bob.ID))
+		}
+		if err := s.Members.UpdateMember(ctx, work.ID, carol.ID, store.RoleViewer); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("UpdateMember of non-member err = %v, want ErrNotFound", err)
+		}
+
+		if err := s.Members.RemoveMember(ctx, work.ID, bob.ID); err != nil {
+			t.Fatalf("RemoveMember: %v", err)
+		}
+		if role(bob.ID) != "" {
+			t.Fatalf("role after RemoveMember = %q", role(bob.ID))
+		}
+		if _, err := s.Todos.GetProjectTodo(ctx, bob.ID, shared.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("GetProjectTodo after RemoveMember err = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func testSessions(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+
+	t.Run("Rotation", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		session := createSession(t, s, alice.ID, time.Hour)
+		if session.ID == 0 || session.RefreshToken == "" {
+			t.Fatalf("Create did not set ID or RefreshToken: %+v", session)
+		}
+
+		refreshed, err := s.Sessions.Refresh(ctx, session.RefreshToken, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatalf("Refresh: %v", err)
+		}
+		if refreshed.ID != session.ID || refreshed.UserID != alice.ID {
+			t.Fatalf("Refresh = %+v, want session %d", refreshed, session.ID)
+		}
+		if refreshed.RefreshToken == "" || refreshed.RefreshToken == session.RefreshToken {
+			t.Fatal("Refresh did not rotate the refresh token")
+		}
+
+		if _, err := s.Sessions.Refresh(ctx, "bogus", time.Now().Add(time.Hour)); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Refresh unknown token err = %v, want ErrNotFound", err)
+		}
+		expired := createSession(t, s, alice.ID, -time.Hour)
+		if _, err := s.Sessions.Refresh(ctx, expired.RefreshToken, time.Now().Add(time.Hour)); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Refresh expired token err = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("ReuseRevokesFamily", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		session := createSession(t, s, alice.ID, time.Hour)
+
+		refreshed, err := s.Sessions.Refresh(ctx, session.RefreshToken, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatalf("Refresh: %v", err)
+		}
+		if _, err := s.Sessions.Refresh(ctx, session.RefreshToken, time.Now().Add(time.Hour)); !errors.Is(err, store.ErrTokenReused) {
+			t.Fatalf("Refresh reused token err = %v, want ErrTokenReused", err)
+		}
+
+		got, err := s.Sessions.Get(ctx, session.ID)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if got.RevokedAt == nil {
+			t.Fatal("reusing a refresh token did not revoke its session")
+		}
+		if _, err := s.Sessions.Refresh(ctx, refreshed.RefreshToken, time.Now().Add(time.Hour)); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Refresh newest token of revoked session err = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+		first := createSession(t, s, alice.ID, time.Hour)
+		second := createSession(t, s, alice.ID, time.Hour)
+		other := createSession(t, s, bob.ID, time.Hour)
+
+		if err := s.Sessions.Revoke(ctx, bob.ID, first.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Revoke other user's session err = %v, want ErrNotFound", err)
+		}
+		if err := s.Sessions.Revoke(ctx, alice.ID, first.ID); err != nil {
+			t.Fatalf("Revoke: %v", err)
+		}
+		if err := s.Sessions.Revoke(ctx, alice.ID, first.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Revoke twice err = %v, want ErrNotFound", err)
+		}
+		if _, err := s.Sessions.Refresh(ctx, first.RefreshToken, time.Now().Add(time.Hour)); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Refresh revoked session err = %v, want ErrNotFound", err)
+		}
+
+		if err := s.Sessions.RevokeAll(ctx, alice.ID); err != nil {
+			t.Fatalf("RevokeAll: %v", err)
+		}
+		for _, session := range []*store.Session{second, other} {
+			got, err := s.Sessions.Get(ctx, session.ID)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if revoked := got.RevokedAt != nil; revoked != (session.UserID == alice.ID) {
+				t.Fatalf("session %d of user %d revoked = %v after RevokeAll(%d)", session.ID, session.UserID, revoked, alice.ID)
+			}
+		}
+	})
+}
+
+func testAccessTokens(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+
+	t.Run("CreateAuthenticateDelete", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+
+		ci := createAccessToken(t, s, alice.ID, "ci", store.ScopeWrite, nil, nil)
+		if ci.ID == 0 || !store.IsAccessToken(ci.Token) {
+			t.Fatalf("Create did not set ID or Token: %+v", ci)
+		}
+		past := time.Now().Add(-time.Hour)
+		expired := createAccessToken(t, s, alice.ID, "old", store.ScopeRead, nil, &past)
+
+		got, err := s.AccessTokens.Authenticate(ctx, ci.Token)
+		if err != nil {
+			t.Fatalf("Authenticate: %v", err)
+		}
+		if got.ID != ci.ID || got.UserID != alice.ID || got.Scope != store.ScopeWrite || got.LastUsedAt == nil {
+			t.Fatalf("Authenticate = %+v", got)
+		}
+		if _, err := s.AccessTokens.Authenticate(ctx, expired.Token); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Authenticate expired err = %v, want ErrNotFound", err)
+		}
+		if _, err := s.AccessTokens.Authenticate(ctx, store.AccessTokenPrefix+"bogus"); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Authenticate unknown err = %v, want ErrNotFound", err)
+		}
+
+		tokens, err := s.AccessTokens.GetByUser(ctx, alice.ID)
+		if err != nil {
+			t.Fatalf("GetByUser: %v", err)
+		}
+		if len(tokens) != 2 || tokens[0].ID != expired.ID || tokens[1].ID != ci.ID {
+			t.Fatalf("GetByUser = %+v, want newest first", tokens)
+		}
+		if tokens[0].Token != "" || tokens[1].LastUsedAt == nil {
+			t.Fatalf("GetByUser = %+v, want last use and no secrets", tokens)
+		}
+
+		if err := s.AccessTokens.Delete(ctx, bob.ID, ci.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Delete other user's token err = %v, want ErrNotFound", err)
+		}
+		if err := s.AccessTokens.Delete(ctx, alice.ID, ci.ID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := s.AccessTokens.Authenticate(ctx, ci.Token); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Authenticate deleted err = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("DeleteAll", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+
+		ci := createAccessToken(t, s, alice.ID, "ci", store.ScopeWrite, nil, nil)
+		cli := createAccessToken(t, s, alice.ID, "cli", store.ScopeRead, nil, nil)
+		other := createAccessToken(t, s, bob.ID, "cli", store.ScopeRead, nil, nil)
+
+		if err := s.AccessTokens.DeleteAll(ctx, alice.ID); err != nil {
+			t.Fatalf("DeleteAll: %v", err)
+		}
+		for _, token := range []*store.AccessToken{ci, cli} {
+			if _, err := s.AccessTokens.Authenticate(ctx, token.Token); !errors.Is(err, store.ErrNotFound) {
+				t.Fatalf("Authenticate %q after DeleteAll err = %v, want ErrNotFound", token.Name, err)
+			}
+		}
+		if _, err := s.AccessTokens.Authenticate(ctx, other.Token); err != nil {
+			t.Fatalf("Authenticate other user's token after DeleteAll: %v", err)
+		}
+		if err := s.AccessTokens.DeleteAll(ctx, alice.ID); err != nil {
+			t.Fatalf("DeleteAll with no tokens: %v", err)
+		}
+	})
+
+	t.Run("ProjectTokens", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		work := createProject(t, s, alice.ID, "Work")
+		home := createProject(t, s, alice.ID, "Home")
+
+		token := createAccessToken(t, s, alice.ID, "work", store.ScopeRead, &work.ID, nil)
+		got, err := s.AccessTokens.Authenticate(ctx, token.Token)
+		if err != nil {
+			t.Fatalf("Authenticate: %v", err)
+		}
+		if got.ProjectID == nil || *got.ProjectID != work.ID {
+			t.Fatalf("Authenticate ProjectID = %v, want %d", got.ProjectID, work.ID)
+		}
+		if !got.AllowsProject(&work.ID) || got.AllowsProject(&home.ID) || got.AllowsProject(nil) {
+			t.Fatal("project token reaches projects other than its own")
+		}
+
+		if err := s.Projects.Delete(ctx, alice.ID, work.ID); err != nil {
+			t.Fatalf("Delete project: %v", err)
+		}
+		if _, err := s.AccessTokens.Authenticate(ctx, token.Token); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Authenticate after project delete err = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func testUserTokens(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+
+	t.Run("CreateConsume", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+
+		reset := createUserToken(t, s, alice.ID, store.PurposeResetPassword, time.Hour)
+		if reset.ID == 0 || reset.Token == "" {
+			t.Fatalf("Create did not set ID or Token: %+v", reset)
+		}
+
+		if _, err := s.UserTokens.Consume(ctx, store.PurposeVerifyEmail, reset.Token); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Consume for another purpose err = %v, want ErrNotFound", err)
+		}
+		userID, err := s.UserTokens.Consume(ctx, store.PurposeResetPassword, reset.Token)
+		if err != nil {
+			t.Fatalf("Consume: %v", err)
+		}
+		if userID != alice.ID {
+			t.Fatalf("Consume = %d, want %d", userID, alice.ID)
+		}
+		if _, err := s.UserTokens.Consume(ctx, store.PurposeResetPassword, reset.Token); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Consume twice err = %v, want ErrNotFound", err)
+		}
+
+		expired := createUserToken(t, s, alice.ID, store.PurposeVerifyEmail, -time.Hour)
+		if _, err := s.UserTokens.Consume(ctx, store.PurposeVerifyEmail, expired.Token); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Consume expired err = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("NewestOnly", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+
+		first := createUserToken(t, s, alice.ID, store.PurposeVerifyEmail, time.Hour)
+		reset := createUserToken(t, s, alice.ID, store.PurposeResetPassword, time.Hour)
+		second := createUserToken(t, s, alice.ID, store.PurposeVerifyEmail, time.Hour)
+
+		if _, err := s.UserTokens.Consume(ctx, store.PurposeVerifyEmail, first.Token); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Consume replaced token err = %v, want ErrNotFound", err)
+		}
+		for _, token := range []*store.UserToken{second, reset} {
+			if _, err := s.UserTokens.Consume(ctx, token.Purpose, token.Token); err != nil {
+				t.Fatalf("Consume %s: %v", token.Purpose, err)
+			}
+		}
+	})
+
+	t.Run("DeleteAll", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+
+		verify := createUserToken(t, s, alice.ID, store.PurposeVerifyEmail, time.Hour)
+		reset := createUserToken(t, s, alice.ID, store.PurposeResetPassword, time.Hour)
+		other := createUserToken(t, s, bob.ID, store.PurposeResetPassword, time.Hour)
+
+		if err := s.UserTokens.DeleteAll(ctx, alice.ID); err != nil {
+			t.Fatalf("DeleteAll: %v", err)
+		}
+		for _, token := range []*store.UserToken{verify, reset} {
+			if _, err := s.UserTokens.Consume(ctx, token.Purpose, token.Token); !errors.Is(err, store.ErrNotFound) {
+				t.Fatalf("Consume %s after DeleteAll err = %v, want ErrNotFound", token.Purpose, err)
+			}
+		}
+		if _, err := s.UserTokens.Consume(ctx, other.Purpose, other.Token); err != nil {
+			t.Fatalf("Consume other user's token after DeleteAll: %v", err)
+		}
+		if err := s.UserTokens.DeleteAll(ctx, alice.ID); err != nil {
+			t.Fatalf("DeleteAll with no tokens: %v", err)
+		}
+	})
+}
+
+func testTOTP(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+
+	t.Run("EnrollConfirm", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+
+		if _, err := s.TOTP.Get(ctx, alice.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Get before Enroll err = %v, want ErrNotFound", err)
+		}
+		if err := s.TOTP.Enroll(ctx, alice.ID, []byte("first secret")); err != nil {
+			t.Fatalf("Enroll: %v", err)
+		}
+		if err := s.TOTP.Enroll(ctx, alice.ID, []byte("second secret")); err != nil {
+			t.Fatalf("Enroll again: %v", err)
+		}
+		if err := s.TOTP.UseStep(ctx, alice.ID, 10); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("UseStep unconfirmed err = %v, want ErrNotFound", err)
+		}
+
+		codes, err := s.TOTP.Confirm(ctx, alice.ID, 10)
+		if err != nil {
+			t.Fatalf("Confirm: %v", err)
+		}
+		if len(codes) != store.RecoveryCodeCount {
+			t.Fatalf("Confirm returned %d codes, want %d", len(codes), store.RecoveryCodeCount)
+		}
+		if _, err := s.TOTP.Confirm(ctx, alice.ID, 11); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Confirm twice err = %v, want ErrNotFound", err)
+		}
+		if err := s.TOTP.Enroll(ctx, alice.ID, []byte("third secret")); !errors.Is(err, store.ErrConflict) {
+			t.Fatalf("Enroll confirmed err = %v, want ErrConflict", err)
+		}
+
+		totp, err := s.TOTP.Get(ctx, alice.ID)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if string(totp.Secret) != "second secret" || totp.ConfirmedAt == nil || totp.LastUsedStep != 10 {
+			t.Fatalf("Get = %+v", totp)
+		}
+
+		if err := s.TOTP.Delete(ctx, alice.ID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if err := s.TOTP.Delete(ctx, alice.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Delete twice err = %v, want ErrNotFound", err)
+		}
+		if n, err := s.TOTP.CountRecoveryCodes(ctx, alice.ID); err != nil || n != 0 {
+			t.Fatalf("CountRecoveryCodes after Delete = %d, %v; want 0", n, err)
+		}
+	})
+
+	t.Run("UseStep", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		confirmTOTP(t, s, alice.ID, 10)
+
+		if err := s.TOTP.UseStep(ctx, alice.ID, 10); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("UseStep replayed err = %v, want ErrNotFound", err)
+		}
+		if err := s.TOTP.UseStep(ctx, alice.ID, 11); err != nil {
+			t.Fatalf("UseStep: %v", err)
+		}
+		if err := s.TOTP.UseStep(ctx, alice.ID, 9); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("UseStep older err = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("RecoveryCodes", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+		codes := confirmTOTP(t, s, alice.ID, 10)
+
+		if err := s.TOTP.UseRecoveryCode(ctx, bob.ID, codes[0]); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("UseRecoveryCode of another user err = %v, want ErrNotFound", err)
+		}
+		if err := s.TOTP.UseRecoveryCode(ctx, alice.ID, strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))); err != nil {
+			t.Fatalf("UseRecoveryCode: %v", err)
+		}
+		if err := s.TOTP.UseRecoveryCode(ctx, alice.ID, codes[0]); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("UseRecoveryCode twice err = %v, want ErrNotFound", err)
+		}
+		if n, err := s.TOTP.CountRecoveryCodes(ctx, alice.ID); err != nil || n != store.RecoveryCodeCount-1 {
+			t.Fatalf("CountRecoveryCodes = %d, %v; want %d", n, err, store.RecoveryCodeCount-1)
+		}
+
+		fresh, err := s.TOTP.RegenerateRecoveryCodes(ctx, alice.ID)
+		if err != nil {
+			t.Fatalf("RegenerateRecoveryCodes: %v", err)
+		}
+		if err := s.TOTP.UseRecoveryCode(ctx, alice.ID, codes[1]); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("UseRecoveryCode replaced err = %v, want ErrNotFound", err)
+		}
+		if err := s.TOTP.UseRecoveryCode(ctx, alice.ID, fresh[1]); err != nil {
+			t.Fatalf("UseRecoveryCode fresh: %v", err)
+		}
+	})
+}
+
+func testIdentities(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+
+	t.Run("LinkAndUnlink", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+
+		github := &store.Identity{UserID: alice.ID, Provider: "github", Subject: "42", Email: "alice@example.com"}
+		if err := s.Identities.Create(ctx, github); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if github.ID == 0 {
+			t.Fatal("Create did not set ID")
+		}
+		google := &store.Identity{UserID: alice.ID, Provider: "google", Subject: "42"}
+		if err := s.Identities.Create(ctx, google); err != nil {
+			t.Fatalf("Create same subject at another provider: %v", err)
+		}
+		taken := &store.Identity{UserID: bob.ID, Provider: "github", Subject: "42"}
+		if err := s.Identities.Create(ctx, taken); !errors.Is(err, store.ErrConflict) {
+			t.Fatalf("Create linked identity err = %v, want ErrConflict", err)
+		}
+
+		got, err := s.Identities.GetBySubject(ctx, "github", "42")
+		if err != nil {
+			t.Fatalf("GetBySubject: %v", err)
+		}
+		if got.ID != github.ID || got.UserID != alice.ID || got.Email != "alice@example.com" {
+			t.Fatalf("GetBySubject = %+v, want %+v", got, github)
+		}
+		if _, err := s.Identities.GetBySubject(ctx, "github", "43"); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("GetBySubject unknown err = %v, want ErrNotFound", err)
+		}
+
+		identities, err := s.Identities.GetByUser(ctx, alice.ID)
+		if err != nil {
+			t.Fatalf("GetByUser: %v", err)
+		}
+		if len(identities) != 2 || identities[0].ID != github.ID || identities[1].ID != google.ID {
+			t.Fatalf("GetByUser = %+v, want github then google", identities)
+		}
+
+		if err := s.Identities.Delete(ctx, bob.ID, github.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Delete by another user err = %v, want ErrNotFound", err)
+		}
+		if err := s.Identities.Delete(ctx, alice.ID, github.ID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if err := s.Identities.Create(ctx, taken); err != nil {
+			t.Fatalf("Create unlinked identity: %v", err)
+		}
+	})
+
+	t.Run("Flows", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+
+		flow := &store.OIDCFlow{
+			Provider:  "github",
+			Nonce:     "nonce",
+			Verifier:  "verifier",
+			UserID:    &alice.ID,
+			ExpiresAt: time.Now().Add(time.Minute),
+		}
+		if err := s.Identities.CreateFlow(ctx, flow); err != nil {
+			t.Fatalf("CreateFlow: %v", err)
+		}
+		if flow.State == "" {
+			t.Fatal("CreateFlow did not set State")
+		}
+
+		if _, err := s.Identities.ConsumeFlow(ctx, "google", flow.State); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("ConsumeFlow at another provider err = %v, want ErrNotFound", err)
+		}
+		got, err := s.Identities.ConsumeFlow(ctx, "github", flow.State)
+		if err != nil {
+			t.Fatalf("ConsumeFlow: %v", err)
+		}
+		if got.Nonce != "nonce" || got.Verifier != "verifier" || got.UserID == nil || *got.UserID != alice.ID {
+			t.Fatalf("ConsumeFlow = %+v", got)
+		}
+		if _, err := s.Identities.ConsumeFlow(ctx, "github", flow.State); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("ConsumeFlow twice err = %v, want ErrNotFound", err)
+		}
+
+		expired := &store.OIDCFlow{Provider: "github", Nonce: "n", Verifier: "v", ExpiresAt: time.Now().Add(-time.Minute)}
+		if err := s.Identities.CreateFlow(ctx, expired); err != nil {
+			t.Fatalf("CreateFlow expired: %v", err)
+		}
+		if _, err := s.Identities.ConsumeFlow(ctx, "github", expired.State); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("ConsumeFlow expired err = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func testAudit(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+
+	t.Run("CreateList", func(t *testing.T) {
+		s := newStorage(t)
+		admin, alice, bob := int64(1), int64(2), int64(3)
+
+		entries := []*store.AuditEntry{
+			{ActorID: &admin, Action: "user.suspend", TargetUserID: &alice, Details: json.RawMessage(`{"reason":"spam"}`)},
+			{ActorID: &admin, Action: "user.suspend", TargetUserID: &bob},
+			{Action: "user.set_role", TargetUserID: &alice},
+		}
+		for _, e := range entries {
+			if err := s.Audit.Create(ctx, e); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if e.ID == 0 || e.CreatedAt.IsZero() {
+				t.Fatalf("Create did not set ID and CreatedAt: %+v", e)
+			}
+		}
+
+		list := func(f store.AuditFilter) []int64 {
+			t.Helper()
+			got, err := s.Audit.List(ctx, f)
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			var ids []int64
+			for _, e := range got {
+				ids = append(ids, e.ID)
+			}
+			return ids
+		}
+
+		all := list(store.AuditFilter{Limit: 10})
+		if !reflect.DeepEqual(all, []int64{entries[2].ID, entries[1].ID, entries[0].ID}) {
+			t.Fatalf("List = %v, want newest first", all)
+		}
+		if got := list(store.AuditFilter{Limit: 10, ActorID: &admin}); !reflect.DeepEqual(got, []int64{entries[1].ID, entries[0].ID}) {
+			t.Fatalf("List by actor = %v", got)
+		}
+		if got := list(store.AuditFilter{Limit: 10, TargetUserID: &alice}); !reflect.DeepEqual(got, []int64{entries[2].ID, entries[0].ID}) {
+			t.Fatalf("List by target = %v", got)
+		}
+		if got := list(store.AuditFilter{Limit: 1, Before: entries[2].ID}); !reflect.DeepEqual(got, []int64{entries[1].ID}) {
+			t.Fatalf("List before = %v", got)
+		}
+
+		got, err := s.Audit.List(ctx, store.AuditFilter{Limit: 10, TargetUserID: &bob})
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(got) != 1 || string(got[0].Details) != "{}" || got[0].ActorID == nil || *got[0].ActorID != admin {
+			t.Fatalf("List = %+v", got)
+		}
+		got, _ = s.Audit.List(ctx, store.AuditFilter{Limit: 1})
+		if got[0].ActorID != nil {
+			t.Fatalf("entry without actor = %+v", got[0])
+		}
+	})
+}
+
+func testTodoHistory(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+
+	t.Run("Versions", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+		todo := createTodo(t, s, alice.ID, "draft", "a")
+		other := createTodo(t, s, bob.ID, "other")
+
+		record := func(actorID *int64, action store.TodoAction, before, after *store.Todo) *store.TodoVersion {
+			t.Helper()
+			changes, err := store.DiffTodos(before, after)
+			if err != nil {
+				t.Fatalf("DiffTodos: %v", err)
+			}
+			v := &store.TodoVersion{ActorID: actorID, Action: action, Changes: changes}
+			if after != nil {
+				v.Todo = *after
+			} else {
+				v.Todo = *before
+			}
+			if err := s.TodoHistory.Create(ctx, v); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if v.ID == 0 || v.CreatedAt.IsZero() || v.TodoID != v.Todo.ID || v.UserID != v.Todo.UserID {
+				t.Fatalf("Create did not fill in the version: %+v", v)
+			}
+			return v
+		}
+
+		created := record(&alice.ID, store.TodoCreated, nil, todo)
+		if created.Version != 1 || len(created.Changes) == 0 {
+			t.Fatalf("first version = %+v", created)
+		}
+
+		dueAt := time.Date(2030, 1, 2, 3, 0, 0, 0, time.UTC)
+		setTodo(t, s, alice.ID, todo.ID, map[string]interface{}{"title": "final", "due_at": dueAt})
+		updated, err := s.Todos.GetTodoByID(ctx, alice.ID, todo.ID)
+		if err != nil {
+			t.Fatalf("GetTodoByID: %v", err)
+		}
+		changed := record(nil, store.TodoUpdated, todo, updated)
+		if changed.Version != 2 {
+			t.Fatalf("second version = %d, want 2", changed.Version)
+		}
+		var names []string
+		for name := range changed.Changes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, []string{"dueAt", "title"}) {
+			t.Fatalf("changes = %v, want dueAt and title", names)
+		}
+		if c := changed.Changes["title"]; string(c.From) != `"draft"` || string(c.To) != `"final"` {
+			t.Fatalf("title change = %s -> %s", c.From, c.To)
+		}
+		record(&bob.ID, store.TodoCreated, nil, other)
+
+		got, err := s.TodoHistory.Get(ctx, todo.ID, 2)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if got.Todo.Title != "final" || got.Todo.DueAt == nil || !got.Todo.DueAt.Equal(dueAt) || got.ActorID != nil {
+			t.Fatalf("Get = %+v", got)
+		}
+		if c := got.Changes["title"]; string(c.To) != `"final"` {
+			t.Fatalf("stored title change = %+v", got.Changes)
+		}
+		if _, err := s.TodoHistory.Get(ctx, todo.ID, 3); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Get missing version err = %v, want ErrNotFound", err)
+		}
+
+		list := func(f store.TodoHistoryFilter) []int {
+			t.Helper()
+			versions, err := s.TodoHistory.List(ctx, f)
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			var numbers []int
+			for _, v := range versions {
+				numbers = append(numbers, v.Version)
+			}
+			return numbers
+		}
+		if got := list(store.TodoHistoryFilter{TodoID: &todo.ID, Limit: 10}); !reflect.DeepEqual(got, []int{2, 1}) {
+			t.Fatalf("List by todo = %v, want newest first", got)
+		}
+		if got := list(store.TodoHistoryFilter{UserID: &bob.ID, Limit: 10}); !reflect.DeepEqual(got, []int{1}) {
+			t.Fatalf("List by user = %v", got)
+		}
+		if got := list(store.TodoHistoryFilter{TodoID: &todo.ID, Limit: 1, Before: changed.ID}); !reflect.DeepEqual(got, []int{1}) {
+			t.Fatalf("List before = %v", got)
+		}
+
+		// Reverting clears dates the old version did not have.
+		setTodo(t, s, alice.ID, todo.ID, map[string]interface{}{"title": "draft", "due_at": nil})
+		reverted, err := s.Todos.GetTodoByID(ctx, alice.ID, todo.ID)
+		if err != nil {
+			t.Fatalf("GetTodoByID: %v", err)
+		}
+		if reverted.DueAt != nil {
+			t.Fatalf("DueAt = %v, want it cleared", reverted.DueAt)
+		}
+		deleted := record(&alice.ID, store.TodoDeleted, reverted, nil)
+		if deleted.Version != 3 || deleted.Todo.ID != todo.ID {
+			t.Fatalf("deletion = %+v", deleted)
+		}
+
+		if err := s.Users.Delete(ctx, bob.ID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if got := list(store.TodoHistoryFilter{UserID: &bob.ID, Limit: 10}); len(got) != 0 {
+			t.Fatalf("history of deleted user = %v", got)
+		}
+	})
+}
+
+func testDataRequests(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+
+	t.Run("Lifecycle", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+
+		export := &store.DataRequest{UserID: alice.ID, Kind: store.DataExport}
+		if err := s.DataRequests.Create(ctx, export); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if export.ID == 0 || export.Status != store.DataRequestPending {
+			t.Fatalf("Create = %+v", export)
+		}
+		if err := s.DataRequests.Create(ctx, &store.DataRequest{UserID: alice.ID, Kind: store.DataExport}); !errors.Is(err, store.ErrConflict) {
+			t.Fatalf("Create second export err = %v, want ErrConflict", err)
+		}
+		erasure := &store.DataRequest{UserID: alice.ID, Kind: store.DataErasure}
+		if err := s.DataRequests.Create(ctx, erasure); err != nil {
+			t.Fatalf("Create erasure: %v", err)
+		}
+
+		claimed, err := s.DataRequests.ClaimPending(ctx, time.Now().Add(-time.Hour), 10)
+		if err != nil {
+			t.Fatalf("ClaimPending: %v", err)
+		}
+		if len(claimed) != 2 || claimed[0].Status != store.DataRequestRunning {
+			t.Fatalf("ClaimPending = %+v, want both running", claimed)
+		}
+		if claimed, _ := s.DataRequests.ClaimPending(ctx, time.Now().Add(-time.Hour), 10); len(claimed) != 0 {
+			t.Fatalf("ClaimPending claimed running requests again: %+v", claimed)
+		}
+		if claimed, _ := s.DataRequests.ClaimPending(ctx, time.Now().Add(time.Hour), 1); len(claimed) != 1 {
+			t.Fatalf("ClaimPending stale = %+v, want one", claimed)
+		}
+
+		expiresAt := time.Now().Add(time.Hour)
+		if err := s.DataRequests.Complete(ctx, export.ID, []byte("zip"), expiresAt); err != nil {
+			t.Fatalf("Complete: %v", err)
+		}
+		if err := s.DataRequests.Fail(ctx, erasure.ID, "boom"); err != nil {
+			t.Fatalf("Fail: %v", err)
+		}
+		if err := s.DataRequests.Fail(ctx, erasure.ID, "boom"); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Fail finished request err = %v, want ErrNotFound", err)
+		}
+
+		archive, err := s.DataRequests.GetArchive(ctx, export.ID, time.Now())
+		if err != nil || string(archive) != "zip" {
+			t.Fatalf("GetArchive = %q, %v", archive, err)
+		}
+		if _, err := s.DataRequests.GetArchive(ctx, erasure.ID, time.Now()); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("GetArchive of erasure err = %v, want ErrNotFound", err)
+		}
+		if _, err := s.DataRequests.GetArchive(ctx, export.ID, expiresAt.Add(time.Second)); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("GetArchive expired err = %v, want ErrNotFound", err)
+		}
+
+		requests, err := s.DataRequests.GetByUser(ctx, alice.ID)
+		if err != nil {
+			t.Fatalf("GetByUser: %v", err)
+		}
+		if len(requests) != 2 || requests[0].ID != erasure.ID || requests[0].Error != "boom" ||
+			requests[1].Status != store.DataRequestReady || requests[1].ExpiresAt == nil {
+			t.Fatalf("GetByUser = %+v", requests)
+		}
+
+		if err := s.DataRequests.Create(ctx, &store.DataRequest{UserID: alice.ID, Kind: store.DataExport}); err != nil {
+			t.Fatalf("Create after export finished: %v", err)
+		}
+		n, err := s.DataRequests.DeleteExpired(ctx, expiresAt.Add(time.Second))
+		if err != nil || n != 1 {
+			t.Fatalf("DeleteExpired = %d, %v; want 1", n, err)
+		}
+		if requests, _ := s.DataRequests.GetByUser(ctx, alice.ID); len(requests) != 2 {
+			t.Fatalf("GetByUser after DeleteExpired = %+v", requests)
+		}
+	})
+}
+
+func testReminders(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+
+	t.Run("CreateListDelete", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		bob := createUser(t, s, "bob")
+		todo := createTodo(t, s, alice.ID, "with reminders")
+
+		remindAt := time.Date(2030, 1, 1, 8, 0, 0, 0, time.UTC)
+		second := createReminder(t, s, alice.ID, todo.ID, remindAt.Add(time.Hour))
+		first := createReminder(t, s, alice.ID, todo.ID, remindAt)
+
+		reminders, err := s.Reminders.GetByTodo(ctx, alice.ID, todo.ID)
+		if err != nil {
+			t.Fatalf("GetByTodo: %v", err)
+		}
+		if len(reminders) != 2 || reminders[0].ID != first.ID || reminders[1].ID != second.ID {
+			t.Fatalf("GetByTodo = %+v, want earliest first", reminders)
+		}
+		if !reminders[0].RemindAt.Equal(remindAt) || reminders[0].FiredAt != nil {
+			t.Fatalf("reminder did not round trip: %+v", reminders[0])
+		}
+
+		if reminders, _ := s.Reminders.GetByTodo(ctx, bob.ID, todo.ID); len(reminders) != 0 {
+			t.Fatalf("another user sees %d reminders", len(reminders))
+		}
+		if err := s.Reminders.Delete(ctx, bob.ID, todo.ID, first.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("cross-user Delete err = %v, want ErrNotFound", err)
+		}
+		if err := s.Reminders.Delete(ctx, alice.ID, todo.ID, first.ID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if err := s.Reminders.Delete(ctx, alice.ID, todo.ID, first.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("second Delete err = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("ClaimDue", func(t *testing.T) {
+		s := newStorage(t)
+		alice := createUser(t, s, "alice")
+		todo := createTodo(t, s, alice.ID, "ping me")
+		gone := createTodo(t, s, alice.ID, "deleted")
+
+		now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+		due := createReminder(t, s, alice.ID, todo.ID, now.Add(-time.Minute))
+		createReminder(t, s, alice.ID, todo.ID, now.Add(time.Minute))
+		createReminder(t, s, alice.ID, gone.ID, now.Add(-time.Hour))
+		if err := s.Todos.DeleteTodo(ctx, alice.ID, gone.ID); err != nil {
+			t.Fatalf("DeleteTodo: %v", err)
+		}
+
+		claimed, err := s.Reminders.ClaimDue(ctx, now, 10)
+		if err != nil {
+			t.Fatalf("ClaimDue: %v", err)
+		}
+		if len(claimed) != 1 || claimed[0].ID != due.ID || claimed[0].FiredAt == nil {
+			t.Fatalf("ClaimDue = %+v, want only reminder %d marked fired", claimed, due.ID)
+		}
+
+		claimed, err = s.Reminders.ClaimDue(ctx, now, 10)
+		if err != nil {
+			t.Fatalf("ClaimDue: %v", err)
+		}
+		if len(claimed) != 0 {
+			t.Fatalf("reminders claimed twice: %+v", claimed)
+		}
+	})
+}
+
+func createProject(t *testing.T, s store.Storage, userID int64, name string) *store.Project {
+	t.Helper()
+
+	p := &store.Project{UserID: userID, Name: name, Color: "#336699"}
+	if err := s.Projects.Create(context.Background(), p); err != nil {
+		t.Fatalf("create project %q: %v", name, err)
+	}
+	return p
+}
+
+func createInvitation(t *testing.T, s store.Storage, projectID, invitedBy int64, email string, role store.Role, ttl time.Duration) *store.Invitation {
+	t.Helper()
+
+	inv := &store.Invitation{
+		ProjectID: projectID,
+		Email:     email,
+		Role:      role,
+		InvitedBy: invitedBy,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := s.Members.CreateInvitation(context.Background(), inv); err != nil {
+		t.Fatalf("create invitation for %q: %v", email, err)
+	}
+	return inv
+}
+
+func createSession(t *testing.T, s store.Storage, userID int64, ttl time.Duration) *store.Session {
+	t.Helper()
+
+	session := &store.Session{UserID: userID, RefreshExpiresAt: time.Now().Add(ttl)}
+	if err := s.Sessions.Create(context.Background(), session); err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	return session
+}
+
+func createAccessToken(t *testing.T, s store.Storage, userID int64, name string, scope store.TokenScope, projectID *int64, expiresAt *time.Time) *store.AccessToken {
+	t.Helper()
+
+	token := &store.AccessToken{UserID: userID, Name: name, Scope: scope, ProjectID: projectID, ExpiresAt: expiresAt}
+	if err := s.AccessTokens.Create(context.Background(), token); err != nil {
+		t.Fatalf("create access token %q: %v", name, err)
+	}
+	return token
+}
+
+func createUserToken(t *testing.T, s store.Storage, userID int64, purpose store.TokenPurpose, ttl time.Duration) *store.UserToken {
+	t.Helper()
+
+	token := &store.UserToken{UserID: userID, Purpose: purpose, ExpiresAt: time.Now().Add(ttl)}
+	if err := s.UserTokens.Create(context.Background(), token); err != nil {
+		t.Fatalf("create %s token: %v", purpose, err)
+	}
+	return token
+}
+
+func confirmTOTP(t *testing.T, s store.Storage, userID, step int64) []string {
+	t.Helper()
+
+	if err := s.TOTP.Enroll(context.Background(), userID, []byte("secret")); err != nil {
+		t.Fatalf("enroll totp: %v", err)
+	}
+	codes, err := s.TOTP.Confirm(context.Background(), userID, step)
+	if err != nil {
+		t.Fatalf("confirm totp: %v", err)
+	}
+	return codes
+}
+
+func createReminder(t *testing.T, s store.Storage, userID, todoID int64, at time.Time) *store.Reminder {
+	t.Helper()
+
+	r := &store.Reminder{UserID: userID, TodoID: todoID, RemindAt: at}
+	if err := s.Reminders.Create(context.Background(), r); err != nil {
+		t.Fatalf("create reminder: %v", err)
+	}
+	return r
+}
+
+func setTodo(t *testing.T, s store.Storage, userID, todoID int64, updates map[string]interface{}) {
+	t.Helper()
+
+	if err := s.Todos.UpdateTodo(context.Background(), userID, todoID, updates); err != nil {
+		t.Fatalf("update todo %d: %v", todoID, err)
+	}
+}
+
+func createSubtask(t *testing.T, s store.Storage, userID, parentID int64, title string) *store.Todo {
+	t.Helper()
+
+	todo := &store.Todo{UserID: userID, Title: title, ParentID: &parentID}
+	if err := s.Todos.Create(context.Background(), todo); err != nil {
+		t.Fatalf("create subtask %q: %v", title, err)
+	}
+	return todo
+}
+
+func newUser(t *testing.T, username string) *store.User {
+	t.Helper()
+
+	user := &store.User{Username: username, Email: username + "@example.com"}
+	user.Password.Hash = hashPassword(t, "password123")
+	return user
+}
+
+// hashPassword hashes text as cheaply as bcrypt allows.
+func hashPassword(t *testing.T, text string) []byte {
+	t.Helper()
+
+	hash, err := password.Bcrypt{Cost: bcrypt.MinCost}.Hash(text)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	return []byte(hash)
+}
+
+func createUser(t *testing.T, s store.Storage, username string) *store.User {
+	t.Helper()
+
+	user := newUser(t, username)
+	if err := s.Users.Create(context.Background(), user); err != nil {
+		t.Fatalf("create user %q: %v", username, err)
+	}
+	return user
+}
+
+func createTodo(t *testing.T, s store.Storage, userID int64, title string, tags ...string) *store.Todo {
+	t.Helper()
+
+	todo := &store.Todo{
+		UserID:      userID,
+		Title:       title,
+		Description: "about " + title,
+		Priority:    2,
+		Tags:        tags,
+	}
+	if err := s.Todos.Create(context.Background(), todo); err != nil {
+		t.Fatalf("create todo %q: %v", title, err)
+	}
+	return todo
+}
@@ -0,0 +1,170 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+var (
+	ErrInvalidParent = errors.New("parent todo not found")
+	ErrCycle         = errors.New("a todo cannot be moved under itself or one of its subtasks")
+)
+
+// TodoNode is a todo together with its subtasks.
+type TodoNode struct {
+	Todo
+	Children []*TodoNode `json:"children"`
+}
+
+// BuildTodoTree nests the todos returned by GetSubtree under their parents
+// and returns the node of rootID, or nil if it is not among them.
+func BuildTodoTree(todos []Todo, rootID int64) *TodoNode {
+	nodes := make(map[int64]*TodoNode, len(todos))
+	for _, todo := range todos {
+		nodes[todo.ID] = &TodoNode{Todo: todo, Children: []*TodoNode{}}
+	}
+
+	// todos are in creation order, so children keep that order too.
+	for _, todo := range todos {
+		if todo.ID == rootID || todo.ParentID == nil {
+			continue
+		}
+		if parent, ok := nodes[*todo.ParentID]; ok {
+			parent.Children = append(parent.Children, nodes[todo.ID])
+		}
+	}
+
+	return nodes[rootID]
+}
+
+// subtreeSQL selects columns of the todo $1 followed by all of its
+// descendants owned by user $2 that are not in the trash, oldest first. The
+// query is the same for Postgres and SQLite.
+func subtreeSQL(columns string) string {
+	return `
+    WITH RECURSIVE subtree (id) AS (
+      SELECT id FROM todos WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+      UNION
+      SELECT t.id FROM todos t JOIN subtree s ON t.parent_id = s.id WHERE t.user_id = $2 AND t.deleted_at IS NULL
+    )
+    SELECT ` + columns + `
+    FROM todos
+    WHERE id IN (SELECT id FROM subtree)
+    ORDER BY id <> $1, created_at ASC, id ASC
+    `
+}
+
+// checkTodoParent returns ErrInvalidParent unless parentID is nil or names a
+// todo owned by userID that is not in the trash.
+func checkTodoParent(ctx context.Context, db interface {
+	QueryRowContext(context.Context, string, ...any) *sql.Row
+}, userID int64, parentID *int64) error {
+	if parentID == nil {
+		return nil
+	}
+
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM todos WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)`
+	if err := db.QueryRowContext(ctx, query, *parentID, userID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrInvalidParent
+	}
+	return nil
+}
+
+// countOpenSubtasks counts the direct subtasks of parentID that are neither
+// completed nor in the trash.
+func countOpenSubtasks(ctx context.Context, db *sql.DB, userID, parentID int64) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `SELECT COUNT(*) FROM todos WHERE parent_id = $1 AND user_id = $2 AND NOT completed AND deleted_at IS NULL`
+	var n int
+	err := db.QueryRowContext(ctx, query, parentID, userID).Scan(&n)
+	return n, err
+}
+
+// moveTodo reparents todoID under parentID, or makes it a top-level todo when
+// parentID is nil. The move is made first and the new parent's ancestors
+// checked afterwards within the same transaction; lock, if set, serializes
+// concurrent moves on backends that need it.
+func moveTodo(ctx context.Context, db *sql.DB, lock func(*sql.Tx) error, userID, todoID int64, parentID *int64, updatedAt any) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if lock != nil {
+		if err := lock(tx); err != nil {
+			return err
+		}
+	}
+
+	if err := checkTodoParent(ctx, tx, userID, parentID); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE todos SET parent_id = $1, updated_at = $2 WHERE id = $3 AND user_id = $4 AND deleted_at IS NULL`,
+		parentID, updatedAt, todoID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+
+	if parentID != nil {
+		// UNION rather than UNION ALL stops the walk if it does loop.
+		query := `
+      WITH RECURSIVE ancestors (id) AS (
+        SELECT CAST($1 AS BIGINT)
+        UNION
+        SELECT t.parent_id FROM todos t JOIN ancestors a ON t.id = a.id WHERE t.parent_id IS NOT NULL
+      )
+      SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $2)
+      `
+		var cycle bool
+		if err := tx.QueryRowContext(ctx, query, *parentID, todoID).Scan(&cycle); err != nil {
+			return err
+		}
+		if cycle {
+			return ErrCycle
+		}
+
+		// Subtasks are in their parent's project, so the moved todos join
+		// the new parent's.
+		if err := setSubtreeProject(ctx, tx, todoID, *parentID, updatedAt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// setSubtreeProject puts todoID and all of its subtasks, trashed ones
+// included, in the project of the todo projectOf.
+func setSubtreeProject(ctx context.Context, tx *sql.Tx, todoID, projectOf int64, updatedAt any) error {
+	query := `
+    WITH RECURSIVE subtree (id) AS (
+      SELECT CAST($1 AS BIGINT)
+      UNION
+      SELECT t.id FROM todos t JOIN subtree s ON t.parent_id = s.id
+    )
+    UPDATE todos SET project_id = (SELECT project_id FROM todos WHERE id = $2), updated_at = $3
+    WHERE id IN (SELECT id FROM subtree)
+      AND project_id IS DISTINCT FROM (SELECT project_id FROM todos WHERE id = $2)
+    `
+	_, err := tx.ExecContext(ctx, query, todoID, projectOf, updatedAt)
+	return err
+}
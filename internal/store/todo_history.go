@@ -0,0 +1,239 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TodoAction is what a version of a todo records.
+type TodoAction string
+
+const (
+	TodoCreated  TodoAction = "create"
+	TodoUpdated  TodoAction = "update"
+	TodoDeleted  TodoAction = "delete"
+	TodoReverted TodoAction = "revert"
+)
+
+// FieldChange is the JSON value of a todo field before and after a change.
+type FieldChange struct {
+	From json.RawMessage `json:"from"`
+	To   json.RawMessage `json:"to"`
+}
+
+// TodoVersion is one entry in the history of a todo. Versions of a todo are
+// numbered from 1 in the order they were made.
+type TodoVersion struct {
+	ID     int64 `json:"id"`
+	TodoID int64 `json:"todoID"`
+	// UserID is the todo's owner; ActorID whoever made the change, or nil
+	// for changes made by the service itself.
+	UserID  int64      `json:"userID"`
+	Version int        `json:"version"`
+	ActorID *int64     `json:"actorID"`
+	Action  TodoAction `json:"action"`
+	// Changes maps the JSON names of the fields that changed to their old
+	// and new values.
+	Changes map[string]FieldChange `json:"changes"`
+	// Todo is the todo as it was after the change, or before it for a
+	// deletion.
+	Todo      Todo      `json:"todo"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TodoHistoryFilter narrows and pages the versions returned by List, newest
+// first.
+type TodoHistoryFilter struct {
+	TodoID *int64
+	UserID *int64
+	// Before is the ID of the last version of the previous page.
+	Before int64
+	Limit  int
+}
+
+// todoHistoryIgnored are the fields of a todo that are not tracked: they
+// identify the todo or change with every version anyway.
+var todoHistoryIgnored = map[string]bool{"id": true, "userID": true, "createdAt": true, "updatedAt": true}
+
+// DiffTodos returns the fields that differ between before and after, keyed
+// by their JSON names. A nil todo has every field null.
+func DiffTodos(before, after *Todo) (map[string]FieldChange, error) {
+	from, err := todoFields(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := todoFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	null := json.RawMessage("null")
+	changes := make(map[string]FieldChange)
+	for name := range mergeKeys(from, to) {
+		if todoHistoryIgnored[name] {
+			continue
+		}
+		f, ok := from[name]
+		if !ok {
+			f = null
+		}
+		t, ok := to[name]
+		if !ok {
+			t = null
+		}
+		if !bytes.Equal(f, t) {
+			changes[name] = FieldChange{From: f, To: t}
+		}
+	}
+	return changes, nil
+}
+
+func todoFields(todo *Todo) (map[string]json.RawMessage, error) {
+	fields := make(map[string]json.RawMessage)
+	if todo == nil {
+		return fields, nil
+	}
+	data, err := json.Marshal(todo)
+	if err != nil {
+		return nil, err
+	}
+	return fields, json.Unmarshal(data, &fields)
+}
+
+func mergeKeys(a, b map[string]json.RawMessage) map[string]bool {
+	keys := make(map[string]bool, len(a))
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	return keys
+}
+
+// TodoHistoryStore keeps the history of todos. Its queries are portable, so
+// the SQLite storage uses it too.
+type TodoHistoryStore struct {
+	db *sql.DB
+}
+
+// versionAttempts is how many times Create tries to take the next version
+// number of a todo that others are changing at the same time.
+const versionAttempts = 5
+
+// Create appends v to the history of its todo, numbering it after the
+// todo's latest version.
+func (s *TodoHistoryStore) Create(ctx context.Context, v *TodoVersion) error {
+	if v.Changes == nil {
+		v.Changes = map[string]FieldChange{}
+	}
+	changes, err := json.Marshal(v.Changes)
+	if err != nil {
+		return err
+	}
+	snapshot, err := json.Marshal(v.Todo)
+	if err != nil {
+		return err
+	}
+	v.TodoID, v.UserID, v.CreatedAt = v.Todo.ID, v.Todo.UserID, now()
+
+	query := `
+    INSERT INTO todo_history (todo_id, user_id, version, actor_id, action, changes, snapshot, created_at)
+    VALUES ($1, $2, (SELECT coalesce(max(version), 0) + 1 FROM todo_history WHERE todo_id = $1), $3, $4, $5, $6, $7)
+    ON CONFLICT DO NOTHING
+    RETURNING id, version
+  `
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	for i := 0; i < versionAttempts; i++ {
+		err = s.db.QueryRowContext(ctx, query,
+			v.TodoID, v.UserID, v.ActorID, v.Action, string(changes), string(snapshot), v.CreatedAt,
+		).Scan(&v.ID, &v.Version)
+		if err != sql.ErrNoRows {
+			return err
+		}
+	}
+	return fmt.Errorf("todo %d: %w", v.TodoID, ErrConflict)
+}
+
+func (s *TodoHistoryStore) List(ctx context.Context, f TodoHistoryFilter) ([]TodoVersion, error) {
+	var conds []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if f.TodoID != nil {
+		conds = append(conds, "todo_id = "+arg(*f.TodoID))
+	}
+	if f.UserID != nil {
+		conds = append(conds, "user_id = "+arg(*f.UserID))
+	}
+	if f.Before > 0 {
+		conds = append(conds, "id < "+arg(f.Before))
+	}
+
+	query := `SELECT ` + todoVersionColumns + ` FROM todo_history`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT " + arg(f.Limit)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []TodoVersion{}
+	for rows.Next() {
+		v, err := scanTodoVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, *v)
+	}
+
+	return versions, rows.Err()
+}
+
+// Get returns a version of a todo.
+func (s *TodoHistoryStore) Get(ctx context.Context, todoID int64, version int) (*TodoVersion, error) {
+	query := `SELECT ` + todoVersionColumns + ` FROM todo_history WHERE todo_id = $1 AND version = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	v, err := scanTodoVersion(s.db.QueryRowContext(ctx, query, todoID, version))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return v, err
+}
+
+const todoVersionColumns = `id, todo_id, user_id, version, actor_id, action, changes, snapshot, created_at`
+
+func scanTodoVersion(row interface{ Scan(...any) error }) (*TodoVersion, error) {
+	var v TodoVersion
+	var changes, snapshot []byte
+	if err := row.Scan(&v.ID, &v.TodoID, &v.UserID, &v.Version, &v.ActorID, &v.Action, &changes, &snapshot, &v.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(changes, &v.Changes); err != nil {
+		return nil, fmt.Errorf("todo history %d: changes: %w", v.ID, err)
+	}
+	if err := json.Unmarshal(snapshot, &v.Todo); err != nil {
+		return nil, fmt.Errorf("todo history %d: snapshot: %w", v.ID, err)
+	}
+	return &v, nil
+}
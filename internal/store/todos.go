@@ -12,28 +12,82 @@ import (
 
 // model
 type Todo struct {
-	ID          int64     `json:"id"`
-	UserID      int64     `json:"userID"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Completed   bool      `json:"completed"`
-	Priority    int16     `json:"priority"`
-	Tags        []string  `json:"tags"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   string    `json:"updatedAt"`
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"userID"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Completed   bool       `json:"completed"`
+	Priority    int16      `json:"priority"`
+	Tags        []string   `json:"tags"`
+	StartAt     *time.Time `json:"startAt"`
+	DueAt       *time.Time `json:"dueAt"`
+	Timezone    string     `json:"timezone"`
+	Recurrence  string     `json:"recurrence"`
+	ProjectID   *int64     `json:"projectID"`
+	ParentID    *int64     `json:"parentID"`
+	// CompleteWithChildren marks the todo completed once all of its
+	// subtasks are.
+	CompleteWithChildren bool      `json:"completeWithChildren"`
+	CreatedAt            time.Time `json:"createdAt"`
+	UpdatedAt            string    `json:"updatedAt"`
+	// DeletedAt is when the todo was moved to the trash. Todos in the trash
+	// are left out everywhere but the trash endpoints.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+// Location returns the time zone the todo's dates are meant in, UTC if the
+// todo has none or it is unknown.
+func (t *Todo) Location() *time.Location {
+	loc, err := time.LoadLocation(t.Timezone)
+	if err != nil || t.Timezone == "" {
+		return time.UTC
+	}
+	return loc
 }
 
 type TodosStore struct {
 	db *sql.DB
 }
 
+const todoColumns = `id, user_id, title, description, completed, priority, tags, start_at, due_at, timezone, recurrence, project_id, parent_id, complete_with_children, created_at, updated_at, deleted_at`
+
+// todoScanDest returns the Scan destinations for todoColumns.
+func todoScanDest(todo *Todo) []any {
+	return []any{
+		&todo.ID,
+		&todo.UserID,
+		&todo.Title,
+		&todo.Description,
+		&todo.Completed,
+		&todo.Priority,
+		pq.Array(&todo.Tags),
+		&todo.StartAt,
+		&todo.DueAt,
+		&todo.Timezone,
+		&todo.Recurrence,
+		&todo.ProjectID,
+		&todo.ParentID,
+		&todo.CompleteWithChildren,
+		&todo.CreatedAt,
+		&todo.UpdatedAt,
+		&todo.DeletedAt,
+	}
+}
+
 // create todo
 func (s *TodosStore) Create(ctx context.Context, todo *Todo) error {
-	fmt.Println("what is this", todo)
 	// pq: got 6 parameters but the statement requires 5, had to add user id here, but why?
+	if todo.Timezone == "" {
+		todo.Timezone = "UTC"
+	}
+
+	if err := checkTodoParent(ctx, s.db, todo.UserID, todo.ParentID); err != nil {
+		return err
+	}
+
 	query := `
-	 INSERT INTO todos (user_id, title, description, completed, priority, tags)
-		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at, updated_at
+	 INSERT INTO todos (user_id, title, description, completed, priority, tags, start_at, due_at, timezone, recurrence, project_id, parent_id, complete_with_children)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id, created_at, updated_at
 	`
 	err := s.db.QueryRowContext(
 		ctx,
@@ -44,12 +98,18 @@ func (s *TodosStore) Create(ctx context.Context, todo *Todo) error {
 		todo.Completed,
 		todo.Priority,
 		pq.Array(todo.Tags),
+		todo.StartAt,
+		todo.DueAt,
+		todo.Timezone,
+		todo.Recurrence,
+		todo.ProjectID,
+		todo.ParentID,
+		todo.CompleteWithChildren,
 	).Scan(
 		&todo.ID,
 		&todo.CreatedAt,
 		&todo.UpdatedAt,
 	)
-	fmt.Println(err)
 	if err != nil {
 		return err
 	}
@@ -59,58 +119,130 @@ func (s *TodosStore) Create(ctx context.Context, todo *Todo) error {
 // get all todos
 func (s *TodosStore) GetAllTodos(ctx context.Context, userID int64) ([]Todo, error) {
 	query := `
-      SELECT id, user_id, title, description, completed, priority, tags, created_at, updated_at
+      SELECT ` + todoColumns + `
       FROM todos
-      WHERE user_id = $1
+      WHERE user_id = $1 AND deleted_at IS NULL
       ORDER BY created_at DESC
       `
 
-	rows, err := s.db.QueryContext(ctx, query, userID)
+	return s.queryTodos(ctx, query, userID)
+}
+
+// ListTodos returns one page of the user's todos matching f, along with the
+// total number of matches.
+func (s *TodosStore) ListTodos(ctx context.Context, userID int64, f TodoFilter) (*TodoPage, error) {
+	cursor, cursorValue, err := decodeTodoCursor(f)
+	if err != nil {
+		return nil, err
+	}
+
+	conds, args := todoFilterSQL(f, func(p string) (string, any) {
+		return "tags @> " + p, pq.Array(f.Tags)
+	}, "ILIKE")
+	args = append([]any{userID}, args...)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	page := &TodoPage{}
+	countQuery := "SELECT COUNT(*) FROM todos WHERE " + strings.Join(conds, " AND ")
+	if err := s.db.QueryRowContext(ctx, countQuery, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	if cursor != nil {
+		cond, cursorArgs := todoCursorSQL(f, `"C"`, cursorValue, cursor.ID, len(args))
+		conds = append(conds, cond)
+		args = append(args, cursorArgs...)
+	}
+
+	query := fmt.Sprintf(`
+      SELECT `+todoColumns+`
+      FROM todos
+      WHERE %s
+      ORDER BY %s
+      LIMIT %d
+      `, strings.Join(conds, " AND "), todoOrderSQL(f, `"C"`), f.Limit+1)
+
+	page.Todos, err = s.queryTodos(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(page.Todos) > f.Limit {
+		page.Todos = page.Todos[:f.Limit]
+		page.NextCursor = encodeTodoCursor(f, page.Todos[f.Limit-1])
+	}
+
+	return page, nil
+}
+
+// SearchTodos runs a full-text search over the user's todo titles and
+// descriptions, best matches first.
+func (s *TodosStore) SearchTodos(ctx context.Context, userID int64, q string, limit int) ([]TodoSearchResult, error) {
+	query := `
+      SELECT ` + todoColumns + `,
+        ts_rank(search_vector, query) AS rank,
+        ts_headline('english', title || ' ' || coalesce(description, ''), query,
+          'StartSel=<mark>, StopSel=</mark>, MaxWords=25, MinWords=10') AS snippet
+      FROM todos, websearch_to_tsquery('english', $2) AS query
+      WHERE user_id = $1 AND deleted_at IS NULL AND search_vector @@ query
+      ORDER BY rank DESC, id DESC
+      LIMIT $3
+      `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, q, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var todos []Todo
+	var results []TodoSearchResult
 	for rows.Next() {
-		var todo Todo
-		err := rows.Scan(
-			&todo.ID,
-			&todo.UserID,
-			&todo.Title,
-			&todo.Description,
-			&todo.Completed,
-			&todo.Priority,
-			pq.Array(&todo.Tags),
-			&todo.CreatedAt,
-			&todo.UpdatedAt,
-		)
+		var r TodoSearchResult
+		err := rows.Scan(append(todoScanDest(&r.Todo), &r.Rank, &r.Snippet)...)
 		if err != nil {
 			return nil, err
 		}
-		todos = append(todos, todo)
+		results = append(results, r)
 	}
 
-	if err = rows.Err(); err != nil {
+	return results, rows.Err()
+}
+
+// GetTodoByID only returns todos owned by userID; anything else is ErrNotFound.
+func (s *TodosStore) GetTodoByID(ctx context.Context, userID, todoID int64) (*Todo, error) {
+	query := `
+    SELECT ` + todoColumns + `
+    FROM todos
+    WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+    `
+	var todo Todo
+	err := s.db.QueryRowContext(ctx, query, todoID, userID).Scan(todoScanDest(&todo)...)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
 		return nil, err
 	}
-
-	return todos, nil
+	return &todo, nil
 }
 
-func (s *TodosStore) GetTodoByID(ctx context.Context, todoID int64) (*Todo, error) {
+// GetProjectTodo returns a todo in one of the projects shared with memberID.
+// The todo's owner is someone else, so GetTodoByID does not find it.
+func (s *TodosStore) GetProjectTodo(ctx context.Context, memberID, todoID int64) (*Todo, error) {
 	query := `
-    SELECT id, user_id, title, description, completed, priority, tags, created_at, updated_at
+    SELECT ` + todoColumns + `
     FROM todos
-    WHERE id = $1
+    WHERE id = $1 AND deleted_at IS NULL AND project_id IN (SELECT project_id FROM project_members WHERE user_id = $2)
     `
 	var todo Todo
-	err := s.db.QueryRowContext(ctx, query, todoID).Scan(
-		&todo.ID, &todo.UserID, &todo.Title, &todo.Description, &todo.Completed,
-		&todo.Priority, pq.Array(&todo.Tags), &todo.CreatedAt, &todo.UpdatedAt,
-	)
+	err := s.db.QueryRowContext(ctx, query, todoID, memberID).Scan(todoScanDest(&todo)...)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("no todo found with ID %d", todoID)
+		return nil, ErrNotFound
 	}
 	if err != nil {
 		return nil, err
@@ -118,7 +250,7 @@ func (s *TodosStore) GetTodoByID(ctx context.Context, todoID int64) (*Todo, erro
 	return &todo, nil
 }
 
-func (s *TodosStore) UpdateTodo(ctx context.Context, todoID int64, updates map[string]interface{}) error {
+func (s *TodosStore) UpdateTodo(ctx context.Context, userID, todoID int64, updates map[string]interface{}) error {
 	if len(updates) == 0 {
 		return fmt.Errorf("no fields to update")
 	}
@@ -131,75 +263,158 @@ func (s *TodosStore) UpdateTodo(ctx context.Context, todoID int64, updates map[s
 	for field, value := range updates {
 		// Use double quotes for field names and $n placeholders for values
 		queryFields = append(queryFields, fmt.Sprintf(`"%s" = $%d`, field, argCounter))
-		fmt.Println(fmt.Sprintf(`"%s" = $%d`, field, argCounter))
+		if tags, ok := value.([]string); ok {
+			value = pq.Array(tags)
+		}
 		args = append(args, value)
 		argCounter++
 	}
+	queryFields = append(queryFields, "updated_at = CURRENT_TIMESTAMP")
 
-	fmt.Println(strings.Join(queryFields, ", "), argCounter)
 	// Construct the SQL query
-	query := fmt.Sprintf("UPDATE todos SET %s WHERE id = $%d", strings.Join(queryFields, ", "), argCounter)
-
-	// Append todoID as the final argument for the WHERE clause
-	args = append(args, todoID)
+	query := fmt.Sprintf("UPDATE todos SET %s WHERE id = $%d AND user_id = $%d AND deleted_at IS NULL", strings.Join(queryFields, ", "), argCounter, argCounter+1)
 
-	// Debugging: Print the query and arguments to verify correctness
-	fmt.Println("Generated Query:", query)
-	fmt.Println("Args:", args)
+	// Append todoID and userID as the final arguments for the WHERE clause
+	args = append(args, todoID, userID)
 
 	// Execute the query
-	_, err := s.db.ExecContext(ctx, query, args...)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("error updating todo: %w", err)
 	}
 
-	return nil
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	// Subtasks are in their parent's project, so they follow the todo to
+	// its new one.
+	if _, ok := updates["project_id"]; ok {
+		if err := setSubtreeProject(ctx, tx, todoID, todoID, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 func (s *TodosStore) GetTodosByTag(ctx context.Context, userID int64, tag string) ([]Todo, error) {
 	query := `
-    SELECT id, user_id, title, description, completed, priority, tags, created_at, updated_at
+    SELECT ` + todoColumns + `
     FROM todos
-    WHERE user_id = $1 AND $2 = ANY(tags)
+    WHERE user_id = $1 AND deleted_at IS NULL AND $2 = ANY(tags)
     ORDER BY created_at DESC
     `
-	rows, err := s.db.QueryContext(ctx, query, userID, tag)
+	return s.queryTodos(ctx, query, userID, tag)
+}
+
+// GetTodosDue returns the user's incomplete todos due in [from, to), soonest
+// first. A nil bound leaves that side open.
+func (s *TodosStore) GetTodosDue(ctx context.Context, userID int64, from, to *time.Time) ([]Todo, error) {
+	query := `
+    SELECT ` + todoColumns + `
+    FROM todos
+    WHERE user_id = $1 AND deleted_at IS NULL AND NOT completed AND due_at IS NOT NULL
+      AND ($2::timestamptz IS NULL OR due_at >= $2)
+      AND ($3::timestamptz IS NULL OR due_at < $3)
+    ORDER BY due_at ASC, id ASC
+    `
+	return s.queryTodos(ctx, query, userID, from, to)
+}
+
+// GetSubtree returns the todo followed by all of its subtasks at any depth,
+// oldest first. Use BuildTodoTree to nest them.
+func (s *TodosStore) GetSubtree(ctx context.Context, userID, todoID int64) ([]Todo, error) {
+	todos, err := s.queryTodos(ctx, subtreeSQL(todoColumns), todoID, userID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	if len(todos) == 0 {
+		return nil, ErrNotFound
+	}
+	return todos, nil
+}
 
-	var todos []Todo
-	for rows.Next() {
-		var todo Todo
-		err := rows.Scan(&todo.ID, &todo.UserID, &todo.Title, &todo.Description, &todo.Completed,
-			&todo.Priority, pq.Array(&todo.Tags), &todo.CreatedAt, &todo.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-		todos = append(todos, todo)
+// CountOpenSubtasks counts the direct subtasks of parentID that are not
+// completed yet.
+func (s *TodosStore) CountOpenSubtasks(ctx context.Context, userID, parentID int64) (int, error) {
+	return countOpenSubtasks(ctx, s.db, userID, parentID)
+}
+
+// MoveTodo moves the todo and its subtasks under parentID, and into its
+// project, or to the top level when parentID is nil. Moves that would make a
+// todo its own ancestor fail with ErrCycle.
+func (s *TodosStore) MoveTodo(ctx context.Context, userID, todoID int64, parentID *int64) error {
+	// Moves within one user's todos are serialized, otherwise two concurrent
+	// moves could each pass the cycle check and together create a cycle.
+	lock := func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, userID)
+		return err
 	}
-	return todos, rows.Err()
+	return moveTodo(ctx, s.db, lock, userID, todoID, parentID, time.Now())
+}
+
+// DeleteTodo moves the todo and its subtasks to the trash.
+func (s *TodosStore) DeleteTodo(ctx context.Context, userID, todoID int64) error {
+	return trashTodo(ctx, s.db, userID, todoID, time.Now())
 }
 
-func (s *TodosStore) DeleteTodo(ctx context.Context, todoID int64) error {
+// GetTrash returns the todos in the user's trash, most recently deleted
+// first.
+func (s *TodosStore) GetTrash(ctx context.Context, userID int64) ([]Todo, error) {
 	query := `
-        DELETE FROM todos
-        WHERE id = $1
+    SELECT ` + todoColumns + `
+    FROM todos
+    WHERE user_id = $1 AND deleted_at IS NOT NULL
+    ORDER BY deleted_at DESC, id DESC
     `
-	result, err := s.db.ExecContext(ctx, query, todoID)
-	if err != nil {
-		return err
-	}
+	return s.queryTodos(ctx, query, userID)
+}
 
-	rowsAffected, err := result.RowsAffected()
+func (s *TodosStore) RestoreTodo(ctx context.Context, userID, todoID int64) error {
+	return restoreTodo(ctx, s.db, userID, todoID)
+}
+
+func (s *TodosStore) PurgeTodo(ctx context.Context, userID, todoID int64) error {
+	return purgeTodo(ctx, s.db, userID, todoID)
+}
+
+func (s *TodosStore) EmptyTrash(ctx context.Context, userID int64) (int, error) {
+	return emptyTrash(ctx, s.db, userID)
+}
+
+// PurgeDeleted empties the trash of every user of the todos deleted before
+// before.
+func (s *TodosStore) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	return purgeDeleted(ctx, s.db, before)
+}
+
+func (s *TodosStore) queryTodos(ctx context.Context, query string, args ...any) ([]Todo, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	if rowsAffected == 0 {
-		return err
+	var todos []Todo
+	for rows.Next() {
+		var todo Todo
+		if err := rows.Scan(todoScanDest(&todo)...); err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
 	}
 
-	return nil
+	return todos, rows.Err()
 }
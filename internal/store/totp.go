@@ -0,0 +1,240 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"strings"
+	"time"
+)
+
+// RecoveryCodeCount is how many recovery codes a user gets at a time.
+const RecoveryCodeCount = 10
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTP is a user's authenticator app enrollment. It only protects the
+// account once it has been confirmed.
+type TOTP struct {
+	UserID      int64
+	Secret      []byte
+	ConfirmedAt *time.Time
+	// LastUsedStep is the newest time step a code was accepted for.
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+// TOTPStore keeps TOTP enrollments and recovery codes. Its queries are
+// portable, so the SQLite storage uses it too.
+type TOTPStore struct {
+	db *sql.DB
+}
+
+// Get returns the user's enrollment, confirmed or not.
+func (s *TOTPStore) Get(ctx context.Context, userID int64) (*TOTP, error) {
+	query := `
+    SELECT user_id, secret, confirmed_at, last_used_step, created_at
+    FROM totp_credentials WHERE user_id = $1
+  `
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var t TOTP
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&t.UserID, &t.Secret, &t.ConfirmedAt, &t.LastUsedStep, &t.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Enroll starts an enrollment with secret, replacing an unconfirmed one.
+// Users who already have a confirmed enrollment get ErrConflict.
+func (s *TOTPStore) Enroll(ctx context.Context, userID int64, secret []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var confirmed bool
+	query := `SELECT confirmed_at IS NOT NULL FROM totp_credentials WHERE user_id = $1`
+	err = tx.QueryRowContext(ctx, query, userID).Scan(&confirmed)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return err
+	case confirmed:
+		return ErrConflict
+	default:
+		if _, err := tx.ExecContext(ctx, `DELETE FROM totp_credentials WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+	}
+
+	query = `INSERT INTO totp_credentials (user_id, secret, created_at) VALUES ($1, $2, $3)`
+	if _, err := tx.ExecContext(ctx, query, userID, secret, now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Confirm turns on the user's pending enrollment with the code for step and
+// returns a fresh set of recovery codes. Users without a pending enrollment
+// get ErrNotFound.
+func (s *TOTPStore) Confirm(ctx context.Context, userID, step int64) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+    UPDATE totp_credentials SET confirmed_at = $1, last_used_step = $2
+    WHERE user_id = $3 AND confirmed_at IS NULL
+  `
+	result, err := tx.ExecContext(ctx, query, now(), step, userID)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrNotFound
+	}
+
+	codes, err := replaceRecoveryCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return codes, tx.Commit()
+}
+
+// UseStep records that a code for step was accepted. A step no newer than
+// the last one accepted is ErrNotFound, which stops a code from being used
+// twice.
+func (s *TOTPStore) UseStep(ctx context.Context, userID, step int64) error {
+	query := `
+    UPDATE totp_credentials SET last_used_step = $1
+    WHERE user_id = $2 AND confirmed_at IS NOT NULL AND last_used_step < $1
+  `
+	return execAffectingOne(ctx, s.db, query, step, userID)
+}
+
+// Delete turns off two-factor login for the user and drops their recovery
+// codes.
+func (s *TOTPStore) Delete(ctx context.Context, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM totp_credentials WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes.
+func (s *TOTPStore) RegenerateRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	codes, err := replaceRecoveryCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return codes, tx.Commit()
+}
+
+// UseRecoveryCode uses up one of the user's recovery codes. Unknown and
+// used codes are ErrNotFound.
+func (s *TOTPStore) UseRecoveryCode(ctx context.Context, userID int64, code string) error {
+	query := `
+    UPDATE recovery_codes SET used_at = $1
+    WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL
+  `
+	return execAffectingOne(ctx, s.db, query, now(), userID, hashToken(normalizeRecoveryCode(code)))
+}
+
+// CountRecoveryCodes returns how many of the user's recovery codes are left.
+func (s *TOTPStore) CountRecoveryCodes(ctx context.Context, userID int64) (int, error) {
+	query := `SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var n int
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&n)
+	return n, err
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int64) ([]string, error) {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return nil, err
+	}
+
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt := now()
+	query := `INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, $3)`
+	for _, code := range codes {
+		if _, err := tx.ExecContext(ctx, query, userID, hashToken(normalizeRecoveryCode(code)), createdAt); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// newRecoveryCodes returns RecoveryCodeCount random codes of 50 bits each,
+// written as two groups of five characters to be easy to copy down.
+func newRecoveryCodes() ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode lets users type a code with or without its dash and
+// in either case.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// The queries of the trash are the same for Postgres and SQLite. Deleting a
+// todo moves it to the trash together with its subtasks, all at the same
+// deleted_at, which is how they are later restored together. Purging a
+// todo deletes it for good; its subtasks follow through ON DELETE CASCADE.
+
+// trashTodo moves todoID and its subtasks to the trash at deletedAt.
+func trashTodo(ctx context.Context, db *sql.DB, userID, todoID int64, deletedAt any) error {
+	query := `
+    WITH RECURSIVE subtree (id) AS (
+      SELECT id FROM todos WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+      UNION
+      SELECT t.id FROM todos t JOIN subtree s ON t.parent_id = s.id WHERE t.user_id = $2 AND t.deleted_at IS NULL
+    )
+    UPDATE todos SET deleted_at = $3 WHERE id IN (SELECT id FROM subtree)
+    `
+	return execAffectingOne(ctx, db, query, todoID, userID, deletedAt)
+}
+
+// restoreTodo takes todoID out of the trash along with the subtasks that
+// were deleted with it. A todo whose parent is still in the trash is
+// restored to the top level.
+func restoreTodo(ctx context.Context, db *sql.DB, userID, todoID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+    WITH RECURSIVE subtree (id, deleted_at) AS (
+      SELECT id, deleted_at FROM todos WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+      UNION
+      SELECT t.id, t.deleted_at FROM todos t JOIN subtree s ON t.parent_id = s.id
+      WHERE t.user_id = $2 AND t.deleted_at = s.deleted_at
+    )
+    SELECT id FROM subtree
+    `
+	rows, err := tx.QueryContext(ctx, query, todoID, userID)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return ErrNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `
+    UPDATE todos SET parent_id = NULL
+    WHERE id = $1 AND parent_id IN (SELECT id FROM todos WHERE deleted_at IS NOT NULL)
+    `, todoID)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, err := tx.ExecContext(ctx, `UPDATE todos SET deleted_at = NULL WHERE id = $1`, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// purgeTodo deletes todoID for good if it is in the trash.
+func purgeTodo(ctx context.Context, db *sql.DB, userID, todoID int64) error {
+	query := `DELETE FROM todos WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`
+	return execAffectingOne(ctx, db, query, todoID, userID)
+}
+
+// emptyTrash deletes every todo in the user's trash for good and returns
+// how many there were.
+func emptyTrash(ctx context.Context, db *sql.DB, userID int64) (int, error) {
+	return execCount(ctx, db, `DELETE FROM todos WHERE user_id = $1 AND deleted_at IS NOT NULL`, userID)
+}
+
+// purgeDeleted deletes every todo moved to the trash before before for
+// good and returns how many there were.
+func purgeDeleted(ctx context.Context, db *sql.DB, before time.Time) (int, error) {
+	return execCount(ctx, db, `DELETE FROM todos WHERE deleted_at < $1`, before.UTC())
+}
+
+func execCount(ctx context.Context, db *sql.DB, query string, args ...any) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// TokenPurpose is what a UserToken can be used for.
+type TokenPurpose string
+
+const (
+	PurposeVerifyEmail   TokenPurpose = "verify_email"
+	PurposeResetPassword TokenPurpose = "reset_password"
+)
+
+// UserToken is a one-time token mailed to a user. Creating a token replaces
+// any earlier one for the same user and purpose, so only the newest link
+// works.
+type UserToken struct {
+	ID        int64
+	UserID    int64
+	Purpose   TokenPurpose
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	// Token is only known right after Create; the store keeps a hash of it.
+	Token string
+}
+
+// UserTokensStore keeps one-time user tokens. Its queries are portable, so
+// the SQLite storage uses it too.
+type UserTokensStore struct {
+	db *sql.DB
+}
+
+// Create stores token with a new random secret and sets token.Token.
+func (s *UserTokensStore) Create(ctx context.Context, token *UserToken) error {
+	secret, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM user_tokens WHERE user_id = $1 AND purpose = $2`
+	if _, err := tx.ExecContext(ctx, query, token.UserID, token.Purpose); err != nil {
+		return err
+	}
+
+	token.ExpiresAt = token.ExpiresAt.UTC()
+	token.CreatedAt = now()
+	query = `
+    INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at, created_at)
+    VALUES ($1, $2, $3, $4, $5) RETURNING id
+  `
+	err = tx.QueryRowContext(ctx, query,
+		token.UserID, token.Purpose, hash, token.ExpiresAt, token.CreatedAt,
+	).Scan(&token.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	token.Token = secret
+	return nil
+}
+
+// Consume uses up a token and returns the user it was issued to. Unknown
+// and expired tokens and tokens issued for another purpose are ErrNotFound.
+func (s *UserTokensStore) Consume(ctx context.Context, purpose TokenPurpose, secret string) (int64, error) {
+	query := `
+    DELETE FROM user_tokens
+    WHERE token_hash = $1 AND purpose = $2 AND expires_at > $3
+    RETURNING user_id
+  `
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var userID int64
+	err := s.db.QueryRowContext(ctx, query, hashToken(secret), purpose, now()).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	return userID, err
+}
+
+// DeleteAll deletes every outstanding token of the user, whatever its
+// purpose.
+func (s *UserTokensStore) DeleteAll(ctx context.Context, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `DELETE FROM user_tokens WHERE user_id = $1`
+	_, err := s.db.ExecContext(ctx, query, userID)
+	return err
+}
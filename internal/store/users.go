@@ -5,8 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-
-	"golang.org/x/crypto/bcrypt"
+	"time"
 )
 
 var (
@@ -14,6 +13,20 @@ var (
 	ErrDuplicateUsername = errors.New("a user with that username already exists")
 )
 
+const (
+	DefaultTimezone = "UTC"
+	DefaultLocale   = "en"
+)
+
+// UserRole is what a user may do across the whole service, as opposed to the
+// Role they have in a project.
+type UserRole string
+
+const (
+	UserRoleUser  UserRole = "user"
+	UserRoleAdmin UserRole = "admin"
+)
+
 // model
 type User struct {
 	ID        int64    `json:"id"`
@@ -21,23 +34,53 @@ type User struct {
 	Email     string   `json:"email"`
 	Password  password `json:"-"`
 	CreatedAt string   `json:"createdAt"`
+	// EmailVerifiedAt is nil until the user follows the link mailed to them.
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt"`
+	// FailedLogins counts failed logins since the last successful one;
+	// LockedUntil is when a locked account can log in again.
+	FailedLogins int        `json:"-"`
+	LockedUntil  *time.Time `json:"-"`
+	// Timezone is an IANA zone name and Locale a BCP 47 language tag.
+	Timezone string `json:"timezone"`
+	Locale   string `json:"locale"`
+	// DeletedAt is set once the account has been anonymized.
+	DeletedAt *time.Time `json:"-"`
+	Role      UserRole   `json:"role"`
+	// SuspendedAt is set while an admin has suspended the account.
+	SuspendedAt *time.Time `json:"suspendedAt,omitempty"`
 }
 
-type password struct {
-	text *string
-	Hash []byte
+const userColumns = `id, username, email, password, created_at, email_verified_at, failed_logins, locked_until, timezone, locale, deleted_at, role, suspended_at`
+
+func userScanDest(u *User) []any {
+	return []any{
+		&u.ID, &u.Username, &u.Email, &u.Password.Hash, &u.CreatedAt, &u.EmailVerifiedAt, &u.FailedLogins,
+		&u.LockedUntil, &u.Timezone, &u.Locale, &u.DeletedAt, &u.Role, &u.SuspendedAt,
+	}
 }
 
-func (p *password) SetPassword(text string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(text), bcrypt.DefaultCost)
-	if err != nil {
-		return err
+// setDefaults fills in the preferences of a new user that chose none.
+func (u *User) setDefaults() {
+	if u.Timezone == "" {
+		u.Timezone = DefaultTimezone
+	}
+	if u.Locale == "" {
+		u.Locale = DefaultLocale
+	}
+	if u.Role == "" {
+		u.Role = UserRoleUser
 	}
+}
 
-	p.text = &text
-	p.Hash = hash
+// Locked reports whether the user may not log in at t.
+func (u *User) Locked(t time.Time) bool {
+	return u.LockedUntil != nil && u.LockedUntil.After(t)
+}
 
-	return nil
+// password holds the hash of a user's password, made by the password
+// package.
+type password struct {
+	Hash []byte
 }
 
 type UserStore struct {
@@ -45,42 +88,41 @@ type UserStore struct {
 }
 
 func (s *UserStore) Create(ctx context.Context, user *User) error {
+	user.setDefaults()
 	query := `
-    INSERT INTO users (username, password, email) VALUES($1, $2, $3) RETURNING id, created_at
+    INSERT INTO users (username, password, email, timezone, locale, role) VALUES($1, $2, $3, $4, $5, $6) RETURNING id, created_at
   `
-	err := s.db.QueryRowContext(ctx, query, user.Username, user.Password.Hash, user.Email).Scan(&user.ID, &user.CreatedAt)
+	err := s.db.QueryRowContext(ctx, query, user.Username, user.Password.Hash, user.Email, user.Timezone, user.Locale, user.Role).Scan(&user.ID, &user.CreatedAt)
+	return duplicateUserError(err)
+}
 
-	if err != nil {
-		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
-			return ErrDuplicateEmail
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_username_key"`:
-			return ErrDuplicateUsername
-		default:
-			return err
-		}
+// duplicateUserError turns the unique constraint failures of users into
+// ErrDuplicateEmail and ErrDuplicateUsername.
+func duplicateUserError(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch {
+	case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+		return ErrDuplicateEmail
+	case err.Error() == `pq: duplicate key value violates unique constraint "users_username_key"`:
+		return ErrDuplicateUsername
+	default:
+		return err
 	}
-	return nil
 }
 
 func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
-		SELECT id, username, email, password, created_at FROM users
+		SELECT ` + userColumns + `
+		FROM users
 		WHERE email = $1
 	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
 	user := &User{}
-	var hashedPassword []byte
-	err := s.db.QueryRowContext(ctx, query, email).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&hashedPassword,
-		&user.CreatedAt,
-	)
-	fmt.Println("email retrieval error: ", err)
+	err := s.db.QueryRowContext(ctx, query, email).Scan(userScanDest(user)...)
 	if err != nil {
 		switch err {
 		case sql.ErrNoRows:
@@ -89,14 +131,12 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 			return nil, err
 		}
 	}
-	// Assign the hashed password to the user's Password struct
-	user.Password = password{Hash: hashedPassword}
 	return user, nil
 }
 
 func (s *UserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 	query := `
-		SELECT users.id, username, email, password, created_at
+		SELECT ` + userColumns + `
 		FROM users
 		WHERE users.id = $1
 	`
@@ -105,17 +145,7 @@ func (s *UserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 	defer cancel()
 
 	user := &User{}
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		userID,
-	).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.Password.Hash,
-		&user.CreatedAt,
-	)
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(userScanDest(user)...)
 	if err != nil {
 		switch err {
 		case sql.ErrNoRows:
@@ -127,3 +157,202 @@ func (s *UserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 
 	return user, nil
 }
+
+// SetEmailVerified marks the user's email address as verified.
+func (s *UserStore) SetEmailVerified(ctx context.Context, userID int64) error {
+	query := `UPDATE users SET email_verified_at = coalesce(email_verified_at, $1) WHERE id = $2`
+	return execAffectingOne(ctx, s.db, query, now(), userID)
+}
+
+// UpdatePassword stores user.Password.Hash as the user's password.
+func (s *UserStore) UpdatePassword(ctx context.Context, user *User) error {
+	query := `UPDATE users SET password = $1 WHERE id = $2`
+	return execAffectingOne(ctx, s.db, query, user.Password.Hash, user.ID)
+}
+
+// RecordFailedLogin counts a failed login and returns how many there have
+// been since the last successful one.
+func (s *UserStore) RecordFailedLogin(ctx context.Context, userID int64) (int, error) {
+	return recordFailedLogin(ctx, s.db, userID)
+}
+
+// LockLogin stops the user from logging in until until.
+func (s *UserStore) LockLogin(ctx context.Context, userID int64, until time.Time) error {
+	query := `UPDATE users SET locked_until = $1 WHERE id = $2`
+	return execAffectingOne(ctx, s.db, query, until.UTC(), userID)
+}
+
+// ResetFailedLogins clears the user's failed logins and any lock.
+func (s *UserStore) ResetFailedLogins(ctx context.Context, userID int64) error {
+	query := `UPDATE users SET failed_logins = 0, locked_until = NULL WHERE id = $1`
+	return execAffectingOne(ctx, s.db, query, userID)
+}
+
+// UpdateProfile stores the user's username, email address, its verification
+// and preferences.
+func (s *UserStore) UpdateProfile(ctx context.Context, user *User) error {
+	return duplicateUserError(updateProfile(ctx, s.db, user))
+}
+
+// Delete deletes the user and, through the foreign keys, everything of
+// theirs.
+func (s *UserStore) Delete(ctx context.Context, userID int64) error {
+	return execAffectingOne(ctx, s.db, `DELETE FROM users WHERE id = $1`, userID)
+}
+
+// Anonymize deletes the user's account but keeps their todos and projects,
+// so work shared with others survives. See anonymizeUser.
+func (s *UserStore) Anonymize(ctx context.Context, userID int64) error {
+	return anonymizeUser(ctx, s.db, userID)
+}
+
+// Erase deletes the user and every row tied to them. See eraseUser.
+func (s *UserStore) Erase(ctx context.Context, userID int64) error {
+	return eraseUser(ctx, s.db, userID)
+}
+
+// List pages through the users matching f, oldest first.
+func (s *UserStore) List(ctx context.Context, f UserFilter) (*UserPage, error) {
+	return listUsers(ctx, s.db, f)
+}
+
+// Usage counts what the user has stored.
+func (s *UserStore) Usage(ctx context.Context, userID int64) (*UserUsage, error) {
+	return userUsage(ctx, s.db, userID)
+}
+
+// SetRole makes the user an admin or takes it away.
+func (s *UserStore) SetRole(ctx context.Context, userID int64, role UserRole) error {
+	return setUserRole(ctx, s.db, userID, role)
+}
+
+// SetSuspended suspends the user, ending their sessions, or lifts their
+// suspension.
+func (s *UserStore) SetSuspended(ctx context.Context, userID int64, suspended bool) error {
+	return setUserSuspended(ctx, s.db, userID, suspended)
+}
+
+func updateProfile(ctx context.Context, db *sql.DB, user *User) error {
+	query := `
+    UPDATE users SET username = $1, email = $2, email_verified_at = $3, timezone = $4, locale = $5
+    WHERE id = $6 AND deleted_at IS NULL
+  `
+	return execAffectingOne(ctx, db, query,
+		user.Username, user.Email, copyTime(user.EmailVerifiedAt), user.Timezone, user.Locale, user.ID)
+}
+
+// anonymizeUser strips the user's row of personal data, so no one can log in
+// to it any more, and deletes the rest of the account: sessions, tokens,
+// second factors, linked identities, reminders and memberships of other
+// people's projects.
+func anonymizeUser(ctx context.Context, db *sql.DB, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	deletedAt := now()
+	query := `
+    UPDATE users
+    SET username = $1, email = $2, password = '', email_verified_at = NULL, failed_logins = 0,
+        locked_until = NULL, timezone = $3, locale = $4, role = $5, deleted_at = $6
+    WHERE id = $7 AND deleted_at IS NULL
+  `
+	res, err := tx.ExecContext(ctx, query,
+		fmt.Sprintf("deleted-%d", userID), fmt.Sprintf("deleted-%d@deleted.invalid", userID),
+		DefaultTimezone, DefaultLocale, UserRoleUser, deletedAt, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+
+	for _, table := range []string{
+		"sessions", "personal_access_tokens", "user_tokens", "totp_credentials", "recovery_codes",
+		"user_identities", "oidc_flows", "reminders", "project_members",
+	} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM project_invitations WHERE invited_by = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// eraseUser deletes the user and, table by table, every row tied to them,
+// rather than trusting the foreign keys to reach everything. Todos others
+// keep in the user's projects move to their inboxes, as when a project is
+// deleted. The audit log and todo history forget the user: entries about
+// them are deleted and those they made lose their actor.
+func eraseUser(ctx context.Context, db *sql.DB, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`DELETE FROM reminders WHERE user_id = $1 OR todo_id IN (SELECT id FROM todos WHERE user_id = $1)`,
+		`UPDATE todos SET project_id = NULL WHERE user_id <> $1 AND project_id IN (SELECT id FROM projects WHERE user_id = $1)`,
+		`DELETE FROM todos WHERE user_id = $1`,
+		`DELETE FROM todo_history WHERE user_id = $1`,
+		`UPDATE todo_history SET actor_id = NULL WHERE actor_id = $1`,
+		`DELETE FROM project_invitations WHERE invited_by = $1 OR project_id IN (SELECT id FROM projects WHERE user_id = $1)`,
+		`DELETE FROM project_members WHERE user_id = $1 OR project_id IN (SELECT id FROM projects WHERE user_id = $1)`,
+		`DELETE FROM projects WHERE user_id = $1`,
+		`DELETE FROM refresh_tokens WHERE session_id IN (SELECT id FROM sessions WHERE user_id = $1)`,
+		`DELETE FROM sessions WHERE user_id = $1`,
+		`DELETE FROM personal_access_tokens WHERE user_id = $1`,
+		`DELETE FROM user_tokens WHERE user_id = $1`,
+		`DELETE FROM recovery_codes WHERE user_id = $1`,
+		`DELETE FROM totp_credentials WHERE user_id = $1`,
+		`DELETE FROM user_identities WHERE user_id = $1`,
+		`DELETE FROM oidc_flows WHERE user_id = $1`,
+		`DELETE FROM data_requests WHERE user_id = $1`,
+		`DELETE FROM admin_audit_log WHERE target_user_id = $1`,
+		`UPDATE admin_audit_log SET actor_id = NULL WHERE actor_id = $1`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+
+	return tx.Commit()
+}
+
+func recordFailedLogin(ctx context.Context, db *sql.DB, userID int64) (int, error) {
+	query := `UPDATE users SET failed_logins = failed_logins + 1 WHERE id = $1 RETURNING failed_logins`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var n int
+	err := db.QueryRowContext(ctx, query, userID).Scan(&n)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	return n, err
+}
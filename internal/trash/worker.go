@@ -0,0 +1,60 @@
+// Package trash empties the trash of todos that have been in it longer than
+// the retention period.
+package trash
+
+import (
+	"context"
+	"time"
+
+	"open-todo-go/internal/store"
+
+	"go.uber.org/zap"
+)
+
+// Worker purges todos deleted more than retention ago, for every user.
+type Worker struct {
+	store     store.Storage
+	logger    *zap.SugaredLogger
+	interval  time.Duration
+	retention time.Duration
+}
+
+func NewWorker(s store.Storage, logger *zap.SugaredLogger, interval, retention time.Duration) *Worker {
+	return &Worker{
+		store:     s,
+		logger:    logger,
+		interval:  interval,
+		retention: retention,
+	}
+}
+
+// Run purges the trash every interval until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.Tick(ctx, time.Now()); err != nil {
+			w.logger.Errorw("trash worker", "error", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick purges the todos deleted more than retention before now and returns
+// how many there were.
+func (w *Worker) Tick(ctx context.Context, now time.Time) (int, error) {
+	n, err := w.store.Todos.PurgeDeleted(ctx, now.Add(-w.retention))
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		w.logger.Infow("purged todos from the trash", "count", n)
+	}
+	return n, nil
+}